@@ -1,42 +1,254 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 
 	"github.com/Anand078/rbac/internal/config"
 	"github.com/Anand078/rbac/internal/database"
 	"github.com/Anand078/rbac/internal/handlers"
+	"github.com/Anand078/rbac/internal/integrations"
+	"github.com/Anand078/rbac/internal/mail"
 	"github.com/Anand078/rbac/internal/middleware"
+	"github.com/Anand078/rbac/internal/migrations"
+	"github.com/Anand078/rbac/internal/oidc"
 	"github.com/Anand078/rbac/internal/services"
+	"github.com/Anand078/rbac/internal/signing"
+	"github.com/Anand078/rbac/internal/storage"
+	"github.com/Anand078/rbac/internal/storage/memory"
+	"github.com/Anand078/rbac/internal/storage/postgres"
+	"github.com/Anand078/rbac/internal/storage/sqlite"
+	"github.com/Anand078/rbac/internal/webauthn"
 )
 
 func main() {
 	// Load configuration
 	cfg := config.Load()
 
-	// Initialize database
-	db, err := database.NewConnection(cfg.DatabaseURL, cfg.SupabaseURL, cfg.SupabaseServiceKey)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(cfg, os.Args[2:])
+		return
+	}
+
+	// Initialize storage
+	var (
+		db    *database.DB
+		store storage.Store
+	)
+	switch cfg.DatabaseDriver {
+	case config.DatabaseDriverPostgres:
+		var err error
+		db, err = database.NewConnection(cfg.DatabaseURL)
+		if err != nil {
+			errStr := err.Error()
+			fmt.Println(errStr)
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
+		if cfg.MigrateOnStartup {
+			// Replicas starting together wait on an advisory lock, so only
+			// one of them applies each migration.
+			migrator, err := migrations.New(db.DB, migrations.DialectPostgres)
+			if err != nil {
+				log.Fatalf("Failed to load migrations: %v", err)
+			}
+			applied, err := migrator.Up(context.Background())
+			for _, m := range applied {
+				log.Printf("Applied migration %d_%s", m.Version, m.Name)
+			}
+			if err != nil {
+				log.Fatalf("Failed to apply migrations: %v", err)
+			}
+		}
+		store = postgres.New(db)
+	case config.DatabaseDriverSQLite:
+		sqliteStore, err := sqlite.Open(cfg.DatabasePath)
+		if err != nil {
+			log.Fatalf("Failed to open SQLite database: %v", err)
+		}
+		log.Printf("Using SQLite database %s", cfg.DatabasePath)
+		store = sqliteStore
+	default:
+		log.Println("DATABASE_URL not set; using in-memory storage, nothing is kept across restarts")
+		memoryStore := memory.New()
+		if err := memoryStore.SeedDefaults(); err != nil {
+			log.Fatalf("Failed to seed in-memory storage: %v", err)
+		}
+		store = memoryStore
+	}
+	defer store.Close()
+
+	// Optional integrations
+	integs, err := integrations.New(cfg)
 	if err != nil {
-		errStr := err.Error()
-		fmt.Println(errStr)
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Failed to initialize integrations: %v", err)
+	}
+	if enabled := integs.Enabled(); len(enabled) > 0 {
+		log.Printf("Integrations enabled: %s", strings.Join(enabled, ", "))
+	}
+
+	// Initialize signing keys
+	var keyManager *signing.KeyManager
+	if cfg.JWTSigningAlg == signing.AlgHS256 {
+		keyManager = signing.NewHMACKeyManager(cfg.JWTSecret)
+	} else {
+		keys, err := cfg.LoadSigningKeys()
+		if err != nil {
+			log.Fatalf("Failed to load signing keys: %v", err)
+		}
+		keyManager, err = signing.NewKeyManager(cfg.JWTSigningAlg, keys, cfg.JWTKeyGracePeriod)
+		if err != nil {
+			log.Fatalf("Failed to initialize signing keys: %v", err)
+		}
 	}
-	defer db.Close()
 
 	// Initialize services
-	authService := services.NewAuthService(db, cfg.JWTSecret)
-	rbacService := services.NewRBACService(db)
+	authService := services.NewAuthService(store, keyManager, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+	rbacService := services.NewRBACService(store)
+	if cfg.PermissionCacheSize > 0 {
+		rbacService.EnablePermissionCache(cfg.PermissionCacheTTL, cfg.PermissionCacheSize)
+	}
+	aclService := services.NewACLService(store, rbacService)
+	if cfg.AuthzMode == config.AuthzModeClaims {
+		authService.EmbedAuthorizationClaims(rbacService)
+	}
+	authService.EnableGroupSync(rbacService)
+	mailer, err := newMailer(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+	authService.EnableEmailFlows(mailer, services.EmailSettings{
+		VerificationURL:     cfg.EmailVerificationURL,
+		ResetURL:            cfg.PasswordResetURL,
+		VerificationTTL:     cfg.EmailVerificationTTL,
+		ResetTTL:            cfg.PasswordResetTTL,
+		RequireVerification: cfg.RequireEmailVerification,
+	})
+
+	rbacService.Events().Subscribe(func(event services.Event) {
+		if event.Type == services.EventAssignmentExpired {
+			log.Printf("Role %s of user %s expired (tenant %s)", event.RoleID, event.UserID, event.TenantID)
+		}
+	})
+
+	// Background jobs
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go services.NewSweeper("expired role assignment", cfg.AssignmentSweepInterval, rbacService.SweepExpiredAssignments).Run(ctx)
+	go services.NewSweeper("expired token", cfg.TokenPurgeInterval, authService.PurgeExpiredTokens).Run(ctx)
+	if cfg.ChangeNotifications && db != nil {
+		// Keeps the permission cache and policy version of every replica in
+		// sync with changes made elsewhere.
+		notifier := services.NewChangeNotifier(db, cfg.DatabaseURL, rbacService)
+		go notifier.Run(ctx)
+	}
+	if cfg.JWTSigningAlg != signing.AlgHS256 && cfg.JWTKeyRotationInterval > 0 {
+		// With a key path, rotation re-reads the files so every replica
+		// picks up the same keys; otherwise a new key is generated in memory.
+		var reload func() ([]*signing.Key, error)
+		if cfg.JWTKeyPath != "" {
+			reload = cfg.LoadSigningKeys
+		}
+		go keyManager.RunRotation(ctx, cfg.JWTKeyRotationInterval, reload)
+	}
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService)
 	roleHandler := handlers.NewRoleHandler(rbacService)
 	permissionHandler := handlers.NewPermissionHandler(rbacService)
+	aclHandler := handlers.NewACLHandler(aclService)
+	groupMappingHandler := handlers.NewGroupMappingHandler(rbacService)
+	serviceAccountHandler := handlers.NewServiceAccountHandler(authService)
+	oauthHandler := handlers.NewOAuthHandler(authService)
+	mfaHandler := handlers.NewMFAHandler(authService)
+	userHandler := handlers.NewUserHandler(rbacService)
+	jwksHandler := handlers.NewJWKSHandler(keyManager)
 
 	// Initialize middleware
-	authMiddleware := middleware.NewAuthMiddleware(cfg.JWTSecret, rbacService)
+	authMiddleware := middleware.NewAuthMiddleware(keyManager, authService, rbacService, aclService)
+	if cfg.AuthzMode == config.AuthzModeClaims {
+		authMiddleware.EnableClaimsMode()
+	}
+	if integs.SupabaseAuth != nil {
+		if cfg.SupabaseDefaultRole != "" {
+			roleID, err := findRole(rbacService, cfg.SupabaseDefaultRole)
+			if err != nil {
+				log.Fatalf("Invalid SUPABASE_DEFAULT_ROLE: %v", err)
+			}
+			authService.SetFederatedDefaultRole(roleID)
+		}
+		authMiddleware.AddIdentityProvider(integs.SupabaseAuth)
+	}
+	for _, name := range cfg.MFARequiredRoles {
+		if _, err := findRole(rbacService, name); err != nil {
+			log.Fatalf("Invalid MFA_REQUIRED_ROLES: %v", err)
+		}
+	}
+	authService.ConfigureMFA(services.MFASettings{Issuer: cfg.MFAIssuer, RequiredRoles: cfg.MFARequiredRoles})
+	if cfg.WebAuthnRPID != "" {
+		authService.EnablePasskeys(services.PasskeySettings{
+			RelyingParty: webauthn.RelyingParty{ID: cfg.WebAuthnRPID, Name: cfg.WebAuthnRPName, Origins: cfg.WebAuthnOrigins},
+			Passwordless: cfg.WebAuthnPasswordless,
+		})
+	}
+
+	// OpenID Connect login and externally issued tokens
+	var oidcHandler *handlers.OIDCHandler
+	oidcClient := &http.Client{Timeout: 10 * time.Second}
+	oidcIssuers := map[string]*oidc.Issuer{}
+	oidcIssuer := func(url string) *oidc.Issuer {
+		if _, ok := oidcIssuers[url]; !ok {
+			oidcIssuers[url] = oidc.NewIssuer(url, oidcClient)
+		}
+		return oidcIssuers[url]
+	}
+	if cfg.OIDCIssuer != "" {
+		relyingParty := oidc.NewRelyingParty(cfg.OIDCProviderName, oidcIssuer(cfg.OIDCIssuer),
+			cfg.OIDCClientID, cfg.OIDCClientSecret, cfg.OIDCRedirectURL, cfg.OIDCScopes, cfg.OIDCGroupsClaim)
+		oidcHandler = handlers.NewOIDCHandler(relyingParty, authService, "/api/auth/oidc")
+	}
+	for name, url := range cfg.OIDCTrustedIssuers {
+		authMiddleware.AddIdentityProvider(oidc.NewProvider(name, oidcIssuer(url), cfg.OIDCAudience, cfg.OIDCGroupsClaim))
+	}
+
+	// Per-provider federation settings
+	federatedProviders := map[string]bool{}
+	if integs.SupabaseAuth != nil {
+		federatedProviders[integrations.SupabaseAuthProvider] = true
+	}
+	if cfg.OIDCIssuer != "" {
+		federatedProviders[cfg.OIDCProviderName] = true
+	}
+	for name := range cfg.OIDCTrustedIssuers {
+		federatedProviders[name] = true
+	}
+	federation := map[string]services.FederationSettings{}
+	for _, name := range cfg.FederationLinkByEmail {
+		if !federatedProviders[name] {
+			log.Fatalf("Invalid FEDERATION_LINK_BY_EMAIL: no identity provider named %q", name)
+		}
+		settings := federation[name]
+		settings.LinkByEmail = true
+		federation[name] = settings
+	}
+	for _, name := range cfg.FederationEnforcesMFA {
+		if !federatedProviders[name] {
+			log.Fatalf("Invalid FEDERATION_ENFORCES_MFA: no identity provider named %q", name)
+		}
+		settings := federation[name]
+		settings.EnforcesMFA = true
+		federation[name] = settings
+	}
+	for name, settings := range federation {
+		authService.ConfigureFederation(name, settings)
+	}
 
 	// Setup router
 	router := gin.Default()
@@ -47,25 +259,100 @@ func main() {
 		// Authentication endpoints
 		api.POST("/auth/register", authHandler.Register)
 		api.POST("/auth/login", authHandler.Login)
+		api.POST("/auth/refresh", authHandler.Refresh)
+
+		// Email verification and password reset
+		api.POST("/auth/verify-email/request", authHandler.RequestEmailVerification)
+		api.POST("/auth/verify-email/confirm", authHandler.VerifyEmail)
+		api.POST("/auth/password-reset/request", authHandler.RequestPasswordReset)
+		api.POST("/auth/password-reset/confirm", authHandler.ResetPassword)
+
+		// Second factor of password logins
+		api.POST("/auth/mfa/verify", mfaHandler.Verify)
+		api.POST("/auth/mfa/enroll", mfaHandler.EnrollWithChallenge)
+		api.POST("/auth/mfa/enroll/confirm", mfaHandler.ConfirmWithChallenge)
+		api.POST("/auth/mfa/passkey/begin", authHandler.BeginPasskeyMFA)
+		api.POST("/auth/mfa/passkey/finish", authHandler.FinishPasskeyMFA)
+
+		// Passwordless login with passkeys
+		api.POST("/auth/passkeys/login/begin", authHandler.BeginPasskeyLogin)
+		api.POST("/auth/passkeys/login/finish", authHandler.FinishPasskeyLogin)
+
+		// OpenID Connect login
+		if oidcHandler != nil {
+			api.GET("/auth/oidc/login", oidcHandler.Login)
+			api.GET("/auth/oidc/callback", oidcHandler.Callback)
+		}
 	}
 
 	// Protected routes
 	protected := api.Group("/")
 	protected.Use(authMiddleware.Authenticate())
 	{
+		// Session management
+		protected.POST("/auth/logout", authHandler.Logout)
+		protected.POST("/auth/logout-all", authHandler.LogoutAll)
+
+		// Multi-factor authentication
+		protected.GET("/auth/mfa", mfaHandler.GetStatus)
+		protected.POST("/auth/mfa/totp", mfaHandler.EnrollTOTP)
+		protected.POST("/auth/mfa/totp/confirm", mfaHandler.ConfirmTOTP)
+		protected.DELETE("/auth/mfa/totp", mfaHandler.DisableTOTP)
+		protected.POST("/auth/mfa/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+
+		// Passkeys
+		protected.GET("/auth/passkeys", authHandler.GetPasskeys)
+		protected.POST("/auth/passkeys/register/begin", authHandler.BeginPasskeyRegistration)
+		protected.POST("/auth/passkeys/register/finish", authHandler.FinishPasskeyRegistration)
+		protected.DELETE("/auth/passkeys/:credentialID", authHandler.DeletePasskey)
+
 		// Role management
 		protected.POST("/roles/create", authMiddleware.RequireRole("admin"), roleHandler.CreateRole)
 		protected.GET("/roles", roleHandler.GetAllRoles)
 		protected.GET("/users/:userID/roles", roleHandler.GetUserRoles)
+		protected.GET("/users/:userID/assignments", authMiddleware.RequireRole("admin"), roleHandler.GetUserAssignments)
 		protected.POST("/users/assign-role", authMiddleware.RequireRole("admin"), roleHandler.AssignRole)
 		protected.DELETE("/users/:userID/roles/:roleID", authMiddleware.RequireRole("admin"), roleHandler.RemoveRole)
 
+		// User attributes read by conditional grants
+		protected.GET("/users/:userID/attributes", authMiddleware.RequireRole("admin"), userHandler.GetAttributes)
+		protected.PUT("/users/:userID/attributes", authMiddleware.RequireRole("admin"), userHandler.UpdateAttributes)
+		protected.DELETE("/users/:userID/mfa", authMiddleware.RequireRole("admin"), mfaHandler.ResetUserMFA)
+
+		// Role hierarchy
+		protected.GET("/roles/:roleID/parents", roleHandler.GetParentRoles)
+		protected.POST("/roles/:roleID/parents", authMiddleware.RequireRole("admin"), roleHandler.AddParentRole)
+		protected.DELETE("/roles/:roleID/parents/:parentID", authMiddleware.RequireRole("admin"), roleHandler.RemoveParentRole)
+
 		// Permission management
 		protected.POST("/permissions/create", authMiddleware.RequireRole("admin"), permissionHandler.CreatePermission)
 		protected.GET("/permissions", permissionHandler.GetAllPermissions)
 		protected.GET("/roles/:roleID/permissions", permissionHandler.GetRolePermissions)
 		protected.POST("/permissions/grant", authMiddleware.RequireRole("admin"), permissionHandler.GrantPermission)
 		protected.DELETE("/roles/:roleID/permissions/:permissionID", authMiddleware.RequireRole("admin"), permissionHandler.RevokePermission)
+		protected.GET("/permissions/cache/stats", authMiddleware.RequireRole("admin"), permissionHandler.GetCacheStats)
+
+		// Instance-level access control entries
+		protected.POST("/acl", authMiddleware.RequireRole("admin"), aclHandler.CreateEntry)
+		protected.GET("/acl", authMiddleware.RequireRole("admin"), aclHandler.GetEntries)
+		protected.DELETE("/acl/:entryID", authMiddleware.RequireRole("admin"), aclHandler.DeleteEntry)
+
+		// Identity provider groups mapped to roles
+		protected.POST("/group-mappings", authMiddleware.RequireRole("admin"), groupMappingHandler.CreateMapping)
+		protected.GET("/group-mappings", authMiddleware.RequireRole("admin"), groupMappingHandler.GetMappings)
+		protected.DELETE("/group-mappings/:mappingID", authMiddleware.RequireRole("admin"), groupMappingHandler.DeleteMapping)
+
+		// Service accounts and their API keys
+		protected.POST("/service-accounts", authMiddleware.RequireRole("admin"), serviceAccountHandler.CreateServiceAccount)
+		protected.GET("/service-accounts", authMiddleware.RequireRole("admin"), serviceAccountHandler.GetServiceAccounts)
+		protected.GET("/service-accounts/:accountID", authMiddleware.RequireRole("admin"), serviceAccountHandler.GetServiceAccount)
+		protected.DELETE("/service-accounts/:accountID", authMiddleware.RequireRole("admin"), serviceAccountHandler.DeleteServiceAccount)
+		protected.POST("/service-accounts/:accountID/keys", authMiddleware.RequireRole("admin"), serviceAccountHandler.CreateAPIKey)
+		protected.GET("/service-accounts/:accountID/keys", authMiddleware.RequireRole("admin"), serviceAccountHandler.GetAPIKeys)
+		protected.DELETE("/service-accounts/:accountID/keys/:keyID", authMiddleware.RequireRole("admin"), serviceAccountHandler.DeleteAPIKey)
+		protected.POST("/service-accounts/:accountID/clients", authMiddleware.RequireRole("admin"), serviceAccountHandler.CreateOAuthClient)
+		protected.GET("/service-accounts/:accountID/clients", authMiddleware.RequireRole("admin"), serviceAccountHandler.GetOAuthClients)
+		protected.DELETE("/service-accounts/:accountID/clients/:clientID", authMiddleware.RequireRole("admin"), serviceAccountHandler.DeleteOAuthClient)
 
 		// Example protected endpoints with specific permissions
 		protected.GET("/courses", authMiddleware.Authorize("course", "read"), func(c *gin.Context) {
@@ -74,11 +361,26 @@ func main() {
 		protected.POST("/courses", authMiddleware.Authorize("course", "create"), func(c *gin.Context) {
 			c.JSON(200, gin.H{"message": "Course created"})
 		})
+		protected.PUT("/courses/:courseID", authMiddleware.AuthorizeInstance("course", "update", "courseID"), func(c *gin.Context) {
+			c.JSON(200, gin.H{"message": "Course updated", "course_id": c.Param("courseID")})
+		})
 		protected.GET("/grades", authMiddleware.Authorize("grades", "read"), func(c *gin.Context) {
 			c.JSON(200, gin.H{"message": "Grades list"})
 		})
+
+		// Tenant-scoped example: the tenant comes from the path
+		protected.GET("/tenants/:tenantID/courses", authMiddleware.Authorize("course", "read"), func(c *gin.Context) {
+			c.JSON(200, gin.H{"message": "Course list", "tenant_id": c.MustGet("tenant_id")})
+		})
 	}
 
+	// OAuth2 client credentials grant and token introspection
+	router.POST("/oauth/token", oauthHandler.Token)
+	router.POST("/oauth/introspect", oauthHandler.Introspect)
+
+	// Public signing keys for verifying issued tokens
+	router.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
+
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
@@ -90,3 +392,36 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// findRole returns the ID of the role called name.
+func findRole(rbacService *services.RBACService, name string) (uuid.UUID, error) {
+	roles, err := rbacService.GetAllRoles()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	for _, role := range roles {
+		if role.Name == name {
+			return role.ID, nil
+		}
+	}
+	return uuid.Nil, fmt.Errorf("role %q does not exist", name)
+}
+
+// newMailer returns the SMTP mailer when SMTP_ADDR is set, and otherwise one
+// that writes emails to MAIL_LOG_FILE or the log.
+func newMailer(cfg *config.Config) (mail.Mailer, error) {
+	if cfg.SMTPAddr != "" {
+		log.Printf("Sending emails through SMTP server %s", cfg.SMTPAddr)
+		return mail.NewSMTPMailer(cfg.SMTPAddr, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	}
+	if cfg.MailLogFile == "" {
+		log.Println("SMTP_ADDR is not set; emails are written to the log")
+		return mail.NewLogMailer(log.Writer()), nil
+	}
+	file, err := os.OpenFile(cfg.MailLogFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("SMTP_ADDR is not set; emails are written to %s", cfg.MailLogFile)
+	return mail.NewLogMailer(file), nil
+}
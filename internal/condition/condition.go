@@ -0,0 +1,379 @@
+// Package condition implements the small expression language used to attach
+// attribute-based conditions to permission grants, for example:
+//
+//	request.time >= user.term_start && request.time < user.term_end
+//	resource.id in user.enrolled_courses
+//	in_cidr(request.ip, "10.0.0.0/8")
+//
+// Expressions are side-effect free: they can only read the attributes passed
+// to Eval, call a fixed set of pure functions and contain no loops, so their
+// cost is bounded by their (capped) size.
+package condition
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxSourceLength = 1024
+	maxDepth        = 32
+)
+
+// Roots are the top-level names an expression may refer to.
+var Roots = []string{"user", "resource", "request"}
+
+var ErrInvalidCondition = errors.New("invalid condition")
+
+// Expr is a compiled condition.
+type Expr struct {
+	source string
+	root   node
+}
+
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Compile parses and validates a condition. Unknown roots, unknown functions
+// and malformed syntax are reported as ErrInvalidCondition.
+func Compile(source string) (*Expr, error) {
+	if len(source) > maxSourceLength {
+		return nil, fmt.Errorf("%w: longer than %d characters", ErrInvalidCondition, maxSourceLength)
+	}
+
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCondition, err)
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr(0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCondition, err)
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("%w: unexpected %q at offset %d", ErrInvalidCondition, tok.text, tok.pos)
+	}
+
+	return &Expr{source: source, root: root}, nil
+}
+
+// Eval evaluates the condition against env, whose keys are the roots listed
+// in Roots. Attributes that are absent evaluate to null; null only equals
+// null, and ordering comparisons or "in" involving null are false.
+func (e *Expr) Eval(env map[string]any) (bool, error) {
+	value, err := e.root.eval(env)
+	if err != nil {
+		return false, err
+	}
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("condition evaluated to %s, not a boolean", typeName(value))
+	}
+	return result, nil
+}
+
+// Lexer
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(source); {
+		ch := source[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case isIdentStart(ch):
+			start := i
+			for i < len(source) && isIdentPart(source[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, source[start:i], start})
+		case ch >= '0' && ch <= '9':
+			start := i
+			for i < len(source) && (source[i] >= '0' && source[i] <= '9' || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, source[start:i], start})
+		case ch == '"' || ch == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			for {
+				if i >= len(source) {
+					return nil, fmt.Errorf("unterminated string at offset %d", start)
+				}
+				if source[i] == '\\' && i+1 < len(source) {
+					sb.WriteByte(source[i+1])
+					i += 2
+					continue
+				}
+				if source[i] == ch {
+					i++
+					break
+				}
+				sb.WriteByte(source[i])
+				i++
+			}
+			tokens = append(tokens, token{tokString, sb.String(), start})
+		default:
+			op := ""
+			for _, candidate := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ",", "."} {
+				if strings.HasPrefix(source[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", ch, i)
+			}
+			tokens = append(tokens, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{tokEOF, "end of expression", len(source)}), nil
+}
+
+func isIdentStart(ch byte) bool {
+	return ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
+}
+
+func isIdentPart(ch byte) bool {
+	return isIdentStart(ch) || ch >= '0' && ch <= '9'
+}
+
+// Parser
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) acceptOp(op string) bool {
+	if tok := p.peek(); tok.kind == tokOp && tok.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectOp(op string) error {
+	if !p.acceptOp(op) {
+		tok := p.peek()
+		return fmt.Errorf("expected %q at offset %d, got %q", op, tok.pos, tok.text)
+	}
+	return nil
+}
+
+func (p *parser) parseOr(depth int) (node, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("nested deeper than %d levels", maxDepth)
+	}
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOp("||") {
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd(depth int) (node, error) {
+	left, err := p.parseNot(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOp("&&") {
+		right, err := p.parseNot(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot(depth int) (node, error) {
+	if p.acceptOp("!") {
+		if depth+1 > maxDepth {
+			return nil, fmt.Errorf("nested deeper than %d levels", maxDepth)
+		}
+		operand, err := p.parseNot(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseComparison(depth)
+}
+
+func (p *parser) parseComparison(depth int) (node, error) {
+	left, err := p.parsePrimary(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	switch {
+	case tok.kind == tokOp && (tok.text == "==" || tok.text == "!=" || tok.text == "<" ||
+		tok.text == "<=" || tok.text == ">" || tok.text == ">="):
+	case tok.kind == tokIdent && tok.text == "in":
+	default:
+		return left, nil
+	}
+	p.next()
+
+	right, err := p.parsePrimary(depth)
+	if err != nil {
+		return nil, err
+	}
+	return &compareNode{op: tok.text, left: left, right: right}, nil
+}
+
+func (p *parser) parsePrimary(depth int) (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", tok.text, tok.pos)
+		}
+		return &literalNode{value: n}, nil
+	case tokString:
+		return &literalNode{value: tok.text}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+		if p.acceptOp("(") {
+			return p.parseCall(tok, depth)
+		}
+		return p.parsePath(tok)
+	case tokOp:
+		switch tok.text {
+		case "(":
+			inner, err := p.parseOr(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		case "[":
+			if depth+1 > maxDepth {
+				return nil, fmt.Errorf("nested deeper than %d levels", maxDepth)
+			}
+			var items []node
+			if !p.acceptOp("]") {
+				for {
+					item, err := p.parsePrimary(depth + 1)
+					if err != nil {
+						return nil, err
+					}
+					items = append(items, item)
+					if p.acceptOp("]") {
+						break
+					}
+					if err := p.expectOp(","); err != nil {
+						return nil, err
+					}
+				}
+			}
+			return &listNode{items: items}, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+}
+
+func (p *parser) parsePath(first token) (node, error) {
+	known := false
+	for _, root := range Roots {
+		if first.text == root {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return nil, fmt.Errorf("unknown name %q at offset %d; expected one of %s",
+			first.text, first.pos, strings.Join(Roots, ", "))
+	}
+
+	path := []string{first.text}
+	for p.acceptOp(".") {
+		tok := p.next()
+		if tok.kind != tokIdent {
+			return nil, fmt.Errorf("expected attribute name at offset %d", tok.pos)
+		}
+		path = append(path, tok.text)
+	}
+	return &pathNode{path: path}, nil
+}
+
+func (p *parser) parseCall(name token, depth int) (node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at offset %d", name.text, name.pos)
+	}
+
+	var args []node
+	if !p.acceptOp(")") {
+		for {
+			arg, err := p.parseOr(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.acceptOp(")") {
+				break
+			}
+			if err := p.expectOp(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(args) != fn.arity {
+		return nil, fmt.Errorf("%s expects %d argument(s), got %d", name.text, fn.arity, len(args))
+	}
+	return &callNode{name: name.text, fn: fn, args: args}, nil
+}
@@ -0,0 +1,208 @@
+package condition
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEnv() map[string]any {
+	return map[string]any{
+		"user": map[string]any{
+			"id":         "u1",
+			"name":       "Ada",
+			"department": "math",
+			"level":      float64(3),
+			"courses":    []any{"c1", "c2"},
+			"term_start": "2024-09-01T00:00:00Z",
+			"term_end":   "2025-01-31T00:00:00Z",
+			"active":     true,
+		},
+		"resource": map[string]any{
+			"type": "course",
+			"id":   "c2",
+		},
+		"request": map[string]any{
+			"time":   time.Date(2024, 10, 15, 14, 30, 0, 0, time.UTC),
+			"ip":     "10.1.2.3",
+			"action": "read",
+		},
+	}
+}
+
+func TestCompileRejectsInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"account.id == 1",
+		"user.id == ",
+		"== user.id",
+		"user.id = 1",
+		"user.id == 'open",
+		"user.id == 1 )",
+		"(user.id == 1",
+		"user.id == 1 user.level",
+		"user.",
+		"user.1",
+		"exec(user.id)",
+		"lower()",
+		"lower(user.name, user.id)",
+		"user.id == $x",
+		"user.id == 1 ; true",
+		"[user.id,",
+		"user.id in [1, 2",
+		strings.Repeat("!", maxDepth+1) + "true",
+		strings.Repeat("(", maxDepth+2) + "true" + strings.Repeat(")", maxDepth+2),
+		strings.Repeat("[", maxDepth+2) + strings.Repeat("]", maxDepth+2) + " == null",
+		"user.id == '" + strings.Repeat("x", maxSourceLength) + "'",
+	}
+	for _, source := range tests {
+		if _, err := Compile(source); !errors.Is(err, ErrInvalidCondition) {
+			t.Errorf("Compile(%q) = %v, want ErrInvalidCondition", source, err)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{"user.department == 'math'", true},
+		{`user.department == "physics"`, false},
+		{"user.department != 'physics'", true},
+		{"user.level >= 3", true},
+		{"user.level > 3", false},
+		{"user.level == 3.0", true},
+		{"user.active", true},
+		{"user.active == true", true},
+		{"!user.active", false},
+		{"resource.id in user.courses", true},
+		{"'c3' in user.courses", false},
+		{"'ma' in user.department", true},
+		{"user.department in ['math', 'cs']", true},
+		{"in_cidr(request.ip, '10.0.0.0/8')", true},
+		{"in_cidr(request.ip, '192.168.0.0/16')", false},
+		{"in_cidr(request.ip, 'not a cidr')", false},
+		{"request.time >= user.term_start && request.time < user.term_end", true},
+		{"request.time < time('2024-01-01T00:00:00Z')", false},
+		{"hour(request.time) == 14", true},
+		{"weekday(request.time) == 2", true},
+		{"lower(user.name) == 'ada'", true},
+		{"len(user.courses) == 2", true},
+		{"starts_with(user.department, 'ma')", true},
+		{"user.level < 2 || user.department == 'math' && !(user.level > 5)", true},
+		{"user.id == \"u\\1\"", true},
+
+		// Missing attributes are null: they only equal null and never order
+		// or belong to anything.
+		{"user.missing == null", true},
+		{"user.missing != 'x'", true},
+		{"user.missing < 5", false},
+		{"user.missing >= 5", false},
+		{"user.missing in user.courses", false},
+		{"!user.missing", true},
+		{"user.department.head == null", true},
+		{"resource.owner == user.id", false},
+
+		// Mismatched types compare unequal and unordered instead of failing.
+		{"user.level == '3'", false},
+		{"user.level < 'z'", false},
+		{"user.level > 'a'", false},
+		{"hour(user.name) == null", true},
+		{"user.active == 1", false},
+
+		// Short-circuiting skips the other operand.
+		{"false && user.level", false},
+		{"true || user.level", true},
+		{"true && user.level", false},
+	}
+	env := testEnv()
+	for _, tt := range tests {
+		expr, err := Compile(tt.source)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.source, err)
+			continue
+		}
+		got, err := expr.Eval(env)
+		if err != nil {
+			t.Errorf("Eval(%q): %v", tt.source, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%q) = %v, want %v", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestEvalRequiresBoolean(t *testing.T) {
+	for _, source := range []string{
+		"user.level",
+		"user.department",
+		"user.missing",
+		"user.courses",
+		"lower(user.name)",
+		"request.time",
+		"'yes'",
+	} {
+		expr, err := Compile(source)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", source, err)
+		}
+		if got, err := expr.Eval(testEnv()); err == nil {
+			t.Errorf("Eval(%q) = %v, want a type error", source, got)
+		}
+	}
+}
+
+func TestEvalWithoutEnv(t *testing.T) {
+	expr, err := Compile("user.id == 'u1' || resource.id in user.courses")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, env := range []map[string]any{nil, {}, {"user": "not a map"}} {
+		if got, err := expr.Eval(env); err != nil || got {
+			t.Errorf("Eval(%v) = %v, %v; want false", env, got, err)
+		}
+	}
+}
+
+// FuzzCondition checks that arbitrary input is rejected or evaluated without
+// panicking, and that whatever compiles evaluates to a boolean or an error.
+func FuzzCondition(f *testing.F) {
+	for _, seed := range []string{
+		"user.department == 'math'",
+		"resource.id in user.courses && in_cidr(request.ip, '10.0.0.0/8')",
+		"request.time >= user.term_start",
+		"!(user.level < 2) || len(user.courses) > 1",
+		"user.__proto__.constructor == null",
+		"user.id == '\\'",
+		"user.id == \"\x00\"",
+		"\xff\xfe",
+		"user.id == 'é' || lower('İ') == 'i'",
+		"1.2.3.4 == user.level",
+		"99999999999999999999999999999999999 > user.level",
+		"in_cidr(request.ip, '::/0')",
+		"[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[",
+		strings.Repeat("(", 40),
+		strings.Repeat("!", 40) + "true",
+		strings.Repeat("user.id == 'x' || ", 60) + "true",
+		"time(time(time(request.time))) == request.time",
+		"user.id in user.id in user.id",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, source string) {
+		expr, err := Compile(source)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidCondition) {
+				t.Fatalf("Compile(%q) returned an unwrapped error: %v", source, err)
+			}
+			return
+		}
+		if result, err := expr.Eval(testEnv()); err != nil && result {
+			t.Fatalf("Eval(%q) = true with error %v", source, err)
+		}
+	})
+}
@@ -0,0 +1,329 @@
+package condition
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+type node interface {
+	eval(env map[string]any) (any, error)
+}
+
+type literalNode struct {
+	value any
+}
+
+func (n *literalNode) eval(map[string]any) (any, error) {
+	return n.value, nil
+}
+
+type listNode struct {
+	items []node
+}
+
+func (n *listNode) eval(env map[string]any) (any, error) {
+	values := make([]any, 0, len(n.items))
+	for _, item := range n.items {
+		value, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+type pathNode struct {
+	path []string
+}
+
+func (n *pathNode) eval(env map[string]any) (any, error) {
+	var current any = env
+	for _, key := range n.path {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, nil
+		}
+		current = m[key]
+	}
+	return current, nil
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) eval(env map[string]any) (any, error) {
+	value, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return value != true, nil
+}
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+func (n *logicalNode) eval(env map[string]any) (any, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "&&" && left != true {
+		return false, nil
+	}
+	if n.op == "||" && left == true {
+		return true, nil
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return right == true, nil
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n *compareNode) eval(env map[string]any) (any, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "in":
+		return contains(right, left), nil
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	}
+
+	cmp, ok := compare(left, right)
+	if !ok {
+		return false, nil
+	}
+	switch n.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+type callNode struct {
+	name string
+	fn   function
+	args []node
+}
+
+func (n *callNode) eval(env map[string]any) (any, error) {
+	args := make([]any, 0, len(n.args))
+	for _, arg := range n.args {
+		value, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+	return n.fn.call(args)
+}
+
+type function struct {
+	arity int
+	call  func(args []any) (any, error)
+}
+
+// functions is the complete set of callable functions. Each is pure and
+// returns null rather than failing on arguments of the wrong type.
+var functions = map[string]function{
+	"time": {1, func(args []any) (any, error) {
+		t, ok := toTime(args[0])
+		if !ok {
+			return nil, nil
+		}
+		return t, nil
+	}},
+	"hour": {1, func(args []any) (any, error) {
+		t, ok := toTime(args[0])
+		if !ok {
+			return nil, nil
+		}
+		return float64(t.UTC().Hour()), nil
+	}},
+	"weekday": {1, func(args []any) (any, error) {
+		t, ok := toTime(args[0])
+		if !ok {
+			return nil, nil
+		}
+		return float64(t.UTC().Weekday()), nil
+	}},
+	"in_cidr": {2, func(args []any) (any, error) {
+		ipStr, ok1 := args[0].(string)
+		cidrStr, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			return false, nil
+		}
+		ip := net.ParseIP(ipStr)
+		_, network, err := net.ParseCIDR(cidrStr)
+		if ip == nil || err != nil {
+			return false, nil
+		}
+		return network.Contains(ip), nil
+	}},
+	"lower": {1, func(args []any) (any, error) {
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, nil
+		}
+		return strings.ToLower(s), nil
+	}},
+	"len": {1, func(args []any) (any, error) {
+		switch v := args[0].(type) {
+		case string:
+			return float64(len(v)), nil
+		case []any:
+			return float64(len(v)), nil
+		case map[string]any:
+			return float64(len(v)), nil
+		}
+		return nil, nil
+	}},
+	"starts_with": {2, func(args []any) (any, error) {
+		s, ok1 := args[0].(string)
+		prefix, ok2 := args[1].(string)
+		return ok1 && ok2 && strings.HasPrefix(s, prefix), nil
+	}},
+}
+
+func toTime(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		parsed, err := time.Parse(time.RFC3339, t)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return parsed, true
+	}
+	return time.Time{}, false
+}
+
+func equal(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if cmp, ok := compare(a, b); ok {
+		return cmp == 0
+	}
+	if ab, ok := a.(bool); ok {
+		bb, ok := b.(bool)
+		return ok && ab == bb
+	}
+	return false
+}
+
+// compare orders two scalar values of compatible types. Times compare with
+// RFC 3339 strings so attributes stored as text work against request.time.
+func compare(a, b any) (int, bool) {
+	switch av := a.(type) {
+	case float64:
+		bv, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case av < bv:
+			return -1, true
+		case av > bv:
+			return 1, true
+		}
+		return 0, true
+	case time.Time:
+		bv, ok := toTime(b)
+		if !ok {
+			return 0, false
+		}
+		return av.Compare(bv), true
+	case string:
+		if bt, ok := b.(time.Time); ok {
+			at, ok := toTime(av)
+			if !ok {
+				return 0, false
+			}
+			return at.Compare(bt), true
+		}
+		bv, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(av, bv), true
+	}
+	return 0, false
+}
+
+func contains(collection, item any) bool {
+	switch c := collection.(type) {
+	case []any:
+		for _, element := range c {
+			if equal(element, item) {
+				return true
+			}
+		}
+	case []string:
+		s, ok := item.(string)
+		if !ok {
+			return false
+		}
+		for _, element := range c {
+			if element == s {
+				return true
+			}
+		}
+	case map[string]any:
+		s, ok := item.(string)
+		if !ok {
+			return false
+		}
+		_, found := c[s]
+		return found
+	case string:
+		s, ok := item.(string)
+		return ok && strings.Contains(c, s)
+	}
+	return false
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case time.Time:
+		return "time"
+	case []any:
+		return "list"
+	}
+	return fmt.Sprintf("%T", v)
+}
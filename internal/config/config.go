@@ -1,10 +1,31 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+
+	"github.com/Anand078/rbac/internal/signing"
+)
+
+const (
+	AuthzModeDatabase = "database"
+	AuthzModeClaims   = "claims"
+)
+
+// Storage drivers, selected by the scheme of DATABASE_URL.
+const (
+	DatabaseDriverPostgres = "postgres"
+	DatabaseDriverSQLite   = "sqlite"
+	DatabaseDriverMemory   = "memory"
 )
 
 type Config struct {
@@ -14,6 +35,119 @@ type Config struct {
 	DatabaseURL        string
 	JWTSecret          string
 	Port               string
+
+	// SupabaseAuthFederation accepts access tokens issued by Supabase Auth,
+	// provisioning a local user for each new Supabase user. Provisioned users
+	// get the global role named SupabaseDefaultRole, if set. Verified tokens
+	// are cached for SupabaseAuthCacheTTL.
+	SupabaseAuthFederation bool
+	SupabaseDefaultRole    string
+	SupabaseAuthCacheTTL   time.Duration
+
+	// OIDCIssuer enables login through an OpenID provider with the
+	// authorization code flow and PKCE. Identities are recorded under
+	// OIDCProviderName.
+	OIDCIssuer       string
+	OIDCProviderName string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCScopes       []string
+	// OIDCTrustedIssuers maps provider names to issuers whose JWTs are
+	// accepted as bearer tokens when their audience includes OIDCAudience.
+	OIDCTrustedIssuers map[string]string
+	OIDCAudience       string
+	// OIDCGroupsClaim names the claim listing a user's groups at the
+	// provider, which group mappings assign roles from.
+	OIDCGroupsClaim string
+
+	// FederationLinkByEmail names the identity providers whose verified
+	// emails may link a new identity to an existing local user without
+	// credentials of its own. Identities of other providers never take over
+	// existing users.
+	FederationLinkByEmail []string
+	// FederationEnforcesMFA names the identity providers trusted to require
+	// a second factor themselves. Logins through other providers get this
+	// service's MFA challenge like password logins.
+	FederationEnforcesMFA []string
+
+	// SMTPAddr (host:port) delivers account emails through an SMTP server,
+	// sent as MailFrom. Without it, emails are written to MailLogFile, or to
+	// the log when that is unset too.
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	MailFrom     string
+	MailLogFile  string
+	// EmailVerificationURL and PasswordResetURL are the pages the emailed
+	// links open, with the token in the token query parameter.
+	EmailVerificationURL string
+	PasswordResetURL     string
+	EmailVerificationTTL time.Duration
+	PasswordResetTTL     time.Duration
+	// RequireEmailVerification refuses password logins until the user has
+	// verified their email.
+	RequireEmailVerification bool
+
+	// MFAIssuer names this service in authenticator apps. Users holding one
+	// of MFARequiredRoles must pass TOTP at password login.
+	MFAIssuer        string
+	MFARequiredRoles []string
+
+	// WebAuthnRPID enables passkeys bound to that domain, registered and used
+	// from WebAuthnOrigins. WebAuthnPasswordless lets passkeys replace the
+	// password instead of only following it as a second factor.
+	WebAuthnRPID         string
+	WebAuthnRPName       string
+	WebAuthnOrigins      []string
+	WebAuthnPasswordless bool
+
+	// DatabaseDriver is derived from DATABASE_URL: postgres:// (or a key=value
+	// DSN) for Postgres, sqlite:<path> for SQLite, and memory when unset.
+	// DatabasePath is the SQLite file name.
+	DatabaseDriver string
+	DatabasePath   string
+	// MigrateOnStartup applies pending Postgres migrations before serving.
+	// SQLite databases are always migrated when opened.
+	MigrateOnStartup bool
+
+	// AccessTokenTTL is the lifetime of issued JWTs; RefreshTokenTTL is the
+	// lifetime of the opaque refresh tokens used to renew them.
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// AssignmentSweepInterval is how often expired role assignments are archived.
+	AssignmentSweepInterval time.Duration
+	// TokenPurgeInterval is how often expired refresh tokens and revocation
+	// entries are deleted.
+	TokenPurgeInterval time.Duration
+
+	// JWTSigningAlg selects HS256 (JWT_SECRET) or an asymmetric algorithm
+	// whose private keys are read from JWTKeyPath, a PEM file or a directory
+	// of *.pem files.
+	JWTSigningAlg string
+	JWTKeyPath    string
+	// JWTKeyRotationInterval is how often keys are rotated (or re-read from
+	// JWTKeyPath); zero disables rotation. Retired keys keep verifying tokens
+	// for JWTKeyGracePeriod.
+	JWTKeyRotationInterval time.Duration
+	JWTKeyGracePeriod      time.Duration
+
+	// AuthzMode is "database" (every check queries Postgres) or "claims"
+	// (tokens carry the user's roles and permissions and are authorized from
+	// them while the policy version they were issued at is current).
+	AuthzMode string
+
+	// PermissionCacheTTL bounds how long a user's effective permissions are
+	// cached; PermissionCacheSize caps the cached (user, tenant) pairs, and
+	// zero disables the cache.
+	PermissionCacheTTL  time.Duration
+	PermissionCacheSize int
+
+	// ChangeNotifications propagates authorization changes between replicas
+	// with Postgres LISTEN/NOTIFY. It needs a session-mode connection; turn it
+	// off behind a transaction-mode pooler.
+	ChangeNotifications bool
 }
 
 func Load() *Config {
@@ -37,17 +171,262 @@ func Load() *Config {
 		SupabaseAnonKey:    os.Getenv("SUPABASE_ANON_KEY"),
 		SupabaseServiceKey: os.Getenv("SUPABASE_SERVICE_KEY"),
 		DatabaseURL:        os.Getenv("DATABASE_URL"),
+		MigrateOnStartup:   getBool("MIGRATE_ON_STARTUP", false),
 		JWTSecret:          os.Getenv("JWT_SECRET"),
 		Port:               os.Getenv("PORT"),
+
+		SupabaseAuthFederation: getBool("SUPABASE_AUTH_FEDERATION", false),
+		SupabaseDefaultRole:    os.Getenv("SUPABASE_DEFAULT_ROLE"),
+		SupabaseAuthCacheTTL:   getDuration("SUPABASE_AUTH_CACHE_TTL", time.Minute),
+
+		OIDCIssuer:         os.Getenv("OIDC_ISSUER"),
+		OIDCProviderName:   getEnv("OIDC_PROVIDER_NAME", "oidc"),
+		OIDCClientID:       os.Getenv("OIDC_CLIENT_ID"),
+		OIDCClientSecret:   os.Getenv("OIDC_CLIENT_SECRET"),
+		OIDCRedirectURL:    os.Getenv("OIDC_REDIRECT_URL"),
+		OIDCScopes:         strings.Fields(getEnv("OIDC_SCOPES", "openid email profile")),
+		OIDCTrustedIssuers: getMap("OIDC_TRUSTED_ISSUERS"),
+		OIDCGroupsClaim:    getEnv("OIDC_GROUPS_CLAIM", "groups"),
+
+		FederationLinkByEmail: getList("FEDERATION_LINK_BY_EMAIL"),
+		FederationEnforcesMFA: getList("FEDERATION_ENFORCES_MFA"),
+
+		SMTPAddr:                 os.Getenv("SMTP_ADDR"),
+		SMTPUsername:             os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:             os.Getenv("SMTP_PASSWORD"),
+		MailFrom:                 os.Getenv("MAIL_FROM"),
+		MailLogFile:              os.Getenv("MAIL_LOG_FILE"),
+		EmailVerificationURL:     os.Getenv("EMAIL_VERIFICATION_URL"),
+		PasswordResetURL:         os.Getenv("PASSWORD_RESET_URL"),
+		EmailVerificationTTL:     getDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+		PasswordResetTTL:         getDuration("PASSWORD_RESET_TTL", time.Hour),
+		RequireEmailVerification: getBool("REQUIRE_EMAIL_VERIFICATION", false),
+
+		MFAIssuer:        getEnv("MFA_ISSUER", "RBAC"),
+		MFARequiredRoles: getList("MFA_REQUIRED_ROLES"),
+
+		WebAuthnRPID:         os.Getenv("WEBAUTHN_RP_ID"),
+		WebAuthnOrigins:      getList("WEBAUTHN_ORIGINS"),
+		WebAuthnPasswordless: getBool("WEBAUTHN_PASSWORDLESS", true),
+
+		AccessTokenTTL:          getDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:         getDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		AssignmentSweepInterval: getDuration("ASSIGNMENT_SWEEP_INTERVAL", time.Minute),
+		TokenPurgeInterval:      getDuration("TOKEN_PURGE_INTERVAL", time.Hour),
+
+		JWTSigningAlg:          getEnv("JWT_SIGNING_ALG", signing.AlgHS256),
+		JWTKeyPath:             os.Getenv("JWT_KEY_PATH"),
+		JWTKeyRotationInterval: getDuration("JWT_KEY_ROTATION_INTERVAL", 0),
+
+		AuthzMode: getEnv("AUTHZ_MODE", AuthzModeDatabase),
+
+		PermissionCacheTTL:  getDuration("PERMISSION_CACHE_TTL", 30*time.Second),
+		PermissionCacheSize: getInt("PERMISSION_CACHE_SIZE", 10000),
+		ChangeNotifications: getBool("CHANGE_NOTIFICATIONS", true),
 	}
+	config.JWTKeyGracePeriod = getDuration("JWT_KEY_GRACE_PERIOD", config.AccessTokenTTL)
+	config.OIDCAudience = getEnv("OIDC_AUDIENCE", config.OIDCClientID)
+	config.WebAuthnRPName = getEnv("WEBAUTHN_RP_NAME", config.MFAIssuer)
+
+	driver, path, err := databaseDriver(config.DatabaseURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.DatabaseDriver, config.DatabasePath = driver, path
 
 	// Validate required fields
-	if config.SupabaseURL == "" {
-		log.Fatal("SUPABASE_URL is required")
+	if config.SupabaseURL != "" && config.SupabaseServiceKey == "" && config.SupabaseAnonKey == "" {
+		log.Fatal("SUPABASE_SERVICE_KEY or SUPABASE_ANON_KEY is required with SUPABASE_URL")
+	}
+	if config.SupabaseAuthFederation && config.SupabaseURL == "" {
+		log.Fatal("SUPABASE_URL is required with SUPABASE_AUTH_FEDERATION")
+	}
+	if config.OIDCIssuer != "" && (config.OIDCClientID == "" || config.OIDCRedirectURL == "") {
+		log.Fatal("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required with OIDC_ISSUER")
+	}
+	if len(config.OIDCTrustedIssuers) > 0 && config.OIDCAudience == "" {
+		log.Fatal("OIDC_AUDIENCE (or OIDC_CLIENT_ID) is required with OIDC_TRUSTED_ISSUERS")
+	}
+	if config.SMTPAddr != "" && config.MailFrom == "" {
+		log.Fatal("MAIL_FROM is required with SMTP_ADDR")
+	}
+	for key, raw := range map[string]string{
+		"EMAIL_VERIFICATION_URL": config.EmailVerificationURL,
+		"PASSWORD_RESET_URL":     config.PasswordResetURL,
+	} {
+		if raw == "" {
+			continue
+		}
+		if u, err := url.Parse(raw); err != nil || !u.IsAbs() {
+			log.Fatalf("Invalid %s %q, expected an absolute URL", key, raw)
+		}
+	}
+	if config.WebAuthnRPID != "" && len(config.WebAuthnOrigins) == 0 {
+		log.Fatal("WEBAUTHN_ORIGINS is required with WEBAUTHN_RP_ID")
+	}
+	for _, origin := range config.WebAuthnOrigins {
+		// Origins must be the relying party's domain or one of its subdomains.
+		u, err := url.Parse(origin)
+		if err != nil || !u.IsAbs() || u.Path != "" ||
+			(u.Hostname() != config.WebAuthnRPID && !strings.HasSuffix(u.Hostname(), "."+config.WebAuthnRPID)) {
+			log.Fatalf("Invalid WEBAUTHN_ORIGINS entry %q, expected an origin on %q", origin, config.WebAuthnRPID)
+		}
+	}
+	switch config.JWTSigningAlg {
+	case signing.AlgHS256:
+		if config.JWTSecret == "" {
+			log.Fatal("JWT_SECRET is required for HS256")
+		}
+	case signing.AlgRS256, signing.AlgES256, signing.AlgEdDSA:
+		if config.JWTKeyGracePeriod < config.AccessTokenTTL {
+			log.Printf("Warning: JWT_KEY_GRACE_PERIOD is shorter than ACCESS_TOKEN_TTL; tokens may fail before they expire")
+		}
+	default:
+		log.Fatalf("Unsupported JWT_SIGNING_ALG %q", config.JWTSigningAlg)
 	}
-	if config.DatabaseURL == "" {
-		log.Fatal("DATABASE_URL is required")
+	if config.AuthzMode != AuthzModeDatabase && config.AuthzMode != AuthzModeClaims {
+		log.Fatalf("Unsupported AUTHZ_MODE %q", config.AuthzMode)
 	}
 
 	return config
 }
+
+// databaseDriver picks the storage driver for a DATABASE_URL, returning the
+// database file name for SQLite.
+func databaseDriver(databaseURL string) (driver, path string, err error) {
+	if databaseURL == "" {
+		return DatabaseDriverMemory, "", nil
+	}
+	scheme, rest, ok := strings.Cut(databaseURL, ":")
+	if !ok || strings.Contains(scheme, "=") {
+		// key=value connection string
+		return DatabaseDriverPostgres, "", nil
+	}
+	switch strings.ToLower(scheme) {
+	case "postgres", "postgresql":
+		return DatabaseDriverPostgres, "", nil
+	case "sqlite", "sqlite3":
+		path = strings.TrimPrefix(rest, "//")
+		if path == "" {
+			return "", "", fmt.Errorf("DATABASE_URL %q names no SQLite database file", databaseURL)
+		}
+		return DatabaseDriverSQLite, path, nil
+	default:
+		return "", "", fmt.Errorf("unsupported DATABASE_URL scheme %q", scheme)
+	}
+}
+
+func getBool(key string, fallback bool) bool {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using %t", key, raw, fallback)
+		return fallback
+	}
+	return value
+}
+
+func getInt(key string, fallback int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 {
+		log.Printf("Warning: invalid %s %q, using %d", key, raw, fallback)
+		return fallback
+	}
+	return value
+}
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil || value <= 0 {
+		log.Printf("Warning: invalid %s %q, using %s", key, raw, fallback)
+		return fallback
+	}
+	return value
+}
+
+// getMap parses a comma-separated list of key=value pairs.
+func getMap(key string) map[string]string {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return nil
+	}
+	values := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" || v == "" {
+			log.Fatalf("Invalid %s entry %q, expected key=value", key, pair)
+		}
+		values[k] = v
+	}
+	return values
+}
+
+// getList parses a comma-separated list.
+func getList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// LoadSigningKeys reads the private keys at JWTKeyPath. A directory yields
+// its *.pem files in name order, so the last file is the active key. It
+// returns no keys when JWTKeyPath is unset.
+func (c *Config) LoadSigningKeys() ([]*signing.Key, error) {
+	if c.JWTKeyPath == "" {
+		return nil, nil
+	}
+
+	info, err := os.Stat(c.JWTKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{c.JWTKeyPath}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(c.JWTKeyPath, "*.pem"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no *.pem files in %s", c.JWTKeyPath)
+	}
+
+	keys := make([]*signing.Key, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		key, err := signing.ParsePEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
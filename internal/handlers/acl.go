@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/services"
+	"github.com/Anand078/rbac/pkg/utils"
+)
+
+type ACLHandler struct {
+	aclService *services.ACLService
+}
+
+func NewACLHandler(aclService *services.ACLService) *ACLHandler {
+	return &ACLHandler{aclService: aclService}
+}
+
+func (h *ACLHandler) CreateEntry(c *gin.Context) {
+	var req models.CreateACLEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entry, err := h.aclService.CreateEntry(req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidACLEntry) {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "ACL entry created successfully", entry)
+}
+
+func (h *ACLHandler) GetEntries(c *gin.Context) {
+	resource := c.Query("resource")
+	resourceID := c.Query("resource_id")
+	if resource == "" || resourceID == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "resource and resource_id are required")
+		return
+	}
+
+	entries, err := h.aclService.GetEntries(resource, resourceID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "ACL entries retrieved successfully", entries)
+}
+
+func (h *ACLHandler) DeleteEntry(c *gin.Context) {
+	entryID, err := uuid.Parse(c.Param("entryID"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid ACL entry ID")
+		return
+	}
+
+	if err := h.aclService.DeleteEntry(entryID); err != nil {
+		if errors.Is(err, services.ErrACLEntryNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "ACL entry deleted successfully", nil)
+}
@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 
 	"github.com/Anand078/rbac/internal/models"
 	"github.com/Anand078/rbac/internal/services"
@@ -27,6 +29,10 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 	user, err := h.authService.Register(req)
 	if err != nil {
+		if errors.Is(err, services.ErrDuplicate) {
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+			return
+		}
 		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -41,11 +47,170 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	response, err := h.authService.Login(req)
+	response, challenge, err := h.authService.Login(req)
 	if err != nil {
+		if errors.Is(err, services.ErrEmailNotVerified) {
+			utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+			return
+		}
 		utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
 		return
 	}
+	if challenge != nil {
+		utils.SuccessResponse(c, http.StatusOK, "Second factor required", challenge)
+		return
+	}
 
 	utils.SuccessResponse(c, http.StatusOK, "Login successful", response)
 }
+
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response, err := h.authService.Refresh(req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if errors.Is(err, services.ErrMFAEnrollmentRequired) {
+			utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Token refreshed successfully", response)
+}
+
+// Logout revokes the access token used for the request and, when a refresh
+// token is supplied, the session it belongs to.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req models.LogoutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	value, isToken := c.Get("jti")
+	if !isToken {
+		utils.ErrorResponse(c, http.StatusBadRequest, "API keys cannot log out; delete the key to revoke it")
+		return
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+	jti := value.(uuid.UUID)
+	expiresAt := c.GetTime("token_expires_at")
+
+	if err := h.authService.RevokeToken(jti, userID, expiresAt); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if req.RefreshToken != "" {
+		if err := h.authService.RevokeRefreshToken(userID, req.RefreshToken); err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Logged out successfully", nil)
+}
+
+// LogoutAll revokes every access and refresh token issued to the user.
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	if err := h.authService.RevokeAllSessions(userID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "All sessions logged out successfully", nil)
+}
+
+// Email Verification
+
+// RequestEmailVerification emails a new verification link. It answers the
+// same whether or not the address belongs to an account.
+func (h *AuthHandler) RequestEmailVerification(c *gin.Context) {
+	var req models.EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.authService.RequestEmailVerification(req.Email); err != nil {
+		respondEmailFlowError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "If the address belongs to an unverified account, a verification email has been sent", nil)
+}
+
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.authService.VerifyEmail(req.Token); err != nil {
+		respondEmailFlowError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Email verified successfully", nil)
+}
+
+// Password Reset
+
+// RequestPasswordReset emails a password reset link. It answers the same
+// whether or not the address belongs to an account.
+func (h *AuthHandler) RequestPasswordReset(c *gin.Context) {
+	var req models.EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.authService.RequestPasswordReset(req.Email); err != nil {
+		respondEmailFlowError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "If the address belongs to an account, a password reset email has been sent", nil)
+}
+
+// ResetPassword sets a new password and signs the user out of every session.
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.authService.ResetPassword(req); err != nil {
+		respondEmailFlowError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Password reset successfully", nil)
+}
+
+func respondEmailFlowError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidEmailToken):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrMailerDisabled):
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/services"
+	"github.com/Anand078/rbac/pkg/utils"
+)
+
+type GroupMappingHandler struct {
+	rbacService *services.RBACService
+}
+
+func NewGroupMappingHandler(rbacService *services.RBACService) *GroupMappingHandler {
+	return &GroupMappingHandler{rbacService: rbacService}
+}
+
+func (h *GroupMappingHandler) CreateMapping(c *gin.Context) {
+	var req models.CreateGroupRoleMappingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	mapping, err := h.rbacService.CreateGroupMapping(req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidGroupMapping):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrRoleNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrDuplicate):
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Group mapping created successfully", mapping)
+}
+
+func (h *GroupMappingHandler) GetMappings(c *gin.Context) {
+	mappings, err := h.rbacService.GetGroupMappings()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Group mappings retrieved successfully", mappings)
+}
+
+func (h *GroupMappingHandler) DeleteMapping(c *gin.Context) {
+	mappingID, err := uuid.Parse(c.Param("mappingID"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid group mapping ID")
+		return
+	}
+
+	if err := h.rbacService.DeleteGroupMapping(mappingID); err != nil {
+		if errors.Is(err, services.ErrGroupMappingNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Group mapping deleted successfully", nil)
+}
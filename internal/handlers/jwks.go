@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Anand078/rbac/internal/signing"
+)
+
+type JWKSHandler struct {
+	keys *signing.KeyManager
+}
+
+func NewJWKSHandler(keys *signing.KeyManager) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+// GetJWKS serves the public signing keys as a bare JWK Set rather than the
+// usual response envelope, since JWT libraries consume it directly.
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
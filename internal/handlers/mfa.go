@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/services"
+	"github.com/Anand078/rbac/pkg/utils"
+)
+
+type MFAHandler struct {
+	authService *services.AuthService
+}
+
+func NewMFAHandler(authService *services.AuthService) *MFAHandler {
+	return &MFAHandler{authService: authService}
+}
+
+// Login Challenge
+
+// Verify completes a password login with a TOTP code or a recovery code.
+func (h *MFAHandler) Verify(c *gin.Context) {
+	var req models.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response, err := h.authService.VerifyMFA(req)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Login successful", response)
+}
+
+// EnrollWithChallenge starts the enrollment required by a login.
+func (h *MFAHandler) EnrollWithChallenge(c *gin.Context) {
+	var req models.MFATokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	enrollment, err := h.authService.EnrollTOTPWithChallenge(req.MFAToken)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "TOTP enrollment started", enrollment)
+}
+
+// ConfirmWithChallenge confirms the enrollment required by a login and
+// completes the login.
+func (h *MFAHandler) ConfirmWithChallenge(c *gin.Context) {
+	var req models.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response, err := h.authService.ConfirmTOTPWithChallenge(req)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "TOTP enrolled; store the recovery codes safely", response)
+}
+
+// Current User
+
+func (h *MFAHandler) GetStatus(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	status, err := h.authService.GetMFAStatus(userID)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "MFA status retrieved successfully", status)
+}
+
+func (h *MFAHandler) EnrollTOTP(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	enrollment, err := h.authService.EnrollTOTP(userID)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "TOTP enrollment started", enrollment)
+}
+
+func (h *MFAHandler) ConfirmTOTP(c *gin.Context) {
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	codes, err := h.authService.ConfirmTOTP(userID, req.Code)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "TOTP enrolled; store the recovery codes safely",
+		models.MFAEnrollmentResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTP removes the user's factor. A confirmed factor needs a current
+// code; a pending enrollment can be cancelled with an empty body.
+func (h *MFAHandler) DisableTOTP(c *gin.Context) {
+	var req models.MFACodeRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	if err := h.authService.DisableTOTP(userID, req.Code); err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "TOTP disabled successfully", nil)
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	codes, err := h.authService.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Recovery codes regenerated; store them safely",
+		models.MFAEnrollmentResponse{RecoveryCodes: codes})
+}
+
+// Administration
+
+// ResetUserMFA removes the factor of a user who lost access to it.
+func (h *MFAHandler) ResetUserMFA(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userID"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := h.authService.ResetMFA(userID); err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "MFA reset successfully", nil)
+}
+
+func respondMFAError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidMFAToken), errors.Is(err, services.ErrInvalidMFACode):
+		utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, services.ErrMFAEnrollmentRequired):
+		utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrMFANotEnrolled), errors.Is(err, services.ErrUserNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrMFAAlreadyEnrolled):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrMFALocked):
+		utils.ErrorResponse(c, http.StatusTooManyRequests, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+}
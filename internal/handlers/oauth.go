@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/services"
+)
+
+// OAuthHandler serves the OAuth2 token and introspection endpoints. They
+// answer with the error format of RFC 6749 rather than the API's envelope,
+// since OAuth2 client libraries expect it.
+type OAuthHandler struct {
+	authService *services.AuthService
+}
+
+func NewOAuthHandler(authService *services.AuthService) *OAuthHandler {
+	return &OAuthHandler{authService: authService}
+}
+
+// Token implements the client_credentials grant (RFC 6749, section 4.4).
+// Clients authenticate with HTTP Basic or with client_id and client_secret
+// form parameters.
+func (h *OAuthHandler) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	grantType := c.PostForm("grant_type")
+	switch grantType {
+	case "client_credentials":
+	case "":
+		oauthError(c, http.StatusBadRequest, "invalid_request", "grant_type is required")
+		return
+	default:
+		oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "only client_credentials is supported")
+		return
+	}
+
+	response, err := h.authService.ClientCredentialsToken(client, c.PostForm("scope"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidScope) {
+			oauthError(c, http.StatusBadRequest, "invalid_scope", err.Error())
+			return
+		}
+		// The client was deleted while the token was issued.
+		if errors.Is(err, services.ErrInvalidClient) {
+			oauthError(c, http.StatusUnauthorized, "invalid_client", err.Error())
+			return
+		}
+		log.Printf("OAuth token for client %s: %v", client.ID, err)
+		oauthError(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Introspect implements token introspection (RFC 7662) for access tokens
+// issued by this service. Callers authenticate as a registered client.
+func (h *OAuthHandler) Introspect(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	if _, ok := h.authenticateClient(c); !ok {
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		oauthError(c, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+
+	response, err := h.authService.IntrospectToken(token)
+	if err != nil {
+		log.Printf("Token introspection: %v", err)
+		oauthError(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// authenticateClient reads the client credentials from the Authorization
+// header or the form, and answers with invalid_client when they are wrong.
+func (h *OAuthHandler) authenticateClient(c *gin.Context) (*models.OAuthClient, bool) {
+	clientID, secret, basic := c.Request.BasicAuth()
+	if basic {
+		// client_secret_basic form-encodes both values (RFC 6749, 2.3.1).
+		var errID, errSecret error
+		clientID, errID = url.QueryUnescape(clientID)
+		secret, errSecret = url.QueryUnescape(secret)
+		if errID != nil || errSecret != nil {
+			oauthError(c, http.StatusBadRequest, "invalid_request", "malformed client credentials")
+			return nil, false
+		}
+		if c.PostForm("client_secret") != "" {
+			oauthError(c, http.StatusBadRequest, "invalid_request", "use only one client authentication method")
+			return nil, false
+		}
+	} else {
+		clientID, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+	if clientID == "" {
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		oauthError(c, http.StatusUnauthorized, "invalid_client", "client authentication is required")
+		return nil, false
+	}
+
+	client, err := h.authService.AuthenticateClient(clientID, secret)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidClient) {
+			if basic {
+				c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+			}
+			oauthError(c, http.StatusUnauthorized, "invalid_client", err.Error())
+			return nil, false
+		}
+		log.Printf("OAuth client authentication: %v", err)
+		oauthError(c, http.StatusInternalServerError, "server_error", "")
+		return nil, false
+	}
+	return client, true
+}
+
+func oauthError(c *gin.Context, status int, code, description string) {
+	body := gin.H{"error": code}
+	if description != "" {
+		body["error_description"] = description
+	}
+	c.AbortWithStatusJSON(status, body)
+}
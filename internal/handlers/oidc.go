@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/oidc"
+	"github.com/Anand078/rbac/internal/services"
+	"github.com/Anand078/rbac/pkg/utils"
+)
+
+// oidcFlowCookie keeps the state, nonce and PKCE verifier of a login between
+// the redirect to the provider and the callback.
+const (
+	oidcFlowCookie = "oidc_flow"
+	oidcFlowMaxAge = 600
+)
+
+type oidcFlow struct {
+	State    string    `json:"state"`
+	Nonce    string    `json:"nonce"`
+	Verifier string    `json:"verifier"`
+	TenantID uuid.UUID `json:"tenant_id"`
+}
+
+type OIDCHandler struct {
+	relyingParty *oidc.RelyingParty
+	authService  *services.AuthService
+	// cookiePath scopes the flow cookie to the callback.
+	cookiePath string
+	secure     bool
+}
+
+func NewOIDCHandler(relyingParty *oidc.RelyingParty, authService *services.AuthService, cookiePath string) *OIDCHandler {
+	return &OIDCHandler{
+		relyingParty: relyingParty,
+		authService:  authService,
+		cookiePath:   cookiePath,
+		secure:       strings.HasPrefix(relyingParty.RedirectURL(), "https://"),
+	}
+}
+
+// Login redirects to the identity provider. The optional tenant_id query
+// parameter selects the tenant of the issued tokens, like in a password
+// login.
+func (h *OIDCHandler) Login(c *gin.Context) {
+	tenantID, err := models.ParseTenantID(c.Query("tenant_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid tenant ID")
+		return
+	}
+
+	flow := oidcFlow{
+		State:    oidc.NewRandom(),
+		Nonce:    oidc.NewRandom(),
+		Verifier: oidc.NewRandom(),
+		TenantID: tenantID,
+	}
+	authURL, err := h.relyingParty.AuthCodeURL(c.Request.Context(), flow.State, flow.Nonce, flow.Verifier)
+	if err != nil {
+		log.Printf("OIDC login: %v", err)
+		utils.ErrorResponse(c, http.StatusBadGateway, "Identity provider unavailable")
+		return
+	}
+
+	raw, _ := json.Marshal(flow)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookie, base64.RawURLEncoding.EncodeToString(raw), oidcFlowMaxAge, h.cookiePath, "", h.secure, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback completes the login the provider redirected back from and returns
+// tokens like a password login.
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	if errCode := c.Query("error"); errCode != "" {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Login failed: "+errCode)
+		return
+	}
+
+	var flow oidcFlow
+	cookie, err := c.Cookie(oidcFlowCookie)
+	if err == nil {
+		var raw []byte
+		if raw, err = base64.RawURLEncoding.DecodeString(cookie); err == nil {
+			err = json.Unmarshal(raw, &flow)
+		}
+	}
+	if err != nil || flow.State == "" || c.Query("state") != flow.State {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid or expired login state")
+		return
+	}
+	// The flow is single use.
+	c.SetCookie(oidcFlowCookie, "", -1, h.cookiePath, "", h.secure, true)
+
+	code := c.Query("code")
+	if code == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Missing authorization code")
+		return
+	}
+
+	identity, err := h.relyingParty.Exchange(c.Request.Context(), code, flow.Verifier, flow.Nonce)
+	if err != nil {
+		if errors.Is(err, oidc.ErrInvalidToken) {
+			utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		log.Printf("OIDC callback: %v", err)
+		utils.ErrorResponse(c, http.StatusBadGateway, "Identity provider unavailable")
+		return
+	}
+
+	response, challenge, err := h.authService.LoginExternal(identity, flow.TenantID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrIdentityConflict):
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrIdentityEmailRequired):
+			utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	if challenge != nil {
+		utils.SuccessResponse(c, http.StatusOK, "Second factor required", challenge)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Login successful", response)
+}
@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"github.com/Anand078/rbac/internal/condition"
 	"github.com/Anand078/rbac/internal/models"
 	"github.com/Anand078/rbac/internal/services"
 	"github.com/Anand078/rbac/pkg/utils"
@@ -28,6 +30,14 @@ func (h *PermissionHandler) CreatePermission(c *gin.Context) {
 
 	permission, err := h.rbacService.CreatePermission(req)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidPermissionPattern) {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, services.ErrDuplicate) {
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+			return
+		}
 		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -81,7 +91,11 @@ func (h *PermissionHandler) GrantPermission(c *gin.Context) {
 		return
 	}
 
-	if err := h.rbacService.GrantPermission(roleID, permissionID); err != nil {
+	if err := h.rbacService.GrantPermission(roleID, permissionID, req.Effect, req.Condition); err != nil {
+		if errors.Is(err, condition.ErrInvalidCondition) {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
 		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -112,3 +126,7 @@ func (h *PermissionHandler) RevokePermission(c *gin.Context) {
 
 	utils.SuccessResponse(c, http.StatusOK, "Permission revoked successfully", nil)
 }
+
+func (h *PermissionHandler) GetCacheStats(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "Permission cache statistics retrieved successfully", h.rbacService.CacheStats())
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -28,6 +29,10 @@ func (h *RoleHandler) CreateRole(c *gin.Context) {
 
 	role, err := h.rbacService.CreateRole(req)
 	if err != nil {
+		if errors.Is(err, services.ErrDuplicate) {
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+			return
+		}
 		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -53,7 +58,13 @@ func (h *RoleHandler) GetUserRoles(c *gin.Context) {
 		return
 	}
 
-	roles, err := h.rbacService.GetUserRoles(userID)
+	tenantID, err := models.ParseTenantID(c.Query("tenant_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid tenant ID")
+		return
+	}
+
+	roles, err := h.rbacService.GetUserRoles(userID, tenantID)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -62,6 +73,22 @@ func (h *RoleHandler) GetUserRoles(c *gin.Context) {
 	utils.SuccessResponse(c, http.StatusOK, "User roles retrieved successfully", roles)
 }
 
+func (h *RoleHandler) GetUserAssignments(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userID"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	assignments, err := h.rbacService.GetUserAssignments(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "User role assignments retrieved successfully", assignments)
+}
+
 func (h *RoleHandler) AssignRole(c *gin.Context) {
 	var req models.AssignRoleRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -81,7 +108,24 @@ func (h *RoleHandler) AssignRole(c *gin.Context) {
 		return
 	}
 
-	if err := h.rbacService.AssignRole(userID, roleID); err != nil {
+	tenantID, err := models.ParseTenantID(req.TenantID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid tenant ID")
+		return
+	}
+
+	assignment := models.RoleAssignment{
+		UserID:     userID,
+		RoleID:     roleID,
+		TenantID:   tenantID,
+		ValidFrom:  req.ValidFrom,
+		ValidUntil: req.ValidUntil,
+	}
+	if err := h.rbacService.AssignRole(assignment); err != nil {
+		if errors.Is(err, services.ErrInvalidValidity) {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
 		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -105,10 +149,93 @@ func (h *RoleHandler) RemoveRole(c *gin.Context) {
 		return
 	}
 
-	if err := h.rbacService.RemoveRole(userID, roleID); err != nil {
+	tenantID, err := models.ParseTenantID(c.Query("tenant_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid tenant ID")
+		return
+	}
+
+	if err := h.rbacService.RemoveRole(userID, roleID, tenantID); err != nil {
+		if errors.Is(err, services.ErrAssignmentManaged) {
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+			return
+		}
 		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	utils.SuccessResponse(c, http.StatusOK, "Role removed successfully", nil)
 }
+
+func (h *RoleHandler) GetParentRoles(c *gin.Context) {
+	roleID, err := uuid.Parse(c.Param("roleID"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid role ID")
+		return
+	}
+
+	roles, err := h.rbacService.GetParentRoles(roleID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Parent roles retrieved successfully", roles)
+}
+
+func (h *RoleHandler) AddParentRole(c *gin.Context) {
+	roleID, err := uuid.Parse(c.Param("roleID"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid role ID")
+		return
+	}
+
+	var req models.AddParentRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	parentID, err := uuid.Parse(req.ParentRoleID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid parent role ID")
+		return
+	}
+
+	if err := h.rbacService.AddParentRole(roleID, parentID); err != nil {
+		switch {
+		case errors.Is(err, services.ErrSelfParentRole):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrRoleNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrRoleCycle):
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Parent role added successfully", nil)
+}
+
+func (h *RoleHandler) RemoveParentRole(c *gin.Context) {
+	roleID, err := uuid.Parse(c.Param("roleID"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid role ID")
+		return
+	}
+
+	parentID, err := uuid.Parse(c.Param("parentID"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid parent role ID")
+		return
+	}
+
+	if err := h.rbacService.RemoveParentRole(roleID, parentID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Parent role removed successfully", nil)
+}
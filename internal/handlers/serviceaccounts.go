@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/services"
+	"github.com/Anand078/rbac/pkg/utils"
+)
+
+type ServiceAccountHandler struct {
+	authService *services.AuthService
+}
+
+func NewServiceAccountHandler(authService *services.AuthService) *ServiceAccountHandler {
+	return &ServiceAccountHandler{authService: authService}
+}
+
+func (h *ServiceAccountHandler) CreateServiceAccount(c *gin.Context) {
+	var req models.CreateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	account, err := h.authService.CreateServiceAccount(req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidServiceAccount):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrRoleNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrDuplicate):
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Service account created successfully", account)
+}
+
+func (h *ServiceAccountHandler) GetServiceAccounts(c *gin.Context) {
+	accounts, err := h.authService.GetServiceAccounts()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Service accounts retrieved successfully", accounts)
+}
+
+func (h *ServiceAccountHandler) GetServiceAccount(c *gin.Context) {
+	accountID, ok := parseAccountID(c)
+	if !ok {
+		return
+	}
+
+	account, err := h.authService.GetServiceAccount(accountID)
+	if err != nil {
+		respondServiceAccountError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Service account retrieved successfully", account)
+}
+
+func (h *ServiceAccountHandler) DeleteServiceAccount(c *gin.Context) {
+	accountID, ok := parseAccountID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.DeleteServiceAccount(accountID); err != nil {
+		respondServiceAccountError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Service account deleted successfully", nil)
+}
+
+// API Keys
+func (h *ServiceAccountHandler) CreateAPIKey(c *gin.Context) {
+	accountID, ok := parseAccountID(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response, err := h.authService.CreateAPIKey(accountID, req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAPIKeyRequest) {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondServiceAccountError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "API key created successfully; store it now, it cannot be shown again", response)
+}
+
+func (h *ServiceAccountHandler) GetAPIKeys(c *gin.Context) {
+	accountID, ok := parseAccountID(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.authService.GetAPIKeys(accountID)
+	if err != nil {
+		respondServiceAccountError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "API keys retrieved successfully", keys)
+}
+
+func (h *ServiceAccountHandler) DeleteAPIKey(c *gin.Context) {
+	accountID, ok := parseAccountID(c)
+	if !ok {
+		return
+	}
+	keyID, err := uuid.Parse(c.Param("keyID"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	if err := h.authService.DeleteAPIKey(accountID, keyID); err != nil {
+		respondServiceAccountError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "API key deleted successfully", nil)
+}
+
+// OAuth Clients
+func (h *ServiceAccountHandler) CreateOAuthClient(c *gin.Context) {
+	accountID, ok := parseAccountID(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response, err := h.authService.CreateOAuthClient(accountID, req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidOAuthClient) {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondServiceAccountError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "OAuth client created successfully; store the secret now, it cannot be shown again", response)
+}
+
+func (h *ServiceAccountHandler) GetOAuthClients(c *gin.Context) {
+	accountID, ok := parseAccountID(c)
+	if !ok {
+		return
+	}
+
+	clients, err := h.authService.GetOAuthClients(accountID)
+	if err != nil {
+		respondServiceAccountError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "OAuth clients retrieved successfully", clients)
+}
+
+func (h *ServiceAccountHandler) DeleteOAuthClient(c *gin.Context) {
+	accountID, ok := parseAccountID(c)
+	if !ok {
+		return
+	}
+	clientID, err := uuid.Parse(c.Param("clientID"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid client ID")
+		return
+	}
+
+	if err := h.authService.DeleteOAuthClient(accountID, clientID); err != nil {
+		respondServiceAccountError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "OAuth client deleted successfully", nil)
+}
+
+func parseAccountID(c *gin.Context) (uuid.UUID, bool) {
+	accountID, err := uuid.Parse(c.Param("accountID"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid service account ID")
+		return uuid.Nil, false
+	}
+	return accountID, true
+}
+
+func respondServiceAccountError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrServiceAccountNotFound) || errors.Is(err, services.ErrAPIKeyNotFound) ||
+		errors.Is(err, services.ErrOAuthClientNotFound) {
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+	utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/services"
+	"github.com/Anand078/rbac/pkg/utils"
+)
+
+// Passkey Registration
+
+// BeginPasskeyRegistration returns the options for navigator.credentials.create.
+func (h *AuthHandler) BeginPasskeyRegistration(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	options, err := h.authService.BeginPasskeyRegistration(userID)
+	if err != nil {
+		respondPasskeyError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Passkey registration started", options)
+}
+
+func (h *AuthHandler) FinishPasskeyRegistration(c *gin.Context) {
+	var req models.PasskeyRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	passkey, err := h.authService.FinishPasskeyRegistration(userID, req)
+	if err != nil {
+		respondPasskeyError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Passkey registered successfully", passkey)
+}
+
+func (h *AuthHandler) GetPasskeys(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	passkeys, err := h.authService.GetPasskeys(userID)
+	if err != nil {
+		respondPasskeyError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Passkeys retrieved successfully", passkeys)
+}
+
+func (h *AuthHandler) DeletePasskey(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	if err := h.authService.DeletePasskey(userID, c.Param("credentialID")); err != nil {
+		respondPasskeyError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Passkey deleted successfully", nil)
+}
+
+// Passkey Login
+
+// BeginPasskeyLogin returns the options for navigator.credentials.get of a
+// passwordless login.
+func (h *AuthHandler) BeginPasskeyLogin(c *gin.Context) {
+	var req models.PasskeyLoginRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if _, err := models.ParseTenantID(req.TenantID); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid tenant ID")
+		return
+	}
+
+	options, err := h.authService.BeginPasskeyLogin(req)
+	if err != nil {
+		respondPasskeyError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Passkey login started", options)
+}
+
+func (h *AuthHandler) FinishPasskeyLogin(c *gin.Context) {
+	var req models.PasskeyAssertionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response, err := h.authService.FinishPasskeyLogin(req)
+	if err != nil {
+		respondPasskeyError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Login successful", response)
+}
+
+// BeginPasskeyMFA returns the options for navigator.credentials.get of a
+// password login's second factor.
+func (h *AuthHandler) BeginPasskeyMFA(c *gin.Context) {
+	var req models.MFATokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	options, err := h.authService.BeginPasskeyMFA(req.MFAToken)
+	if err != nil {
+		respondPasskeyError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Passkey verification started", options)
+}
+
+func (h *AuthHandler) FinishPasskeyMFA(c *gin.Context) {
+	var req models.MFAPasskeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response, err := h.authService.FinishPasskeyMFA(req)
+	if err != nil {
+		respondPasskeyError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Login successful", response)
+}
+
+func respondPasskeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPasskey), errors.Is(err, services.ErrInvalidPasskeySession),
+		errors.Is(err, services.ErrPasskeyUserNotVerified), errors.Is(err, services.ErrPasskeyCounterRegressed),
+		errors.Is(err, services.ErrInvalidMFAToken):
+		utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, services.ErrPasswordlessDisabled), errors.Is(err, services.ErrPasskeyServiceAccount),
+		errors.Is(err, services.ErrMFAEnrollmentRequired):
+		utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrPasskeyNotFound), errors.Is(err, services.ErrUserNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrPasskeyExists):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrPasskeysDisabled):
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+}
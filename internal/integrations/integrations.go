@@ -0,0 +1,63 @@
+// Package integrations sets up optional third-party services. Each one is
+// enabled only when configured, and is nil otherwise; nothing in the core
+// request path depends on them.
+package integrations
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	supa "github.com/supabase-community/supabase-go"
+
+	"github.com/Anand078/rbac/internal/config"
+)
+
+// supabaseTimeout bounds each request to Supabase made on behalf of a
+// request to this service.
+const supabaseTimeout = 10 * time.Second
+
+type Integrations struct {
+	// Supabase is a client for the project at SUPABASE_URL, authenticated
+	// with the service key (or the anon key when no service key is set), for
+	// features such as Supabase Auth or Storage.
+	Supabase *supa.Client
+	// SupabaseAuth verifies Supabase Auth access tokens when
+	// SUPABASE_AUTH_FEDERATION is enabled.
+	SupabaseAuth *SupabaseAuth
+}
+
+// New creates the integrations enabled in cfg.
+func New(cfg *config.Config) (*Integrations, error) {
+	i := &Integrations{}
+
+	if cfg.SupabaseURL != "" {
+		key := cfg.SupabaseServiceKey
+		if key == "" {
+			key = cfg.SupabaseAnonKey
+		}
+		client, err := supa.NewClient(cfg.SupabaseURL, key, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create supabase client: %w", err)
+		}
+		i.Supabase = client
+
+		if cfg.SupabaseAuthFederation {
+			i.SupabaseAuth = NewSupabaseAuth(cfg.SupabaseURL, key, &http.Client{Timeout: supabaseTimeout}, cfg.SupabaseAuthCacheTTL)
+		}
+	}
+
+	return i, nil
+}
+
+// Enabled names the configured integrations.
+func (i *Integrations) Enabled() []string {
+	var names []string
+	if i.Supabase != nil {
+		names = append(names, "supabase")
+	}
+	if i.SupabaseAuth != nil {
+		names = append(names, "supabase-auth")
+	}
+	return names
+}
@@ -0,0 +1,208 @@
+package integrations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/services"
+)
+
+// SupabaseAuthProvider is the provider name recorded on identities from
+// Supabase Auth.
+const SupabaseAuthProvider = "supabase"
+
+// ErrSupabaseUnavailable is returned when GoTrue could not be asked about a
+// token, or did not give a usable answer.
+var ErrSupabaseUnavailable = errors.New("supabase auth unavailable")
+
+// SupabaseAuth accepts access tokens issued by Supabase Auth (GoTrue). A token
+// is verified by asking GoTrue for the user it belongs to, so signing keys
+// never have to be shared, and sessions ended in Supabase stop working here
+// once the cached answer expires.
+type SupabaseAuth struct {
+	client   *http.Client
+	apiKey   string
+	issuer   string
+	cacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]supabaseAuthEntry
+}
+
+type supabaseAuthEntry struct {
+	identity  services.ExternalIdentity
+	expiresAt time.Time
+}
+
+// supabaseUser is the part of GoTrue's user object that is used.
+type supabaseUser struct {
+	ID               uuid.UUID      `json:"id"`
+	Email            string         `json:"email"`
+	EmailConfirmedAt *time.Time     `json:"email_confirmed_at"`
+	BannedUntil      *time.Time     `json:"banned_until"`
+	UserMetadata     map[string]any `json:"user_metadata"`
+}
+
+var _ services.IdentityProvider = (*SupabaseAuth)(nil)
+
+// NewSupabaseAuth verifies tokens of the Supabase project at projectURL,
+// sending apiKey and using client for every request to GoTrue, and remembers
+// each verified token for at most cacheTTL.
+func NewSupabaseAuth(projectURL, apiKey string, client *http.Client, cacheTTL time.Duration) *SupabaseAuth {
+	return &SupabaseAuth{
+		client:   client,
+		apiKey:   apiKey,
+		issuer:   strings.TrimRight(projectURL, "/") + "/auth/v1",
+		cacheTTL: cacheTTL,
+		cache:    map[string]supabaseAuthEntry{},
+	}
+}
+
+func (a *SupabaseAuth) Name() string {
+	return SupabaseAuthProvider
+}
+
+func (a *SupabaseAuth) Issues(claims jwt.MapClaims) bool {
+	issuer, err := claims.GetIssuer()
+	return err == nil && issuer == a.issuer
+}
+
+func (a *SupabaseAuth) Verify(token string, claims jwt.MapClaims) (*services.ExternalIdentity, error) {
+	now := time.Now()
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil || !expiresAt.After(now) {
+		return nil, services.ErrInvalidExternalToken
+	}
+
+	key := tokenKey(token)
+	if identity, ok := a.cached(key, now); ok {
+		return identity, nil
+	}
+
+	resp, err := a.getUser(token)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, _ := claims.GetSubject()
+	if resp.ID == uuid.Nil || resp.ID.String() != subject {
+		return nil, services.ErrInvalidExternalToken
+	}
+	if resp.BannedUntil != nil && resp.BannedUntil.After(now) {
+		return nil, services.ErrInvalidExternalToken
+	}
+
+	identity := &services.ExternalIdentity{
+		Provider:      SupabaseAuthProvider,
+		Subject:       subject,
+		Email:         resp.Email,
+		EmailVerified: resp.EmailConfirmedAt != nil,
+		ExpiresAt:     expiresAt.Time,
+	}
+	for _, field := range []string{"full_name", "name"} {
+		if name, ok := resp.UserMetadata[field].(string); ok && name != "" {
+			identity.Name = name
+			break
+		}
+	}
+	// Supabase access tokens usually have no jti; they are then identified by
+	// their hash, so logging out here revokes the presented token.
+	identity.TokenID = uuid.NewSHA1(uuid.NameSpaceOID, []byte(token))
+	if raw, ok := claims["jti"].(string); ok {
+		if id, err := uuid.Parse(raw); err == nil {
+			identity.TokenID = id
+		}
+	}
+	if issuedAt, err := claims.GetIssuedAt(); err == nil && issuedAt != nil {
+		identity.IssuedAt = issuedAt.Time
+	}
+
+	a.store(key, *identity, now)
+	return identity, nil
+}
+
+// getUser asks GoTrue for the user token belongs to. Tokens GoTrue refuses
+// are invalid; rate limiting and server errors say nothing about the token
+// and make GoTrue unavailable instead.
+func (a *SupabaseAuth) getUser(token string) (*supabaseUser, error) {
+	req, err := http.NewRequest(http.MethodGet, a.issuer+"/user", nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSupabaseUnavailable, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("apikey", a.apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSupabaseUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, fmt.Errorf("%w: GET /user returned %s", ErrSupabaseUnavailable, resp.Status)
+	case resp.StatusCode >= 400:
+		// Expired, malformed, revoked, or of a deleted user.
+		return nil, services.ErrInvalidExternalToken
+	default:
+		return nil, fmt.Errorf("%w: GET /user returned %s", ErrSupabaseUnavailable, resp.Status)
+	}
+
+	var user supabaseUser
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, fmt.Errorf("%w: GET /user: %v", ErrSupabaseUnavailable, err)
+	}
+	return &user, nil
+}
+
+// tokenKey identifies token in the cache without keeping the token itself.
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (a *SupabaseAuth) cached(key string, now time.Time) (*services.ExternalIdentity, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entry, ok := a.cache[key]
+	if !ok || !entry.expiresAt.After(now) {
+		return nil, false
+	}
+	identity := entry.identity
+	return &identity, true
+}
+
+// store caches identity until the token expires or cacheTTL passes, and drops
+// entries that have expired.
+func (a *SupabaseAuth) store(key string, identity services.ExternalIdentity, now time.Time) {
+	if a.cacheTTL <= 0 {
+		return
+	}
+	expiresAt := now.Add(a.cacheTTL)
+	if identity.ExpiresAt.Before(expiresAt) {
+		expiresAt = identity.ExpiresAt
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for k, entry := range a.cache {
+		if !entry.expiresAt.After(now) {
+			delete(a.cache, k)
+		}
+	}
+	a.cache[key] = supabaseAuthEntry{identity: identity, expiresAt: expiresAt}
+}
@@ -0,0 +1,277 @@
+package integrations
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/services"
+	"github.com/Anand078/rbac/internal/signing"
+	"github.com/Anand078/rbac/internal/storage/memory"
+)
+
+const testAPIKey = "anon-key"
+
+// fakeGoTrue answers GET /auth/v1/user for the tokens it knows, and counts
+// the requests it receives.
+type fakeGoTrue struct {
+	mu       sync.Mutex
+	users    map[string]any
+	statuses map[string]int
+	requests int
+}
+
+func newFakeGoTrue(t *testing.T) (*fakeGoTrue, *SupabaseAuth, *httptest.Server) {
+	t.Helper()
+	fake := &fakeGoTrue{users: map[string]any{}, statuses: map[string]int{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	auth := NewSupabaseAuth(server.URL+"/", testAPIKey, server.Client(), time.Minute)
+	return fake, auth, server
+}
+
+func (f *fakeGoTrue) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+
+	if r.Method != http.MethodGet || r.URL.Path != "/auth/v1/user" || r.Header.Get("apikey") != testAPIKey {
+		http.Error(w, `{"msg":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if status, ok := f.statuses[token]; ok {
+		http.Error(w, `{"msg":"error"}`, status)
+		return
+	}
+	user, ok := f.users[token]
+	if !ok {
+		http.Error(w, `{"msg":"invalid JWT"}`, http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+func (f *fakeGoTrue) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+// addUser makes token valid for a new Supabase user and returns the token's
+// claims.
+func (f *fakeGoTrue) addUser(auth *SupabaseAuth, token string, user map[string]any) jwt.MapClaims {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := uuid.New().String()
+	user["id"] = id
+	f.users[token] = user
+	return jwt.MapClaims{
+		"iss": auth.issuer,
+		"sub": id,
+		"iat": float64(time.Now().Unix()),
+		"exp": float64(time.Now().Add(time.Hour).Unix()),
+	}
+}
+
+func TestSupabaseAuthVerify(t *testing.T) {
+	fake, auth, _ := newFakeGoTrue(t)
+	confirmed := time.Now().Add(-time.Hour)
+	claims := fake.addUser(auth, "token", map[string]any{
+		"email":              "ada@example.com",
+		"email_confirmed_at": confirmed,
+		"user_metadata":      map[string]any{"full_name": "Ada Lovelace"},
+	})
+
+	if !auth.Issues(claims) {
+		t.Error("Issues rejected a token of the project")
+	}
+	if auth.Issues(jwt.MapClaims{"iss": "https://elsewhere.example.com/auth/v1"}) {
+		t.Error("Issues accepted a token of another issuer")
+	}
+
+	identity, err := auth.Verify("token", claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Provider != SupabaseAuthProvider || identity.Subject != claims["sub"] ||
+		identity.Email != "ada@example.com" || !identity.EmailVerified || identity.Name != "Ada Lovelace" {
+		t.Errorf("Verify = %+v", identity)
+	}
+	if identity.TokenID != uuid.NewSHA1(uuid.NameSpaceOID, []byte("token")) {
+		t.Errorf("TokenID = %v, want the hash of the token", identity.TokenID)
+	}
+
+	// Users provisioned on first sight get the default role, and are found
+	// again by their identity afterwards.
+	store := memory.New()
+	if err := store.SeedDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	roles, err := store.ListRoles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var student models.Role
+	for _, role := range roles {
+		if role.Name == "student" {
+			student = role
+		}
+	}
+	authService := services.NewAuthService(store, signing.NewHMACKeyManager("test-secret"), time.Minute, time.Hour)
+	authService.SetFederatedDefaultRole(student.ID)
+	user, err := authService.ProvisionExternalUser(identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "ada@example.com" || user.Name != "Ada Lovelace" {
+		t.Errorf("provisioned %+v", user)
+	}
+	roleNames, err := store.EffectiveRoles(user.ID, models.GlobalTenantID)
+	if err != nil || len(roleNames) != 1 || roleNames[0].ID != student.ID {
+		t.Errorf("provisioned user roles = %v, %v; want student", roleNames, err)
+	}
+	again, err := authService.ProvisionExternalUser(identity)
+	if err != nil || again.ID != user.ID {
+		t.Errorf("second ProvisionExternalUser = %v, %v; want the same user", again, err)
+	}
+}
+
+func TestSupabaseAuthRejects(t *testing.T) {
+	fake, auth, _ := newFakeGoTrue(t)
+
+	banned := fake.addUser(auth, "banned", map[string]any{
+		"email":        "banned@example.com",
+		"banned_until": time.Now().Add(time.Hour),
+	})
+	bannedBefore := fake.addUser(auth, "formerly-banned", map[string]any{
+		"email":        "pardoned@example.com",
+		"banned_until": time.Now().Add(-time.Hour),
+	})
+	mismatch := fake.addUser(auth, "mismatch", map[string]any{"email": "eve@example.com"})
+	mismatch["sub"] = uuid.New().String()
+	expired := fake.addUser(auth, "expired", map[string]any{"email": "old@example.com"})
+	expired["exp"] = float64(time.Now().Add(-time.Minute).Unix())
+
+	tests := []struct {
+		name   string
+		token  string
+		claims jwt.MapClaims
+	}{
+		{"banned user", "banned", banned},
+		{"subject mismatch", "mismatch", mismatch},
+		{"expired token", "expired", expired},
+		{"unknown token", "unknown", jwt.MapClaims{"iss": auth.issuer, "exp": float64(time.Now().Add(time.Hour).Unix())}},
+	}
+	for _, tt := range tests {
+		if identity, err := auth.Verify(tt.token, tt.claims); !errors.Is(err, services.ErrInvalidExternalToken) {
+			t.Errorf("%s: Verify = %+v, %v; want ErrInvalidExternalToken", tt.name, identity, err)
+		}
+	}
+	if _, err := auth.Verify("formerly-banned", bannedBefore); err != nil {
+		t.Errorf("user whose ban ended: Verify = %v", err)
+	}
+}
+
+func TestSupabaseAuthStatusCodes(t *testing.T) {
+	fake, auth, _ := newFakeGoTrue(t)
+	tests := []struct {
+		status      int
+		unavailable bool
+	}{
+		{http.StatusUnauthorized, false},
+		{http.StatusForbidden, false},
+		{http.StatusNotFound, false},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusBadGateway, true},
+		{http.StatusServiceUnavailable, true},
+	}
+	for _, tt := range tests {
+		claims := fake.addUser(auth, "token", map[string]any{"email": "ada@example.com"})
+		fake.mu.Lock()
+		fake.statuses["token"] = tt.status
+		fake.mu.Unlock()
+
+		_, err := auth.Verify("token", claims)
+		if tt.unavailable {
+			if !errors.Is(err, ErrSupabaseUnavailable) || errors.Is(err, services.ErrInvalidExternalToken) {
+				t.Errorf("status %d: Verify = %v, want ErrSupabaseUnavailable", tt.status, err)
+			}
+		} else if !errors.Is(err, services.ErrInvalidExternalToken) {
+			t.Errorf("status %d: Verify = %v, want ErrInvalidExternalToken", tt.status, err)
+		}
+	}
+
+	// An unreachable GoTrue is unavailable too.
+	unreachable := NewSupabaseAuth("http://127.0.0.1:1", testAPIKey, http.DefaultClient, time.Minute)
+	claims := jwt.MapClaims{"sub": uuid.New().String(), "exp": float64(time.Now().Add(time.Hour).Unix())}
+	if _, err := unreachable.Verify("token", claims); !errors.Is(err, ErrSupabaseUnavailable) {
+		t.Errorf("unreachable: Verify = %v, want ErrSupabaseUnavailable", err)
+	}
+}
+
+func TestSupabaseAuthCache(t *testing.T) {
+	fake, auth, server := newFakeGoTrue(t)
+	claims := fake.addUser(auth, "token", map[string]any{"email": "ada@example.com"})
+
+	for i := 0; i < 3; i++ {
+		if _, err := auth.Verify("token", claims); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := fake.count(); got != 1 {
+		t.Errorf("GoTrue asked %d times within the cache TTL, want 1", got)
+	}
+
+	// Once the entry expires GoTrue is asked again, and sees that the
+	// session ended.
+	auth = NewSupabaseAuth(server.URL, testAPIKey, server.Client(), 50*time.Millisecond)
+	if _, err := auth.Verify("token", claims); err != nil {
+		t.Fatal(err)
+	}
+	fake.mu.Lock()
+	delete(fake.users, "token")
+	fake.mu.Unlock()
+	if _, err := auth.Verify("token", claims); err != nil {
+		t.Errorf("cached token: Verify = %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, err := auth.Verify("token", claims); !errors.Is(err, services.ErrInvalidExternalToken) {
+		t.Errorf("after the cache TTL: Verify = %v, want ErrInvalidExternalToken", err)
+	}
+
+	// Entries never outlive the token.
+	claims = fake.addUser(auth, "short", map[string]any{"email": "bob@example.com"})
+	claims["exp"] = float64(time.Now().Add(time.Second).Unix())
+	auth = NewSupabaseAuth(server.URL, testAPIKey, server.Client(), time.Hour)
+	if _, err := auth.Verify("short", claims); err != nil {
+		t.Fatal(err)
+	}
+	if entry := auth.cache[tokenKey("short")]; entry.expiresAt.After(time.Unix(int64(claims["exp"].(float64)), 0)) {
+		t.Errorf("cache entry expires at %v, after the token", entry.expiresAt)
+	}
+
+	// Without a TTL nothing is cached.
+	auth = NewSupabaseAuth(server.URL, testAPIKey, server.Client(), 0)
+	claims = fake.addUser(auth, "uncached", map[string]any{"email": "carol@example.com"})
+	before := fake.count()
+	for i := 0; i < 2; i++ {
+		if _, err := auth.Verify("uncached", claims); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := fake.count() - before; got != 2 {
+		t.Errorf("GoTrue asked %d times without a cache, want 2", got)
+	}
+}
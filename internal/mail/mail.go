@@ -0,0 +1,48 @@
+// Package mail sends the emails of the account flows, such as email
+// verification and password reset.
+package mail
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// LogMailer writes messages to a writer instead of delivering them, for local
+// development and testing.
+type LogMailer struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+var _ Mailer = (*LogMailer)(nil)
+
+func NewLogMailer(out io.Writer) *LogMailer {
+	return &LogMailer{out: out}
+}
+
+func (m *LogMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.out, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC1123Z), msg.To, msg.Subject, strings.TrimRight(msg.Body, "\n"))
+	if err != nil {
+		return fmt.Errorf("failed to write email to %s: %w", msg.To, err)
+	}
+	return nil
+}
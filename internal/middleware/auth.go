@@ -1,31 +1,74 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 
+	"github.com/Anand078/rbac/internal/models"
 	"github.com/Anand078/rbac/internal/services"
+	"github.com/Anand078/rbac/internal/signing"
 	"github.com/Anand078/rbac/pkg/utils"
 )
 
+// TenantHeader carries the tenant a request is made in. The tenantID path
+// parameter takes precedence over it, and the token's tenant_id claim is used
+// when neither is present.
+const TenantHeader = "X-Tenant-ID"
+
+// APIKeyHeader carries an API key, as an alternative to an
+// "Authorization: ApiKey <key>" header.
+const APIKeyHeader = "X-API-Key"
+
 type AuthMiddleware struct {
-	jwtSecret   string
+	keys        *signing.KeyManager
+	authService *services.AuthService
 	rbacService *services.RBACService
+	aclService  *services.ACLService
+
+	// claimsMode authorizes from the token's authz claim while it is current.
+	claimsMode bool
+
+	// identityProviders authenticate tokens not issued by this service.
+	identityProviders []services.IdentityProvider
 }
 
-func NewAuthMiddleware(jwtSecret string, rbacService *services.RBACService) *AuthMiddleware {
+func NewAuthMiddleware(keys *signing.KeyManager, authService *services.AuthService, rbacService *services.RBACService, aclService *services.ACLService) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwtSecret:   jwtSecret,
+		keys:        keys,
+		authService: authService,
 		rbacService: rbacService,
+		aclService:  aclService,
 	}
 }
 
+// EnableClaimsMode makes Authorize and RequireRole decide from the authz
+// claim embedded by AuthService.EmbedAuthorizationClaims. Tokens without the
+// claim, or whose snapshot is outdated, are checked against the database.
+func (m *AuthMiddleware) EnableClaimsMode() {
+	m.claimsMode = true
+}
+
+// AddIdentityProvider makes Authenticate accept tokens issued by p. Their
+// users are provisioned locally on first use and authorized like local users.
+func (m *AuthMiddleware) AddIdentityProvider(p services.IdentityProvider) {
+	m.identityProviders = append(m.identityProviders, p)
+}
+
 func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if apiKey := apiKeyFromRequest(c); apiKey != "" {
+			m.authenticateAPIKey(c, apiKey)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			utils.ErrorResponse(c, http.StatusUnauthorized, "Authorization header is required")
@@ -34,12 +77,12 @@ func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
 		}
 
 		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(m.jwtSecret), nil
-		})
+		if provider, claims := m.externalIssuer(tokenString); provider != nil {
+			m.authenticateExternal(c, provider, tokenString, claims)
+			return
+		}
+
+		token, err := jwt.Parse(tokenString, m.keys.Keyfunc)
 
 		if err != nil || !token.Valid {
 			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token")
@@ -53,21 +96,204 @@ func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		// Other tokens this service signs, such as MFA challenges, carry a
+		// typ claim and are no access tokens.
+		if _, typed := claims["typ"]; typed {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token")
+			c.Abort()
+			return
+		}
 
-		userID, err := uuid.Parse(claims["user_id"].(string))
+		rawUserID, _ := claims["user_id"].(string)
+		userID, err := uuid.Parse(rawUserID)
 		if err != nil {
 			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid user ID in token")
 			c.Abort()
 			return
 		}
 
+		jtiClaim, _ := claims["jti"].(string)
+		jti, err := uuid.Parse(jtiClaim)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token ID")
+			c.Abort()
+			return
+		}
+
+		issuedAt, err := claims.GetIssuedAt()
+		if err != nil || issuedAt == nil {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token issue time")
+			c.Abort()
+			return
+		}
+
+		if !m.checkRevocation(c, jti, userID, issuedAt.Time) {
+			return
+		}
+
+		if expiresAt, err := claims.GetExpirationTime(); err == nil && expiresAt != nil {
+			c.Set("token_expires_at", expiresAt.Time)
+		}
+		c.Set("jti", jti)
 		c.Set("user_id", userID)
-		c.Set("email", claims["email"].(string))
+		email, _ := claims["email"].(string)
+		c.Set("email", email)
+		if tenantID, ok := claims["tenant_id"].(string); ok {
+			c.Set("token_tenant_id", tenantID)
+		}
+		// Tokens from the client_credentials grant are limited to their
+		// scopes, like API keys.
+		if scope, _ := claims["scope"].(string); scope != "" {
+			c.Set("scopes", strings.Fields(scope))
+		}
+		if clientID, ok := claims["client_id"].(string); ok {
+			c.Set("client_id", clientID)
+		}
+		if authz, ok := services.ParseAuthzClaims(claims[services.AuthzClaimKey]); ok {
+			c.Set("authz_claims", authz)
+		}
 		c.Next()
 	}
 }
 
+// externalIssuer returns the identity provider that claims to have issued
+// tokenString, with its unverified claims, or nil for tokens of this service.
+func (m *AuthMiddleware) externalIssuer(tokenString string) (services.IdentityProvider, jwt.MapClaims) {
+	if len(m.identityProviders) == 0 {
+		return nil, nil
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		return nil, nil
+	}
+	for _, provider := range m.identityProviders {
+		if provider.Issues(claims) {
+			return provider, claims
+		}
+	}
+	return nil, nil
+}
+
+// authenticateExternal has provider verify tokenString and authenticates the
+// request as the local user the identity maps to.
+func (m *AuthMiddleware) authenticateExternal(c *gin.Context, provider services.IdentityProvider, tokenString string, claims jwt.MapClaims) {
+	identity, err := provider.Verify(tokenString, claims)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidExternalToken) {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token")
+		} else {
+			log.Printf("Identity provider %s: %v", provider.Name(), err)
+			utils.ErrorResponse(c, http.StatusBadGateway, "Identity provider unavailable")
+		}
+		c.Abort()
+		return
+	}
+
+	user, err := m.authService.ProvisionExternalUser(identity)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrIdentityConflict):
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrIdentityEmailRequired):
+			utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to provision user")
+		}
+		c.Abort()
+		return
+	}
+
+	if !m.checkRevocation(c, identity.TokenID, user.ID, identity.IssuedAt) {
+		return
+	}
+	if err := m.authService.CheckExternalMFA(identity.Provider, user.ID); err != nil {
+		if errors.Is(err, services.ErrExternalMFARequired) {
+			utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+		} else {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check MFA requirements")
+		}
+		c.Abort()
+		return
+	}
+
+	c.Set("token_expires_at", identity.ExpiresAt)
+	c.Set("jti", identity.TokenID)
+	c.Set("user_id", user.ID)
+	c.Set("email", user.Email)
+	c.Set("identity_provider", provider.Name())
+	c.Next()
+}
+
+func apiKeyFromRequest(c *gin.Context) string {
+	if key := c.GetHeader(APIKeyHeader); key != "" {
+		return key
+	}
+	if key, ok := strings.CutPrefix(c.GetHeader("Authorization"), "ApiKey "); ok {
+		return key
+	}
+	return ""
+}
+
+// authenticateAPIKey authenticates the request as the service account owning
+// the key. Scoped keys store their scopes under "scopes", which Authorize,
+// AuthorizeInstance and RequireRole enforce on top of the account's roles.
+func (m *AuthMiddleware) authenticateAPIKey(c *gin.Context, key string) {
+	apiKey, err := m.authService.AuthenticateAPIKey(key)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAPIKey) {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid API key")
+		} else {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check API key")
+		}
+		c.Abort()
+		return
+	}
+
+	c.Set("user_id", apiKey.ServiceAccountID)
+	c.Set("api_key_id", apiKey.ID)
+	if len(apiKey.Scopes) > 0 {
+		c.Set("scopes", apiKey.Scopes)
+	}
+	c.Next()
+}
+
+// checkScopes aborts the request with 403 when the credential is limited to
+// scopes that do not cover (resource, action).
+func checkScopes(c *gin.Context, resource, action string) bool {
+	value, restricted := c.Get("scopes")
+	if !restricted || services.ScopesAllow(value.([]string), resource, action) {
+		return true
+	}
+	utils.ErrorResponse(c, http.StatusForbidden, "Insufficient scope")
+	c.Abort()
+	return false
+}
+
+// checkRevocation aborts the request unless the token is still valid.
+func (m *AuthMiddleware) checkRevocation(c *gin.Context, jti, userID uuid.UUID, issuedAt time.Time) bool {
+	revoked, err := m.authService.IsTokenRevoked(jti, userID, issuedAt)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check token revocation")
+		c.Abort()
+		return false
+	}
+	if revoked {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Token has been revoked")
+		c.Abort()
+		return false
+	}
+	return true
+}
+
+// Authorize requires the authenticated user to hold a permission covering
+// (resource, action). Granted permissions may use wildcards; the requested
+// pair must be concrete, which is checked when the route is registered.
 func (m *AuthMiddleware) Authorize(resource, action string) gin.HandlerFunc {
+	if err := services.ValidatePermissionPattern(resource, action); err != nil ||
+		strings.Contains(resource, services.Wildcard) || action == services.Wildcard {
+		panic(fmt.Sprintf("middleware: Authorize requires a concrete resource and action, got %q/%q", resource, action))
+	}
+
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
@@ -76,23 +302,184 @@ func (m *AuthMiddleware) Authorize(resource, action string) gin.HandlerFunc {
 			return
 		}
 
-		hasPermission, err := m.rbacService.HasPermission(userID.(uuid.UUID), resource, action)
+		if !checkScopes(c, resource, action) {
+			return
+		}
+
+		tenantID, err := resolveTenant(c)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid tenant ID")
+			c.Abort()
+			return
+		}
+
+		if authz := m.currentClaims(c); authz != nil && tenantID == tokenTenant(c) {
+			if decision, ok := authz.Decide(resource, action); ok {
+				if allowDecision(c, decision) {
+					c.Next()
+				}
+				return
+			}
+		}
+
+		decision, err := m.rbacService.Evaluate(services.AccessRequest{
+			UserID:             userID.(uuid.UUID),
+			TenantID:           tenantID,
+			Resource:           resource,
+			Action:             action,
+			ResourceAttributes: resourceAttributes(c),
+			ClientIP:           c.ClientIP(),
+		})
 		if err != nil {
 			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check permissions")
 			c.Abort()
 			return
 		}
 
-		if !hasPermission {
-			utils.ErrorResponse(c, http.StatusForbidden, "Insufficient permissions")
+		if !allowDecision(c, decision) {
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// AuthorizeInstance requires the authenticated user to be allowed action on
+// the instance of resource named by the param route parameter, through either
+// a type-level permission or an ACL entry on that instance.
+func (m *AuthMiddleware) AuthorizeInstance(resource, action, param string) gin.HandlerFunc {
+	if err := services.ValidatePermissionPattern(resource, action); err != nil ||
+		strings.Contains(resource, services.Wildcard) || action == services.Wildcard {
+		panic(fmt.Sprintf("middleware: AuthorizeInstance requires a concrete resource and action, got %q/%q", resource, action))
+	}
+
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+			c.Abort()
+			return
+		}
+
+		resourceID := c.Param(param)
+		if resourceID == "" {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Missing resource ID")
+			c.Abort()
+			return
+		}
+
+		if !checkScopes(c, resource, action) {
+			return
+		}
+
+		tenantID, err := resolveTenant(c)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid tenant ID")
+			c.Abort()
+			return
+		}
+
+		decision, err := m.aclService.Evaluate(services.AccessRequest{
+			UserID:             userID.(uuid.UUID),
+			TenantID:           tenantID,
+			Resource:           resource,
+			ResourceID:         resourceID,
+			Action:             action,
+			ResourceAttributes: resourceAttributes(c),
+			ClientIP:           c.ClientIP(),
+		})
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check permissions")
 			c.Abort()
 			return
 		}
 
+		if !allowDecision(c, decision) {
+			return
+		}
+
 		c.Next()
 	}
 }
 
+// ResourceAttributesKey is the context key under which a handler-specific
+// middleware placed before Authorize can store the attributes of the resource
+// being accessed (map[string]any), for use by conditional grants.
+const ResourceAttributesKey = "resource_attributes"
+
+func resourceAttributes(c *gin.Context) map[string]any {
+	if value, ok := c.Get(ResourceAttributesKey); ok {
+		if attributes, ok := value.(map[string]any); ok {
+			return attributes
+		}
+	}
+	return nil
+}
+
+// allowDecision aborts the request with 403 unless decision allows access.
+func allowDecision(c *gin.Context, decision services.Decision) bool {
+	switch decision {
+	case services.DecisionAllow:
+		return true
+	case services.DecisionDeny:
+		utils.ErrorResponse(c, http.StatusForbidden, "Access explicitly denied")
+	default:
+		utils.ErrorResponse(c, http.StatusForbidden, "Insufficient permissions")
+	}
+	c.Abort()
+	return false
+}
+
+// currentClaims returns the token's authz snapshot in claims mode, or nil
+// when the request must be authorized against the database.
+func (m *AuthMiddleware) currentClaims(c *gin.Context) *services.AuthzClaims {
+	if !m.claimsMode {
+		return nil
+	}
+	value, ok := c.Get("authz_claims")
+	if !ok {
+		return nil
+	}
+	authz := value.(*services.AuthzClaims)
+	if !m.rbacService.IsCurrent(authz) {
+		return nil
+	}
+	return authz
+}
+
+// tokenTenant is the tenant the token's authz snapshot was taken in.
+func tokenTenant(c *gin.Context) uuid.UUID {
+	tenantID, err := models.ParseTenantID(c.GetString("token_tenant_id"))
+	if err != nil {
+		return models.GlobalTenantID
+	}
+	return tenantID
+}
+
+// resolveTenant determines the tenant a request is made in from the tenantID
+// path parameter, the X-Tenant-ID header or the token's tenant_id claim, in
+// that order. It stores the result under "tenant_id" for downstream handlers.
+func resolveTenant(c *gin.Context) (uuid.UUID, error) {
+	raw := c.Param("tenantID")
+	if raw == "" {
+		raw = c.GetHeader(TenantHeader)
+	}
+	if raw == "" {
+		raw = c.GetString("token_tenant_id")
+	}
+
+	tenantID, err := models.ParseTenantID(raw)
+	if err != nil {
+		return models.GlobalTenantID, err
+	}
+	c.Set("tenant_id", tenantID)
+	return tenantID, nil
+}
+
+// RequireRole requires a globally assigned role (directly or inherited).
+// Tenant-scoped assignments never satisfy it, so a tenant administrator
+// cannot reach global administration routes. Credentials limited to scopes
+// never satisfy it either.
 func (m *AuthMiddleware) RequireRole(roleName string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
@@ -102,7 +489,23 @@ func (m *AuthMiddleware) RequireRole(roleName string) gin.HandlerFunc {
 			return
 		}
 
-		roles, err := m.rbacService.GetUserRoles(userID.(uuid.UUID))
+		if _, restricted := c.Get("scopes"); restricted {
+			utils.ErrorResponse(c, http.StatusForbidden, "Insufficient scope")
+			c.Abort()
+			return
+		}
+
+		if authz := m.currentClaims(c); authz != nil {
+			if !authz.HasRole(roleName) {
+				utils.ErrorResponse(c, http.StatusForbidden, "Insufficient role")
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
+		roles, err := m.rbacService.GetUserRoles(userID.(uuid.UUID), models.GlobalTenantID)
 		if err != nil {
 			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to get user roles")
 			c.Abort()
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/services"
+	"github.com/Anand078/rbac/internal/signing"
+	"github.com/Anand078/rbac/internal/storage/memory"
+)
+
+func TestAPIKeyScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := memory.New()
+	if err := store.SeedDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	keys := signing.NewHMACKeyManager("test-secret")
+	authService := services.NewAuthService(store, keys, time.Minute, time.Hour)
+	rbacService := services.NewRBACService(store)
+	auth := NewAuthMiddleware(keys, authService, rbacService, services.NewACLService(store, rbacService))
+
+	role, err := rbacService.CreateRole(models.CreateRoleRequest{Name: "batch"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []models.CreatePermissionRequest{
+		{Name: "course_read", Resource: "course", Action: "read"},
+		{Name: "grades_read", Resource: "grades", Action: "read"},
+	} {
+		permission, err := rbacService.CreatePermission(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rbacService.GrantPermission(role.ID, permission.ID, models.EffectAllow, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	roles, err := store.ListRoles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := authService.CreateServiceAccount(models.CreateServiceAccountRequest{Name: "batch", RoleID: role.ID.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range roles {
+		if r.Name == "admin" {
+			if err := rbacService.AssignRole(models.RoleAssignment{UserID: account.ID, RoleID: r.ID, TenantID: models.GlobalTenantID}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	key := func(scopes ...string) string {
+		t.Helper()
+		created, err := authService.CreateAPIKey(account.ID, models.CreateAPIKeyRequest{Name: uuid.NewString(), Scopes: scopes})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return created.Key
+	}
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+
+	router := gin.New()
+	router.Use(auth.Authenticate())
+	router.GET("/courses", auth.Authorize("course", "read"), ok)
+	router.GET("/grades", auth.Authorize("grades", "read"), ok)
+	router.GET("/admin", auth.RequireRole("admin"), ok)
+
+	tests := []struct {
+		name string
+		key  string
+		path string
+		want int
+	}{
+		{"unscoped key", key(), "/courses", http.StatusOK},
+		{"unscoped key", key(), "/grades", http.StatusOK},
+		{"unscoped key", key(), "/admin", http.StatusOK},
+		{"scoped key within its scope", key("course:read"), "/courses", http.StatusOK},
+		{"scoped key outside its scope", key("course:read"), "/grades", http.StatusForbidden},
+		{"scoped key on an admin route", key("course:read"), "/admin", http.StatusForbidden},
+		{"key with several scopes", key("course:read", "grades:read"), "/grades", http.StatusOK},
+		{"unknown key", "rbk_000000000000_secret", "/courses", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set(APIKeyHeader, tt.key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s on %s: %d, want %d", tt.name, tt.path, w.Code, tt.want)
+		}
+	}
+}
+
+func TestAuthorizeInstance(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := memory.New()
+	keys := signing.NewHMACKeyManager("test-secret")
+	authService := services.NewAuthService(store, keys, time.Minute, time.Hour)
+	rbacService := services.NewRBACService(store)
+	aclService := services.NewACLService(store, rbacService)
+	auth := NewAuthMiddleware(keys, authService, rbacService, aclService)
+
+	token := func(email string) (uuid.UUID, string) {
+		t.Helper()
+		user, err := authService.Register(models.CreateUserRequest{Email: email, Name: email, Password: "correct horse"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, _, err := authService.Login(models.LoginRequest{Email: email, Password: "correct horse"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user.ID, response.Token
+	}
+	guestID, guest := token("guest@example.com")
+	if _, err := aclService.CreateEntry(models.CreateACLEntryRequest{
+		Resource: "course", ResourceID: "42", SubjectType: models.SubjectUser,
+		SubjectID: guestID.String(), Action: "update",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	_, stranger := token("stranger@example.com")
+
+	router := gin.New()
+	router.Use(auth.Authenticate())
+	router.PUT("/courses/:courseID", auth.AuthorizeInstance("course", "update", "courseID"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name  string
+		token string
+		path  string
+		want  int
+	}{
+		{"instance of the entry", guest, "/courses/42", http.StatusOK},
+		{"another instance", guest, "/courses/43", http.StatusForbidden},
+		{"instance ID with a suffix", guest, "/courses/420", http.StatusForbidden},
+		{"user without an entry", stranger, "/courses/42", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPut, tt.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: PUT %s = %d, want %d", tt.name, tt.path, w.Code, tt.want)
+		}
+	}
+}
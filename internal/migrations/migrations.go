@@ -0,0 +1,338 @@
+// Package migrations applies the versioned schema embedded in the binary.
+// Each dialect has its own directory of <version>_<name>.up.sql and
+// <version>_<name>.down.sql files; applied versions are recorded in the
+// schema_migrations table.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+const (
+	DialectPostgres = "postgres"
+	DialectSQLite   = "sqlite"
+)
+
+// lockKey identifies the Postgres advisory lock held while migrating.
+const lockKey = 4_172_093_517
+
+// baselineVersion is the last migration that the SQL script in
+// docs/database_schema_design.md already covers. Databases created from that
+// script before migrations existed are recorded at this version instead of
+// having it applied again.
+const baselineVersion = 2
+
+// baselineTables are the tables migration 0001 creates. A database without
+// migration history is only baselined when all of them exist; a schema built
+// from an older version of the script lacks some and is refused rather than
+// recorded as up to date.
+var baselineTables = []string{
+	"users", "roles", "permissions", "user_roles", "user_roles_archive", "role_permissions",
+	"role_parents", "acl_entries", "refresh_tokens", "revoked_tokens", "policy_version",
+}
+
+// ErrUnknownSchema is returned when the database holds tables but neither a
+// migration history nor the complete baseline schema.
+var ErrUnknownSchema = errors.New("existing schema predates the baseline migrations")
+
+type dialect struct {
+	createTable string
+	// lock and unlock bracket a run; empty when the database serializes
+	// writers on its own.
+	lock, unlock string
+	// tableExists reports whether the table named by its argument exists.
+	tableExists string
+}
+
+var dialects = map[string]dialect{
+	DialectPostgres: {
+		createTable: `
+            CREATE TABLE IF NOT EXISTS schema_migrations (
+                version BIGINT PRIMARY KEY,
+                name VARCHAR(255) NOT NULL,
+                applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+            )
+        `,
+		lock:        fmt.Sprintf(`SELECT pg_advisory_lock(%d)`, lockKey),
+		unlock:      fmt.Sprintf(`SELECT pg_advisory_unlock(%d)`, lockKey),
+		tableExists: `SELECT to_regclass($1) IS NOT NULL`,
+	},
+	DialectSQLite: {
+		createTable: `
+            CREATE TABLE IF NOT EXISTS schema_migrations (
+                version INTEGER PRIMARY KEY,
+                name TEXT NOT NULL,
+                applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+            )
+        `,
+		tableExists: `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = $1)`,
+	},
+}
+
+type Migration struct {
+	Version int64
+	Name    string
+	up      string
+	down    string
+}
+
+// MigrationStatus is a migration and when it was applied, if it was.
+type MigrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    dialect
+	migrations []Migration
+}
+
+func New(db *sql.DB, dialectName string) (*Migrator, error) {
+	d, ok := dialects[dialectName]
+	if !ok {
+		return nil, fmt.Errorf("unsupported migration dialect %q", dialectName)
+	}
+	migrations, err := load(dialectName)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: d, migrations: migrations}, nil
+}
+
+// load reads the migrations of a dialect, ordered by version.
+func load(dialectName string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dialectName)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s: expected <version>_<name>.up.sql or .down.sql", name)
+		}
+		rawVersion, title, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(rawVersion, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", name, err)
+		}
+
+		contents, err := fs.ReadFile(files, path.Join(dialectName, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.up = string(contents)
+		} else {
+			m.down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// session runs fn on a single connection holding the migration lock, after
+// making sure the migrations table exists.
+func (m *Migrator) session(ctx context.Context, fn func(conn *sql.Conn, applied map[int64]time.Time) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.dialect.lock != "" {
+		if _, err := conn.ExecContext(ctx, m.dialect.lock); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer func() {
+			if _, err := conn.ExecContext(context.Background(), m.dialect.unlock); err != nil {
+				log.Printf("Failed to release migration lock: %v", err)
+			}
+		}()
+	}
+
+	var missing []string
+	for _, table := range baselineTables {
+		var exists bool
+		if err := conn.QueryRowContext(ctx, m.dialect.tableExists, table).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to inspect schema: %w", err)
+		}
+		if !exists {
+			missing = append(missing, table)
+		}
+	}
+	existing := len(missing) < len(baselineTables)
+
+	if _, err := conn.ExecContext(ctx, m.dialect.createTable); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if existing && len(applied) == 0 {
+		if len(missing) > 0 {
+			return fmt.Errorf("%w: tables %s are missing; bring the schema up to migrations 0001 and 0002 by hand or migrate an empty database",
+				ErrUnknownSchema, strings.Join(missing, ", "))
+		}
+		if err := m.baseline(ctx, conn); err != nil {
+			return err
+		}
+		if applied, err = appliedVersions(ctx, conn); err != nil {
+			return err
+		}
+	}
+
+	return fn(conn, applied)
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var (
+			version   int64
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// baseline records the migrations up to baselineVersion as applied on a
+// database whose schema was created by hand.
+func (m *Migrator) baseline(ctx context.Context, conn *sql.Conn) error {
+	log.Printf("Existing schema without migration history; recording migrations up to %d as applied", baselineVersion)
+	for _, migration := range m.migrations {
+		if migration.Version > baselineVersion {
+			break
+		}
+		_, err := conn.ExecContext(ctx,
+			`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+			migration.Version, migration.Name)
+		if err != nil {
+			return fmt.Errorf("failed to baseline migration %d: %w", migration.Version, err)
+		}
+	}
+	return nil
+}
+
+// run executes script and records the change in one transaction.
+func run(ctx context.Context, conn *sql.Conn, script, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Up applies every pending migration in order and returns the ones applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.session(ctx, func(conn *sql.Conn, applied map[int64]time.Time) error {
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			err := run(ctx, conn, migration.up,
+				`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+				migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns
+// the ones reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.session(ctx, func(conn *sql.Conn, applied map[int64]time.Time) error {
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.down == "" {
+				return fmt.Errorf("migration %d_%s cannot be reverted", migration.Version, migration.Name)
+			}
+			err := run(ctx, conn, migration.down,
+				`DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+			if err != nil {
+				return fmt.Errorf("reverting migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status lists every known migration and when it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.session(ctx, func(_ *sql.Conn, applied map[int64]time.Time) error {
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if appliedAt, ok := applied[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
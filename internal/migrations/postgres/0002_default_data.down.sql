@@ -0,0 +1,9 @@
+-- Junction rows referencing the default roles and permissions go with them.
+DELETE FROM permissions WHERE name IN (
+    'create_course', 'view_course', 'update_course', 'delete_course',
+    'view_grades', 'update_grades',
+    'view_students', 'manage_students',
+    'manage_users', 'assign_roles',
+    'view_analytics', 'manage_settings'
+);
+DELETE FROM roles WHERE name IN ('student', 'teacher', 'admin');
@@ -0,0 +1,61 @@
+-- Insert default roles
+INSERT INTO roles (name, description) VALUES 
+    ('student', 'Student role with basic access to courses and grades'),
+    ('teacher', 'Teacher role with course management and grading access'),
+    ('admin', 'Administrator role with full system access');
+
+-- Insert default permissions
+INSERT INTO permissions (name, resource, action, description) VALUES 
+    -- Course permissions
+    ('create_course', 'course', 'create', 'Create new courses'),
+    ('view_course', 'course', 'read', 'View course details and content'),
+    ('update_course', 'course', 'update', 'Update course information and content'),
+    ('delete_course', 'course', 'delete', 'Delete courses permanently'),
+    
+    -- Grade permissions
+    ('view_grades', 'grades', 'read', 'View student grades and transcripts'),
+    ('update_grades', 'grades', 'update', 'Update and manage student grades'),
+    
+    -- Student management permissions
+    ('view_students', 'students', 'read', 'View student profiles and information'),
+    ('manage_students', 'students', 'manage', 'Full student management capabilities'),
+    
+    -- User management permissions
+    ('manage_users', 'users', 'manage', 'Create, update, and delete user accounts'),
+    ('assign_roles', 'users', 'assign_roles', 'Assign and remove user roles'),
+    
+    -- System permissions
+    ('view_analytics', 'analytics', 'read', 'View system analytics and reports'),
+    ('manage_settings', 'settings', 'manage', 'Manage system settings and configuration');
+
+-- Assign permissions to roles
+-- Student role permissions
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'student' 
+AND p.name IN ('view_course', 'view_grades');
+
+-- Teacher role permissions (student permissions are inherited)
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'teacher' 
+AND p.name IN (
+    'create_course', 'update_course',
+    'update_grades', 'view_students',
+    'view_analytics'
+);
+
+-- Admin role permissions (teacher and student permissions are inherited)
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'admin'
+AND p.name IN (
+    'delete_course', 'manage_students', 'manage_users',
+    'assign_roles', 'manage_settings'
+);
+
+-- Role hierarchy: admin > teacher > student
+INSERT INTO role_parents (role_id, parent_role_id)
+SELECT c.id, p.id FROM roles c, roles p
+WHERE (c.name = 'teacher' AND p.name = 'student')
+   OR (c.name = 'admin' AND p.name = 'teacher');
@@ -0,0 +1,13 @@
+-- Roles given to the members of identity provider groups
+CREATE TABLE group_role_mappings (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    provider VARCHAR(50) NOT NULL DEFAULT '',
+    group_name VARCHAR(255) NOT NULL,
+    role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, group_name, role_id, tenant_id)
+);
+
+-- Provider whose group sync maintains an assignment; NULL for manual ones
+ALTER TABLE user_roles ADD COLUMN managed_by VARCHAR(50);
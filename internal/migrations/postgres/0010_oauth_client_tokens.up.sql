@@ -0,0 +1,10 @@
+-- Access tokens issued to OAuth clients, kept until they expire so that
+-- deleting a client revokes exactly the tokens it obtained
+CREATE TABLE oauth_client_tokens (
+    jti UUID PRIMARY KEY,
+    client_id UUID NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_oauth_client_tokens_client_id ON oauth_client_tokens(client_id);
+CREATE INDEX idx_oauth_client_tokens_expires_at ON oauth_client_tokens(expires_at);
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS policy_version;
+DROP TABLE IF EXISTS revoked_tokens;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS acl_entries;
+DROP TABLE IF EXISTS role_parents;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS user_roles_archive;
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,119 @@
+-- SQLite version of postgres/0001_initial_schema.up.sql. UUIDs are stored
+-- as text and timestamps as fixed-width UTC text (see storage/sqlite).
+
+CREATE TABLE users (
+    id TEXT PRIMARY KEY,
+    email TEXT UNIQUE NOT NULL,
+    name TEXT NOT NULL,
+    password_hash TEXT NOT NULL,
+    attributes TEXT NOT NULL DEFAULT '{}',
+    tokens_valid_after TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE roles (
+    id TEXT PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE permissions (
+    id TEXT PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL,
+    resource TEXT NOT NULL,
+    action TEXT NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE user_roles (
+    user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+    role_id TEXT REFERENCES roles(id) ON DELETE CASCADE,
+    tenant_id TEXT NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000',
+    valid_from TIMESTAMP,
+    valid_until TIMESTAMP,
+    assigned_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, role_id, tenant_id),
+    CHECK (valid_until IS NULL OR valid_from IS NULL OR valid_until > valid_from)
+);
+
+CREATE TABLE user_roles_archive (
+    user_id TEXT NOT NULL,
+    role_id TEXT NOT NULL,
+    tenant_id TEXT NOT NULL,
+    valid_from TIMESTAMP,
+    valid_until TIMESTAMP,
+    assigned_at TIMESTAMP,
+    archived_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE role_permissions (
+    role_id TEXT REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id TEXT REFERENCES permissions(id) ON DELETE CASCADE,
+    effect TEXT NOT NULL DEFAULT 'allow' CHECK (effect IN ('allow', 'deny')),
+    condition TEXT,
+    granted_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE TABLE role_parents (
+    role_id TEXT REFERENCES roles(id) ON DELETE CASCADE,
+    parent_role_id TEXT REFERENCES roles(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (role_id, parent_role_id),
+    CHECK (role_id <> parent_role_id)
+);
+
+CREATE TABLE acl_entries (
+    id TEXT PRIMARY KEY,
+    resource TEXT NOT NULL,
+    resource_id TEXT NOT NULL,
+    subject_type TEXT NOT NULL CHECK (subject_type IN ('user', 'role')),
+    subject_id TEXT NOT NULL,
+    action TEXT NOT NULL,
+    effect TEXT NOT NULL DEFAULT 'allow' CHECK (effect IN ('allow', 'deny')),
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (resource, resource_id, subject_type, subject_id, action)
+);
+
+CREATE TABLE refresh_tokens (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tenant_id TEXT NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000',
+    family_id TEXT NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE revoked_tokens (
+    jti TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE policy_version (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    version INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT INTO policy_version (id) VALUES (1);
+
+CREATE INDEX idx_user_roles_user_id ON user_roles(user_id);
+CREATE INDEX idx_user_roles_role_id ON user_roles(role_id);
+CREATE INDEX idx_user_roles_tenant_id ON user_roles(tenant_id);
+CREATE INDEX idx_user_roles_valid_until ON user_roles(valid_until) WHERE valid_until IS NOT NULL;
+CREATE INDEX idx_user_roles_archive_user_id ON user_roles_archive(user_id);
+CREATE INDEX idx_role_permissions_role_id ON role_permissions(role_id);
+CREATE INDEX idx_role_permissions_permission_id ON role_permissions(permission_id);
+CREATE INDEX idx_permissions_resource_action ON permissions(resource, action);
+CREATE INDEX idx_role_parents_parent_role_id ON role_parents(parent_role_id);
+CREATE INDEX idx_acl_entries_resource ON acl_entries(resource, resource_id);
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
@@ -0,0 +1,49 @@
+-- Same data as postgres/0002_default_data.up.sql. IDs are version 4 UUIDs
+-- built from random bytes, and timestamps use the store's fixed-width layout.
+
+INSERT INTO roles (id, name, description, created_at)
+SELECT lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-'
+           || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+       column1, column2, strftime('%Y-%m-%d %H:%M:%f000000', 'now')
+FROM (VALUES
+    ('student', 'Student role with basic access to courses and grades'),
+    ('teacher', 'Teacher role with course management and grading access'),
+    ('admin', 'Administrator role with full system access')
+);
+
+INSERT INTO permissions (id, name, resource, action, description, created_at)
+SELECT lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-'
+           || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+       column1, column2, column3, column4, strftime('%Y-%m-%d %H:%M:%f000000', 'now')
+FROM (VALUES
+    ('create_course', 'course', 'create', 'Create new courses'),
+    ('view_course', 'course', 'read', 'View course details and content'),
+    ('update_course', 'course', 'update', 'Update course information and content'),
+    ('delete_course', 'course', 'delete', 'Delete courses permanently'),
+    ('view_grades', 'grades', 'read', 'View student grades and transcripts'),
+    ('update_grades', 'grades', 'update', 'Update and manage student grades'),
+    ('view_students', 'students', 'read', 'View student profiles and information'),
+    ('manage_students', 'students', 'manage', 'Full student management capabilities'),
+    ('manage_users', 'users', 'manage', 'Create, update, and delete user accounts'),
+    ('assign_roles', 'users', 'assign_roles', 'Assign and remove user roles'),
+    ('view_analytics', 'analytics', 'read', 'View system analytics and reports'),
+    ('manage_settings', 'settings', 'manage', 'Manage system settings and configuration')
+);
+
+INSERT INTO role_permissions (role_id, permission_id, granted_at)
+SELECT r.id, p.id, strftime('%Y-%m-%d %H:%M:%f000000', 'now') FROM roles r, permissions p
+WHERE (r.name = 'student' AND p.name IN ('view_course', 'view_grades'))
+   OR (r.name = 'teacher' AND p.name IN (
+       'create_course', 'update_course',
+       'update_grades', 'view_students',
+       'view_analytics'
+   ))
+   OR (r.name = 'admin' AND p.name IN (
+       'delete_course', 'manage_students', 'manage_users',
+       'assign_roles', 'manage_settings'
+   ));
+
+INSERT INTO role_parents (role_id, parent_role_id, created_at)
+SELECT c.id, p.id, strftime('%Y-%m-%d %H:%M:%f000000', 'now') FROM roles c, roles p
+WHERE (c.name = 'teacher' AND p.name = 'student')
+   OR (c.name = 'admin' AND p.name = 'teacher');
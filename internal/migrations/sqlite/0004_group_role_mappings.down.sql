@@ -0,0 +1,2 @@
+ALTER TABLE user_roles DROP COLUMN managed_by;
+DROP TABLE IF EXISTS group_role_mappings;
@@ -0,0 +1,11 @@
+CREATE TABLE group_role_mappings (
+    id TEXT PRIMARY KEY,
+    provider TEXT NOT NULL DEFAULT '',
+    group_name TEXT NOT NULL,
+    role_id TEXT NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    tenant_id TEXT NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000',
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (provider, group_name, role_id, tenant_id)
+);
+
+ALTER TABLE user_roles ADD COLUMN managed_by TEXT;
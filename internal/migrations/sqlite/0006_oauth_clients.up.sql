@@ -0,0 +1,10 @@
+CREATE TABLE oauth_clients (
+    id TEXT PRIMARY KEY,
+    service_account_id TEXT NOT NULL REFERENCES service_accounts(user_id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    secret_hash TEXT NOT NULL,
+    scopes TEXT NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_oauth_clients_service_account_id ON oauth_clients(service_account_id);
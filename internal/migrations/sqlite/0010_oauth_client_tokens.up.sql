@@ -0,0 +1,8 @@
+CREATE TABLE oauth_client_tokens (
+    jti TEXT PRIMARY KEY,
+    client_id TEXT NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_oauth_client_tokens_client_id ON oauth_client_tokens(client_id);
+CREATE INDEX idx_oauth_client_tokens_expires_at ON oauth_client_tokens(expires_at);
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SubjectType identifies who an ACL entry grants access to.
+type SubjectType string
+
+const (
+	SubjectUser SubjectType = "user"
+	SubjectRole SubjectType = "role"
+)
+
+// ACLEntry grants or denies an action on a single resource instance, such as
+// "update" on course 42, to a user or to every holder of a role.
+type ACLEntry struct {
+	ID          uuid.UUID   `json:"id" db:"id"`
+	Resource    string      `json:"resource" db:"resource"`
+	ResourceID  string      `json:"resource_id" db:"resource_id"`
+	SubjectType SubjectType `json:"subject_type" db:"subject_type"`
+	SubjectID   uuid.UUID   `json:"subject_id" db:"subject_id"`
+	Action      string      `json:"action" db:"action"`
+	Effect      Effect      `json:"effect" db:"effect"`
+	CreatedAt   time.Time   `json:"created_at" db:"created_at"`
+}
+
+type CreateACLEntryRequest struct {
+	Resource    string      `json:"resource" binding:"required"`
+	ResourceID  string      `json:"resource_id" binding:"required"`
+	SubjectType SubjectType `json:"subject_type" binding:"required,oneof=user role"`
+	SubjectID   string      `json:"subject_id" binding:"required"`
+	Action      string      `json:"action" binding:"required"`
+	Effect      Effect      `json:"effect" binding:"omitempty,oneof=allow deny"`
+}
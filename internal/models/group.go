@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GroupRoleMapping gives the members of an identity provider group a role,
+// within TenantID or globally. An empty Provider matches the group at every
+// provider.
+type GroupRoleMapping struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	Provider  string    `json:"provider,omitempty" db:"provider"`
+	Group     string    `json:"group" db:"group_name"`
+	RoleID    uuid.UUID `json:"role_id" db:"role_id"`
+	TenantID  uuid.UUID `json:"tenant_id" db:"tenant_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type CreateGroupRoleMappingRequest struct {
+	Provider string `json:"provider"`
+	Group    string `json:"group" binding:"required"`
+	RoleID   string `json:"role_id" binding:"required"`
+	TenantID string `json:"tenant_id"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TOTPFactor is a user's authenticator app. It counts for MFA once confirmed.
+type TOTPFactor struct {
+	UserID      uuid.UUID  `json:"user_id"`
+	Secret      string     `json:"-"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+	// LastUsedStep is the time step of the last accepted code; codes of
+	// that or an earlier step are rejected as replays.
+	LastUsedStep   int64      `json:"-"`
+	FailedAttempts int        `json:"-"`
+	LockedUntil    *time.Time `json:"-"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth:// URI to show as a QR code.
+	URI string `json:"otpauth_uri"`
+}
+
+type MFAStatus struct {
+	Enrolled               bool       `json:"enrolled"`
+	ConfirmedAt            *time.Time `json:"confirmed_at,omitempty"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+	// Passkeys counts the user's WebAuthn credentials, which serve as a
+	// second factor too.
+	Passkeys int `json:"passkeys"`
+}
+
+// MFAChallenge is returned by a password login that needs a second factor.
+// MFAToken stands for the verified password until ExpiresIn seconds pass.
+type MFAChallenge struct {
+	MFAToken  string `json:"mfa_token"`
+	ExpiresIn int64  `json:"expires_in"`
+	// Methods lists the second factors the user can complete the login
+	// with: totp (which accepts recovery codes too) and passkey.
+	Methods []string `json:"methods,omitempty"`
+	// EnrollmentRequired means the user holds a role that requires MFA but
+	// has not enrolled yet; the token can only be used to enroll.
+	EnrollmentRequired bool `json:"enrollment_required,omitempty"`
+}
+
+// MFACodeRequest carries a TOTP code or a recovery code.
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type MFATokenRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// MFAEnrollmentResponse holds the recovery codes created when an enrollment
+// is confirmed, and the login it completes when it was required.
+type MFAEnrollmentResponse struct {
+	RecoveryCodes []string       `json:"recovery_codes"`
+	Login         *LoginResponse `json:"login,omitempty"`
+}
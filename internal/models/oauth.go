@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OAuthClient is an OAuth2 client acting as a service account through the
+// client_credentials grant. Its ID is the client_id; only a hash of the
+// client secret is stored.
+type OAuthClient struct {
+	ID               uuid.UUID `json:"client_id" db:"id"`
+	ServiceAccountID uuid.UUID `json:"service_account_id" db:"service_account_id"`
+	Name             string    `json:"name" db:"name"`
+	SecretHash       string    `json:"-" db:"secret_hash"`
+	// Scopes are the "resource:action" permission patterns the client may
+	// request; a client has at least one. "*:*" lets it act with all of the
+	// account's permissions.
+	Scopes    []string  `json:"scopes" db:"scopes"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type CreateOAuthClientRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes"`
+}
+
+// CreateOAuthClientResponse carries the client secret, which cannot be
+// retrieved again.
+type CreateOAuthClientResponse struct {
+	ClientSecret string      `json:"client_secret"`
+	Client       OAuthClient `json:"client"`
+}
+
+// OAuthTokenResponse is a successful access token response (RFC 6749,
+// section 5.1).
+type OAuthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+// TokenIntrospection is a token introspection response (RFC 7662). Only
+// Active is set for tokens that are not active.
+type TokenIntrospection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenID   string `json:"jti,omitempty"`
+	TenantID  string `json:"tenant_id,omitempty"`
+}
@@ -6,12 +6,23 @@ import (
 	"github.com/google/uuid"
 )
 
+// Effect decides whether a granted permission allows or denies access. A
+// matching deny on any of a user's roles overrides every allow.
+type Effect string
+
+const (
+	EffectAllow Effect = "allow"
+	EffectDeny  Effect = "deny"
+)
+
 type Permission struct {
 	ID          uuid.UUID `json:"id" db:"id"`
 	Name        string    `json:"name" db:"name"`
 	Resource    string    `json:"resource" db:"resource"`
 	Action      string    `json:"action" db:"action"`
 	Description string    `json:"description" db:"description"`
+	Effect      Effect    `json:"effect,omitempty" db:"effect"`
+	Condition   string    `json:"condition,omitempty" db:"condition"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 }
 
@@ -25,4 +36,8 @@ type CreatePermissionRequest struct {
 type GrantPermissionRequest struct {
 	RoleID       string `json:"role_id" binding:"required"`
 	PermissionID string `json:"permission_id" binding:"required"`
+	Effect       Effect `json:"effect" binding:"omitempty,oneof=allow deny"`
+	// Condition optionally restricts the grant to requests whose user,
+	// resource and request attributes satisfy the expression.
+	Condition string `json:"condition"`
 }
@@ -11,15 +11,59 @@ type Role struct {
 	Name        string       `json:"name" db:"name"`
 	Description string       `json:"description" db:"description"`
 	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+	ParentIDs   []uuid.UUID  `json:"parent_ids,omitempty"`
 	Permissions []Permission `json:"permissions,omitempty"`
 }
 
 type CreateRoleRequest struct {
-	Name        string `json:"name" binding:"required"`
-	Description string `json:"description"`
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	ParentIDs   []string `json:"parent_ids"`
+}
+
+type AddParentRoleRequest struct {
+	ParentRoleID string `json:"parent_role_id" binding:"required"`
+}
+
+// GlobalTenantID scopes an assignment to every tenant. Tenant-scoped
+// assignments only apply to requests made within that tenant.
+var GlobalTenantID = uuid.Nil
+
+// ParseTenantID parses an optional tenant ID, treating an empty string as the
+// global scope.
+func ParseTenantID(raw string) (uuid.UUID, error) {
+	if raw == "" {
+		return GlobalTenantID, nil
+	}
+	return uuid.Parse(raw)
+}
+
+// RoleAssignment is a row of user_roles. An assignment is active between
+// ValidFrom and ValidUntil; a nil bound is open-ended. ManagedBy names the
+// identity provider whose group memberships maintain the assignment, and is
+// empty for assignments made by hand.
+type RoleAssignment struct {
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	RoleID     uuid.UUID  `json:"role_id" db:"role_id"`
+	TenantID   uuid.UUID  `json:"tenant_id" db:"tenant_id"`
+	ValidFrom  *time.Time `json:"valid_from,omitempty" db:"valid_from"`
+	ValidUntil *time.Time `json:"valid_until,omitempty" db:"valid_until"`
+	AssignedAt time.Time  `json:"assigned_at" db:"assigned_at"`
+	ManagedBy  string     `json:"managed_by,omitempty" db:"managed_by"`
+}
+
+// IsActive reports whether the assignment applies at t.
+func (a RoleAssignment) IsActive(t time.Time) bool {
+	if a.ValidFrom != nil && t.Before(*a.ValidFrom) {
+		return false
+	}
+	return a.ValidUntil == nil || t.Before(*a.ValidUntil)
 }
 
 type AssignRoleRequest struct {
-	UserID string `json:"user_id" binding:"required"`
-	RoleID string `json:"role_id" binding:"required"`
+	UserID     string     `json:"user_id" binding:"required"`
+	RoleID     string     `json:"role_id" binding:"required"`
+	TenantID   string     `json:"tenant_id"`
+	ValidFrom  *time.Time `json:"valid_from"`
+	ValidUntil *time.Time `json:"valid_until"`
 }
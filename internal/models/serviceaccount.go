@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ServiceAccountDomain is the email domain of service account users. The
+// reserved .invalid TLD keeps it apart from every real mailbox.
+const ServiceAccountDomain = "service-accounts.invalid"
+
+// ServiceAccount is a user for machine-to-machine access. It holds roles like
+// any other user but cannot sign in with a password; it authenticates with
+// API keys.
+type ServiceAccount struct {
+	ID          uuid.UUID `json:"id" db:"user_id"`
+	Name        string    `json:"name" db:"name"`
+	Email       string    `json:"email" db:"email"`
+	Description string    `json:"description,omitempty" db:"description"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+type CreateServiceAccountRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	RoleID      string `json:"role_id"`
+}
+
+// APIKey is a long-lived credential of a service account. Only a hash of the
+// key is stored; Prefix is the non-secret start of the key that identifies it.
+type APIKey struct {
+	ID               uuid.UUID `json:"id" db:"id"`
+	ServiceAccountID uuid.UUID `json:"service_account_id" db:"service_account_id"`
+	Name             string    `json:"name" db:"name"`
+	Prefix           string    `json:"prefix" db:"prefix"`
+	KeyHash          string    `json:"-" db:"key_hash"`
+	// Scopes limit the key to the account's permissions matching these
+	// "resource:action" patterns. A key without scopes has all of them.
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreateAPIKeyResponse carries the key itself, which cannot be retrieved
+// again.
+type CreateAPIKeyResponse struct {
+	Key    string `json:"key"`
+	APIKey APIKey `json:"api_key"`
+}
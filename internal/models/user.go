@@ -7,13 +7,15 @@ import (
 )
 
 type User struct {
-	ID           uuid.UUID `json:"id" db:"id"`
-	Email        string    `json:"email" db:"email"`
-	Name         string    `json:"name" db:"name"`
-	PasswordHash string    `json:"-" db:"password_hash"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
-	Roles        []Role    `json:"roles,omitempty"`
+	ID              uuid.UUID      `json:"id" db:"id"`
+	Email           string         `json:"email" db:"email"`
+	Name            string         `json:"name" db:"name"`
+	PasswordHash    string         `json:"-" db:"password_hash"`
+	Attributes      map[string]any `json:"attributes,omitempty" db:"attributes"`
+	EmailVerifiedAt *time.Time     `json:"email_verified_at,omitempty" db:"email_verified_at"`
+	CreatedAt       time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at" db:"updated_at"`
+	Roles           []Role         `json:"roles,omitempty"`
 }
 
 type CreateUserRequest struct {
@@ -26,9 +28,39 @@ type CreateUserRequest struct {
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+	TenantID string `json:"tenant_id"`
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// ExpiresIn is the lifetime of Token in seconds.
+	ExpiresIn int64 `json:"expires_in,omitempty"`
+	User      User  `json:"user"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type UpdateUserAttributesRequest struct {
+	Attributes map[string]any `json:"attributes" binding:"required"`
+}
+
+type LogoutRequest struct {
+	// RefreshToken, when given, also ends the session it belongs to.
+	RefreshToken string `json:"refresh_token"`
+}
+
+type EmailRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
 }
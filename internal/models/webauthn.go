@@ -0,0 +1,138 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebAuthnCredential is a passkey or security key registered by a user.
+type WebAuthnCredential struct {
+	// ID is the base64url credential ID.
+	ID     string    `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+	Name   string    `json:"name"`
+	// PublicKey is the credential's COSE key.
+	PublicKey []byte `json:"-"`
+	// SignCount is the signature counter of the last assertion; a lower or
+	// equal non-zero counter reveals a cloned authenticator.
+	SignCount  uint32   `json:"-"`
+	Transports []string `json:"transports,omitempty"`
+	// BackupEligible credentials can be synced between the user's devices.
+	BackupEligible bool       `json:"backup_eligible"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// PublicKeyCredentialCreationOptions and PublicKeyCredentialRequestOptions
+// are the options of navigator.credentials.create and get in their JSON
+// form, with binary values base64url encoded, as parsed by
+// PublicKeyCredential.parseCreationOptionsFromJSON and
+// parseRequestOptionsFromJSON.
+type PublicKeyCredentialCreationOptions struct {
+	Challenge              string                          `json:"challenge"`
+	RP                     PublicKeyCredentialRPEntity     `json:"rp"`
+	User                   PublicKeyCredentialUserEntity   `json:"user"`
+	PubKeyCredParams       []PublicKeyCredentialParameters `json:"pubKeyCredParams"`
+	Timeout                int64                           `json:"timeout"`
+	ExcludeCredentials     []PublicKeyCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelectionCriteria  `json:"authenticatorSelection"`
+	Attestation            string                          `json:"attestation"`
+}
+
+type PublicKeyCredentialRequestOptions struct {
+	Challenge        string                          `json:"challenge"`
+	Timeout          int64                           `json:"timeout"`
+	RPID             string                          `json:"rpId"`
+	AllowCredentials []PublicKeyCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                          `json:"userVerification"`
+}
+
+type PublicKeyCredentialRPEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type PublicKeyCredentialUserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type PublicKeyCredentialParameters struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type PublicKeyCredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type AuthenticatorSelectionCriteria struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// PasskeyRegistrationOptions and PasskeyLoginOptions start a ceremony.
+// Session is returned with the credential to finish it.
+type PasskeyRegistrationOptions struct {
+	Session   string                             `json:"session"`
+	PublicKey PublicKeyCredentialCreationOptions `json:"publicKey"`
+}
+
+type PasskeyLoginOptions struct {
+	Session   string                            `json:"session"`
+	PublicKey PublicKeyCredentialRequestOptions `json:"publicKey"`
+}
+
+// RegistrationCredential and AssertionCredential are the results of
+// navigator.credentials.create and get, serialized by
+// PublicKeyCredential.toJSON.
+type RegistrationCredential struct {
+	ID       string                           `json:"id" binding:"required"`
+	Type     string                           `json:"type"`
+	Response AuthenticatorAttestationResponse `json:"response"`
+}
+
+type AuthenticatorAttestationResponse struct {
+	ClientDataJSON    string   `json:"clientDataJSON" binding:"required"`
+	AttestationObject string   `json:"attestationObject" binding:"required"`
+	Transports        []string `json:"transports"`
+}
+
+type AssertionCredential struct {
+	ID       string                         `json:"id" binding:"required"`
+	Type     string                         `json:"type"`
+	Response AuthenticatorAssertionResponse `json:"response"`
+}
+
+type AuthenticatorAssertionResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+	AuthenticatorData string `json:"authenticatorData" binding:"required"`
+	Signature         string `json:"signature" binding:"required"`
+	UserHandle        string `json:"userHandle"`
+}
+
+type PasskeyRegistrationRequest struct {
+	Session    string                 `json:"session" binding:"required"`
+	Name       string                 `json:"name" binding:"max=100"`
+	Credential RegistrationCredential `json:"credential"`
+}
+
+type PasskeyLoginRequest struct {
+	TenantID string `json:"tenant_id"`
+}
+
+type PasskeyAssertionRequest struct {
+	Session    string              `json:"session" binding:"required"`
+	Credential AssertionCredential `json:"credential"`
+}
+
+// MFAPasskeyRequest completes a password login with a passkey.
+type MFAPasskeyRequest struct {
+	MFAToken   string              `json:"mfa_token" binding:"required"`
+	Session    string              `json:"session" binding:"required"`
+	Credential AssertionCredential `json:"credential"`
+}
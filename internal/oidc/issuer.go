@@ -0,0 +1,198 @@
+// Package oidc verifies tokens issued by OpenID Connect providers and
+// implements the relying-party side of the authorization code flow with
+// PKCE. Provider metadata and signing keys are discovered from the issuer on
+// first use, so the service starts even while the provider is unreachable.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Anand078/rbac/internal/signing"
+)
+
+var (
+	// ErrInvalidToken is returned for tokens, and authorization codes, that
+	// the provider did not issue or that are no longer valid.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrUnavailable is returned when the provider could not be reached or
+	// answered with something unusable.
+	ErrUnavailable = errors.New("identity provider unavailable")
+)
+
+// keyRefreshInterval limits how often the key set is fetched again because a
+// token names an unknown kid.
+const keyRefreshInterval = time.Minute
+
+// clockSkew is tolerated when checking exp, nbf and iat.
+const clockSkew = time.Minute
+
+// signingMethods are the algorithms accepted from providers. HMAC is excluded
+// because the provider's keys are public.
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// Metadata is the part of the provider configuration document that is used.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Issuer is an OpenID provider identified by its issuer URL.
+type Issuer struct {
+	url    string
+	client *http.Client
+
+	mu          sync.Mutex
+	metadata    *Metadata
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+// NewIssuer returns the provider at issuerURL, which must match the iss claim
+// of its tokens exactly. client is used for every request to the provider.
+func NewIssuer(issuerURL string, client *http.Client) *Issuer {
+	return &Issuer{url: issuerURL, client: client}
+}
+
+func (i *Issuer) URL() string {
+	return i.url
+}
+
+// Metadata returns the provider configuration, fetching it on first use.
+func (i *Issuer) Metadata(ctx context.Context) (*Metadata, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.metadataLocked(ctx)
+}
+
+func (i *Issuer) metadataLocked(ctx context.Context) (*Metadata, error) {
+	if i.metadata != nil {
+		return i.metadata, nil
+	}
+
+	var metadata Metadata
+	discoveryURL := strings.TrimSuffix(i.url, "/") + "/.well-known/openid-configuration"
+	if err := i.getJSON(ctx, discoveryURL, &metadata); err != nil {
+		return nil, err
+	}
+	if metadata.Issuer != i.url {
+		return nil, fmt.Errorf("%w: discovery document is for issuer %q", ErrUnavailable, metadata.Issuer)
+	}
+	if metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: discovery document has no jwks_uri", ErrUnavailable)
+	}
+	i.metadata = &metadata
+	return i.metadata, nil
+}
+
+// key returns the public key with the given kid, fetching the key set again
+// when the kid is unknown, as happens after the provider rotates its keys.
+// Tokens without a kid are accepted only while the set has a single key.
+func (i *Issuer) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if key, ok := i.lookupLocked(kid); ok {
+		return key, nil
+	}
+	if !i.keysFetched.IsZero() && time.Since(i.keysFetched) < keyRefreshInterval {
+		return nil, fmt.Errorf("%w: unknown signing key %q", ErrInvalidToken, kid)
+	}
+
+	metadata, err := i.metadataLocked(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var set signing.JWKS
+	if err := i.getJSON(ctx, metadata.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			// Skip keys of unsupported types; tokens signed with them fail.
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	i.keys, i.keysFetched = keys, time.Now()
+
+	if key, ok := i.lookupLocked(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown signing key %q", ErrInvalidToken, kid)
+}
+
+func (i *Issuer) lookupLocked(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(i.keys) == 1 {
+		for _, key := range i.keys {
+			return key, true
+		}
+	}
+	key, ok := i.keys[kid]
+	return key, ok
+}
+
+// Verify checks the signature of a JWT issued by the provider, its issuer,
+// that its audience includes audience, and its lifetime. It returns the
+// token's claims.
+func (i *Issuer) Verify(ctx context.Context, raw, audience string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(i.url),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+	_, err := parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return i.key(ctx, kid)
+	})
+	if err != nil {
+		if errors.Is(err, ErrUnavailable) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return claims, nil
+}
+
+func (i *Issuer) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: GET %s returned %s", ErrUnavailable, url, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%w: GET %s: %v", ErrUnavailable, url, err)
+	}
+	return nil
+}
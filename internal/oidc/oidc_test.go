@@ -0,0 +1,519 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/middleware"
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/services"
+	"github.com/Anand078/rbac/internal/signing"
+	"github.com/Anand078/rbac/internal/storage/memory"
+)
+
+const (
+	testClientID     = "rbac"
+	testClientSecret = "client-secret"
+	testRedirectURL  = "https://rbac.example.com/callback"
+)
+
+// authorization is an authorization code the mock provider issued, with
+// what the token endpoint checks when it is redeemed.
+type authorization struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+// mockIdP is an OpenID provider serving discovery, JWKS and the token
+// endpoint. ID tokens are signed with keys, which tests rotate.
+type mockIdP struct {
+	t      *testing.T
+	server *httptest.Server
+	keys   *signing.KeyManager
+
+	mu             sync.Mutex
+	codes          map[string]authorization
+	discoveries    int
+	keyFetches     int
+	discoverIssuer string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := signing.GenerateKey(signing.AlgES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := signing.NewKeyManager(signing.AlgES256, []*signing.Key{key}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{t: t, keys: keys, codes: map[string]authorization{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/jwks", idp.jwks)
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	idp.discoverIssuer = idp.server.URL
+	return idp
+}
+
+func (idp *mockIdP) issuer() *Issuer {
+	return NewIssuer(idp.server.URL, idp.server.Client())
+}
+
+func (idp *mockIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	idp.discoveries++
+	issuer := idp.discoverIssuer
+	idp.mu.Unlock()
+	json.NewEncoder(w).Encode(Metadata{
+		Issuer:                issuer,
+		AuthorizationEndpoint: idp.server.URL + "/authorize",
+		TokenEndpoint:         idp.server.URL + "/token",
+		JWKSURI:               idp.server.URL + "/jwks",
+	})
+}
+
+func (idp *mockIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	idp.keyFetches++
+	idp.mu.Unlock()
+	json.NewEncoder(w).Encode(idp.keys.JWKS())
+}
+
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	fail := func(status int, code string) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": code})
+	}
+	if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "authorization_code" {
+		fail(http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+	clientID, secret, basic := r.BasicAuth()
+	if basic {
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+		if secret != testClientSecret {
+			fail(http.StatusUnauthorized, "invalid_client")
+			return
+		}
+	} else {
+		clientID = r.Form.Get("client_id")
+	}
+	if clientID != testClientID {
+		fail(http.StatusUnauthorized, "invalid_client")
+		return
+	}
+
+	idp.mu.Lock()
+	auth, ok := idp.codes[r.Form.Get("code")]
+	delete(idp.codes, r.Form.Get("code"))
+	idp.mu.Unlock()
+	if !ok || r.Form.Get("redirect_uri") != testRedirectURL || codeChallenge(r.Form.Get("code_verifier")) != auth.challenge {
+		fail(http.StatusBadRequest, "invalid_grant")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": idp.sign(auth.claims), "token_type": "Bearer"})
+}
+
+// claims returns valid ID token claims for a new subject; overrides replace
+// or, when nil, delete claims.
+func (idp *mockIdP) claims(overrides jwt.MapClaims) jwt.MapClaims {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"sub":            uuid.New().String(),
+		"aud":            testClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"email":          "ada@example.com",
+		"email_verified": true,
+		"name":           "Ada Lovelace",
+		"groups":         []string{"staff"},
+	}
+	for name, value := range overrides {
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+	}
+	return claims
+}
+
+func (idp *mockIdP) sign(claims jwt.MapClaims) string {
+	idp.t.Helper()
+	token, err := idp.keys.Sign(claims)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	return token
+}
+
+// authorize plays the user's visit to the authorization endpoint: it issues
+// a code bound to the PKCE challenge of authURL for an ID token with claims.
+func (idp *mockIdP) authorize(authURL string, claims jwt.MapClaims) string {
+	idp.t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	params := u.Query()
+	if params.Get("code_challenge_method") != "S256" || params.Get("client_id") != testClientID ||
+		params.Get("redirect_uri") != testRedirectURL || params.Get("response_type") != "code" {
+		idp.t.Fatalf("unexpected authorization request %s", authURL)
+	}
+	if _, ok := claims["nonce"]; !ok {
+		claims["nonce"] = params.Get("nonce")
+	}
+	code := NewRandom()
+	idp.mu.Lock()
+	idp.codes[code] = authorization{challenge: params.Get("code_challenge"), claims: claims}
+	idp.mu.Unlock()
+	return code
+}
+
+func (idp *mockIdP) counts() (discoveries, keyFetches int) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	return idp.discoveries, idp.keyFetches
+}
+
+func TestIssuerDiscoveryAndKeyRotation(t *testing.T) {
+	idp := newMockIdP(t)
+	issuer := idp.issuer()
+	ctx := context.Background()
+
+	if _, err := issuer.Verify(ctx, idp.sign(idp.claims(nil)), testClientID); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if _, err := issuer.Verify(ctx, idp.sign(idp.claims(nil)), testClientID); err != nil {
+		t.Fatalf("second Verify: %v", err)
+	}
+	if discoveries, keyFetches := idp.counts(); discoveries != 1 || keyFetches != 1 {
+		t.Errorf("discovery fetched %d times and keys %d times, want once each", discoveries, keyFetches)
+	}
+
+	// After a rotation, tokens with the new kid are accepted once the key set
+	// may be fetched again, and the old key keeps working while published.
+	if err := idp.keys.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	rotated := idp.sign(idp.claims(nil))
+	if _, err := issuer.Verify(ctx, rotated, testClientID); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("unknown kid within the refresh interval: Verify = %v, want ErrInvalidToken", err)
+	}
+	if _, keyFetches := idp.counts(); keyFetches != 1 {
+		t.Errorf("keys fetched %d times, want no refetch within the refresh interval", keyFetches)
+	}
+	issuer.mu.Lock()
+	issuer.keysFetched = time.Now().Add(-keyRefreshInterval)
+	issuer.mu.Unlock()
+	if _, err := issuer.Verify(ctx, rotated, testClientID); err != nil {
+		t.Errorf("token of the rotated key: Verify = %v", err)
+	}
+	if _, keyFetches := idp.counts(); keyFetches != 2 {
+		t.Errorf("keys fetched %d times, want 2", keyFetches)
+	}
+
+	// A discovery document for another issuer is refused.
+	idp.mu.Lock()
+	idp.discoverIssuer = "https://evil.example.com"
+	idp.mu.Unlock()
+	if _, err := idp.issuer().Metadata(ctx); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("foreign discovery document: Metadata = %v, want ErrUnavailable", err)
+	}
+	idp.server.Close()
+	if _, err := NewIssuer(idp.server.URL, http.DefaultClient).Verify(ctx, rotated, testClientID); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("unreachable provider: Verify = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestIssuerVerifyRejects(t *testing.T) {
+	idp := newMockIdP(t)
+	issuer := idp.issuer()
+	ctx := context.Background()
+
+	hmacToken := func(alg jwt.SigningMethod, key any) string {
+		token := jwt.NewWithClaims(alg, idp.claims(nil))
+		token.Header["kid"] = idp.keys.JWKS().Keys[0].Kid
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	// The public key as an HMAC secret is the classic algorithm confusion.
+	publicKey, err := json.Marshal(idp.keys.JWKS().Keys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"wrong issuer", idp.sign(idp.claims(jwt.MapClaims{"iss": "https://evil.example.com"}))},
+		{"wrong audience", idp.sign(idp.claims(jwt.MapClaims{"aud": "someone-else"}))},
+		{"expired", idp.sign(idp.claims(jwt.MapClaims{"exp": time.Now().Add(-2 * clockSkew).Unix()}))},
+		{"no expiry", idp.sign(idp.claims(jwt.MapClaims{"exp": nil}))},
+		{"issued in the future", idp.sign(idp.claims(jwt.MapClaims{"iat": time.Now().Add(2 * clockSkew).Unix()}))},
+		{"alg none", hmacToken(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType)},
+		{"alg HS256", hmacToken(jwt.SigningMethodHS256, publicKey)},
+		{"tampered", idp.sign(idp.claims(nil))[:40] + "x" + idp.sign(idp.claims(nil))[41:]},
+	}
+	for _, tt := range tests {
+		if _, err := issuer.Verify(ctx, tt.token, testClientID); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: Verify = %v, want ErrInvalidToken", tt.name, err)
+		}
+	}
+
+	// Tolerated clock skew and multiple audiences are fine.
+	for _, claims := range []jwt.MapClaims{
+		{"exp": time.Now().Add(-clockSkew / 2).Unix()},
+		{"aud": []string{"other", testClientID}},
+	} {
+		if _, err := issuer.Verify(ctx, idp.sign(idp.claims(claims)), testClientID); err != nil {
+			t.Errorf("Verify(%v) = %v", claims, err)
+		}
+	}
+}
+
+func TestRelyingPartyExchange(t *testing.T) {
+	idp := newMockIdP(t)
+	ctx := context.Background()
+
+	for _, secret := range []string{"", testClientSecret} {
+		rp := NewRelyingParty("corp", idp.issuer(), testClientID, secret, testRedirectURL, []string{"openid", "email"}, "groups")
+		state, nonce, verifier := NewRandom(), NewRandom(), NewRandom()
+		authURL, err := rp.AuthCodeURL(ctx, state, nonce, verifier)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(authURL, idp.server.URL+"/authorize?") || !strings.Contains(authURL, "state="+state) {
+			t.Errorf("AuthCodeURL = %s", authURL)
+		}
+
+		claims := idp.claims(nil)
+		code := idp.authorize(authURL, claims)
+		identity, err := rp.Exchange(ctx, code, verifier, nonce)
+		if err != nil {
+			t.Fatalf("secret %q: Exchange: %v", secret, err)
+		}
+		if identity.Provider != "corp" || identity.Subject != claims["sub"] || identity.Email != "ada@example.com" ||
+			!identity.EmailVerified || identity.Name != "Ada Lovelace" || len(identity.Groups) != 1 || identity.Groups[0] != "staff" {
+			t.Errorf("Exchange = %+v", identity)
+		}
+
+		// Codes are single use.
+		if _, err := rp.Exchange(ctx, code, verifier, nonce); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("reused code: Exchange = %v, want ErrInvalidToken", err)
+		}
+	}
+
+	rp := NewRelyingParty("corp", idp.issuer(), testClientID, "", testRedirectURL, []string{"openid"}, "groups")
+	tests := []struct {
+		name     string
+		claims   jwt.MapClaims
+		verifier func(string) string
+		nonce    func(string) string
+	}{
+		{"wrong PKCE verifier", nil, func(string) string { return NewRandom() }, nil},
+		{"nonce mismatch", nil, nil, func(string) string { return NewRandom() }},
+		{"nonce missing", jwt.MapClaims{"nonce": ""}, nil, nil},
+		{"azp of another client", jwt.MapClaims{"aud": []string{testClientID, "other"}, "azp": "other"}, nil, nil},
+		{"wrong issuer", jwt.MapClaims{"iss": "https://evil.example.com"}, nil, nil},
+		{"wrong audience", jwt.MapClaims{"aud": "other"}, nil, nil},
+		{"expired", jwt.MapClaims{"exp": time.Now().Add(-2 * clockSkew).Unix()}, nil, nil},
+		{"no subject", jwt.MapClaims{"sub": nil}, nil, nil},
+	}
+	for _, tt := range tests {
+		nonce, verifier := NewRandom(), NewRandom()
+		authURL, err := rp.AuthCodeURL(ctx, NewRandom(), nonce, verifier)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code := idp.authorize(authURL, idp.claims(tt.claims))
+		if tt.verifier != nil {
+			verifier = tt.verifier(verifier)
+		}
+		if tt.nonce != nil {
+			nonce = tt.nonce(nonce)
+		}
+		if identity, err := rp.Exchange(ctx, code, verifier, nonce); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: Exchange = %+v, %v; want ErrInvalidToken", tt.name, identity, err)
+		}
+	}
+
+	// A confidential client with the wrong secret is a configuration problem,
+	// not a bad login.
+	rp = NewRelyingParty("corp", idp.issuer(), testClientID, "wrong", testRedirectURL, []string{"openid"}, "groups")
+	nonce, verifier := NewRandom(), NewRandom()
+	authURL, err := rp.AuthCodeURL(ctx, NewRandom(), nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.Exchange(ctx, idp.authorize(authURL, idp.claims(nil)), verifier, nonce); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("wrong client secret: Exchange = %v, want ErrUnavailable", err)
+	}
+}
+
+// protectedAPI is a service accepting the tokens of a mock provider through
+// AuthMiddleware, on a route answering who the caller is.
+type protectedAPI struct {
+	idp         *mockIdP
+	store       *memory.Store
+	authService *services.AuthService
+	rbacService *services.RBACService
+	auth        *middleware.AuthMiddleware
+	router      *gin.Engine
+}
+
+func newProtectedAPI(t *testing.T) *protectedAPI {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	api := &protectedAPI{idp: newMockIdP(t), store: memory.New()}
+	keys := signing.NewHMACKeyManager("test-secret")
+	api.authService = services.NewAuthService(api.store, keys, time.Minute, time.Hour)
+	api.rbacService = services.NewRBACService(api.store)
+	api.authService.EnableGroupSync(api.rbacService)
+	api.auth = middleware.NewAuthMiddleware(keys, api.authService, api.rbacService, services.NewACLService(api.store, api.rbacService))
+	api.auth.AddIdentityProvider(NewProvider("corp", api.idp.issuer(), testClientID, "groups"))
+
+	api.router = gin.New()
+	api.router.GET("/me", api.auth.Authenticate(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.MustGet("user_id"), "provider": c.GetString("identity_provider")})
+	})
+	return api
+}
+
+func (api *protectedAPI) get(token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	api.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestProviderBearerToken(t *testing.T) {
+	api := newProtectedAPI(t)
+	idp, store, authService, auth, get := api.idp, api.store, api.authService, api.auth, api.get
+
+	claims := idp.claims(jwt.MapClaims{"jti": uuid.New().String()})
+	w := get(idp.sign(claims))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"provider":"corp"`) {
+		t.Fatalf("external token: %d %s", w.Code, w.Body)
+	}
+	user, err := store.GetUserByIdentity("corp", claims["sub"].(string))
+	if err != nil || !strings.Contains(w.Body.String(), user.ID.String()) {
+		t.Errorf("request not authenticated as the provisioned user: %v, %s", err, w.Body)
+	}
+
+	// Logging out revokes the presented token.
+	jti := uuid.MustParse(claims["jti"].(string))
+	if err := authService.RevokeToken(jti, user.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if w := get(idp.sign(claims)); w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked external token: %d, want 401", w.Code)
+	}
+
+	for name, token := range map[string]string{
+		"wrong audience": idp.sign(idp.claims(jwt.MapClaims{"aud": "other"})),
+		"expired":        idp.sign(idp.claims(jwt.MapClaims{"exp": time.Now().Add(-2 * clockSkew).Unix()})),
+	} {
+		if w := get(token); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: %d, want 401", name, w.Code)
+		}
+	}
+
+	idp.server.Close()
+	auth.AddIdentityProvider(NewProvider("down", NewIssuer(idp.server.URL+"/down", http.DefaultClient), testClientID, "groups"))
+	down := idp.claims(jwt.MapClaims{"iss": idp.server.URL + "/down"})
+	if w := get(idp.sign(down)); w.Code != http.StatusBadGateway {
+		t.Errorf("unreachable provider: %d, want 502", w.Code)
+	}
+}
+
+func TestProviderShrunkGroups(t *testing.T) {
+	api := newProtectedAPI(t)
+	idp := api.idp
+	roleIDs := map[string]uuid.UUID{}
+	for _, group := range []string{"staff", "ops"} {
+		role, err := api.rbacService.CreateRole(models.CreateRoleRequest{Name: group})
+		if err != nil {
+			t.Fatal(err)
+		}
+		roleIDs[group] = role.ID
+		if _, err := api.rbacService.CreateGroupMapping(models.CreateGroupRoleMappingRequest{
+			Group: group, RoleID: role.ID.String(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	roles := func(userID uuid.UUID) map[uuid.UUID]bool {
+		t.Helper()
+		effective, err := api.store.EffectiveRoles(userID, models.GlobalTenantID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		held := map[uuid.UUID]bool{}
+		for _, role := range effective {
+			held[role.ID] = true
+		}
+		return held
+	}
+
+	claims := idp.claims(jwt.MapClaims{"groups": []string{"staff", "ops"}})
+	if w := api.get(idp.sign(claims)); w.Code != http.StatusOK {
+		t.Fatalf("external token: %d %s", w.Code, w.Body)
+	}
+	user, err := api.store.GetUserByIdentity("corp", claims["sub"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if held := roles(user.ID); !held[roleIDs["staff"]] || !held[roleIDs["ops"]] {
+		t.Fatalf("roles after the first token = %v, want staff and ops", held)
+	}
+
+	// Another session of the user, which signing the user out would catch.
+	session, challenge, err := api.authService.LoginExternal(&services.ExternalIdentity{
+		Provider: "corp", Subject: claims["sub"].(string), Email: user.Email, EmailVerified: true,
+		Groups: []string{"staff", "ops"},
+	}, models.GlobalTenantID)
+	if err != nil || challenge != nil {
+		t.Fatalf("LoginExternal = %v, %v", challenge, err)
+	}
+
+	// The token presenting fewer groups is accepted; the role the user left
+	// is dropped without signing anyone out.
+	shrunk := idp.claims(jwt.MapClaims{"sub": claims["sub"], "groups": []string{"staff"}})
+	if w := api.get(idp.sign(shrunk)); w.Code != http.StatusOK {
+		t.Errorf("token with fewer groups: %d %s, want 200", w.Code, w.Body)
+	}
+	if held := roles(user.ID); !held[roleIDs["staff"]] || held[roleIDs["ops"]] {
+		t.Errorf("roles after the shrunk token = %v, want staff only", held)
+	}
+	if w := api.get(idp.sign(shrunk)); w.Code != http.StatusOK {
+		t.Errorf("shrunk token presented again: %d, want 200", w.Code)
+	}
+	if w := api.get(session.Token); w.Code != http.StatusOK {
+		t.Errorf("other session after the groups shrank: %d %s, want 200", w.Code, w.Body)
+	}
+}
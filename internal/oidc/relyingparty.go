@@ -0,0 +1,158 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Anand078/rbac/internal/services"
+)
+
+// RelyingParty signs users in with the authorization code flow, protected by
+// PKCE (S256) and a nonce bound to the ID token.
+type RelyingParty struct {
+	name         string
+	issuer       *Issuer
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+	groupsClaim  string
+}
+
+// NewRelyingParty registers the flow for the client clientID at issuer.
+// clientSecret may be empty for public clients. Identities are recorded under
+// name, and group memberships are read from groupsClaim of the ID token.
+func NewRelyingParty(name string, issuer *Issuer, clientID, clientSecret, redirectURL string, scopes []string, groupsClaim string) *RelyingParty {
+	return &RelyingParty{
+		name:         name,
+		issuer:       issuer,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		scopes:       scopes,
+		groupsClaim:  groupsClaim,
+	}
+}
+
+func (rp *RelyingParty) RedirectURL() string {
+	return rp.redirectURL
+}
+
+// NewRandom returns a random URL-safe string suitable for state, nonce and
+// PKCE code verifier values.
+func NewRandom() string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("oidc: failed to read random bytes: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// codeChallenge derives the S256 PKCE challenge of a code verifier.
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the provider URL to send the user to. state, nonce and
+// codeVerifier must be kept by the caller for Exchange.
+func (rp *RelyingParty) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	metadata, err := rp.issuer.Metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {rp.clientID},
+		"redirect_uri":          {rp.redirectURL},
+		"scope":                 {strings.Join(rp.scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge(codeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange redeems an authorization code and returns the identity in the ID
+// token, after checking the token and that it carries nonce.
+func (rp *RelyingParty) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*services.ExternalIdentity, error) {
+	metadata, err := rp.issuer.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {rp.redirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	if rp.clientSecret == "" {
+		form.Set("client_id", rp.clientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if rp.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(rp.clientID), url.QueryEscape(rp.clientSecret))
+	}
+
+	resp, err := rp.issuer.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	var body tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("%w: token endpoint returned %s", ErrUnavailable, resp.Status)
+	}
+	switch {
+	case resp.StatusCode == http.StatusBadRequest && body.Error == "invalid_grant":
+		// Expired, reused or forged code, or a wrong code verifier.
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, body.ErrorDescription)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%w: token endpoint returned %s: %s %s", ErrUnavailable, resp.Status, body.Error, body.ErrorDescription)
+	case body.IDToken == "":
+		return nil, fmt.Errorf("%w: token response has no id_token", ErrUnavailable)
+	}
+
+	claims, err := rp.issuer.Verify(ctx, body.IDToken, rp.clientID)
+	if err != nil {
+		return nil, err
+	}
+	if claimNonce, _ := claims["nonce"].(string); claimNonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+	if azp, ok := claims["azp"].(string); ok && azp != rp.clientID {
+		return nil, fmt.Errorf("%w: token was issued to %q", ErrInvalidToken, azp)
+	}
+
+	identity, err := identityFromClaims(rp.name, body.IDToken, claims, rp.groupsClaim)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return identity, nil
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+)
+
+func TestACLEvaluate(t *testing.T) {
+	f := newFixture(t)
+	acl := NewACLService(f.store, f.rbac)
+	teacher := f.role("teacher")
+	f.grant(teacher, "course", "update", models.EffectAllow, "")
+	suspended := f.role("suspended")
+	f.grant(suspended, "course", "*", models.EffectDeny, "")
+	tenantID := uuid.New()
+
+	member := f.user("member@example.com")
+	f.assign(member, teacher, models.GlobalTenantID)
+	guest := f.user("guest@example.com")
+	banned := f.user("banned@example.com")
+	f.assign(banned, suspended, models.GlobalTenantID)
+	tenantTeacher := f.user("tenant-teacher@example.com")
+	f.assign(tenantTeacher, teacher, tenantID)
+
+	entry := func(resourceID string, subjectType models.SubjectType, subjectID uuid.UUID, action string, effect models.Effect) {
+		t.Helper()
+		if _, err := acl.CreateEntry(models.CreateACLEntryRequest{
+			Resource: "course", ResourceID: resourceID, SubjectType: subjectType,
+			SubjectID: subjectID.String(), Action: action, Effect: effect,
+		}); err != nil {
+			t.Fatalf("CreateEntry: %v", err)
+		}
+	}
+	entry("locked", models.SubjectUser, member, "update", models.EffectDeny)
+	entry("archived", models.SubjectRole, teacher, "*", models.EffectDeny)
+	entry("shared", models.SubjectUser, guest, "update", "")
+	entry("shared", models.SubjectUser, banned, "update", models.EffectAllow)
+	entry("tenant-course", models.SubjectRole, teacher, "read", models.EffectAllow)
+
+	tests := []struct {
+		name       string
+		userID     uuid.UUID
+		tenantID   uuid.UUID
+		resourceID string
+		action     string
+		want       Decision
+	}{
+		{"role allow without entries", member, models.GlobalTenantID, "open", "update", DecisionAllow},
+		{"user entry denies what the role allows", member, models.GlobalTenantID, "locked", "update", DecisionDeny},
+		{"entries for another user", member, models.GlobalTenantID, "shared", "update", DecisionAllow},
+		{"role entry denies every action", member, models.GlobalTenantID, "archived", "update", DecisionDeny},
+		{"entry allows without a role", guest, models.GlobalTenantID, "shared", "update", DecisionAllow},
+		{"entry allows one action only", guest, models.GlobalTenantID, "shared", "delete", DecisionNoMatch},
+		{"no role and no entry", guest, models.GlobalTenantID, "open", "update", DecisionNoMatch},
+		{"role deny overrides an entry allow", banned, models.GlobalTenantID, "shared", "update", DecisionDeny},
+		{"role entry in the tenant of the role", tenantTeacher, tenantID, "tenant-course", "read", DecisionAllow},
+		{"role entry outside the tenant of the role", tenantTeacher, models.GlobalTenantID, "tenant-course", "read", DecisionNoMatch},
+	}
+	for _, tt := range tests {
+		decision, err := acl.Evaluate(AccessRequest{
+			UserID: tt.userID, TenantID: tt.tenantID, Resource: "course", ResourceID: tt.resourceID, Action: tt.action,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decision != tt.want {
+			t.Errorf("%s: Evaluate = %v, want %v", tt.name, decision, tt.want)
+		}
+	}
+
+	// Deleting the deny restores what the role grants.
+	entries, err := acl.GetEntries("course", "locked")
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("GetEntries = %v, %v", entries, err)
+	}
+	if err := acl.DeleteEntry(entries[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	allowed, err := acl.CanAccess(AccessRequest{UserID: member, Resource: "course", ResourceID: "locked", Action: "update"})
+	if err != nil || !allowed {
+		t.Errorf("after DeleteEntry: CanAccess = %v, %v; want true", allowed, err)
+	}
+	if err := acl.DeleteEntry(entries[0].ID); !errors.Is(err, ErrACLEntryNotFound) {
+		t.Errorf("second DeleteEntry = %v, want ErrACLEntryNotFound", err)
+	}
+}
+
+func TestCreateACLEntryRejects(t *testing.T) {
+	f := newFixture(t)
+	acl := NewACLService(f.store, f.rbac)
+	subjectID := uuid.NewString()
+
+	for name, req := range map[string]models.CreateACLEntryRequest{
+		"malformed subject ID": {Resource: "course", ResourceID: "1", SubjectType: models.SubjectUser, SubjectID: "ada", Action: "read"},
+		"wildcard resource":    {Resource: "*", ResourceID: "1", SubjectType: models.SubjectUser, SubjectID: subjectID, Action: "read"},
+		"hierarchical pattern": {Resource: "course.*", ResourceID: "1", SubjectType: models.SubjectUser, SubjectID: subjectID, Action: "read"},
+		"invalid action":       {Resource: "course", ResourceID: "1", SubjectType: models.SubjectUser, SubjectID: subjectID, Action: "re ad"},
+	} {
+		if _, err := acl.CreateEntry(req); !errors.Is(err, ErrInvalidACLEntry) {
+			t.Errorf("%s: CreateEntry = %v, want ErrInvalidACLEntry", name, err)
+		}
+	}
+}
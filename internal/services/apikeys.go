@@ -0,0 +1,237 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+var (
+	ErrInvalidServiceAccount  = errors.New("invalid service account")
+	ErrServiceAccountNotFound = storage.ErrServiceAccountNotFound
+	ErrInvalidAPIKeyRequest   = errors.New("invalid API key request")
+	ErrAPIKeyNotFound         = storage.ErrAPIKeyNotFound
+	ErrInvalidAPIKey          = errors.New("invalid or expired API key")
+)
+
+// APIKeyPrefix starts every API key, so leaked keys are easy to recognize.
+// It is followed by a random key ID, an underscore and the secret; the part
+// up to the key ID is the key's Prefix.
+const APIKeyPrefix = "rbk_"
+
+const apiKeyIDLength = 12
+
+// apiKeyTouchInterval limits how often the last use of a key is written.
+const apiKeyTouchInterval = time.Minute
+
+var serviceAccountName = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// ValidateScope checks a "resource:action" permission pattern.
+func ValidateScope(scope string) error {
+	i := strings.LastIndex(scope, ":")
+	if i < 0 {
+		return fmt.Errorf("%w: scope %q must be resource:action", ErrInvalidPermissionPattern, scope)
+	}
+	return ValidatePermissionPattern(scope[:i], scope[i+1:])
+}
+
+// ScopesAllow reports whether one of the "resource:action" patterns in scopes
+// covers (resource, action).
+func ScopesAllow(scopes []string, resource, action string) bool {
+	return matchEncoded(scopes, resource, action)
+}
+
+// Service Accounts
+func (s *AuthService) CreateServiceAccount(req models.CreateServiceAccountRequest) (*models.ServiceAccount, error) {
+	if !serviceAccountName.MatchString(req.Name) {
+		return nil, fmt.Errorf("%w: name must be lowercase letters, digits and dashes", ErrInvalidServiceAccount)
+	}
+	roleID := uuid.Nil
+	if req.RoleID != "" {
+		var err error
+		if roleID, err = uuid.Parse(req.RoleID); err != nil {
+			return nil, fmt.Errorf("%w: invalid role ID", ErrInvalidServiceAccount)
+		}
+	}
+
+	user := &models.User{
+		ID:           uuid.New(),
+		Email:        req.Name + "@" + models.ServiceAccountDomain,
+		Name:         req.Name,
+		PasswordHash: unusablePasswordHash,
+	}
+	account := &models.ServiceAccount{Description: req.Description}
+	if err := s.store.CreateServiceAccount(user, roleID, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+func (s *AuthService) GetServiceAccounts() ([]models.ServiceAccount, error) {
+	return s.store.ListServiceAccounts()
+}
+
+func (s *AuthService) GetServiceAccount(id uuid.UUID) (*models.ServiceAccount, error) {
+	return s.store.GetServiceAccount(id)
+}
+
+// DeleteServiceAccount deletes the account with its keys and roles.
+func (s *AuthService) DeleteServiceAccount(id uuid.UUID) error {
+	return s.store.DeleteServiceAccount(id)
+}
+
+// API Keys
+
+// CreateAPIKey issues a key for the service account. Each scope must be
+// covered by a permission the account is granted through its roles, so give
+// the account its roles first. The returned key is not stored and cannot be
+// shown again.
+func (s *AuthService) CreateAPIKey(serviceAccountID uuid.UUID, req models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error) {
+	if err := s.checkKeyScopes(serviceAccountID, req.Scopes); err != nil {
+		return nil, err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAPIKeyRequest)
+	}
+
+	idBytes := make([]byte, apiKeyIDLength/2)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	prefix := APIKeyPrefix + hex.EncodeToString(idBytes)
+	key := prefix + "_" + secret
+
+	scopes := req.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	record := &models.APIKey{
+		ID:               uuid.New(),
+		ServiceAccountID: serviceAccountID,
+		Name:             req.Name,
+		Prefix:           prefix,
+		KeyHash:          hashToken(key),
+		Scopes:           scopes,
+		ExpiresAt:        req.ExpiresAt,
+	}
+	if err := s.store.CreateAPIKey(record); err != nil {
+		return nil, err
+	}
+	record.KeyHash = ""
+	return &models.CreateAPIKeyResponse{Key: key, APIKey: *record}, nil
+}
+
+// checkKeyScopes validates "resource:action" scopes and requires each to be
+// covered by a permission allowed to the service account, in any tenant.
+func (s *AuthService) checkKeyScopes(serviceAccountID uuid.UUID, scopes []string) error {
+	for _, scope := range scopes {
+		if err := ValidateScope(scope); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidAPIKeyRequest, err)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil
+	}
+	granted, err := s.grantedScopes(serviceAccountID)
+	if err != nil {
+		return err
+	}
+	for _, scope := range scopes {
+		if !clientAllowsScope(granted, scope) {
+			return fmt.Errorf("%w: scope %q is not covered by a permission of the service account", ErrInvalidAPIKeyRequest, scope)
+		}
+	}
+	return nil
+}
+
+// grantedScopes returns the "resource:action" patterns the service account's
+// roles allow, ignoring deny grants. Scopes of keys and clients must be
+// covered by one of them.
+func (s *AuthService) grantedScopes(serviceAccountID uuid.UUID) ([]string, error) {
+	if _, err := s.store.GetServiceAccount(serviceAccountID); err != nil {
+		return nil, err
+	}
+
+	assignments, err := s.store.ListAssignments(serviceAccountID)
+	if err != nil {
+		return nil, err
+	}
+	var granted []string
+	seen := make(map[uuid.UUID]bool, len(assignments))
+	for _, a := range assignments {
+		if seen[a.RoleID] {
+			continue
+		}
+		seen[a.RoleID] = true
+		permissions, err := s.store.GetRolePermissions(a.RoleID)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range permissions {
+			if p.Effect != models.EffectDeny {
+				granted = append(granted, p.Resource+":"+p.Action)
+			}
+		}
+	}
+	return granted, nil
+}
+
+func (s *AuthService) GetAPIKeys(serviceAccountID uuid.UUID) ([]models.APIKey, error) {
+	if _, err := s.store.GetServiceAccount(serviceAccountID); err != nil {
+		return nil, err
+	}
+	return s.store.ListAPIKeys(serviceAccountID)
+}
+
+// DeleteAPIKey revokes a key immediately.
+func (s *AuthService) DeleteAPIKey(serviceAccountID, keyID uuid.UUID) error {
+	return s.store.DeleteAPIKey(serviceAccountID, keyID)
+}
+
+// AuthenticateAPIKey returns the stored key matching key, recording its use.
+// It returns ErrInvalidAPIKey for unknown, malformed and expired keys.
+func (s *AuthService) AuthenticateAPIKey(key string) (*models.APIKey, error) {
+	prefixLength := len(APIKeyPrefix) + apiKeyIDLength
+	if !strings.HasPrefix(key, APIKeyPrefix) || len(key) <= prefixLength+1 || key[prefixLength] != '_' {
+		return nil, ErrInvalidAPIKey
+	}
+
+	record, err := s.store.GetAPIKeyByPrefix(key[:prefixLength])
+	if err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(key)), []byte(record.KeyHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if record.ExpiresAt != nil && !now.Before(*record.ExpiresAt) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if record.LastUsedAt == nil || now.Sub(*record.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.store.TouchAPIKey(record.ID, now); err != nil {
+			log.Printf("Failed to record use of API key %s: %v", record.Prefix, err)
+		}
+		record.LastUsedAt = &now
+	}
+	record.KeyHash = ""
+	return record, nil
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+)
+
+// serviceAccount creates a service account holding roleID globally.
+func serviceAccount(t *testing.T, auth *AuthService, name string, roleID uuid.UUID) uuid.UUID {
+	t.Helper()
+	req := models.CreateServiceAccountRequest{Name: name}
+	if roleID != uuid.Nil {
+		req.RoleID = roleID.String()
+	}
+	account, err := auth.CreateServiceAccount(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return account.ID
+}
+
+func TestCreateAPIKeyScopes(t *testing.T) {
+	f := newFixture(t)
+	auth := newTestAuth(f.store)
+	parent := f.role("reader")
+	f.grant(parent, "course", "read", models.EffectAllow, "")
+	role := f.role("grader", parent)
+	f.grant(role, "grades", "*", models.EffectAllow, "")
+	f.grant(role, "reports", "read", models.EffectDeny, "")
+	other := f.role("other")
+	f.grant(other, "*", "*", models.EffectAllow, "")
+	accountID := serviceAccount(t, auth, "batch", role)
+
+	tenantRole := f.role("tenant-writer")
+	f.grant(tenantRole, "course", "write", models.EffectAllow, "")
+	f.assign(accountID, tenantRole, uuid.New())
+
+	tests := []struct {
+		scope string
+		ok    bool
+	}{
+		{"course:read", true},
+		{"grades:read", true},
+		{"grades:*", true},
+		{"course:write", true},
+		{"*:*", false},
+		{"course:*", false},
+		{"course:delete", false},
+		{"reports:read", false},
+		{"bad", false},
+	}
+	for _, tt := range tests {
+		_, err := auth.CreateAPIKey(accountID, models.CreateAPIKeyRequest{Name: tt.scope, Scopes: []string{tt.scope}})
+		if tt.ok && err != nil {
+			t.Errorf("scope %q: CreateAPIKey = %v", tt.scope, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidAPIKeyRequest) {
+			t.Errorf("scope %q: CreateAPIKey = %v, want ErrInvalidAPIKeyRequest", tt.scope, err)
+		}
+	}
+	if _, err := auth.CreateAPIKey(accountID, models.CreateAPIKeyRequest{
+		Name: "mixed", Scopes: []string{"course:read", "*:*"},
+	}); !errors.Is(err, ErrInvalidAPIKeyRequest) {
+		t.Errorf("one uncovered scope among others: CreateAPIKey = %v, want ErrInvalidAPIKeyRequest", err)
+	}
+
+	// An account without roles gets unscoped keys only.
+	bare := serviceAccount(t, auth, "bare", uuid.Nil)
+	if _, err := auth.CreateAPIKey(bare, models.CreateAPIKeyRequest{Name: "scoped", Scopes: []string{"course:read"}}); !errors.Is(err, ErrInvalidAPIKeyRequest) {
+		t.Errorf("account without roles: CreateAPIKey = %v, want ErrInvalidAPIKeyRequest", err)
+	}
+	if _, err := auth.CreateAPIKey(bare, models.CreateAPIKeyRequest{Name: "unscoped"}); err != nil {
+		t.Errorf("unscoped key: CreateAPIKey = %v", err)
+	}
+	if _, err := auth.CreateAPIKey(uuid.New(), models.CreateAPIKeyRequest{Name: "k", Scopes: []string{"course:read"}}); !errors.Is(err, ErrServiceAccountNotFound) {
+		t.Errorf("unknown account: CreateAPIKey = %v, want ErrServiceAccountNotFound", err)
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	f := newFixture(t)
+	auth := newTestAuth(f.store)
+	role := f.role("reader")
+	f.grant(role, "course", "read", models.EffectAllow, "")
+	accountID := serviceAccount(t, auth, "batch", role)
+
+	created, err := auth.CreateAPIKey(accountID, models.CreateAPIKeyRequest{Name: "k", Scopes: []string{"course:read"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := created.Key
+	if !strings.HasPrefix(key, created.APIKey.Prefix+"_") || !strings.HasPrefix(created.APIKey.Prefix, APIKeyPrefix) || created.APIKey.KeyHash != "" {
+		t.Fatalf("CreateAPIKey = %+v", created)
+	}
+
+	// Keys are found by their prefix and compared by hash; the key itself is
+	// not stored.
+	stored, err := f.store.GetAPIKeyByPrefix(created.APIKey.Prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.KeyHash != hashToken(key) || strings.Contains(stored.KeyHash, key[len(created.APIKey.Prefix)+1:]) {
+		t.Errorf("stored hash %q is not the hash of the key", stored.KeyHash)
+	}
+	record, err := auth.AuthenticateAPIKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.ID != created.APIKey.ID || record.ServiceAccountID != accountID || record.KeyHash != "" ||
+		len(record.Scopes) != 1 || record.Scopes[0] != "course:read" || record.LastUsedAt == nil {
+		t.Errorf("AuthenticateAPIKey = %+v", record)
+	}
+
+	other, err := auth.CreateAPIKey(accountID, models.CreateAPIKeyRequest{Name: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := key[len(created.APIKey.Prefix)+1:]
+	for name, candidate := range map[string]string{
+		"wrong secret":             created.APIKey.Prefix + "_" + strings.Repeat("a", len(secret)),
+		"secret of another key":    other.APIKey.Prefix + "_" + secret,
+		"truncated":                key[:len(key)-1],
+		"prefix only":              created.APIKey.Prefix,
+		"prefix and separator":     created.APIKey.Prefix + "_",
+		"without the rbk_ prefix":  strings.TrimPrefix(key, APIKeyPrefix),
+		"unknown key ID":           APIKeyPrefix + strings.Repeat("0", apiKeyIDLength) + "_" + secret,
+		"hash instead of the key":  stored.KeyHash,
+		"separator in wrong place": APIKeyPrefix + "_" + key[len(APIKeyPrefix):],
+	} {
+		if _, err := auth.AuthenticateAPIKey(candidate); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("%s: AuthenticateAPIKey = %v, want ErrInvalidAPIKey", name, err)
+		}
+	}
+
+	// Deleting a key revokes it at once, and leaves the other keys alone.
+	if err := auth.DeleteAPIKey(accountID, created.APIKey.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.AuthenticateAPIKey(key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("deleted key: AuthenticateAPIKey = %v, want ErrInvalidAPIKey", err)
+	}
+	if err := auth.DeleteAPIKey(accountID, created.APIKey.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("second DeleteAPIKey = %v, want ErrAPIKeyNotFound", err)
+	}
+	if _, err := auth.AuthenticateAPIKey(other.Key); err != nil {
+		t.Errorf("remaining key: AuthenticateAPIKey = %v", err)
+	}
+
+	// So does deleting the account.
+	if err := auth.DeleteServiceAccount(accountID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.AuthenticateAPIKey(other.Key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("key of a deleted account: AuthenticateAPIKey = %v, want ErrInvalidAPIKey", err)
+	}
+}
+
+func TestAPIKeyExpiry(t *testing.T) {
+	f := newFixture(t)
+	auth := newTestAuth(f.store)
+	accountID := serviceAccount(t, auth, "batch", uuid.Nil)
+
+	past := time.Now().Add(-time.Second)
+	if _, err := auth.CreateAPIKey(accountID, models.CreateAPIKeyRequest{Name: "k", ExpiresAt: &past}); !errors.Is(err, ErrInvalidAPIKeyRequest) {
+		t.Errorf("expiry in the past: CreateAPIKey = %v, want ErrInvalidAPIKeyRequest", err)
+	}
+
+	expiresAt := time.Now().Add(100 * time.Millisecond)
+	created, err := auth.CreateAPIKey(accountID, models.CreateAPIKeyRequest{Name: "k", ExpiresAt: &expiresAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.AuthenticateAPIKey(created.Key); err != nil {
+		t.Errorf("before expiry: AuthenticateAPIKey = %v", err)
+	}
+	time.Sleep(time.Until(expiresAt))
+	if _, err := auth.AuthenticateAPIKey(created.Key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("after expiry: AuthenticateAPIKey = %v, want ErrInvalidAPIKey", err)
+	}
+}
@@ -1,31 +1,64 @@
 package services
 
 import (
-	"database/sql"
 	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 
-	"github.com/Anand078/rbac/internal/database"
+	"github.com/Anand078/rbac/internal/mail"
 	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/signing"
+	"github.com/Anand078/rbac/internal/storage"
 )
 
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
 type AuthService struct {
-	db        *database.DB
-	jwtSecret string
+	store      storage.Store
+	keys       *signing.KeyManager
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+
+	// claimsSource, when set, embeds an AuthzClaims snapshot in every access
+	// token.
+	claimsSource *RBACService
+	// federatedRoleID is assigned to users provisioned from external
+	// identities.
+	federatedRoleID uuid.UUID
+	// groupSync, when set, keeps the roles of external users in line with
+	// their groups at the provider.
+	groupSync *RBACService
+	// federation holds per-provider settings, by provider name.
+	federation map[string]FederationSettings
+	// mailer, when set, sends the emails of the verification and password
+	// reset flows configured by email.
+	mailer mail.Mailer
+	email  EmailSettings
+	mfa    MFASettings
+	// passkeys, when set, enables WebAuthn.
+	passkeys *PasskeySettings
 }
 
-func NewAuthService(db *database.DB, jwtSecret string) *AuthService {
+func NewAuthService(store storage.Store, keys *signing.KeyManager, accessTTL, refreshTTL time.Duration) *AuthService {
 	return &AuthService{
-		db:        db,
-		jwtSecret: jwtSecret,
+		store:      store,
+		keys:       keys,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
 	}
 }
 
+// EmbedAuthorizationClaims makes issued access tokens carry the user's roles
+// and permissions so they can be authorized from the token alone.
+func (s *AuthService) EmbedAuthorizationClaims(rbacService *RBACService) {
+	s.claimsSource = rbacService
+}
+
 func (s *AuthService) Register(req models.CreateUserRequest) (*models.User, error) {
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
@@ -33,13 +66,6 @@ func (s *AuthService) Register(req models.CreateUserRequest) (*models.User, erro
 		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 
-	// Start transaction
-	tx, err := s.db.Begin()
-	if err != nil {
-		return nil, err
-	}
-	defer tx.Rollback()
-
 	// Create user
 	user := &models.User{
 		ID:           uuid.New(),
@@ -48,114 +74,116 @@ func (s *AuthService) Register(req models.CreateUserRequest) (*models.User, erro
 		PasswordHash: string(hashedPassword),
 	}
 
-	query := `
-        INSERT INTO users (id, email, name, password_hash)
-        VALUES ($1, $2, $3, $4)
-        RETURNING created_at, updated_at
-    `
-	err = tx.QueryRow(query, user.ID, user.Email, user.Name, user.PasswordHash).
-		Scan(&user.CreatedAt, &user.UpdatedAt)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
-	}
-
 	// Assign default role if provided
+	roleID := uuid.Nil
 	if req.RoleID != "" {
-		roleID, err := uuid.Parse(req.RoleID)
-		if err != nil {
+		if roleID, err = uuid.Parse(req.RoleID); err != nil {
 			return nil, fmt.Errorf("invalid role ID: %w", err)
 		}
-
-		_, err = tx.Exec(
-			"INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2)",
-			user.ID, roleID,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to assign role: %w", err)
-		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := s.store.CreateUser(user, roleID); err != nil {
 		return nil, err
 	}
 
+	if s.mailer != nil {
+		if err := s.sendVerificationEmail(user); err != nil {
+			log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+		}
+	}
+
 	return user, nil
 }
 
-func (s *AuthService) Login(req models.LoginRequest) (*models.LoginResponse, error) {
-	var user models.User
-	query := `
-        SELECT id, email, name, password_hash, created_at, updated_at
-        FROM users
-        WHERE email = $1
-    `
-	err := s.db.QueryRow(query, req.Email).Scan(
-		&user.ID, &user.Email, &user.Name, &user.PasswordHash,
-		&user.CreatedAt, &user.UpdatedAt,
-	)
+// Login checks the user's password. When a second factor is needed it
+// returns a challenge to complete with VerifyMFA instead of tokens.
+func (s *AuthService) Login(req models.LoginRequest) (*models.LoginResponse, *models.MFAChallenge, error) {
+	user, err := s.store.GetUserByEmail(req.Email)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("invalid credentials")
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, nil, ErrInvalidCredentials
 		}
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, nil, ErrInvalidCredentials
+	}
+	if s.email.RequireVerification && user.EmailVerifiedAt == nil {
+		return nil, nil, ErrEmailNotVerified
+	}
+
+	tenantID, err := models.ParseTenantID(req.TenantID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid tenant ID: %w", err)
+	}
+
+	challenge, err := s.mfaChallenge(user, tenantID)
+	if err != nil || challenge != nil {
+		return nil, challenge, err
 	}
 
+	response, err := s.issueTokens(user, tenantID, uuid.New())
+	return response, nil, err
+}
+
+// issueTokens loads the user's roles and returns a fresh access token together
+// with a refresh token in the given rotation family.
+func (s *AuthService) issueTokens(user *models.User, tenantID, familyID uuid.UUID) (*models.LoginResponse, error) {
 	// Load user roles
-	roles, err := s.getUserRoles(user.ID)
+	roles, err := s.store.EffectiveRoles(user.ID, tenantID)
 	if err != nil {
 		return nil, err
 	}
 	user.Roles = roles
 
 	// Generate JWT token
-	token, err := s.generateToken(&user)
+	token, err := s.generateToken(user, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := s.createRefreshToken(user.ID, tenantID, familyID)
 	if err != nil {
 		return nil, err
 	}
 
 	return &models.LoginResponse{
-		Token: token,
-		User:  user,
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.accessTTL.Seconds()),
+		User:         *user,
 	}, nil
 }
 
-func (s *AuthService) generateToken(user *models.User) (string, error) {
+func (s *AuthService) generateToken(user *models.User, tenantID uuid.UUID) (string, error) {
+	claims, err := s.accessTokenClaims(user, tenantID)
+	if err != nil {
+		return "", err
+	}
+	return s.keys.Sign(claims)
+}
+
+// accessTokenClaims returns the claims of a new access token for user.
+func (s *AuthService) accessTokenClaims(user *models.User, tenantID uuid.UUID) (jwt.MapClaims, error) {
+	jti, now := newTokenID()
 	claims := jwt.MapClaims{
+		"jti":     jti.String(),
 		"user_id": user.ID.String(),
 		"email":   user.Email,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
+		"iat":     now.Unix(),
+		"exp":     now.Add(s.accessTTL).Unix(),
 	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.jwtSecret))
-}
-
-func (s *AuthService) getUserRoles(userID uuid.UUID) ([]models.Role, error) {
-	query := `
-        SELECT r.id, r.name, r.description, r.created_at
-        FROM roles r
-        JOIN user_roles ur ON r.id = ur.role_id
-        WHERE ur.user_id = $1
-    `
-	rows, err := s.db.Query(query, userID)
-	if err != nil {
-		return nil, err
+	if tenantID != models.GlobalTenantID {
+		claims["tenant_id"] = tenantID.String()
 	}
-	defer rows.Close()
-
-	var roles []models.Role
-	for rows.Next() {
-		var role models.Role
-		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt); err != nil {
-			return nil, err
+	if s.claimsSource != nil {
+		authz, err := s.claimsSource.AuthorizationClaims(user.ID, tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load authorization claims: %w", err)
 		}
-		roles = append(roles, role)
+		claims[AuthzClaimKey] = authz
 	}
-
-	return roles, nil
+	return claims, nil
 }
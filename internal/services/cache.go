@@ -0,0 +1,249 @@
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+// permissionEntry is the effective authorization state of a user in a
+// tenant: every role held directly or through inheritance and every grant
+// attached to those roles.
+type permissionEntry struct {
+	roles   []models.Role
+	roleIDs map[uuid.UUID]struct{}
+	grants  []storage.Grant
+	expires time.Time
+}
+
+type permissionCacheKey struct {
+	userID   uuid.UUID
+	tenantID uuid.UUID
+}
+
+// CacheStats reports the effectiveness of the permission cache.
+type CacheStats struct {
+	Enabled       bool  `json:"enabled"`
+	Entries       int   `json:"entries"`
+	MaxEntries    int   `json:"max_entries"`
+	Hits          int64 `json:"hits"`
+	Misses        int64 `json:"misses"`
+	Evictions     int64 `json:"evictions"`
+	Invalidations int64 `json:"invalidations"`
+}
+
+// permissionCache keeps permissionEntry values per (user, tenant). Entries
+// expire after ttl, or earlier when one of the user's assignments starts or
+// ends, and are dropped as soon as an event touches the user or one of the
+// roles in the entry.
+type permissionCache struct {
+	mu         sync.Mutex
+	entries    map[permissionCacheKey]*permissionEntry
+	ttl        time.Duration
+	maxEntries int
+	// generation is bumped on every invalidation so that a load that raced
+	// with one does not store an outdated entry.
+	generation uint64
+
+	hits, misses, evictions, invalidations atomic.Int64
+}
+
+func newPermissionCache(ttl time.Duration, maxEntries int) *permissionCache {
+	return &permissionCache{
+		entries:    make(map[permissionCacheKey]*permissionEntry),
+		ttl:        ttl,
+		maxEntries: maxEntries,
+	}
+}
+
+func (c *permissionCache) get(key permissionCacheKey) (*permissionEntry, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if ok && time.Now().Before(entry.expires) {
+		c.hits.Add(1)
+		return entry, c.generation
+	}
+	if ok {
+		delete(c.entries, key)
+	}
+	c.misses.Add(1)
+	return nil, c.generation
+}
+
+func (c *permissionCache) put(key permissionCacheKey, entry *permissionEntry, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.maxEntries {
+		c.evictLocked()
+	}
+	c.entries[key] = entry
+}
+
+// evictLocked drops expired entries, or an arbitrary one if none expired.
+func (c *permissionCache) evictLocked() {
+	now := time.Now()
+	evicted := 0
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, key)
+			evicted++
+		}
+	}
+	if evicted == 0 {
+		for key := range c.entries {
+			delete(c.entries, key)
+			evicted++
+			break
+		}
+	}
+	c.evictions.Add(int64(evicted))
+}
+
+func (c *permissionCache) invalidateUser(userID uuid.UUID) {
+	c.invalidate(func(key permissionCacheKey, _ *permissionEntry) bool {
+		return key.userID == userID
+	})
+}
+
+func (c *permissionCache) invalidateRole(roleID uuid.UUID) {
+	c.invalidate(func(_ permissionCacheKey, entry *permissionEntry) bool {
+		_, ok := entry.roleIDs[roleID]
+		return ok
+	})
+}
+
+func (c *permissionCache) invalidate(match func(permissionCacheKey, *permissionEntry) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for key, entry := range c.entries {
+		if match(key, entry) {
+			delete(c.entries, key)
+			c.invalidations.Add(1)
+		}
+	}
+}
+
+// handleEvent drops the entries an event may have changed. Attribute
+// changes are not cached and need no invalidation.
+func (c *permissionCache) handleEvent(event Event) {
+	switch event.Type {
+	case EventRoleAssigned, EventRoleRemoved, EventAssignmentExpired:
+		c.invalidateUser(event.UserID)
+	case EventParentRoleAdded, EventParentRoleRemoved, EventPermissionGranted, EventPermissionRevoked:
+		c.invalidateRole(event.RoleID)
+	}
+}
+
+func (c *permissionCache) stats() CacheStats {
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+
+	return CacheStats{
+		Enabled:       true,
+		Entries:       entries,
+		MaxEntries:    c.maxEntries,
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Evictions:     c.evictions.Load(),
+		Invalidations: c.invalidations.Load(),
+	}
+}
+
+// EnablePermissionCache caches each user's effective roles and grants for up
+// to ttl, keeping at most maxEntries (user, tenant) pairs. Changes made
+// through this service invalidate affected entries immediately; changes made
+// by other instances are picked up through a ChangeNotifier, or once entries
+// expire without one.
+func (s *RBACService) EnablePermissionCache(ttl time.Duration, maxEntries int) {
+	s.cache = newPermissionCache(ttl, maxEntries)
+	s.events.Subscribe(s.cache.handleEvent)
+}
+
+// CacheStats returns hit/miss counters for the permission cache.
+func (s *RBACService) CacheStats() CacheStats {
+	if s.cache == nil {
+		return CacheStats{}
+	}
+	return s.cache.stats()
+}
+
+// cachedPermissions returns the user's roles and grants in tenantID from the
+// cache, loading them on a miss. It must only be called with the cache
+// enabled.
+func (s *RBACService) cachedPermissions(userID, tenantID uuid.UUID) (*permissionEntry, error) {
+	key := permissionCacheKey{userID: userID, tenantID: tenantID}
+
+	entry, generation := s.cache.get(key)
+	if entry != nil {
+		return entry, nil
+	}
+
+	entry, err := s.loadPermissions(userID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.expires = time.Now().Add(s.cache.ttl)
+	boundary, err := s.store.NextAssignmentBoundary(userID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if boundary != nil && boundary.Before(entry.expires) {
+		entry.expires = *boundary
+	}
+	s.cache.put(key, entry, generation)
+	return entry, nil
+}
+
+func (s *RBACService) loadPermissions(userID, tenantID uuid.UUID) (*permissionEntry, error) {
+	roles, err := s.store.EffectiveRoles(userID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	grants, err := s.store.EffectiveGrants(userID, tenantID, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &permissionEntry{
+		roles:   roles,
+		roleIDs: make(map[uuid.UUID]struct{}, len(roles)),
+		grants:  grants,
+	}
+	for _, role := range roles {
+		entry.roleIDs[role.ID] = struct{}{}
+	}
+	return entry, nil
+}
+
+// applyRemoteEvent invalidates the local state affected by an event that
+// another instance published. It is not republished on the local bus, since
+// that instance already persisted its side effects.
+func (s *RBACService) applyRemoteEvent(event Event) {
+	if s.cache != nil {
+		s.cache.handleEvent(event)
+	}
+	s.policyVersion.expire()
+}
+
+// flushCaches drops every cached entry and the cached policy version.
+func (s *RBACService) flushCaches() {
+	if s.cache != nil {
+		s.cache.invalidate(func(permissionCacheKey, *permissionEntry) bool { return true })
+	}
+	s.policyVersion.expire()
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+	"github.com/Anand078/rbac/internal/storage/memory"
+)
+
+// hookedStore runs beforeGrants, once, when EffectiveGrants is next called,
+// to interleave a change with a cache load.
+type hookedStore struct {
+	storage.Store
+	beforeGrants func()
+}
+
+func (s *hookedStore) EffectiveGrants(userID, tenantID uuid.UUID, resource, action string) ([]storage.Grant, error) {
+	if hook := s.beforeGrants; hook != nil {
+		s.beforeGrants = nil
+		hook()
+	}
+	return s.Store.EffectiveGrants(userID, tenantID, resource, action)
+}
+
+func TestPermissionCacheSkipsLoadRacingInvalidation(t *testing.T) {
+	store := &hookedStore{Store: memory.New()}
+	f := newFixtureOn(t, store)
+	f.rbac.EnablePermissionCache(time.Minute, 100)
+	editor := f.role("editor")
+	f.grant(editor, "course", "read", models.EffectAllow, "")
+	user := f.user("ada@example.com")
+	f.assign(user, editor, models.GlobalTenantID)
+
+	// The deny lands after the load read the user's roles but before it read
+	// their grants, and before the loaded entry is stored. The invalidation
+	// finds nothing cached to drop, so only the generation keeps the entry
+	// out of the cache.
+	denyID := f.permission("course", "update")
+	store.beforeGrants = func() {
+		if err := f.rbac.GrantPermission(editor, denyID, models.EffectDeny, ""); err != nil {
+			t.Error(err)
+		}
+	}
+	f.decide(user, models.GlobalTenantID, "course", "read")
+	if stats := f.rbac.CacheStats(); stats.Entries != 0 {
+		t.Errorf("entry loaded during an invalidation was cached: %+v", stats)
+	}
+	if got := f.decide(user, models.GlobalTenantID, "course", "update"); got != DecisionDeny {
+		t.Errorf("Evaluate after the racing change = %v, want deny", got)
+	}
+	if stats := f.rbac.CacheStats(); stats.Entries != 1 {
+		t.Errorf("entry loaded without a racing change was not cached: %+v", stats)
+	}
+}
+
+func TestPermissionCachePutChecksGeneration(t *testing.T) {
+	cache := newPermissionCache(time.Minute, 10)
+	key := permissionCacheKey{userID: uuid.New()}
+	entry := &permissionEntry{expires: time.Now().Add(time.Minute)}
+
+	_, generation := cache.get(key)
+	cache.invalidateUser(uuid.New())
+	cache.put(key, entry, generation)
+	if got, _ := cache.get(key); got != nil {
+		t.Error("put stored an entry loaded before an invalidation")
+	}
+
+	_, generation = cache.get(key)
+	cache.put(key, entry, generation)
+	if got, _ := cache.get(key); got != entry {
+		t.Error("put dropped an entry of the current generation")
+	}
+}
+
+func TestPermissionCacheInvalidation(t *testing.T) {
+	f := newFixture(t)
+	f.rbac.EnablePermissionCache(time.Minute, 100)
+	editor := f.role("editor")
+	f.grant(editor, "course", "read", models.EffectAllow, "")
+	reviewer := f.role("reviewer")
+	ada := f.user("ada@example.com")
+	f.assign(ada, editor, models.GlobalTenantID)
+	bob := f.user("bob@example.com")
+	f.assign(bob, reviewer, models.GlobalTenantID)
+
+	warm := func() {
+		f.decide(ada, models.GlobalTenantID, "course", "read")
+		f.decide(bob, models.GlobalTenantID, "course", "read")
+	}
+	warm()
+	if stats := f.rbac.CacheStats(); stats.Entries != 2 {
+		t.Fatalf("cache entries = %d, want 2", stats.Entries)
+	}
+
+	// A grant change drops the entries holding the role only.
+	f.grant(editor, "course", "update", models.EffectAllow, "")
+	if stats := f.rbac.CacheStats(); stats.Entries != 1 {
+		t.Errorf("after grant: cache entries = %d, want 1", stats.Entries)
+	}
+	if got := f.decide(ada, models.GlobalTenantID, "course", "update"); got != DecisionAllow {
+		t.Errorf("new grant not visible through the cache: %v", got)
+	}
+
+	// An assignment change drops the user's entries.
+	warm()
+	f.assign(bob, editor, models.GlobalTenantID)
+	if got := f.decide(bob, models.GlobalTenantID, "course", "read"); got != DecisionAllow {
+		t.Errorf("new assignment not visible through the cache: %v", got)
+	}
+	if err := f.rbac.RemoveRole(ada, editor, models.GlobalTenantID); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.decide(ada, models.GlobalTenantID, "course", "read"); got != DecisionNoMatch {
+		t.Errorf("removed role still applies through the cache: %v", got)
+	}
+}
+
+func TestPermissionCacheEviction(t *testing.T) {
+	cache := newPermissionCache(time.Minute, 2)
+	live := &permissionEntry{expires: time.Now().Add(time.Minute)}
+	expired := &permissionEntry{expires: time.Now().Add(-time.Second)}
+	a, b, c := permissionCacheKey{userID: uuid.New()}, permissionCacheKey{userID: uuid.New()}, permissionCacheKey{userID: uuid.New()}
+
+	cache.put(a, live, 0)
+	cache.put(b, expired, 0)
+	cache.put(c, live, 0)
+	if got, _ := cache.get(a); got != live {
+		t.Error("a live entry was evicted while an expired one was present")
+	}
+	if stats := cache.stats(); stats.Entries != 2 || stats.Evictions != 1 {
+		t.Errorf("stats = %+v, want 2 entries and 1 eviction", stats)
+	}
+	if got, _ := cache.get(b); got != nil {
+		t.Error("expired entry was returned")
+	}
+}
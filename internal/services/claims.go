@@ -0,0 +1,212 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+)
+
+// AuthzClaimKey is the JWT claim that carries AuthzClaims.
+const AuthzClaimKey = "authz"
+
+// policyVersionTTL bounds how long a cached policy version is trusted before
+// it is re-read. Changes made by this instance update the cache immediately;
+// changes made by other instances are noticed within this window, or as soon
+// as their notification arrives when a ChangeNotifier is running.
+const policyVersionTTL = 5 * time.Second
+
+// AuthzClaims is a snapshot of a user's authorization state embedded in an
+// access token, so requests can be authorized without a database round trip.
+// Permissions are encoded as "resource:action"; actions never contain ':'.
+type AuthzClaims struct {
+	// Roles are the user's global roles, directly held or inherited, as
+	// checked by RequireRole.
+	Roles []string `json:"roles"`
+	// Allow, Deny and Conditional hold the permissions granted to the user in
+	// the token's tenant. Conditional grants cannot be decided from the token.
+	Allow       []string `json:"allow,omitempty"`
+	Deny        []string `json:"deny,omitempty"`
+	Conditional []string `json:"cond,omitempty"`
+	// Version is the policy version the snapshot was taken at.
+	Version int64 `json:"v"`
+	// Until, when set, is the Unix time at which one of the user's
+	// assignments starts or ends, after which the snapshot is outdated.
+	Until int64 `json:"until,omitempty"`
+}
+
+// ParseAuthzClaims decodes the authz claim of a parsed token.
+func ParseAuthzClaims(raw any) (*AuthzClaims, bool) {
+	if raw == nil {
+		return nil, false
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, false
+	}
+	var claims AuthzClaims
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, false
+	}
+	return &claims, true
+}
+
+func encodePermission(resource, action string) string {
+	return resource + ":" + action
+}
+
+func matchEncoded(encoded []string, resource, action string) bool {
+	for _, permission := range encoded {
+		i := strings.LastIndex(permission, ":")
+		if i < 0 {
+			continue
+		}
+		if MatchPermission(permission[:i], permission[i+1:], resource, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// Decide evaluates the snapshot with the same deny-overrides rules as
+// RBACService.Evaluate. It reports false when the outcome depends on a
+// conditional grant and must be decided by Evaluate instead.
+func (a *AuthzClaims) Decide(resource, action string) (Decision, bool) {
+	if matchEncoded(a.Deny, resource, action) {
+		return DecisionDeny, true
+	}
+	if matchEncoded(a.Conditional, resource, action) {
+		return DecisionNoMatch, false
+	}
+	if matchEncoded(a.Allow, resource, action) {
+		return DecisionAllow, true
+	}
+	return DecisionNoMatch, true
+}
+
+func (a *AuthzClaims) HasRole(name string) bool {
+	for _, role := range a.Roles {
+		if role == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsCurrent reports whether the snapshot still reflects the current policy.
+func (s *RBACService) IsCurrent(a *AuthzClaims) bool {
+	if a.Until != 0 && time.Now().Unix() >= a.Until {
+		return false
+	}
+	version, err := s.PolicyVersion()
+	return err == nil && version == a.Version
+}
+
+// AuthorizationClaims takes a snapshot of the user's roles and permissions in
+// tenantID for embedding into an access token.
+func (s *RBACService) AuthorizationClaims(userID, tenantID uuid.UUID) (*AuthzClaims, error) {
+	// Read the version first: a change landing while the snapshot is taken
+	// then makes the snapshot stale rather than silently wrong.
+	claims := &AuthzClaims{Roles: []string{}}
+	version, err := s.store.PolicyVersion()
+	if err != nil {
+		return nil, err
+	}
+	claims.Version = version
+
+	roles, err := s.store.EffectiveRoles(userID, models.GlobalTenantID)
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range roles {
+		claims.Roles = append(claims.Roles, role.Name)
+	}
+
+	grants, err := s.store.EffectiveGrants(userID, tenantID, "", "")
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(grants, func(i, j int) bool {
+		if grants[i].Resource != grants[j].Resource {
+			return grants[i].Resource < grants[j].Resource
+		}
+		return grants[i].Action < grants[j].Action
+	})
+	for _, g := range grants {
+		permission := encodePermission(g.Resource, g.Action)
+		switch {
+		case g.Condition != "":
+			claims.Conditional = append(claims.Conditional, permission)
+		case g.Effect == models.EffectDeny:
+			claims.Deny = append(claims.Deny, permission)
+		default:
+			claims.Allow = append(claims.Allow, permission)
+		}
+	}
+
+	until, err := s.store.NextAssignmentBoundary(userID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if until != nil {
+		claims.Until = until.Unix()
+	}
+
+	return claims, nil
+}
+
+// policyVersionCache holds the last known policy version.
+type policyVersionCache struct {
+	mu      sync.Mutex
+	version int64
+	fetched time.Time
+}
+
+// expire forces the next PolicyVersion call to re-read the version.
+func (c *policyVersionCache) expire() {
+	c.mu.Lock()
+	c.fetched = time.Time{}
+	c.mu.Unlock()
+}
+
+// PolicyVersion returns the current policy version. Every change to roles,
+// assignments, grants or user attributes increments it.
+func (s *RBACService) PolicyVersion() (int64, error) {
+	s.policyVersion.mu.Lock()
+	defer s.policyVersion.mu.Unlock()
+
+	if time.Since(s.policyVersion.fetched) < policyVersionTTL {
+		return s.policyVersion.version, nil
+	}
+
+	version, err := s.store.PolicyVersion()
+	if err != nil {
+		return 0, err
+	}
+	s.policyVersion.version = version
+	s.policyVersion.fetched = time.Now()
+	return version, nil
+}
+
+// bumpPolicyVersion is subscribed to the event bus so that every published
+// change invalidates outstanding authorization snapshots.
+func (s *RBACService) bumpPolicyVersion(event Event) {
+	version, err := s.store.BumpPolicyVersion()
+
+	s.policyVersion.mu.Lock()
+	defer s.policyVersion.mu.Unlock()
+	if err != nil {
+		log.Printf("Failed to bump policy version after %s: %v", event.Type, err)
+		// Force a re-read rather than trust a version that may be behind.
+		s.policyVersion.fetched = time.Time{}
+		return
+	}
+	s.policyVersion.version = version
+	s.policyVersion.fetched = time.Now()
+}
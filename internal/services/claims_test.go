@@ -0,0 +1,120 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func parseAuthzClaims(t *testing.T, auth *AuthService, raw string) *AuthzClaims {
+	t.Helper()
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(raw, claims, auth.keys.Keyfunc); err != nil {
+		t.Fatalf("parse access token: %v", err)
+	}
+	authz, ok := ParseAuthzClaims(claims[AuthzClaimKey])
+	if !ok {
+		t.Fatal("access token carries no authz claim")
+	}
+	return authz
+}
+
+func TestAuthzClaimsDecide(t *testing.T) {
+	claims := &AuthzClaims{
+		Allow:       []string{"course:*", "grades/*:read", "reports:export"},
+		Deny:        []string{"course:delete", "grades/2024:read"},
+		Conditional: []string{"reports:*"},
+	}
+	tests := []struct {
+		resource, action string
+		want             Decision
+		decided          bool
+	}{
+		{"course", "read", DecisionAllow, true},
+		{"course", "delete", DecisionDeny, true},
+		{"grades/2025", "read", DecisionAllow, true},
+		{"grades/2024", "read", DecisionDeny, true},
+		{"grades/2024", "update", DecisionNoMatch, true},
+		{"reports", "export", DecisionNoMatch, false},
+		{"roster", "read", DecisionNoMatch, true},
+	}
+	for _, tt := range tests {
+		got, decided := claims.Decide(tt.resource, tt.action)
+		if got != tt.want || decided != tt.decided {
+			t.Errorf("Decide(%s, %s) = %v, %v; want %v, %v", tt.resource, tt.action, got, decided, tt.want, tt.decided)
+		}
+	}
+}
+
+func TestClaimsOutdatedByPolicyChange(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store storage.Store) {
+		f := newFixtureOn(t, store)
+		auth := newTestAuth(store)
+		auth.EmbedAuthorizationClaims(f.rbac)
+		userID := register(t, auth, "ada@example.com")
+		editor := f.role("editor")
+		f.grant(editor, "course", "read", models.EffectAllow, "")
+		f.assign(userID, editor, models.GlobalTenantID)
+
+		before := parseAuthzClaims(t, auth, login(t, auth, "ada@example.com").Token)
+		if !before.HasRole("editor") {
+			t.Errorf("snapshot roles = %v, want editor", before.Roles)
+		}
+		if got, _ := before.Decide("course", "read"); got != DecisionAllow {
+			t.Errorf("snapshot Decide(course, read) = %v, want allow", got)
+		}
+		if !f.rbac.IsCurrent(before) {
+			t.Fatal("fresh snapshot is not current")
+		}
+
+		// A grant added after the token was minted outdates the snapshot, even
+		// though everything the token allows is still allowed.
+		f.grant(editor, "course", "update", models.EffectAllow, "")
+		if f.rbac.IsCurrent(before) {
+			t.Error("snapshot minted before a grant change is still current")
+		}
+		after := parseAuthzClaims(t, auth, login(t, auth, "ada@example.com").Token)
+		if !f.rbac.IsCurrent(after) {
+			t.Error("snapshot minted after the change is not current")
+		}
+		if got, _ := after.Decide("course", "update"); got != DecisionAllow {
+			t.Errorf("new snapshot Decide(course, update) = %v, want allow", got)
+		}
+
+		// So does any other change, such as revoking a permission.
+		if err := f.rbac.RevokePermission(editor, f.permission("course", "update")); err != nil {
+			t.Fatal(err)
+		}
+		if f.rbac.IsCurrent(after) {
+			t.Error("snapshot minted before a revocation is still current")
+		}
+	})
+}
+
+func TestClaimsOutdatedAtAssignmentBoundary(t *testing.T) {
+	f := newFixture(t)
+	auth := newTestAuth(f.store)
+	auth.EmbedAuthorizationClaims(f.rbac)
+	userID := register(t, auth, "ada@example.com")
+	editor := f.role("editor")
+	until := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := f.rbac.AssignRole(models.RoleAssignment{UserID: userID, RoleID: editor, ValidUntil: &until}); err != nil {
+		t.Fatal(err)
+	}
+
+	claims := parseAuthzClaims(t, auth, login(t, auth, "ada@example.com").Token)
+	if claims.Until != until.Unix() {
+		t.Fatalf("snapshot Until = %d, want %d", claims.Until, until.Unix())
+	}
+	if !f.rbac.IsCurrent(claims) {
+		t.Fatal("snapshot is not current before its boundary")
+	}
+	claims.Until = time.Now().Unix()
+	if f.rbac.IsCurrent(claims) {
+		t.Error("snapshot is current past the end of an assignment")
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Anand078/rbac/internal/condition"
+	"github.com/Anand078/rbac/internal/models"
+)
+
+// maxCachedConditions bounds the compiled-expression cache. Conditions are
+// authored by administrators, so the set in use is small; the bound only
+// guards against unbounded growth from churn.
+const maxCachedConditions = 1024
+
+// conditionCache keeps compiled grant conditions keyed by their source so
+// Evaluate does not re-parse them on every request.
+type conditionCache struct {
+	mu    sync.RWMutex
+	exprs map[string]*condition.Expr
+}
+
+func newConditionCache() *conditionCache {
+	return &conditionCache{exprs: make(map[string]*condition.Expr)}
+}
+
+func (c *conditionCache) compile(source string) (*condition.Expr, error) {
+	c.mu.RLock()
+	expr, ok := c.exprs[source]
+	c.mu.RUnlock()
+	if ok {
+		return expr, nil
+	}
+
+	expr, err := condition.Compile(source)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if len(c.exprs) >= maxCachedConditions {
+		c.exprs = make(map[string]*condition.Expr)
+	}
+	c.exprs[source] = expr
+	c.mu.Unlock()
+
+	return expr, nil
+}
+
+func (c *conditionCache) eval(source string, env map[string]any) (bool, error) {
+	expr, err := c.compile(source)
+	if err != nil {
+		return false, err
+	}
+	return expr.Eval(env)
+}
+
+// conditionEnv builds the attributes a condition can read:
+//
+//	user.id and every stored user attribute
+//	resource.type, resource.id and req.ResourceAttributes
+//	request.time, request.ip, request.action and request.tenant_id
+func (s *RBACService) conditionEnv(req AccessRequest) (map[string]any, error) {
+	user, err := s.GetUserAttributes(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	user["id"] = req.UserID.String()
+
+	resource := make(map[string]any, len(req.ResourceAttributes)+2)
+	for key, value := range req.ResourceAttributes {
+		resource[key] = value
+	}
+	resource["type"] = req.Resource
+	if req.ResourceID != "" {
+		resource["id"] = req.ResourceID
+	}
+
+	now := req.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	request := map[string]any{
+		"time":   now,
+		"ip":     req.ClientIP,
+		"action": req.Action,
+	}
+	if req.TenantID != models.GlobalTenantID {
+		request["tenant_id"] = req.TenantID.String()
+	}
+
+	return map[string]any{
+		"user":     user,
+		"resource": resource,
+		"request":  request,
+	}, nil
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+)
+
+func TestEvaluateConditions(t *testing.T) {
+	f := newFixture(t)
+
+	// Every grant below is alone on its permission, so each request sees
+	// exactly the grants named in the test.
+	staff := f.role("staff")
+	f.grant(staff, "course", "read", models.EffectAllow, "user.department == 'math'")
+	f.grant(staff, "course", "update", models.EffectAllow, "user.level")
+	f.grant(staff, "grades", "read", models.EffectAllow, "")
+	f.grant(staff, "grades", "update", models.EffectAllow, "")
+	f.grant(staff, "reports", "read", models.EffectAllow, "resource.owner == user.id")
+	f.grant(staff, "library", "read", models.EffectAllow, "in_cidr(request.ip, '10.0.0.0/8')")
+	f.grant(staff, "exams", "read", models.EffectAllow, "request.time < time('2024-01-01T00:00:00Z')")
+	f.grant(staff, "archive", "read", models.EffectAllow, "user.missing == 'x'")
+	guard := f.role("guard")
+	f.grant(guard, "grades", "read", models.EffectDeny, "user.level")
+	f.grant(guard, "grades", "update", models.EffectDeny, "user.level > 5")
+
+	math := f.user("math@example.com")
+	f.assign(math, staff, models.GlobalTenantID)
+	f.assign(math, guard, models.GlobalTenantID)
+	if err := f.rbac.SetUserAttributes(math, map[string]any{"department": "math", "level": float64(3)}); err != nil {
+		t.Fatal(err)
+	}
+	other := f.user("other@example.com")
+	f.assign(other, staff, models.GlobalTenantID)
+
+	before := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		req  AccessRequest
+		want Decision
+	}{
+		{"condition holds", AccessRequest{UserID: math, Resource: "course", Action: "read"}, DecisionAllow},
+		{"missing attribute fails the condition", AccessRequest{UserID: other, Resource: "course", Action: "read"}, DecisionNoMatch},
+		{"failing condition never allows", AccessRequest{UserID: math, Resource: "course", Action: "update"}, DecisionNoMatch},
+		{"failing condition still denies", AccessRequest{UserID: math, Resource: "grades", Action: "read"}, DecisionDeny},
+		{"false deny condition does not apply", AccessRequest{UserID: math, Resource: "grades", Action: "update"}, DecisionAllow},
+		{"unconditional allow without the deny", AccessRequest{UserID: other, Resource: "grades", Action: "read"}, DecisionAllow},
+		{"resource attribute matches", AccessRequest{UserID: math, Resource: "reports", Action: "read",
+			ResourceAttributes: map[string]any{"owner": math.String()}}, DecisionAllow},
+		{"resource attribute differs", AccessRequest{UserID: math, Resource: "reports", Action: "read",
+			ResourceAttributes: map[string]any{"owner": uuid.NewString()}}, DecisionNoMatch},
+		{"resource attribute missing", AccessRequest{UserID: math, Resource: "reports", Action: "read"}, DecisionNoMatch},
+		{"client IP inside range", AccessRequest{UserID: math, Resource: "library", Action: "read", ClientIP: "10.0.0.7"}, DecisionAllow},
+		{"client IP outside range", AccessRequest{UserID: math, Resource: "library", Action: "read", ClientIP: "192.0.2.1"}, DecisionNoMatch},
+		{"request time before cutoff", AccessRequest{UserID: math, Resource: "exams", Action: "read", Time: before}, DecisionAllow},
+		{"current time after cutoff", AccessRequest{UserID: math, Resource: "exams", Action: "read"}, DecisionNoMatch},
+		{"null never equals a value", AccessRequest{UserID: math, Resource: "archive", Action: "read"}, DecisionNoMatch},
+	}
+	for _, tt := range tests {
+		got, err := f.rbac.Evaluate(tt.req)
+		if err != nil {
+			t.Errorf("%s: Evaluate: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: Evaluate(%s, %s) = %v, want %v", tt.name, tt.req.Resource, tt.req.Action, got, tt.want)
+		}
+	}
+}
+
+func TestGrantPermissionRejectsInvalidCondition(t *testing.T) {
+	f := newFixture(t)
+	role := f.role("staff")
+	permission := f.permission("course", "read")
+
+	for _, condition := range []string{"user.id ==", "account.id == 1", "exec('rm')"} {
+		if err := f.rbac.GrantPermission(role, permission, models.EffectAllow, condition); err == nil {
+			t.Errorf("GrantPermission accepted condition %q", condition)
+		}
+	}
+	grants, err := f.rbac.GetRolePermissions(role)
+	if err != nil || len(grants) != 0 {
+		t.Errorf("rejected grants were stored: %v, %v", grants, err)
+	}
+}
+
+func TestClaimsLeaveConditionalGrantsToEvaluate(t *testing.T) {
+	claims := &AuthzClaims{
+		Allow:       []string{"course:read", "grades:*"},
+		Deny:        []string{"grades:delete"},
+		Conditional: []string{"course:update", "grades:update"},
+	}
+	tests := []struct {
+		resource, action string
+		want             Decision
+		decided          bool
+	}{
+		{"course", "read", DecisionAllow, true},
+		{"course", "update", DecisionNoMatch, false},
+		{"grades", "delete", DecisionDeny, true},
+		// A conditional grant could be a deny, so it defers even an allow.
+		{"grades", "update", DecisionNoMatch, false},
+		{"grades", "read", DecisionAllow, true},
+		{"reports", "read", DecisionNoMatch, true},
+	}
+	for _, tt := range tests {
+		got, decided := claims.Decide(tt.resource, tt.action)
+		if got != tt.want || decided != tt.decided {
+			t.Errorf("Decide(%s, %s) = %v, %v; want %v, %v", tt.resource, tt.action, got, decided, tt.want, tt.decided)
+		}
+	}
+}
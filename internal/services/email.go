@@ -0,0 +1,218 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/Anand078/rbac/internal/mail"
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+var (
+	ErrEmailNotVerified  = errors.New("email address not verified")
+	ErrInvalidEmailToken = errors.New("invalid or expired token")
+	ErrMailerDisabled    = errors.New("email delivery is not configured")
+)
+
+// EmailSettings configures the email verification and password reset flows.
+type EmailSettings struct {
+	// VerificationURL and ResetURL are the pages the emailed links open, with
+	// the token in the token query parameter. When empty, emails carry the
+	// bare token.
+	VerificationURL string
+	ResetURL        string
+	VerificationTTL time.Duration
+	ResetTTL        time.Duration
+	// RequireVerification makes Login refuse users whose email is not
+	// verified.
+	RequireVerification bool
+}
+
+// EnableEmailFlows sends a verification email on registration and enables
+// email verification and password reset through mailer.
+func (s *AuthService) EnableEmailFlows(mailer mail.Mailer, settings EmailSettings) {
+	s.mailer = mailer
+	s.email = settings
+}
+
+// Email Verification
+
+// RequestEmailVerification sends a new verification link to the user with
+// email. Unknown and already verified addresses are ignored, so the result
+// does not reveal which accounts exist.
+func (s *AuthService) RequestEmailVerification(email string) error {
+	if s.mailer == nil {
+		return ErrMailerDisabled
+	}
+	user, err := s.emailRecipient(email)
+	if err != nil || user == nil || user.EmailVerifiedAt != nil {
+		return err
+	}
+	return s.sendVerificationEmail(user)
+}
+
+// VerifyEmail consumes a verification token and marks the email of its user
+// verified.
+func (s *AuthService) VerifyEmail(token string) error {
+	record, err := s.store.ConsumeEmailToken(hashToken(token), storage.EmailTokenVerifyEmail)
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			return ErrInvalidEmailToken
+		}
+		return err
+	}
+	return s.store.MarkEmailVerified(record.UserID, time.Now())
+}
+
+func (s *AuthService) sendVerificationEmail(user *models.User) error {
+	token, err := s.createEmailToken(user.ID, storage.EmailTokenVerifyEmail, s.email.VerificationTTL)
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf("Hello %s,\n\nConfirm your email address with this %s:\n\n%s\n\nIt expires in %s.\n",
+		user.Name, linkOrCode(s.email.VerificationURL), emailLink(s.email.VerificationURL, token),
+		formatTTL(s.email.VerificationTTL))
+	s.deliver(mail.Message{To: user.Email, Subject: "Confirm your email address", Body: body})
+	return nil
+}
+
+// Password Reset
+
+// RequestPasswordReset sends a password reset link to the user with email.
+// Unknown addresses are ignored, so the result does not reveal which accounts
+// exist.
+func (s *AuthService) RequestPasswordReset(email string) error {
+	if s.mailer == nil {
+		return ErrMailerDisabled
+	}
+	user, err := s.emailRecipient(email)
+	if err != nil || user == nil {
+		return err
+	}
+
+	token, err := s.createEmailToken(user.ID, storage.EmailTokenResetPassword, s.email.ResetTTL)
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf("Hello %s,\n\nReset your password with this %s:\n\n%s\n\nIt expires in %s. "+
+		"If you did not ask for a password reset, ignore this email.\n",
+		user.Name, linkOrCode(s.email.ResetURL), emailLink(s.email.ResetURL, token), formatTTL(s.email.ResetTTL))
+	s.deliver(mail.Message{To: user.Email, Subject: "Reset your password", Body: body})
+	return nil
+}
+
+// ResetPassword consumes a password reset token, sets the new password and
+// signs the user out everywhere. Receiving the token also proves the user
+// owns the email.
+func (s *AuthService) ResetPassword(req models.ResetPasswordRequest) error {
+	record, err := s.store.ConsumeEmailToken(hashToken(req.Token), storage.EmailTokenResetPassword)
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			return ErrInvalidEmailToken
+		}
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	if err := s.store.UpdatePassword(record.UserID, string(hashedPassword)); err != nil {
+		return err
+	}
+	if err := s.store.MarkEmailVerified(record.UserID, time.Now()); err != nil {
+		return err
+	}
+	return s.store.RevokeAllSessions(record.UserID)
+}
+
+// emailRecipient returns the user with email, or nil if there is none or it
+// is a service account, which has no mailbox.
+func (s *AuthService) emailRecipient(email string) (*models.User, error) {
+	if strings.HasSuffix(email, "@"+models.ServiceAccountDomain) {
+		return nil, nil
+	}
+	user, err := s.store.GetUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *AuthService) createEmailToken(userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	err = s.store.CreateEmailToken(storage.EmailToken{
+		TokenHash: hashToken(token),
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// deliver sends msg in the background, so responses take as long whether or
+// not an email was sent.
+func (s *AuthService) deliver(msg mail.Message) {
+	go func() {
+		if err := s.mailer.Send(msg); err != nil {
+			log.Printf("Failed to send %q email: %v", msg.Subject, err)
+		}
+	}()
+}
+
+func emailLink(base, token string) string {
+	if base == "" {
+		return token
+	}
+	link, err := url.Parse(base)
+	if err != nil {
+		return token
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
+
+func linkOrCode(base string) string {
+	if base == "" {
+		return "code"
+	}
+	return "link"
+}
+
+// formatTTL renders whole hours and minutes the way people write them.
+func formatTTL(ttl time.Duration) string {
+	switch {
+	case ttl%time.Hour == 0 && ttl >= time.Hour:
+		return plural(int(ttl/time.Hour), "hour")
+	case ttl%time.Minute == 0 && ttl >= time.Minute:
+		return plural(int(ttl/time.Minute), "minute")
+	default:
+		return ttl.String()
+	}
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
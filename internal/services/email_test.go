@@ -0,0 +1,275 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Anand078/rbac/internal/mail"
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+// capturingMailer hands the messages it is sent to the test. Sends block
+// while hold is open.
+type capturingMailer struct {
+	sent chan mail.Message
+	hold chan struct{}
+}
+
+func newCapturingMailer() *capturingMailer {
+	return &capturingMailer{sent: make(chan mail.Message, 16)}
+}
+
+func (m *capturingMailer) Send(msg mail.Message) error {
+	if m.hold != nil {
+		<-m.hold
+	}
+	m.sent <- msg
+	return nil
+}
+
+// next waits for the next message, which is delivered in the background.
+func (m *capturingMailer) next(t *testing.T) mail.Message {
+	t.Helper()
+	select {
+	case msg := <-m.sent:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email was sent")
+		return mail.Message{}
+	}
+}
+
+// none fails if a message arrives shortly.
+func (m *capturingMailer) none(t *testing.T, context string) {
+	t.Helper()
+	select {
+	case msg := <-m.sent:
+		t.Errorf("%s: sent %q to %s", context, msg.Subject, msg.To)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// emailToken returns the token of the link in msg.
+func emailToken(t *testing.T, msg mail.Message) string {
+	t.Helper()
+	for _, line := range strings.Split(msg.Body, "\n") {
+		if !strings.HasPrefix(line, "https://") {
+			continue
+		}
+		link, err := url.Parse(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return link.Query().Get("token")
+	}
+	t.Fatalf("no link in %q", msg.Body)
+	return ""
+}
+
+var testEmailSettings = EmailSettings{
+	VerificationURL: "https://app.example.com/verify",
+	ResetURL:        "https://app.example.com/reset",
+	VerificationTTL: 24 * time.Hour,
+	ResetTTL:        time.Hour,
+}
+
+func newEmailAuth(store storage.Store, settings EmailSettings) (*AuthService, *capturingMailer) {
+	auth := newTestAuth(store)
+	mailer := newCapturingMailer()
+	auth.EnableEmailFlows(mailer, settings)
+	return auth, mailer
+}
+
+func TestEmailVerification(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store storage.Store) {
+		settings := testEmailSettings
+		settings.RequireVerification = true
+		auth, mailer := newEmailAuth(store, settings)
+
+		// Registering sends the first link; signing in waits for it.
+		userID := register(t, auth, "ada@example.com")
+		msg := mailer.next(t)
+		if msg.To != "ada@example.com" || msg.Subject != "Confirm your email address" ||
+			!strings.Contains(msg.Body, "https://app.example.com/verify?token=") || !strings.Contains(msg.Body, "24 hours") {
+			t.Errorf("verification email = %+v", msg)
+		}
+		first := emailToken(t, msg)
+		if _, _, err := auth.Login(models.LoginRequest{Email: "ada@example.com", Password: testPassword}); !errors.Is(err, ErrEmailNotVerified) {
+			t.Errorf("unverified: Login = %v, want ErrEmailNotVerified", err)
+		}
+		if _, _, err := auth.Login(models.LoginRequest{Email: "ada@example.com", Password: "wrong"}); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("unverified, wrong password: Login = %v, want ErrInvalidCredentials", err)
+		}
+
+		// Asking again replaces the link.
+		if err := auth.RequestEmailVerification("ada@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		latest := emailToken(t, mailer.next(t))
+		if err := auth.VerifyEmail(first); !errors.Is(err, ErrInvalidEmailToken) {
+			t.Errorf("superseded token: VerifyEmail = %v, want ErrInvalidEmailToken", err)
+		}
+		if err := auth.VerifyEmail("not-a-token"); !errors.Is(err, ErrInvalidEmailToken) {
+			t.Errorf("unknown token: VerifyEmail = %v, want ErrInvalidEmailToken", err)
+		}
+		if err := auth.VerifyEmail(latest); err != nil {
+			t.Fatalf("VerifyEmail = %v", err)
+		}
+		if err := auth.VerifyEmail(latest); !errors.Is(err, ErrInvalidEmailToken) {
+			t.Errorf("used token: VerifyEmail = %v, want ErrInvalidEmailToken", err)
+		}
+		user, err := store.GetUserByID(userID)
+		if err != nil || user.EmailVerifiedAt == nil {
+			t.Errorf("after VerifyEmail: user = %+v, %v; want the email verified", user, err)
+		}
+		login(t, auth, "ada@example.com")
+
+		// Verified and unknown addresses get no email, with the same answer.
+		for _, email := range []string{"ada@example.com", "nobody@example.com", "batch@" + models.ServiceAccountDomain} {
+			if err := auth.RequestEmailVerification(email); err != nil {
+				t.Errorf("%s: RequestEmailVerification = %v", email, err)
+			}
+		}
+		mailer.none(t, "RequestEmailVerification")
+
+		// A token of the other flow does not verify an email.
+		if err := auth.RequestPasswordReset("ada@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		if err := auth.VerifyEmail(emailToken(t, mailer.next(t))); !errors.Is(err, ErrInvalidEmailToken) {
+			t.Errorf("reset token: VerifyEmail = %v, want ErrInvalidEmailToken", err)
+		}
+	})
+}
+
+func TestPasswordReset(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store storage.Store) {
+		auth, mailer := newEmailAuth(store, testEmailSettings)
+		userID := register(t, auth, "ada@example.com")
+		mailer.next(t) // verification
+		session := login(t, auth, "ada@example.com")
+
+		if err := auth.RequestPasswordReset("ada@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		msg := mailer.next(t)
+		if msg.To != "ada@example.com" || msg.Subject != "Reset your password" ||
+			!strings.Contains(msg.Body, "https://app.example.com/reset?token=") || !strings.Contains(msg.Body, "1 hour") {
+			t.Errorf("reset email = %+v", msg)
+		}
+		first := emailToken(t, msg)
+		if err := auth.RequestPasswordReset("ada@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		latest := emailToken(t, mailer.next(t))
+
+		const newPassword = "battery staple"
+		if err := auth.ResetPassword(models.ResetPasswordRequest{Token: first, Password: newPassword}); !errors.Is(err, ErrInvalidEmailToken) {
+			t.Errorf("superseded token: ResetPassword = %v, want ErrInvalidEmailToken", err)
+		}
+		if err := auth.ResetPassword(models.ResetPasswordRequest{Token: latest, Password: newPassword}); err != nil {
+			t.Fatalf("ResetPassword = %v", err)
+		}
+		if err := auth.ResetPassword(models.ResetPasswordRequest{Token: latest, Password: "third password"}); !errors.Is(err, ErrInvalidEmailToken) {
+			t.Errorf("used token: ResetPassword = %v, want ErrInvalidEmailToken", err)
+		}
+
+		// The new password works, the old one does not, and every session
+		// from before the reset has ended.
+		if _, _, err := auth.Login(models.LoginRequest{Email: "ada@example.com", Password: testPassword}); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("old password: Login = %v, want ErrInvalidCredentials", err)
+		}
+		if _, _, err := auth.Login(models.LoginRequest{Email: "ada@example.com", Password: newPassword}); err != nil {
+			t.Errorf("new password: Login = %v", err)
+		}
+		if !isRevoked(t, auth, parseAccessToken(t, auth, session.Token)) {
+			t.Error("access token from before the reset is still valid")
+		}
+		if _, err := auth.Refresh(models.RefreshTokenRequest{RefreshToken: session.RefreshToken}); !errors.Is(err, ErrRefreshTokenReused) {
+			t.Errorf("refresh token from before the reset: Refresh = %v, want ErrRefreshTokenReused", err)
+		}
+		// Receiving the email proved the address.
+		user, err := store.GetUserByID(userID)
+		if err != nil || user.EmailVerifiedAt == nil {
+			t.Errorf("after ResetPassword: user = %+v, %v; want the email verified", user, err)
+		}
+	})
+}
+
+func TestPasswordResetUnknownEmail(t *testing.T) {
+	f := newFixture(t)
+	auth, mailer := newEmailAuth(f.store, testEmailSettings)
+
+	// Unknown addresses and service accounts get the same answer as users
+	// and no email.
+	for _, email := range []string{"nobody@example.com", "batch@" + models.ServiceAccountDomain, ""} {
+		if err := auth.RequestPasswordReset(email); err != nil {
+			t.Errorf("%q: RequestPasswordReset = %v, want nil", email, err)
+		}
+	}
+	mailer.none(t, "RequestPasswordReset of an unknown address")
+
+	if err := auth.ResetPassword(models.ResetPasswordRequest{Token: "not-a-token", Password: "battery staple"}); !errors.Is(err, ErrInvalidEmailToken) {
+		t.Errorf("unknown token: ResetPassword = %v, want ErrInvalidEmailToken", err)
+	}
+
+	disabled := newTestAuth(f.store)
+	if err := disabled.RequestPasswordReset("nobody@example.com"); !errors.Is(err, ErrMailerDisabled) {
+		t.Errorf("without a mailer: RequestPasswordReset = %v, want ErrMailerDisabled", err)
+	}
+	if err := disabled.RequestEmailVerification("nobody@example.com"); !errors.Is(err, ErrMailerDisabled) {
+		t.Errorf("without a mailer: RequestEmailVerification = %v, want ErrMailerDisabled", err)
+	}
+}
+
+func TestEmailTokenExpiry(t *testing.T) {
+	f := newFixture(t)
+	settings := testEmailSettings
+	settings.VerificationTTL = -time.Minute
+	settings.ResetTTL = -time.Minute
+	auth, mailer := newEmailAuth(f.store, settings)
+	register(t, auth, "ada@example.com")
+
+	if err := auth.VerifyEmail(emailToken(t, mailer.next(t))); !errors.Is(err, ErrInvalidEmailToken) {
+		t.Errorf("expired token: VerifyEmail = %v, want ErrInvalidEmailToken", err)
+	}
+	if err := auth.RequestPasswordReset("ada@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	token := emailToken(t, mailer.next(t))
+	if err := auth.ResetPassword(models.ResetPasswordRequest{Token: token, Password: "battery staple"}); !errors.Is(err, ErrInvalidEmailToken) {
+		t.Errorf("expired token: ResetPassword = %v, want ErrInvalidEmailToken", err)
+	}
+	login(t, auth, "ada@example.com")
+}
+
+func TestEmailDeliveredInBackground(t *testing.T) {
+	f := newFixture(t)
+	auth, mailer := newEmailAuth(f.store, testEmailSettings)
+	register(t, auth, "ada@example.com")
+	mailer.next(t) // verification
+	mailer.hold = make(chan struct{})
+
+	// The request returns while the mailer is still sending, and the link
+	// works once it arrives.
+	done := make(chan error, 1)
+	go func() { done <- auth.RequestPasswordReset("ada@example.com") }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RequestPasswordReset waited for the mailer")
+	}
+	close(mailer.hold)
+
+	token := emailToken(t, mailer.next(t))
+	if err := auth.ResetPassword(models.ResetPasswordRequest{Token: token, Password: "battery staple"}); err != nil {
+		t.Errorf("ResetPassword = %v", err)
+	}
+}
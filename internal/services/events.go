@@ -0,0 +1,71 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EventType string
+
+const (
+	EventRoleAssigned      EventType = "role.assigned"
+	EventRoleRemoved       EventType = "role.removed"
+	EventAssignmentExpired EventType = "assignment.expired"
+	EventParentRoleAdded   EventType = "role.parent_added"
+	EventParentRoleRemoved EventType = "role.parent_removed"
+	EventPermissionGranted EventType = "permission.granted"
+	EventPermissionRevoked EventType = "permission.revoked"
+	EventUserAttributesSet EventType = "user.attributes_updated"
+)
+
+// Event describes a change to authorization state. Only the IDs relevant to
+// the event type are set; the others are uuid.Nil.
+type Event struct {
+	Type         EventType `json:"type"`
+	UserID       uuid.UUID `json:"user_id"`
+	RoleID       uuid.UUID `json:"role_id"`
+	TenantID     uuid.UUID `json:"tenant_id"`
+	PermissionID uuid.UUID `json:"permission_id"`
+	At           time.Time `json:"at"`
+}
+
+// EventBus fans events out to in-process subscribers. Handlers run
+// synchronously on the publishing goroutine and must not block.
+type EventBus struct {
+	mu       sync.RWMutex
+	handlers []func(Event)
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+func (b *EventBus) Subscribe(handler func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+func (b *EventBus) Publish(event Event) {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("event handler panicked on %s: %v", event.Type, r)
+				}
+			}()
+			handler(event)
+		}()
+	}
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+var (
+	ErrInvalidExternalToken  = errors.New("token rejected by identity provider")
+	ErrIdentityConflict      = errors.New("a user with this email already exists and cannot be linked to this identity")
+	ErrIdentityEmailRequired = errors.New("identity provider did not supply an email address")
+)
+
+// unusablePasswordHash is stored for users provisioned from an external
+// identity. It is not a bcrypt hash, so password login always fails for them.
+const unusablePasswordHash = "!"
+
+// ExternalIdentity is a user authenticated by an IdentityProvider.
+type ExternalIdentity struct {
+	Provider string
+	Subject  string
+	Email    string
+	// EmailVerified is required, besides FederationSettings.LinkByEmail, to
+	// link the identity to an existing local user with the same email.
+	EmailVerified bool
+	Name          string
+	// Groups are the provider's groups the user belongs to; see
+	// EnableGroupSync.
+	Groups []string
+
+	// TokenID identifies the presented token for revocation. IssuedAt and
+	// ExpiresAt come from its claims.
+	TokenID   uuid.UUID
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// IdentityProvider authenticates bearer tokens issued outside this service.
+type IdentityProvider interface {
+	Name() string
+	// Issues reports whether a token with the given unverified claims claims
+	// to come from this provider.
+	Issues(claims jwt.MapClaims) bool
+	// Verify validates the token and returns the identity it carries. It
+	// returns ErrInvalidExternalToken for tokens the provider rejects; other
+	// errors mean the provider could not be asked.
+	Verify(token string, claims jwt.MapClaims) (*ExternalIdentity, error)
+}
+
+// FederationSettings configures how the users of one identity provider are
+// treated.
+type FederationSettings struct {
+	// LinkByEmail links a new identity to the existing local user with the
+	// same email, if the provider has verified it. Users with a password, a
+	// second factor or a role requiring one, and service accounts, are never
+	// linked: the provider's login would stand in for their credentials.
+	LinkByEmail bool
+	// EnforcesMFA trusts the provider to require a second factor, so its
+	// users skip the one of this service: MFA_REQUIRED_ROLES and enrolled
+	// factors then apply to their password and passkey logins only. Without
+	// it, logins through the provider get the same MFA challenge as password
+	// logins, and its bearer tokens are refused for users who would need one.
+	EnforcesMFA bool
+}
+
+// ConfigureFederation applies settings to the identities of provider.
+// Providers that are not configured get the zero FederationSettings.
+func (s *AuthService) ConfigureFederation(provider string, settings FederationSettings) {
+	if s.federation == nil {
+		s.federation = map[string]FederationSettings{}
+	}
+	s.federation[provider] = settings
+}
+
+// SetFederatedDefaultRole makes ProvisionExternalUser assign roleID globally
+// to the users it creates. uuid.Nil assigns none.
+func (s *AuthService) SetFederatedDefaultRole(roleID uuid.UUID) {
+	s.federatedRoleID = roleID
+}
+
+// EnableGroupSync makes ProvisionExternalUser synchronize the roles of
+// external users with their groups at the provider through
+// RBACService.SyncGroupRoles.
+func (s *AuthService) EnableGroupSync(rbacService *RBACService) {
+	s.groupSync = rbacService
+}
+
+// ProvisionExternalUser returns the local user an external identity belongs
+// to, creating it on first sight. Roles and permissions of such users are
+// managed locally like those of any other user. An identity whose email
+// matches an existing user is refused with ErrIdentityConflict unless
+// FederationSettings.LinkByEmail allows linking it to that user.
+func (s *AuthService) ProvisionExternalUser(identity *ExternalIdentity) (*models.User, error) {
+	user, err := s.findOrCreateExternalUser(identity)
+	if err != nil {
+		return nil, err
+	}
+	if s.groupSync != nil {
+		if err := s.groupSync.SyncGroupRoles(user.ID, identity.Provider, identity.Groups); err != nil {
+			return nil, fmt.Errorf("failed to synchronize group roles: %w", err)
+		}
+	}
+	return user, nil
+}
+
+// LoginExternal signs in the user of an identity verified by a login flow
+// such as OpenID Connect. Like Login, it returns tokens, or the MFA challenge
+// to complete first unless FederationSettings.EnforcesMFA trusts the
+// provider's.
+func (s *AuthService) LoginExternal(identity *ExternalIdentity, tenantID uuid.UUID) (*models.LoginResponse, *models.MFAChallenge, error) {
+	user, err := s.ProvisionExternalUser(identity)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !s.federation[identity.Provider].EnforcesMFA {
+		challenge, err := s.mfaChallenge(user, tenantID)
+		if err != nil || challenge != nil {
+			return nil, challenge, err
+		}
+	}
+
+	response, err := s.issueTokens(user, tenantID, uuid.New())
+	return response, nil, err
+}
+
+// CheckExternalMFA returns ErrExternalMFARequired if the user of a bearer
+// token from provider would have to pass a second factor, which such a token
+// cannot prove, unless FederationSettings.EnforcesMFA trusts the provider's.
+func (s *AuthService) CheckExternalMFA(provider string, userID uuid.UUID) error {
+	if s.federation[provider].EnforcesMFA {
+		return nil
+	}
+	methods, err := s.mfaMethods(userID)
+	if err != nil {
+		return err
+	}
+	if len(methods) > 0 {
+		return ErrExternalMFARequired
+	}
+	required, err := s.holdsMFARequiredRole(userID)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrExternalMFARequired
+	}
+	return nil
+}
+
+func (s *AuthService) findOrCreateExternalUser(identity *ExternalIdentity) (*models.User, error) {
+	user, err := s.store.GetUserByIdentity(identity.Provider, identity.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, storage.ErrUserNotFound) {
+		return nil, err
+	}
+	if identity.Email == "" {
+		return nil, ErrIdentityEmailRequired
+	}
+
+	link := &models.Identity{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}
+
+	existing, err := s.store.GetUserByEmail(identity.Email)
+	switch {
+	case err == nil:
+		if !identity.EmailVerified || !s.federation[identity.Provider].LinkByEmail {
+			return nil, ErrIdentityConflict
+		}
+		linkable, err := s.linkable(existing)
+		if err != nil {
+			return nil, err
+		}
+		if !linkable {
+			return nil, ErrIdentityConflict
+		}
+		link.UserID = existing.ID
+		if existing.EmailVerifiedAt == nil {
+			verifiedAt := time.Now()
+			if err := s.store.MarkEmailVerified(existing.ID, verifiedAt); err != nil {
+				return nil, err
+			}
+			existing.EmailVerifiedAt = &verifiedAt
+		}
+		if err := s.store.LinkIdentity(link); err != nil {
+			if errors.Is(err, storage.ErrDuplicate) {
+				// Linked by a concurrent request.
+				return s.store.GetUserByIdentity(identity.Provider, identity.Subject)
+			}
+			return nil, err
+		}
+		existing.PasswordHash = ""
+		return existing, nil
+	case !errors.Is(err, storage.ErrUserNotFound):
+		return nil, err
+	}
+
+	name := identity.Name
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+	user = &models.User{
+		ID:           uuid.New(),
+		Email:        identity.Email,
+		Name:         name,
+		PasswordHash: unusablePasswordHash,
+	}
+	if identity.EmailVerified {
+		verifiedAt := time.Now()
+		user.EmailVerifiedAt = &verifiedAt
+	}
+	if err := s.store.CreateUserWithIdentity(user, s.federatedRoleID, link); err != nil {
+		if errors.Is(err, storage.ErrDuplicate) {
+			// Provisioned by a concurrent request.
+			return s.store.GetUserByIdentity(identity.Provider, identity.Subject)
+		}
+		return nil, err
+	}
+	user.PasswordHash = ""
+	return user, nil
+}
+
+// linkable reports whether an identity may be linked to existing by email:
+// only users without credentials of their own, who do not need a second
+// factor, qualify.
+func (s *AuthService) linkable(existing *models.User) (bool, error) {
+	if existing.PasswordHash != unusablePasswordHash {
+		return false, nil
+	}
+	if _, err := s.store.GetServiceAccount(existing.ID); err == nil {
+		return false, nil
+	} else if !errors.Is(err, storage.ErrServiceAccountNotFound) {
+		return false, err
+	}
+
+	factor, err := s.store.GetTOTPFactor(existing.ID)
+	switch {
+	case err == nil && factor.ConfirmedAt != nil:
+		return false, nil
+	case err != nil && !errors.Is(err, storage.ErrTOTPFactorNotFound):
+		return false, err
+	}
+	creds, err := s.store.GetWebAuthnCredentials(existing.ID)
+	if err != nil {
+		return false, err
+	}
+	if len(creds) > 0 {
+		return false, nil
+	}
+
+	required, err := s.holdsMFARequiredRole(existing.ID)
+	return !required, err
+}
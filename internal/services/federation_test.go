@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func externalIdentity(provider, email string, verified bool) *ExternalIdentity {
+	return &ExternalIdentity{
+		Provider:      provider,
+		Subject:       uuid.New().String(),
+		Email:         email,
+		EmailVerified: verified,
+		TokenID:       uuid.New(),
+		IssuedAt:      time.Now(),
+		ExpiresAt:     time.Now().Add(time.Hour),
+	}
+}
+
+func TestProvisionExternalUser(t *testing.T) {
+	f := newFixture(t)
+	auth := newTestAuth(f.store)
+	member := f.role("member")
+	auth.SetFederatedDefaultRole(member)
+
+	identity := externalIdentity("idp", "ada@example.com", false)
+	user, err := auth.ProvisionExternalUser(identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "ada" || user.EmailVerifiedAt != nil {
+		t.Errorf("provisioned %+v", user)
+	}
+	roles, err := f.store.EffectiveRoles(user.ID, models.GlobalTenantID)
+	if err != nil || len(roles) != 1 || roles[0].ID != member {
+		t.Errorf("provisioned user roles = %v, %v; want the default role", roles, err)
+	}
+	if _, _, err := auth.Login(models.LoginRequest{Email: "ada@example.com", Password: "!"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("password login of a provisioned user = %v, want ErrInvalidCredentials", err)
+	}
+	again, err := auth.ProvisionExternalUser(identity)
+	if err != nil || again.ID != user.ID {
+		t.Errorf("second ProvisionExternalUser = %v, %v; want the same user", again, err)
+	}
+
+	identity.Email = ""
+	identity.Subject = uuid.New().String()
+	if _, err := auth.ProvisionExternalUser(identity); !errors.Is(err, ErrIdentityEmailRequired) {
+		t.Errorf("identity without email: %v, want ErrIdentityEmailRequired", err)
+	}
+}
+
+func TestExternalIdentityLinking(t *testing.T) {
+	f := newFixture(t)
+	auth := newTestAuth(f.store)
+	auth.ConfigureMFA(MFASettings{Issuer: "test", RequiredRoles: []string{"admin"}})
+	auth.ConfigureFederation("linking", FederationSettings{LinkByEmail: true})
+	admin := f.role("admin")
+
+	// federated returns a user that another provider created, which has no
+	// credentials of its own.
+	federated := func(email string) uuid.UUID {
+		t.Helper()
+		user, err := auth.ProvisionExternalUser(externalIdentity("other", email, true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user.ID
+	}
+
+	tests := []struct {
+		name     string
+		provider string
+		verified bool
+		setup    func(email string) uuid.UUID
+		linked   bool
+	}{
+		{"linking allowed", "linking", true, federated, true},
+		{"provider not allowed to link", "idp", true, federated, false},
+		{"email not verified", "linking", false, federated, false},
+		{"user with a password", "linking", true, func(email string) uuid.UUID {
+			return register(t, auth, email)
+		}, false},
+		{"user with TOTP", "linking", true, func(email string) uuid.UUID {
+			userID := federated(email)
+			if err := f.store.CreateTOTPFactor(&models.TOTPFactor{UserID: userID, Secret: "secret"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.store.ConfirmTOTPFactor(userID, 1, nil); err != nil {
+				t.Fatal(err)
+			}
+			return userID
+		}, false},
+		{"user with unconfirmed TOTP", "linking", true, func(email string) uuid.UUID {
+			userID := federated(email)
+			if err := f.store.CreateTOTPFactor(&models.TOTPFactor{UserID: userID, Secret: "secret"}); err != nil {
+				t.Fatal(err)
+			}
+			return userID
+		}, true},
+		{"user with a passkey", "linking", true, func(email string) uuid.UUID {
+			userID := federated(email)
+			if err := f.store.CreateWebAuthnCredential(&models.WebAuthnCredential{
+				ID: uuid.New().String(), UserID: userID, PublicKey: []byte{1}, Name: "key",
+			}); err != nil {
+				t.Fatal(err)
+			}
+			return userID
+		}, false},
+		{"user with an MFA-required role in a tenant", "linking", true, func(email string) uuid.UUID {
+			userID := federated(email)
+			f.assign(userID, admin, uuid.New())
+			return userID
+		}, false},
+		{"service account", "linking", true, func(email string) uuid.UUID {
+			account, err := auth.CreateServiceAccount(models.CreateServiceAccountRequest{Name: "batch"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return account.ID
+		}, false},
+	}
+	for i, tt := range tests {
+		email := uuid.New().String()[:8] + "@example.com"
+		existing := tt.setup(email)
+		if user, err := f.store.GetUserByID(existing); err == nil {
+			email = user.Email
+		}
+
+		identity := externalIdentity(tt.provider, email, tt.verified)
+		user, err := auth.ProvisionExternalUser(identity)
+		switch {
+		case tt.linked && (err != nil || user.ID != existing):
+			t.Errorf("%d %s: ProvisionExternalUser = %v, %v; want the existing user", i, tt.name, user, err)
+		case !tt.linked && !errors.Is(err, ErrIdentityConflict):
+			t.Errorf("%d %s: ProvisionExternalUser = %v, %v; want ErrIdentityConflict", i, tt.name, user, err)
+		}
+		if !tt.linked {
+			if _, err := f.store.GetUserByIdentity(identity.Provider, identity.Subject); !errors.Is(err, storage.ErrUserNotFound) {
+				t.Errorf("%d %s: refused identity was linked", i, tt.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+var (
+	ErrInvalidGroupMapping  = errors.New("invalid group mapping")
+	ErrGroupMappingNotFound = storage.ErrGroupMappingNotFound
+	ErrAssignmentManaged    = errors.New("assignment is managed by an identity provider")
+)
+
+// Group Mappings
+func (s *RBACService) CreateGroupMapping(req models.CreateGroupRoleMappingRequest) (*models.GroupRoleMapping, error) {
+	roleID, err := uuid.Parse(req.RoleID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid role ID", ErrInvalidGroupMapping)
+	}
+	tenantID, err := models.ParseTenantID(req.TenantID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid tenant ID", ErrInvalidGroupMapping)
+	}
+
+	mapping := &models.GroupRoleMapping{
+		ID:       uuid.New(),
+		Provider: req.Provider,
+		Group:    req.Group,
+		RoleID:   roleID,
+		TenantID: tenantID,
+	}
+	if err := s.store.CreateGroupMapping(mapping); err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+func (s *RBACService) GetGroupMappings() ([]models.GroupRoleMapping, error) {
+	return s.store.ListGroupMappings()
+}
+
+// DeleteGroupMapping removes a mapping. Assignments it created are removed
+// the next time each user's groups are synchronized.
+func (s *RBACService) DeleteGroupMapping(id uuid.UUID) error {
+	return s.store.DeleteGroupMapping(id)
+}
+
+// SyncGroupRoles brings the assignments provider manages for the user in line
+// with the mappings that match groups: roles mapped to a group the user is in
+// are assigned, and roles the user no longer gets through any group are
+// removed. Assignments made by hand are left alone, and a role the user
+// already holds by hand is not taken over.
+func (s *RBACService) SyncGroupRoles(userID uuid.UUID, provider string, groups []string) error {
+	mappings, err := s.store.ListGroupMappings()
+	if err != nil {
+		return err
+	}
+
+	member := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		member[group] = true
+	}
+	type key struct{ roleID, tenantID uuid.UUID }
+	desired := map[key]models.RoleAssignment{}
+	for _, m := range mappings {
+		if (m.Provider == "" || m.Provider == provider) && member[m.Group] {
+			desired[key{m.RoleID, m.TenantID}] = models.RoleAssignment{
+				UserID: userID, RoleID: m.RoleID, TenantID: m.TenantID,
+			}
+		}
+	}
+
+	// Most logins change nothing; only write when the assignments differ.
+	current, err := s.store.ListAssignments(userID)
+	if err != nil {
+		return err
+	}
+	held := make(map[key]bool, len(current))
+	changed := false
+	for _, a := range current {
+		k := key{a.RoleID, a.TenantID}
+		held[k] = true
+		if _, ok := desired[k]; !ok && a.ManagedBy == provider {
+			changed = true
+		}
+	}
+	wanted := make([]models.RoleAssignment, 0, len(desired))
+	for k, a := range desired {
+		if !held[k] {
+			changed = true
+		}
+		wanted = append(wanted, a)
+	}
+	if !changed {
+		return nil
+	}
+
+	added, removed, err := s.store.SyncManagedAssignments(userID, provider, wanted)
+	if err != nil {
+		return err
+	}
+	for _, a := range added {
+		s.events.Publish(Event{Type: EventRoleAssigned, UserID: a.UserID, RoleID: a.RoleID, TenantID: a.TenantID})
+	}
+	for _, a := range removed {
+		s.events.Publish(Event{Type: EventRoleRemoved, UserID: a.UserID, RoleID: a.RoleID, TenantID: a.TenantID})
+	}
+	return nil
+}
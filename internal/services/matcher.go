@@ -0,0 +1,96 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Wildcard matches any single segment of a resource path, any action, or,
+// when it is the last segment of a resource pattern, every remaining segment.
+const Wildcard = "*"
+
+var ErrInvalidPermissionPattern = errors.New("invalid permission pattern")
+
+// splitResource splits a hierarchical resource such as "course:42:grades" or
+// "grades/2024/fall" into its segments. Both ':' and '/' act as separators.
+func splitResource(resource string) []string {
+	return strings.FieldsFunc(resource, func(r rune) bool {
+		return r == ':' || r == '/'
+	})
+}
+
+func validSegment(segment string) bool {
+	if segment == Wildcard {
+		return true
+	}
+	for _, r := range segment {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '_' || r == '-' || r == '.':
+		default:
+			return false
+		}
+	}
+	return segment != ""
+}
+
+// ValidatePermissionPattern checks that resource and action are either
+// literals or well-formed wildcard patterns.
+func ValidatePermissionPattern(resource, action string) error {
+	if resource == "" || strings.HasPrefix(resource, ":") || strings.HasPrefix(resource, "/") ||
+		strings.HasSuffix(resource, ":") || strings.HasSuffix(resource, "/") ||
+		strings.Contains(resource, "::") || strings.Contains(resource, "//") {
+		return fmt.Errorf("%w: resource %q has an empty segment", ErrInvalidPermissionPattern, resource)
+	}
+	for _, segment := range splitResource(resource) {
+		if !validSegment(segment) {
+			return fmt.Errorf("%w: resource segment %q must be alphanumeric or %q",
+				ErrInvalidPermissionPattern, segment, Wildcard)
+		}
+	}
+	if !validSegment(action) {
+		return fmt.Errorf("%w: action %q must be alphanumeric or %q",
+			ErrInvalidPermissionPattern, action, Wildcard)
+	}
+	return nil
+}
+
+// MatchResource reports whether the resource pattern of a permission covers
+// the requested resource.
+func MatchResource(pattern, resource string) bool {
+	if pattern == Wildcard || pattern == resource {
+		return true
+	}
+
+	patternSegments := splitResource(pattern)
+	resourceSegments := splitResource(resource)
+	for i, segment := range patternSegments {
+		if i >= len(resourceSegments) {
+			return false
+		}
+		if segment == Wildcard {
+			if i == len(patternSegments)-1 {
+				return true
+			}
+			continue
+		}
+		if segment != resourceSegments[i] {
+			return false
+		}
+	}
+
+	return len(patternSegments) == len(resourceSegments)
+}
+
+// MatchAction reports whether the action pattern of a permission covers the
+// requested action.
+func MatchAction(pattern, action string) bool {
+	return pattern == Wildcard || pattern == action
+}
+
+// MatchPermission reports whether a permission granted on
+// (permResource, permAction) covers the requested (resource, action).
+func MatchPermission(permResource, permAction, resource, action string) bool {
+	return MatchAction(permAction, action) && MatchResource(permResource, resource)
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidatePermissionPattern(t *testing.T) {
+	tests := []struct {
+		resource, action string
+		valid            bool
+	}{
+		{"course", "read", true},
+		{"course", "*", true},
+		{"*", "*", true},
+		{"course:*", "read", true},
+		{"course:42:grades", "update", true},
+		{"grades/*", "read", true},
+		{"grades/2024/fall", "read", true},
+		{"course:*:grades", "read", true},
+		{"reports_v2.daily-summary", "export", true},
+
+		{"", "read", false},
+		{"course", "", false},
+		{":course", "read", false},
+		{"/grades", "read", false},
+		{"course:", "read", false},
+		{"grades/", "read", false},
+		{"course::42", "read", false},
+		{"grades//2024", "read", false},
+		{"course:4*", "read", false},
+		{"course:**", "read", false},
+		{"course", "re*d", false},
+		{"course", "read:all", false},
+		{"course name", "read", false},
+		{"cours%e", "read", false},
+	}
+	for _, tt := range tests {
+		err := ValidatePermissionPattern(tt.resource, tt.action)
+		if tt.valid && err != nil {
+			t.Errorf("ValidatePermissionPattern(%q, %q) = %v, want nil", tt.resource, tt.action, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidPermissionPattern) {
+			t.Errorf("ValidatePermissionPattern(%q, %q) = %v, want ErrInvalidPermissionPattern", tt.resource, tt.action, err)
+		}
+	}
+}
+
+func TestMatchPermission(t *testing.T) {
+	tests := []struct {
+		permResource, permAction string
+		resource, action         string
+		want                     bool
+	}{
+		// Literals
+		{"course", "read", "course", "read", true},
+		{"course", "read", "course", "update", false},
+		{"course", "read", "courses", "read", false},
+		{"course", "read", "course:42", "read", false},
+
+		// Any resource, any action
+		{"*", "*", "course", "read", true},
+		{"*", "*", "grades/2024/fall", "delete", true},
+		{"*", "read", "course:42", "read", true},
+		{"*", "read", "course:42", "update", false},
+		{"course", "*", "course", "delete", true},
+		{"course", "*", "grades", "delete", false},
+
+		// A trailing wildcard covers every remaining segment, but at least one
+		{"course:*", "read", "course:42", "read", true},
+		{"course:*", "read", "course:42:grades", "read", true},
+		{"course:*", "read", "course", "read", false},
+		{"grades/*", "read", "grades/2024", "read", true},
+		{"grades/*", "read", "grades/2024/fall", "read", true},
+		{"grades/*", "read", "grades", "read", false},
+		{"grades/*", "read", "course/2024", "read", false},
+
+		// ':' and '/' are interchangeable separators
+		{"grades/*", "read", "grades:2024", "read", true},
+		{"course:42", "read", "course/42", "read", true},
+
+		// An inner wildcard covers exactly one segment
+		{"course:*:grades", "read", "course:42:grades", "read", true},
+		{"course:*:grades", "read", "course:42:roster", "read", false},
+		{"course:*:grades", "read", "course:42", "read", false},
+		{"course:*:grades", "read", "course:42:grades:final", "read", false},
+
+		// Trailing segments of the request are not covered by a literal pattern
+		{"course:42", "read", "course:42:grades", "read", false},
+		{"course:42:grades", "read", "course:42", "read", false},
+
+		// Wildcards in the request are literals, never patterns
+		{"course:42", "read", "course:*", "read", false},
+		{"course", "read", "course", "*", false},
+	}
+	for _, tt := range tests {
+		got := MatchPermission(tt.permResource, tt.permAction, tt.resource, tt.action)
+		if got != tt.want {
+			t.Errorf("MatchPermission(%q, %q, %q, %q) = %v, want %v",
+				tt.permResource, tt.permAction, tt.resource, tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestMatchEncoded(t *testing.T) {
+	encoded := []string{"course:*:read", "grades/2024:update", "reports:*"}
+	tests := []struct {
+		resource, action string
+		want             bool
+	}{
+		{"course:42", "read", true},
+		{"course", "read", false},
+		{"grades/2024", "update", true},
+		{"grades/2025", "update", false},
+		{"reports", "export", true},
+		{"reports:daily", "export", false},
+	}
+	for _, tt := range tests {
+		if got := matchEncoded(encoded, tt.resource, tt.action); got != tt.want {
+			t.Errorf("matchEncoded(%q, %q) = %v, want %v", tt.resource, tt.action, got, tt.want)
+		}
+	}
+}
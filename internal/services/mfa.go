@@ -0,0 +1,542 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+	"github.com/Anand078/rbac/internal/totp"
+)
+
+var (
+	ErrMFANotEnrolled        = storage.ErrTOTPFactorNotFound
+	ErrMFAAlreadyEnrolled    = errors.New("TOTP is already enrolled")
+	ErrMFAEnrollmentRequired = errors.New("a role of this user requires MFA; enroll a second factor first")
+	ErrInvalidMFAToken       = errors.New("invalid or expired MFA token")
+	ErrInvalidMFACode        = errors.New("invalid MFA code")
+	ErrMFALocked             = errors.New("too many failed MFA attempts; try again later")
+	ErrExternalMFARequired   = errors.New("this user must pass a second factor; sign in through the login flow instead of using the identity provider's token")
+)
+
+const (
+	// mfaTokenType is the typ claim of MFA challenge tokens, which the
+	// middleware refuses as access tokens.
+	mfaTokenType    = "mfa"
+	mfaChallengeTTL = 5 * time.Minute
+	// A factor is locked for mfaLockout after mfaMaxAttempts wrong codes in
+	// a row.
+	mfaMaxAttempts = 5
+	mfaLockout     = 5 * time.Minute
+	// totpSkew accepts codes of the adjacent time steps for clock drift.
+	totpSkew          = 1
+	recoveryCodeCount = 10
+)
+
+// MFASettings configures multi-factor authentication.
+type MFASettings struct {
+	// Issuer names this service in authenticator apps.
+	Issuer string
+	// RequiredRoles are roles whose holders must pass a second factor at
+	// login, enrolling first if they have not. See
+	// FederationSettings.EnforcesMFA for federated logins.
+	RequiredRoles []string
+}
+
+func (s *AuthService) ConfigureMFA(settings MFASettings) {
+	s.mfa = settings
+}
+
+// mfaChallengeClaims is a verified MFA challenge token.
+type mfaChallengeClaims struct {
+	userID    uuid.UUID
+	tenantID  uuid.UUID
+	tokenID   uuid.UUID
+	expiresAt time.Time
+	enroll    bool
+}
+
+// Login Challenge
+
+// mfaChallenge returns the challenge a password or federated login of user
+// must pass, or nil if the first factor suffices.
+func (s *AuthService) mfaChallenge(user *models.User, tenantID uuid.UUID) (*models.MFAChallenge, error) {
+	methods, err := s.mfaMethods(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(methods) > 0 {
+		return s.newMFAChallenge(user.ID, tenantID, methods)
+	}
+
+	if len(s.mfa.RequiredRoles) == 0 {
+		return nil, nil
+	}
+	roles, err := s.store.EffectiveRoles(user.ID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if !s.rolesRequireMFA(roles) {
+		return nil, nil
+	}
+	return s.newMFAChallenge(user.ID, tenantID, nil)
+}
+
+// mfaMethods lists the second factors the user has: a confirmed TOTP factor
+// and, when enabled, passkeys.
+func (s *AuthService) mfaMethods(userID uuid.UUID) ([]string, error) {
+	var methods []string
+	factor, err := s.store.GetTOTPFactor(userID)
+	switch {
+	case err == nil && factor.ConfirmedAt != nil:
+		methods = append(methods, methodTOTP)
+	case err != nil && !errors.Is(err, storage.ErrTOTPFactorNotFound):
+		return nil, err
+	}
+	if s.passkeys != nil {
+		creds, err := s.store.GetWebAuthnCredentials(userID)
+		if err != nil {
+			return nil, err
+		}
+		if len(creds) > 0 {
+			methods = append(methods, methodPasskey)
+		}
+	}
+	return methods, nil
+}
+
+func (s *AuthService) rolesRequireMFA(roles []models.Role) bool {
+	for _, role := range roles {
+		if slices.Contains(s.mfa.RequiredRoles, role.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// holdsMFARequiredRole reports whether the user holds a role requiring MFA
+// in any tenant.
+func (s *AuthService) holdsMFARequiredRole(userID uuid.UUID) (bool, error) {
+	if len(s.mfa.RequiredRoles) == 0 {
+		return false, nil
+	}
+	assignments, err := s.store.ListAssignments(userID)
+	if err != nil {
+		return false, err
+	}
+	tenants := map[uuid.UUID]bool{models.GlobalTenantID: true}
+	for _, a := range assignments {
+		tenants[a.TenantID] = true
+	}
+	for tenantID := range tenants {
+		roles, err := s.store.EffectiveRoles(userID, tenantID)
+		if err != nil {
+			return false, err
+		}
+		if s.rolesRequireMFA(roles) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// mfaEnforcedByProvider reports whether the user has an identity at a
+// provider trusted to enforce MFA, whose logins need no local factor.
+func (s *AuthService) mfaEnforcedByProvider(userID uuid.UUID) (bool, error) {
+	identities, err := s.store.ListIdentities(userID)
+	if err != nil {
+		return false, err
+	}
+	for _, identity := range identities {
+		if s.federation[identity.Provider].EnforcesMFA {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// checkMFAEnrollment returns ErrMFAEnrollmentRequired if roles require MFA
+// and the user has no second factor, nor an identity provider trusted to
+// enforce one.
+func (s *AuthService) checkMFAEnrollment(userID uuid.UUID, roles []models.Role) error {
+	if !s.rolesRequireMFA(roles) {
+		return nil
+	}
+	methods, err := s.mfaMethods(userID)
+	if err != nil {
+		return err
+	}
+	if len(methods) > 0 {
+		return nil
+	}
+	enforced, err := s.mfaEnforcedByProvider(userID)
+	if err != nil {
+		return err
+	}
+	if !enforced {
+		return ErrMFAEnrollmentRequired
+	}
+	return nil
+}
+
+// newMFAChallenge returns a challenge to complete with one of methods, or
+// to enroll with when there are none.
+func (s *AuthService) newMFAChallenge(userID, tenantID uuid.UUID, methods []string) (*models.MFAChallenge, error) {
+	enroll := len(methods) == 0
+	jti, now := newTokenID()
+	claims := jwt.MapClaims{
+		"typ":    mfaTokenType,
+		"jti":    jti.String(),
+		"sub":    userID.String(),
+		"enroll": enroll,
+		"iat":    now.Unix(),
+		"exp":    now.Add(mfaChallengeTTL).Unix(),
+	}
+	if tenantID != models.GlobalTenantID {
+		claims["tenant_id"] = tenantID.String()
+	}
+	token, err := s.keys.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+	return &models.MFAChallenge{
+		MFAToken:           token,
+		ExpiresIn:          int64(mfaChallengeTTL.Seconds()),
+		Methods:            methods,
+		EnrollmentRequired: enroll,
+	}, nil
+}
+
+// parseMFAChallenge verifies an MFA challenge token that has not been used.
+func (s *AuthService) parseMFAChallenge(token string) (*mfaChallengeClaims, error) {
+	parsed, err := jwt.Parse(token, s.keys.Keyfunc, jwt.WithExpirationRequired())
+	if err != nil || !parsed.Valid {
+		return nil, ErrInvalidMFAToken
+	}
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != mfaTokenType {
+		return nil, ErrInvalidMFAToken
+	}
+
+	var challenge mfaChallengeClaims
+	rawUserID, _ := claims["sub"].(string)
+	rawTokenID, _ := claims["jti"].(string)
+	rawTenantID, _ := claims["tenant_id"].(string)
+	if challenge.userID, err = uuid.Parse(rawUserID); err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+	if challenge.tokenID, err = uuid.Parse(rawTokenID); err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+	if challenge.tenantID, err = models.ParseTenantID(rawTenantID); err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return nil, ErrInvalidMFAToken
+	}
+	expiresAt, _ := claims.GetExpirationTime()
+	challenge.expiresAt = expiresAt.Time
+	challenge.enroll, _ = claims["enroll"].(bool)
+
+	// Used challenges are revoked like access tokens, and so are those of
+	// users whose sessions were all revoked since.
+	revoked, err := s.IsTokenRevoked(challenge.tokenID, challenge.userID, issuedAt.Time)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrInvalidMFAToken
+	}
+	return &challenge, nil
+}
+
+// completeMFALogin uses up the challenge and signs its user in. Of
+// concurrent requests presenting the same challenge, only the one that
+// revokes it succeeds.
+func (s *AuthService) completeMFALogin(challenge *mfaChallengeClaims) (*models.LoginResponse, error) {
+	consumed, err := s.store.RevokeAccessToken(challenge.tokenID, challenge.userID, challenge.expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, ErrInvalidMFAToken
+	}
+	user, err := s.store.GetUserByID(challenge.userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, ErrInvalidMFAToken
+		}
+		return nil, err
+	}
+	return s.issueTokens(user, challenge.tenantID, uuid.New())
+}
+
+// VerifyMFA completes a password login with a TOTP code or a recovery code.
+func (s *AuthService) VerifyMFA(req models.MFAVerifyRequest) (*models.LoginResponse, error) {
+	challenge, err := s.parseMFAChallenge(req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.enroll {
+		return nil, ErrMFAEnrollmentRequired
+	}
+
+	factor, err := s.store.GetTOTPFactor(challenge.userID)
+	if err != nil {
+		return nil, err
+	}
+	if factor.ConfirmedAt == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	if err := s.checkSecondFactor(factor, req.Code); err != nil {
+		return nil, err
+	}
+	return s.completeMFALogin(challenge)
+}
+
+// EnrollTOTPWithChallenge starts the enrollment a login demanded.
+func (s *AuthService) EnrollTOTPWithChallenge(mfaToken string) (*models.TOTPEnrollment, error) {
+	challenge, err := s.parseMFAChallenge(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	if !challenge.enroll {
+		return nil, ErrInvalidMFAToken
+	}
+	return s.EnrollTOTP(challenge.userID)
+}
+
+// ConfirmTOTPWithChallenge confirms the enrollment a login demanded and
+// completes that login.
+func (s *AuthService) ConfirmTOTPWithChallenge(req models.MFAVerifyRequest) (*models.MFAEnrollmentResponse, error) {
+	challenge, err := s.parseMFAChallenge(req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+	if !challenge.enroll {
+		return nil, ErrInvalidMFAToken
+	}
+
+	codes, err := s.ConfirmTOTP(challenge.userID, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	login, err := s.completeMFALogin(challenge)
+	if err != nil {
+		return nil, err
+	}
+	return &models.MFAEnrollmentResponse{RecoveryCodes: codes, Login: login}, nil
+}
+
+// TOTP Enrollment
+
+// EnrollTOTP generates a secret for the user's authenticator app. It counts
+// once confirmed with a code; until then, enrolling again replaces it.
+func (s *AuthService) EnrollTOTP(userID uuid.UUID) (*models.TOTPEnrollment, error) {
+	user, err := s.store.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.store.CreateTOTPFactor(&models.TOTPFactor{UserID: userID, Secret: secret}); err != nil {
+		if errors.Is(err, storage.ErrDuplicate) {
+			return nil, ErrMFAAlreadyEnrolled
+		}
+		return nil, err
+	}
+
+	issuer := s.mfa.Issuer
+	if issuer == "" {
+		issuer = "RBAC"
+	}
+	return &models.TOTPEnrollment{Secret: secret, URI: totp.URI(issuer, user.Email, secret)}, nil
+}
+
+// ConfirmTOTP confirms the pending enrollment with a code from the app and
+// returns new recovery codes, which are not stored and cannot be shown again.
+func (s *AuthService) ConfirmTOTP(userID uuid.UUID, code string) ([]string, error) {
+	factor, err := s.store.GetTOTPFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if factor.ConfirmedAt != nil {
+		return nil, ErrMFAAlreadyEnrolled
+	}
+	step, ok, err := totp.Validate(factor.Secret, strings.TrimSpace(code), time.Now(), totpSkew)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.ConfirmTOTPFactor(userID, step, hashes); err != nil {
+		if errors.Is(err, storage.ErrTOTPFactorNotFound) {
+			// Confirmed by a concurrent request.
+			return nil, ErrMFAAlreadyEnrolled
+		}
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP removes the user's factor and recovery codes. A confirmed
+// factor can only be removed with a valid code.
+func (s *AuthService) DisableTOTP(userID uuid.UUID, code string) error {
+	factor, err := s.store.GetTOTPFactor(userID)
+	if err != nil {
+		return err
+	}
+	if factor.ConfirmedAt != nil {
+		if err := s.checkSecondFactor(factor, code); err != nil {
+			return err
+		}
+	}
+	return s.store.DeleteTOTPFactor(userID)
+}
+
+// ResetMFA removes a user's TOTP factor and passkeys without a code, for
+// users who lost their authenticator and recovery codes.
+func (s *AuthService) ResetMFA(userID uuid.UUID) error {
+	totpErr := s.store.DeleteTOTPFactor(userID)
+	if totpErr != nil && !errors.Is(totpErr, storage.ErrTOTPFactorNotFound) {
+		return totpErr
+	}
+	deleted, err := s.store.DeleteWebAuthnCredentials(userID)
+	if err != nil {
+		return err
+	}
+	if totpErr != nil && deleted == 0 {
+		return ErrMFANotEnrolled
+	}
+	return nil
+}
+
+func (s *AuthService) GetMFAStatus(userID uuid.UUID) (*models.MFAStatus, error) {
+	creds, err := s.store.GetWebAuthnCredentials(userID)
+	if err != nil {
+		return nil, err
+	}
+	status := &models.MFAStatus{Passkeys: len(creds)}
+
+	factor, err := s.store.GetTOTPFactor(userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrTOTPFactorNotFound) {
+			return status, nil
+		}
+		return nil, err
+	}
+	remaining, err := s.store.CountRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	status.Enrolled = factor.ConfirmedAt != nil
+	status.ConfirmedAt = factor.ConfirmedAt
+	status.RecoveryCodesRemaining = remaining
+	return status, nil
+}
+
+// Recovery Codes
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking
+// a code, and returns the new ones.
+func (s *AuthService) RegenerateRecoveryCodes(userID uuid.UUID, code string) ([]string, error) {
+	factor, err := s.store.GetTOTPFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if factor.ConfirmedAt == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	if err := s.checkSecondFactor(factor, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// newRecoveryCodes returns recovery codes with the hashes stored for them.
+// Each carries 80 random bits, so like opaque tokens they are stored as a
+// plain SHA-256 hash.
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for range recoveryCodeCount {
+		buf := make([]byte, 10)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		raw := strings.ToLower(encoding.EncodeToString(buf))
+		codes = append(codes, raw[0:4]+"-"+raw[4:8]+"-"+raw[8:12]+"-"+raw[12:16])
+		hashes = append(hashes, hashToken(raw))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// checkSecondFactor accepts a current TOTP code or an unused recovery code
+// for a confirmed factor. Wrong codes count towards locking the factor.
+func (s *AuthService) checkSecondFactor(factor *models.TOTPFactor, code string) error {
+	now := time.Now()
+	if factor.LockedUntil != nil && now.Before(*factor.LockedUntil) {
+		return ErrMFALocked
+	}
+
+	ok, err := s.matchSecondFactor(factor, strings.TrimSpace(code), now)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if err := s.store.RecordMFAFailure(factor.UserID, mfaMaxAttempts, now.Add(mfaLockout)); err != nil {
+			return err
+		}
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (s *AuthService) matchSecondFactor(factor *models.TOTPFactor, code string, now time.Time) (bool, error) {
+	if len(code) == totp.Digits && strings.Trim(code, "0123456789") == "" {
+		step, ok, err := totp.Validate(factor.Secret, code, now, totpSkew)
+		if err != nil || !ok {
+			return false, err
+		}
+		// A code is accepted once, even within its time step.
+		return s.store.UseTOTPStep(factor.UserID, step)
+	}
+
+	err := s.store.UseRecoveryCode(factor.UserID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,386 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/totp"
+)
+
+// stableStep waits, if need be, until the current TOTP step has a few seconds
+// left, so a test does not straddle two steps, and returns it.
+func stableStep() int64 {
+	now := time.Now()
+	if left := totp.Period - now.Sub(now.Truncate(totp.Period)); left < 3*time.Second {
+		time.Sleep(left)
+	}
+	return totp.Step(time.Now())
+}
+
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	code, err := totp.Code(secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// enrollTOTP enrolls and confirms a factor for the user with the code of
+// step, returning its secret and recovery codes.
+func enrollTOTP(t *testing.T, auth *AuthService, userID uuid.UUID, step int64) (string, []string) {
+	t.Helper()
+	enrollment, err := auth.EnrollTOTP(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, err := auth.ConfirmTOTP(userID, totpCode(t, enrollment.Secret, step))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enrollment.Secret, codes
+}
+
+// mfaToken starts a password login that must be completed with a second
+// factor.
+func mfaToken(t *testing.T, auth *AuthService, email string) string {
+	t.Helper()
+	response, challenge, err := auth.Login(models.LoginRequest{Email: email, Password: testPassword})
+	if err != nil || challenge == nil {
+		t.Fatalf("Login(%s) = %v, %v, %v; want an MFA challenge", email, response, challenge, err)
+	}
+	return challenge.MFAToken
+}
+
+func TestTOTPSkewAndReplay(t *testing.T) {
+	f := newFixture(t)
+	auth := newTestAuth(f.store)
+	userID := register(t, auth, "ada@example.com")
+	step := stableStep()
+	// Confirming with the previous step's code is within the skew.
+	secret, _ := enrollTOTP(t, auth, userID, step-1)
+
+	tests := []struct {
+		name string
+		step int64
+		ok   bool
+	}{
+		{"code of a step before the last used one", step - 2, false},
+		{"code of the last used step", step - 1, false},
+		{"current code", step, true},
+		{"current code again", step, false},
+		{"code of the next step", step + 1, true},
+		{"code two steps ahead", step + 2, false},
+	}
+	for _, tt := range tests {
+		_, err := auth.VerifyMFA(models.MFAVerifyRequest{MFAToken: mfaToken(t, auth, "ada@example.com"), Code: totpCode(t, secret, tt.step)})
+		if tt.ok && err != nil {
+			t.Errorf("%s: VerifyMFA = %v", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidMFACode) {
+			t.Errorf("%s: VerifyMFA = %v, want ErrInvalidMFACode", tt.name, err)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	f := newFixture(t)
+	auth := newTestAuth(f.store)
+	userID := register(t, auth, "ada@example.com")
+	_, codes := enrollTOTP(t, auth, userID, stableStep())
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("%d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	verify := func(code string) error {
+		_, err := auth.VerifyMFA(models.MFAVerifyRequest{MFAToken: mfaToken(t, auth, "ada@example.com"), Code: code})
+		return err
+	}
+	if err := verify(codes[0]); err != nil {
+		t.Fatalf("recovery code: VerifyMFA = %v", err)
+	}
+	if err := verify(codes[0]); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("used recovery code: VerifyMFA = %v, want ErrInvalidMFACode", err)
+	}
+	// Codes are matched regardless of case, dashes and spaces.
+	if err := verify(" " + strings.ToUpper(strings.ReplaceAll(codes[1], "-", "")) + " "); err != nil {
+		t.Errorf("reformatted recovery code: VerifyMFA = %v", err)
+	}
+	if status, err := auth.GetMFAStatus(userID); err != nil || status.RecoveryCodesRemaining != recoveryCodeCount-2 {
+		t.Errorf("GetMFAStatus = %+v, %v; want %d recovery codes left", status, err, recoveryCodeCount-2)
+	}
+
+	// A recovery code resets the count of failed attempts, like a TOTP code.
+	for range mfaMaxAttempts - 1 {
+		if err := verify("000000"); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("wrong code: VerifyMFA = %v", err)
+		}
+	}
+	if err := verify(codes[2]); err != nil {
+		t.Fatalf("recovery code: VerifyMFA = %v", err)
+	}
+	if factor, err := f.store.GetTOTPFactor(userID); err != nil || factor.FailedAttempts != 0 {
+		t.Errorf("after a recovery code: %+v, %v; want no failed attempts", factor, err)
+	}
+	if err := verify("000000"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("wrong code after a recovery code: VerifyMFA = %v, want ErrInvalidMFACode, not a lockout", err)
+	}
+
+	// Regenerating replaces the remaining codes.
+	fresh, err := auth.RegenerateRecoveryCodes(userID, codes[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verify(codes[4]); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("replaced recovery code: VerifyMFA = %v, want ErrInvalidMFACode", err)
+	}
+	if err := verify(fresh[0]); err != nil {
+		t.Errorf("regenerated recovery code: VerifyMFA = %v", err)
+	}
+}
+
+func TestMFALockout(t *testing.T) {
+	f := newFixture(t)
+	auth := newTestAuth(f.store)
+	userID := register(t, auth, "ada@example.com")
+	step := stableStep()
+	secret, codes := enrollTOTP(t, auth, userID, step-1)
+
+	token := mfaToken(t, auth, "ada@example.com")
+	for i := range mfaMaxAttempts {
+		if _, err := auth.VerifyMFA(models.MFAVerifyRequest{MFAToken: token, Code: "not-a-code"}); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("wrong code %d: VerifyMFA = %v, want ErrInvalidMFACode", i+1, err)
+		}
+	}
+	// Locked, neither TOTP nor recovery codes are checked, and a code tried
+	// meanwhile stays usable.
+	for _, code := range []string{totpCode(t, secret, step), codes[0]} {
+		if _, err := auth.VerifyMFA(models.MFAVerifyRequest{MFAToken: token, Code: code}); !errors.Is(err, ErrMFALocked) {
+			t.Errorf("locked factor: VerifyMFA = %v, want ErrMFALocked", err)
+		}
+	}
+	if _, err := auth.RegenerateRecoveryCodes(userID, codes[0]); !errors.Is(err, ErrMFALocked) {
+		t.Errorf("locked factor: RegenerateRecoveryCodes = %v, want ErrMFALocked", err)
+	}
+
+	factor, err := f.store.GetTOTPFactor(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if factor.LockedUntil == nil || factor.LockedUntil.Sub(time.Now()) > mfaLockout || factor.LockedUntil.Sub(time.Now()) < mfaLockout-time.Minute {
+		t.Errorf("factor locked until %v, want in about %v", factor.LockedUntil, mfaLockout)
+	}
+
+	// Once the lockout has passed, a correct code is accepted again.
+	if err := f.store.RecordMFAFailure(userID, 1, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.VerifyMFA(models.MFAVerifyRequest{MFAToken: token, Code: totpCode(t, secret, step)}); err != nil {
+		t.Errorf("after the lockout: VerifyMFA = %v", err)
+	}
+}
+
+func TestMFAChallengeSingleUse(t *testing.T) {
+	f := newFixture(t)
+	auth := newTestAuth(f.store)
+	userID := register(t, auth, "ada@example.com")
+	_, codes := enrollTOTP(t, auth, userID, stableStep())
+
+	// Concurrent requests completing one challenge, each with a valid code:
+	// only one of them signs in.
+	token := mfaToken(t, auth, "ada@example.com")
+	const attempts = 5
+	errs := make(chan error, attempts)
+	var wg sync.WaitGroup
+	for i := range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := auth.VerifyMFA(models.MFAVerifyRequest{MFAToken: token, Code: codes[i]})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrInvalidMFAToken):
+			t.Errorf("concurrent VerifyMFA = %v, want ErrInvalidMFAToken", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d concurrent VerifyMFA calls succeeded, want 1", succeeded)
+	}
+	if _, err := auth.VerifyMFA(models.MFAVerifyRequest{MFAToken: token, Code: codes[attempts]}); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Errorf("used challenge: VerifyMFA = %v, want ErrInvalidMFAToken", err)
+	}
+
+	// Two requests that both got past the revocation check: the one that
+	// loses the revoke fails.
+	challenge, err := auth.parseMFAChallenge(mfaToken(t, auth, "ada@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.completeMFALogin(challenge); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.completeMFALogin(challenge); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Errorf("second completeMFALogin = %v, want ErrInvalidMFAToken", err)
+	}
+}
+
+func TestMFARequiredRole(t *testing.T) {
+	f := newFixture(t)
+	auth := newTestAuth(f.store)
+	auth.ConfigureMFA(MFASettings{Issuer: "test", RequiredRoles: []string{"admin"}})
+	admin := f.role("admin")
+	userID := register(t, auth, "ada@example.com")
+
+	// Without the role, the password suffices; a session started then ends
+	// at its next refresh once the role is granted.
+	session := login(t, auth, "ada@example.com")
+	f.assign(userID, admin, uuid.New())
+	session, err := auth.Refresh(models.RefreshTokenRequest{RefreshToken: session.RefreshToken})
+	if err != nil {
+		t.Fatalf("role in another tenant: Refresh = %v", err)
+	}
+	f.assign(userID, admin, models.GlobalTenantID)
+	if _, err := auth.Refresh(models.RefreshTokenRequest{RefreshToken: session.RefreshToken}); !errors.Is(err, ErrMFAEnrollmentRequired) {
+		t.Errorf("Refresh = %v, want ErrMFAEnrollmentRequired", err)
+	}
+
+	// Password logins now demand enrollment.
+	_, challenge, err := auth.Login(models.LoginRequest{Email: "ada@example.com", Password: testPassword})
+	if err != nil || challenge == nil || !challenge.EnrollmentRequired {
+		t.Errorf("Login = %+v, %v; want a challenge to enroll", challenge, err)
+	}
+}
+
+func TestMFAEnrollmentChallenge(t *testing.T) {
+	f := newFixture(t)
+	auth := newTestAuth(f.store)
+	auth.ConfigureMFA(MFASettings{Issuer: "test", RequiredRoles: []string{"admin"}})
+	userID := register(t, auth, "ada@example.com")
+	f.assign(userID, f.role("admin"), models.GlobalTenantID)
+
+	_, challenge, err := auth.Login(models.LoginRequest{Email: "ada@example.com", Password: testPassword})
+	if err != nil || challenge == nil || !challenge.EnrollmentRequired || len(challenge.Methods) != 0 {
+		t.Fatalf("Login = %+v, %v; want a challenge to enroll", challenge, err)
+	}
+	if _, err := auth.VerifyMFA(models.MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: "000000"}); !errors.Is(err, ErrMFAEnrollmentRequired) {
+		t.Errorf("VerifyMFA with an enrollment challenge = %v, want ErrMFAEnrollmentRequired", err)
+	}
+
+	enrollment, err := auth.EnrollTOTPWithChallenge(challenge.MFAToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := stableStep()
+	if _, err := auth.ConfirmTOTPWithChallenge(models.MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: "000000"}); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("wrong code: ConfirmTOTPWithChallenge = %v, want ErrInvalidMFACode", err)
+	}
+	confirmed, err := auth.ConfirmTOTPWithChallenge(models.MFAVerifyRequest{MFAToken: challenge.MFAToken, Code: totpCode(t, enrollment.Secret, step)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmed.Login == nil || confirmed.Login.Token == "" || len(confirmed.RecoveryCodes) != recoveryCodeCount {
+		t.Errorf("ConfirmTOTPWithChallenge = %+v", confirmed)
+	}
+	if _, err := auth.EnrollTOTPWithChallenge(challenge.MFAToken); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Errorf("used challenge: EnrollTOTPWithChallenge = %v, want ErrInvalidMFAToken", err)
+	}
+
+	// Enrolled, the user gets an ordinary challenge and refreshes freely.
+	if _, err := auth.Refresh(models.RefreshTokenRequest{RefreshToken: confirmed.Login.RefreshToken}); err != nil {
+		t.Errorf("Refresh after enrolling = %v", err)
+	}
+	_, challenge, err = auth.Login(models.LoginRequest{Email: "ada@example.com", Password: testPassword})
+	if err != nil || challenge == nil || challenge.EnrollmentRequired {
+		t.Errorf("Login after enrolling = %+v, %v; want a TOTP challenge", challenge, err)
+	}
+}
+
+func TestFederatedMFA(t *testing.T) {
+	f := newFixture(t)
+	auth := newTestAuth(f.store)
+	auth.ConfigureMFA(MFASettings{Issuer: "test", RequiredRoles: []string{"admin"}})
+	auth.ConfigureFederation("trusted", FederationSettings{EnforcesMFA: true})
+	admin := f.role("admin")
+
+	// provision returns an identity of provider whose user holds admin.
+	provision := func(provider string) *ExternalIdentity {
+		t.Helper()
+		identity := externalIdentity(provider, uuid.NewString()[:8]+"@example.com", true)
+		user, err := auth.ProvisionExternalUser(identity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.assign(user.ID, admin, models.GlobalTenantID)
+		return identity
+	}
+	userOf := func(identity *ExternalIdentity) uuid.UUID {
+		t.Helper()
+		user, err := f.store.GetUserByIdentity(identity.Provider, identity.Subject)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user.ID
+	}
+
+	// Logins through a provider not trusted with MFA get the challenge of
+	// password logins, and its bearer tokens are refused.
+	untrusted := provision("idp")
+	response, challenge, err := auth.LoginExternal(untrusted, models.GlobalTenantID)
+	if err != nil || response != nil || challenge == nil || !challenge.EnrollmentRequired {
+		t.Errorf("untrusted provider: LoginExternal = %v, %+v, %v; want a challenge to enroll", response, challenge, err)
+	}
+	if err := auth.CheckExternalMFA(untrusted.Provider, userOf(untrusted)); !errors.Is(err, ErrExternalMFARequired) {
+		t.Errorf("untrusted provider: CheckExternalMFA = %v, want ErrExternalMFARequired", err)
+	}
+
+	// So are those of users who enrolled a factor without holding the role.
+	enrolled := externalIdentity("idp", "enrolled@example.com", true)
+	user, err := auth.ProvisionExternalUser(enrolled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enrollTOTP(t, auth, user.ID, stableStep())
+	if _, challenge, err := auth.LoginExternal(enrolled, models.GlobalTenantID); err != nil || challenge == nil || challenge.EnrollmentRequired {
+		t.Errorf("enrolled user: LoginExternal = %+v, %v; want a TOTP challenge", challenge, err)
+	}
+	if err := auth.CheckExternalMFA(enrolled.Provider, user.ID); !errors.Is(err, ErrExternalMFARequired) {
+		t.Errorf("enrolled user: CheckExternalMFA = %v, want ErrExternalMFARequired", err)
+	}
+
+	// Users who need no second factor are let through.
+	plain := externalIdentity("idp", "plain@example.com", true)
+	if response, challenge, err := auth.LoginExternal(plain, models.GlobalTenantID); err != nil || challenge != nil || response == nil {
+		t.Errorf("user without MFA: LoginExternal = %v, %+v, %v; want tokens", response, challenge, err)
+	}
+	if err := auth.CheckExternalMFA(plain.Provider, userOf(plain)); err != nil {
+		t.Errorf("user without MFA: CheckExternalMFA = %v", err)
+	}
+
+	// A provider trusted with MFA stands in for the second factor, at login,
+	// for bearer tokens and when refreshing.
+	trusted := provision("trusted")
+	response, challenge, err = auth.LoginExternal(trusted, models.GlobalTenantID)
+	if err != nil || challenge != nil || response == nil {
+		t.Fatalf("trusted provider: LoginExternal = %v, %+v, %v; want tokens", response, challenge, err)
+	}
+	if err := auth.CheckExternalMFA(trusted.Provider, userOf(trusted)); err != nil {
+		t.Errorf("trusted provider: CheckExternalMFA = %v", err)
+	}
+	if _, err := auth.Refresh(models.RefreshTokenRequest{RefreshToken: response.RefreshToken}); err != nil {
+		t.Errorf("trusted provider: Refresh = %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/Anand078/rbac/internal/database"
+)
+
+// ChangeChannel is the Postgres NOTIFY channel on which instances announce
+// changes to authorization state.
+const ChangeChannel = "rbac_changes"
+
+const (
+	listenerMinReconnect = time.Second
+	listenerMaxReconnect = time.Minute
+	listenerPingInterval = 90 * time.Second
+)
+
+type changeMessage struct {
+	Origin string `json:"origin"`
+	Event  Event  `json:"event"`
+}
+
+// ChangeNotifier keeps the caches of several instances consistent. Every
+// event published on the local bus is sent with NOTIFY, and notifications
+// from other instances invalidate the matching local cache entries. Since
+// notifications sent while disconnected are lost, all caches are flushed
+// whenever the listener (re)connects.
+type ChangeNotifier struct {
+	db          *database.DB
+	dsn         string
+	rbacService *RBACService
+	origin      string
+}
+
+func NewChangeNotifier(db *database.DB, dsn string, rbacService *RBACService) *ChangeNotifier {
+	n := &ChangeNotifier{
+		db:          db,
+		dsn:         dsn,
+		rbacService: rbacService,
+		origin:      uuid.New().String(),
+	}
+	rbacService.Events().Subscribe(n.publish)
+	return n
+}
+
+func (n *ChangeNotifier) publish(event Event) {
+	payload, err := json.Marshal(changeMessage{Origin: n.origin, Event: event})
+	if err != nil {
+		log.Printf("Failed to encode change notification: %v", err)
+		return
+	}
+	if _, err := n.db.Exec(`SELECT pg_notify($1, $2)`, ChangeChannel, string(payload)); err != nil {
+		log.Printf("Failed to send change notification for %s: %v", event.Type, err)
+	}
+}
+
+// Run listens for changes made by other instances until ctx is done. The
+// listener reconnects on its own with exponential backoff.
+func (n *ChangeNotifier) Run(ctx context.Context) {
+	listener := pq.NewListener(n.dsn, listenerMinReconnect, listenerMaxReconnect, n.listenerEvent)
+	defer listener.Close()
+
+	if err := listener.Listen(ChangeChannel); err != nil {
+		log.Printf("Failed to listen on %s: %v", ChangeChannel, err)
+		return
+	}
+
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification := <-listener.Notify:
+			n.receive(notification)
+		case <-ticker.C:
+			// Detects dead connections that would otherwise go unnoticed
+			// while the channel is idle.
+			go listener.Ping()
+		}
+	}
+}
+
+func (n *ChangeNotifier) listenerEvent(event pq.ListenerEventType, err error) {
+	switch event {
+	case pq.ListenerEventConnected:
+		// Changes made before the first connection went unnoticed.
+		n.rbacService.flushCaches()
+	case pq.ListenerEventDisconnected:
+		log.Printf("Change listener disconnected: %v", err)
+	case pq.ListenerEventReconnected:
+		log.Println("Change listener reconnected")
+	case pq.ListenerEventConnectionAttemptFailed:
+		log.Printf("Change listener connection attempt failed: %v", err)
+	}
+}
+
+func (n *ChangeNotifier) receive(notification *pq.Notification) {
+	if notification == nil {
+		// Sent after a reconnect: notifications may have been missed.
+		n.rbacService.flushCaches()
+		return
+	}
+	n.handle(notification.Extra)
+}
+
+func (n *ChangeNotifier) handle(payload string) {
+	var message changeMessage
+	if err := json.Unmarshal([]byte(payload), &message); err != nil {
+		log.Printf("Ignoring malformed change notification: %v", err)
+		return
+	}
+	if message.Origin == n.origin {
+		return
+	}
+	n.rbacService.applyRemoteEvent(message.Event)
+}
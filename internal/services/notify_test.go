@@ -0,0 +1,138 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage/memory"
+)
+
+// peers are two instances sharing a store. The remote one caches
+// permissions and listens through notifier, which is driven by hand instead
+// of a Postgres connection.
+type peers struct {
+	local, remote *fixture
+	notifier      *ChangeNotifier
+	user, role    uuid.UUID
+}
+
+// newPeers sets up a user holding a role that allows reading courses, and
+// warms the remote instance's permission cache and policy version.
+func newPeers(t *testing.T) *peers {
+	t.Helper()
+	store := memory.New()
+	p := &peers{local: newFixtureOn(t, store), remote: newFixtureOn(t, store)}
+	p.remote.rbac.EnablePermissionCache(time.Minute, 100)
+	p.notifier = &ChangeNotifier{rbacService: p.remote.rbac, origin: uuid.New().String()}
+
+	p.user = p.local.user("ada@example.com")
+	p.role = p.local.role("editor")
+	p.local.grant(p.role, "course", "read", models.EffectAllow, "")
+	p.local.assign(p.user, p.role, models.GlobalTenantID)
+	if got := p.remote.decide(p.user, models.GlobalTenantID, "course", "read"); got != DecisionAllow {
+		t.Fatalf("remote Evaluate = %v, want allow", got)
+	}
+	if _, err := p.remote.rbac.PolicyVersion(); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// revokeLocally revokes the role's grant through the local instance only and
+// returns the notification it would have sent.
+func (p *peers) revokeLocally(t *testing.T) string {
+	t.Helper()
+	if err := p.local.rbac.RevokePermission(p.role, p.local.permission("course", "read")); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(changeMessage{
+		Origin: "local",
+		Event:  Event{Type: EventPermissionRevoked, RoleID: p.role},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(payload)
+}
+
+// assertStale checks that the remote instance has not noticed the revocation.
+func (p *peers) assertStale(t *testing.T) {
+	t.Helper()
+	if got := p.remote.decide(p.user, models.GlobalTenantID, "course", "read"); got != DecisionAllow {
+		t.Fatalf("remote instance saw the change without a notification: %v", got)
+	}
+}
+
+// assertFresh checks that the remote instance reloaded the user's permissions
+// and the policy version.
+func (p *peers) assertFresh(t *testing.T) {
+	t.Helper()
+	if got := p.remote.decide(p.user, models.GlobalTenantID, "course", "read"); got != DecisionNoMatch {
+		t.Errorf("remote Evaluate = %v, want no match", got)
+	}
+	remote, err := p.remote.rbac.PolicyVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := p.local.rbac.PolicyVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remote != local {
+		t.Errorf("remote policy version = %d, want %d", remote, local)
+	}
+}
+
+func TestNotifierAppliesRemoteChanges(t *testing.T) {
+	p := newPeers(t)
+	payload := p.revokeLocally(t)
+	p.assertStale(t)
+
+	p.notifier.receive(&pq.Notification{Channel: ChangeChannel, Extra: payload})
+	p.assertFresh(t)
+}
+
+func TestNotifierIgnoresOwnAndMalformedChanges(t *testing.T) {
+	p := newPeers(t)
+	p.revokeLocally(t)
+
+	own, err := json.Marshal(changeMessage{
+		Origin: p.notifier.origin,
+		Event:  Event{Type: EventPermissionRevoked, RoleID: p.role},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.notifier.receive(&pq.Notification{Channel: ChangeChannel, Extra: string(own)})
+	p.notifier.receive(&pq.Notification{Channel: ChangeChannel, Extra: "{"})
+	p.assertStale(t)
+}
+
+// TestNotifierFlushesOnReconnect covers changes whose notifications were
+// lost while the listener was disconnected: the listener signals a
+// reconnect with a nil notification, and its first connection with an
+// event, and both must drop everything cached.
+func TestNotifierFlushesOnReconnect(t *testing.T) {
+	t.Run("reconnect", func(t *testing.T) {
+		p := newPeers(t)
+		p.notifier.listenerEvent(pq.ListenerEventDisconnected, errors.New("connection reset"))
+		p.revokeLocally(t)
+		p.notifier.listenerEvent(pq.ListenerEventReconnected, nil)
+		p.assertStale(t)
+
+		p.notifier.receive(nil)
+		p.assertFresh(t)
+	})
+	t.Run("first connection", func(t *testing.T) {
+		p := newPeers(t)
+		p.revokeLocally(t)
+		p.notifier.listenerEvent(pq.ListenerEventConnected, nil)
+		p.assertFresh(t)
+	})
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+var (
+	ErrInvalidOAuthClient  = errors.New("invalid OAuth client")
+	ErrOAuthClientNotFound = storage.ErrOAuthClientNotFound
+	// ErrInvalidClient and ErrInvalidScope correspond to the invalid_client
+	// and invalid_scope errors of RFC 6749.
+	ErrInvalidClient = errors.New("client authentication failed")
+	ErrInvalidScope  = errors.New("invalid scope")
+)
+
+// OAuth Clients
+
+// CreateOAuthClient registers a client of the service account. A client
+// needs at least one scope, each covered by a permission the account's roles
+// allow, as for scoped API keys; "*:*" is refused unless the account is
+// granted "*:*". The returned secret is not stored and cannot be shown again.
+func (s *AuthService) CreateOAuthClient(serviceAccountID uuid.UUID, req models.CreateOAuthClientRequest) (*models.CreateOAuthClientResponse, error) {
+	if len(req.Scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidOAuthClient)
+	}
+	scopes := req.Scopes
+	if err := s.checkClientScopes(serviceAccountID, scopes); err != nil {
+		return nil, err
+	}
+
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	client := &models.OAuthClient{
+		ID:               uuid.New(),
+		ServiceAccountID: serviceAccountID,
+		Name:             req.Name,
+		SecretHash:       hashToken(secret),
+		Scopes:           scopes,
+	}
+	if err := s.store.CreateOAuthClient(client); err != nil {
+		return nil, err
+	}
+	client.SecretHash = ""
+	return &models.CreateOAuthClientResponse{ClientSecret: secret, Client: *client}, nil
+}
+
+func (s *AuthService) GetOAuthClients(serviceAccountID uuid.UUID) ([]models.OAuthClient, error) {
+	if _, err := s.store.GetServiceAccount(serviceAccountID); err != nil {
+		return nil, err
+	}
+	return s.store.ListOAuthClients(serviceAccountID)
+}
+
+// DeleteOAuthClient removes the client and revokes the access tokens it
+// obtained. Tokens of the account's other clients and API keys stay valid.
+func (s *AuthService) DeleteOAuthClient(serviceAccountID, clientID uuid.UUID) error {
+	return s.store.DeleteOAuthClient(serviceAccountID, clientID)
+}
+
+// checkClientScopes validates "resource:action" scopes and requires each to
+// be covered by a permission allowed to the service account, in any tenant.
+func (s *AuthService) checkClientScopes(serviceAccountID uuid.UUID, scopes []string) error {
+	for _, scope := range scopes {
+		if err := ValidateScope(scope); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidOAuthClient, err)
+		}
+	}
+	granted, err := s.grantedScopes(serviceAccountID)
+	if err != nil {
+		return err
+	}
+	for _, scope := range scopes {
+		if !clientAllowsScope(granted, scope) {
+			return fmt.Errorf("%w: scope %q is not covered by a permission of the service account", ErrInvalidOAuthClient, scope)
+		}
+	}
+	return nil
+}
+
+// Client Credentials Grant
+
+// AuthenticateClient returns the client with clientID if secret is its
+// secret, or ErrInvalidClient.
+func (s *AuthService) AuthenticateClient(clientID, secret string) (*models.OAuthClient, error) {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		return nil, ErrInvalidClient
+	}
+	client, err := s.store.GetOAuthClient(id)
+	if err != nil {
+		if errors.Is(err, storage.ErrOAuthClientNotFound) {
+			return nil, ErrInvalidClient
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(client.SecretHash)) != 1 {
+		return nil, ErrInvalidClient
+	}
+	client.SecretHash = ""
+	return client, nil
+}
+
+// ClientCredentialsToken issues an access token for the client's service
+// account. scope is the space-separated list of requested scopes; when empty,
+// the token gets all scopes registered for the client. The token is limited
+// to the account's permissions its scopes cover. A client without scopes gets
+// no token. No refresh token is issued, as RFC 6749 prescribes for this
+// grant.
+func (s *AuthService) ClientCredentialsToken(client *models.OAuthClient, scope string) (*models.OAuthTokenResponse, error) {
+	if len(client.Scopes) == 0 {
+		return nil, fmt.Errorf("%w: no scopes are registered for the client", ErrInvalidScope)
+	}
+	scopes := strings.Fields(scope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	for _, requested := range scopes {
+		if err := ValidateScope(requested); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidScope, err)
+		}
+		if !clientAllowsScope(client.Scopes, requested) {
+			return nil, fmt.Errorf("%w: %q is not registered for the client", ErrInvalidScope, requested)
+		}
+	}
+
+	user, err := s.store.GetUserByID(client.ServiceAccountID)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := s.accessTokenClaims(user, models.GlobalTenantID)
+	if err != nil {
+		return nil, err
+	}
+	claims["client_id"] = client.ID.String()
+	claims["scope"] = strings.Join(scopes, " ")
+	token, err := s.keys.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+	// Recorded so that deleting the client revokes the token.
+	jti := uuid.MustParse(claims["jti"].(string))
+	expiresAt := time.Unix(claims["exp"].(int64), 0)
+	if err := s.store.RecordOAuthClientToken(client.ID, jti, expiresAt); err != nil {
+		if errors.Is(err, storage.ErrOAuthClientNotFound) {
+			return nil, ErrInvalidClient
+		}
+		return nil, err
+	}
+
+	return &models.OAuthTokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.accessTTL.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+// clientAllowsScope reports whether a requested scope is one of the client's
+// scopes or is covered by one of them.
+func clientAllowsScope(allowed []string, requested string) bool {
+	i := strings.LastIndex(requested, ":")
+	for _, scope := range allowed {
+		if scope == requested {
+			return true
+		}
+	}
+	return ScopesAllow(allowed, requested[:i], requested[i+1:])
+}
+
+// Token Introspection
+
+// IntrospectToken describes an access token issued by this service (RFC
+// 7662). Tokens that are malformed, not signed by this service, expired or
+// revoked are reported as inactive.
+func (s *AuthService) IntrospectToken(token string) (*models.TokenIntrospection, error) {
+	inactive := &models.TokenIntrospection{Active: false}
+
+	parsed, err := jwt.Parse(token, s.keys.Keyfunc, jwt.WithExpirationRequired())
+	if err != nil || !parsed.Valid {
+		return inactive, nil
+	}
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != nil {
+		return inactive, nil
+	}
+
+	rawUserID, _ := claims["user_id"].(string)
+	userID, err := uuid.Parse(rawUserID)
+	if err != nil {
+		return inactive, nil
+	}
+	rawJTI, _ := claims["jti"].(string)
+	jti, err := uuid.Parse(rawJTI)
+	if err != nil {
+		return inactive, nil
+	}
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return inactive, nil
+	}
+	expiresAt, _ := claims.GetExpirationTime()
+
+	revoked, err := s.IsTokenRevoked(jti, userID, issuedAt.Time)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return inactive, nil
+	}
+
+	response := &models.TokenIntrospection{
+		Active:    true,
+		TokenType: "Bearer",
+		ExpiresAt: expiresAt.Unix(),
+		IssuedAt:  issuedAt.Unix(),
+		Subject:   userID.String(),
+		TokenID:   jti.String(),
+	}
+	response.Scope, _ = claims["scope"].(string)
+	response.ClientID, _ = claims["client_id"].(string)
+	response.Username, _ = claims["email"].(string)
+	response.TenantID, _ = claims["tenant_id"].(string)
+	return response, nil
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/signing"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+// oauthClient registers a client with scopes for a new service account
+// holding roleID.
+func oauthClient(t *testing.T, auth *AuthService, name string, roleID uuid.UUID, scopes ...string) *models.CreateOAuthClientResponse {
+	t.Helper()
+	accountID := serviceAccount(t, auth, name, roleID)
+	return clientOf(t, auth, accountID, name, scopes...)
+}
+
+// clientOf registers another client of the service account.
+func clientOf(t *testing.T, auth *AuthService, accountID uuid.UUID, name string, scopes ...string) *models.CreateOAuthClientResponse {
+	t.Helper()
+	client, err := auth.CreateOAuthClient(accountID, models.CreateOAuthClientRequest{Name: name, Scopes: scopes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestCreateOAuthClient(t *testing.T) {
+	f := newFixture(t)
+	auth := newTestAuth(f.store)
+	parent := f.role("reader")
+	f.grant(parent, "course", "read", models.EffectAllow, "")
+	role := f.role("grader", parent)
+	f.grant(role, "grades", "*", models.EffectAllow, "")
+	f.grant(role, "reports", "read", models.EffectDeny, "")
+	f.permission("course", "write")
+	accountID := serviceAccount(t, auth, "batch", role)
+
+	for _, scopes := range [][]string{
+		nil, {}, {"bad"}, {"roster:read"}, {"course:write"}, {"course:*"}, {"*:*"}, {"reports:read"},
+		{"course:read", "course:write"},
+	} {
+		if _, err := auth.CreateOAuthClient(accountID, models.CreateOAuthClientRequest{Name: "c", Scopes: scopes}); !errors.Is(err, ErrInvalidOAuthClient) {
+			t.Errorf("scopes %q: CreateOAuthClient = %v, want ErrInvalidOAuthClient", scopes, err)
+		}
+	}
+	for _, scopes := range [][]string{{"course:read"}, {"grades:*"}, {"grades:read", "course:read"}} {
+		client, err := auth.CreateOAuthClient(accountID, models.CreateOAuthClientRequest{Name: "c", Scopes: scopes})
+		if err != nil {
+			t.Errorf("scopes %q: CreateOAuthClient = %v", scopes, err)
+			continue
+		}
+		if client.ClientSecret == "" || client.Client.SecretHash != "" {
+			t.Errorf("CreateOAuthClient = %+v", client)
+		}
+	}
+
+	// "*:*" needs the account to be granted "*:*".
+	superuser := f.role("superuser")
+	f.grant(superuser, "*", "*", models.EffectAllow, "")
+	root := serviceAccount(t, auth, "root", superuser)
+	for _, scopes := range [][]string{{"*:*"}, {"course:*"}} {
+		if _, err := auth.CreateOAuthClient(root, models.CreateOAuthClientRequest{Name: "c", Scopes: scopes}); err != nil {
+			t.Errorf("account granted *:*, scopes %q: CreateOAuthClient = %v", scopes, err)
+		}
+	}
+
+	bare := serviceAccount(t, auth, "bare", uuid.Nil)
+	if _, err := auth.CreateOAuthClient(bare, models.CreateOAuthClientRequest{Name: "c", Scopes: []string{"course:read"}}); !errors.Is(err, ErrInvalidOAuthClient) {
+		t.Errorf("account without roles: CreateOAuthClient = %v, want ErrInvalidOAuthClient", err)
+	}
+	if _, err := auth.CreateOAuthClient(uuid.New(), models.CreateOAuthClientRequest{Name: "c", Scopes: []string{"course:read"}}); !errors.Is(err, ErrServiceAccountNotFound) {
+		t.Errorf("unknown account: CreateOAuthClient = %v, want ErrServiceAccountNotFound", err)
+	}
+}
+
+func TestAuthenticateClient(t *testing.T) {
+	f := newFixture(t)
+	auth := newTestAuth(f.store)
+	reader := f.role("reader")
+	f.grant(reader, "course", "read", models.EffectAllow, "")
+	client := oauthClient(t, auth, "batch", reader, "course:read")
+	other := oauthClient(t, auth, "other", reader, "course:read")
+	clientID := client.Client.ID.String()
+
+	authenticated, err := auth.AuthenticateClient(clientID, client.ClientSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authenticated.ID != client.Client.ID || authenticated.SecretHash != "" {
+		t.Errorf("AuthenticateClient = %+v", authenticated)
+	}
+
+	tests := []struct {
+		name, clientID, secret string
+	}{
+		{"wrong secret", clientID, client.ClientSecret + "x"},
+		{"empty secret", clientID, ""},
+		{"secret of another client", clientID, other.ClientSecret},
+		{"unknown client", uuid.NewString(), client.ClientSecret},
+		{"malformed client ID", "batch", client.ClientSecret},
+	}
+	for _, tt := range tests {
+		if _, err := auth.AuthenticateClient(tt.clientID, tt.secret); !errors.Is(err, ErrInvalidClient) {
+			t.Errorf("%s: AuthenticateClient = %v, want ErrInvalidClient", tt.name, err)
+		}
+	}
+}
+
+func TestClientCredentialsScopes(t *testing.T) {
+	f := newFixture(t)
+	auth := newTestAuth(f.store)
+	f.permission("course", "read")
+	f.permission("course", "write")
+	role := f.role("batch")
+	f.grant(role, "course", "*", models.EffectAllow, "")
+	f.grant(role, "grades", "*", models.EffectAllow, "")
+	client := &oauthClient(t, auth, "batch", role, "course:*", "grades:read").Client
+
+	tests := []struct {
+		requested string
+		want      string
+	}{
+		{"", "course:* grades:read"},
+		{"course:read", "course:read"},
+		{"course:read  course:write", "course:read course:write"},
+		{"course:*", "course:*"},
+		{"grades:read", "grades:read"},
+	}
+	for _, tt := range tests {
+		response, err := auth.ClientCredentialsToken(client, tt.requested)
+		if err != nil {
+			t.Errorf("scope %q: ClientCredentialsToken = %v", tt.requested, err)
+			continue
+		}
+		if response.Scope != tt.want || response.TokenType != "Bearer" || response.ExpiresIn != 60 {
+			t.Errorf("scope %q: ClientCredentialsToken = %+v", tt.requested, response)
+		}
+		introspection, err := auth.IntrospectToken(response.AccessToken)
+		if err != nil || !introspection.Active || introspection.Scope != tt.want || introspection.ClientID != client.ID.String() {
+			t.Errorf("scope %q: IntrospectToken = %+v, %v", tt.requested, introspection, err)
+		}
+	}
+
+	// Requested scopes must be within the client's.
+	for _, requested := range []string{"grades:write", "grades:*", "*:*", "course:read grades:write", "bad"} {
+		if response, err := auth.ClientCredentialsToken(client, requested); !errors.Is(err, ErrInvalidScope) {
+			t.Errorf("scope %q: ClientCredentialsToken = %+v, %v; want ErrInvalidScope", requested, response, err)
+		}
+	}
+
+	// A client stored without scopes gets no token at all.
+	unscoped := &models.OAuthClient{ID: uuid.New(), ServiceAccountID: client.ServiceAccountID, Name: "legacy", SecretHash: hashToken("secret"), Scopes: []string{}}
+	if err := f.store.CreateOAuthClient(unscoped); err != nil {
+		t.Fatal(err)
+	}
+	for _, requested := range []string{"", "course:read"} {
+		if response, err := auth.ClientCredentialsToken(unscoped, requested); !errors.Is(err, ErrInvalidScope) {
+			t.Errorf("client without scopes, scope %q: ClientCredentialsToken = %+v, %v; want ErrInvalidScope", requested, response, err)
+		}
+	}
+}
+
+func TestIntrospectToken(t *testing.T) {
+	f := newFixture(t)
+	auth := newTestAuth(f.store)
+	reader := f.role("reader")
+	f.grant(reader, "course", "read", models.EffectAllow, "")
+	created := oauthClient(t, auth, "batch", reader, "course:read")
+	client := &created.Client
+
+	issue := func(auth *AuthService) string {
+		t.Helper()
+		response, err := auth.ClientCredentialsToken(client, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response.AccessToken
+	}
+	inactive := func(name, token string) {
+		t.Helper()
+		introspection, err := auth.IntrospectToken(token)
+		if err != nil {
+			t.Fatalf("%s: IntrospectToken: %v", name, err)
+		}
+		body, err := json.Marshal(introspection)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != `{"active":false}` {
+			t.Errorf("%s: introspection = %s, want {\"active\":false}", name, body)
+		}
+	}
+
+	token := issue(auth)
+	claims := parseAccessToken(t, auth, token)
+	introspection, err := auth.IntrospectToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := models.TokenIntrospection{
+		Active:    true,
+		Scope:     "course:read",
+		ClientID:  client.ID.String(),
+		Username:  "batch@" + models.ServiceAccountDomain,
+		TokenType: "Bearer",
+		ExpiresAt: claims.expiresAt.Unix(),
+		IssuedAt:  claims.issuedAt.Unix(),
+		Subject:   client.ServiceAccountID.String(),
+		TokenID:   claims.jti.String(),
+	}
+	if *introspection != want {
+		t.Errorf("IntrospectToken = %+v, want %+v", *introspection, want)
+	}
+
+	expired := NewAuthService(f.store, auth.keys, -time.Minute, time.Hour)
+	inactive("expired", issue(expired))
+	inactive("signed by another key", issue(NewAuthService(f.store, signing.NewHMACKeyManager("other-secret"), time.Minute, time.Hour)))
+	inactive("malformed", "not-a-token")
+	inactive("empty", "")
+
+	// Single-purpose tokens of this service are not access tokens.
+	register(t, auth, "ada@example.com")
+	auth.ConfigureMFA(MFASettings{Issuer: "test", RequiredRoles: []string{"admin"}})
+	admin := f.role("admin")
+	user, err := f.store.GetUserByEmail("ada@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.assign(user.ID, admin, models.GlobalTenantID)
+	_, challenge, err := auth.Login(models.LoginRequest{Email: "ada@example.com", Password: testPassword})
+	if err != nil || challenge == nil {
+		t.Fatalf("Login = %v, %v; want an MFA challenge", challenge, err)
+	}
+	inactive("MFA token", challenge.MFAToken)
+
+	// Revoking the token, or deleting the client, makes it inactive.
+	if err := auth.RevokeToken(claims.jti, claims.userID, claims.expiresAt); err != nil {
+		t.Fatal(err)
+	}
+	inactive("revoked", token)
+
+	token = issue(auth)
+	if err := auth.DeleteOAuthClient(client.ServiceAccountID, client.ID); err != nil {
+		t.Fatal(err)
+	}
+	inactive("client deleted", token)
+}
+
+func TestDeleteOAuthClient(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store storage.Store) {
+		f := newFixtureOn(t, store)
+		auth := newTestAuth(f.store)
+		reader := f.role("reader")
+		f.grant(reader, "course", "read", models.EffectAllow, "")
+		deleted := oauthClient(t, auth, "batch", reader, "course:read").Client
+		kept := clientOf(t, auth, deleted.ServiceAccountID, "other", "course:read").Client
+
+		issue := func(client *models.OAuthClient) string {
+			t.Helper()
+			response, err := auth.ClientCredentialsToken(client, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			return response.AccessToken
+		}
+		active := func(token string) bool {
+			t.Helper()
+			introspection, err := auth.IntrospectToken(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return introspection.Active
+		}
+		deletedTokens := []string{issue(&deleted), issue(&deleted)}
+		keptToken := issue(&kept)
+
+		if err := auth.DeleteOAuthClient(uuid.New(), deleted.ID); !errors.Is(err, ErrOAuthClientNotFound) {
+			t.Errorf("client of another account: DeleteOAuthClient = %v, want ErrOAuthClientNotFound", err)
+		}
+		if err := auth.DeleteOAuthClient(deleted.ServiceAccountID, deleted.ID); err != nil {
+			t.Fatal(err)
+		}
+		for i, token := range deletedTokens {
+			if active(token) {
+				t.Errorf("token %d of the deleted client is still active", i)
+			}
+		}
+		if !active(keptToken) {
+			t.Error("token of the account's other client was revoked")
+		}
+		if !active(issue(&kept)) {
+			t.Error("the account's other client cannot get working tokens")
+		}
+
+		// The deleted client gets no token, even with the client loaded
+		// before it was deleted.
+		if response, err := auth.ClientCredentialsToken(&deleted, ""); !errors.Is(err, ErrInvalidClient) {
+			t.Errorf("deleted client: ClientCredentialsToken = %+v, %v; want ErrInvalidClient", response, err)
+		}
+		if err := auth.DeleteOAuthClient(deleted.ServiceAccountID, deleted.ID); !errors.Is(err, ErrOAuthClientNotFound) {
+			t.Errorf("second DeleteOAuthClient = %v, want ErrOAuthClientNotFound", err)
+		}
+	})
+}
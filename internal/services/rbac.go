@@ -1,21 +1,50 @@
 package services
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 
-	"github.com/Anand078/rbac/internal/database"
 	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+var (
+	ErrRoleCycle       = storage.ErrRoleCycle
+	ErrRoleNotFound    = storage.ErrRoleNotFound
+	ErrSelfParentRole  = errors.New("role cannot be its own parent")
+	ErrUserNotFound    = storage.ErrUserNotFound
+	ErrInvalidValidity = errors.New("valid_until must be in the future and after valid_from")
+	ErrDuplicate       = storage.ErrDuplicate
 )
 
 type RBACService struct {
-	db *database.DB
+	store      storage.Store
+	conditions *conditionCache
+	events     *EventBus
+
+	policyVersion *policyVersionCache
+	// cache is nil unless EnablePermissionCache was called.
+	cache *permissionCache
 }
 
-func NewRBACService(db *database.DB) *RBACService {
-	return &RBACService{db: db}
+func NewRBACService(store storage.Store) *RBACService {
+	s := &RBACService{
+		store:         store,
+		conditions:    newConditionCache(),
+		events:        NewEventBus(),
+		policyVersion: &policyVersionCache{},
+	}
+	s.events.Subscribe(s.bumpPolicyVersion)
+	return s
+}
+
+// Events returns the bus on which every change to roles, assignments and
+// grants is published.
+func (s *RBACService) Events() *EventBus {
+	return s.events
 }
 
 // Role Management
@@ -26,88 +55,163 @@ func (s *RBACService) CreateRole(req models.CreateRoleRequest) (*models.Role, er
 		Description: req.Description,
 	}
 
-	query := `
-        INSERT INTO roles (id, name, description)
-        VALUES ($1, $2, $3)
-        RETURNING created_at
-    `
-	err := s.db.QueryRow(query, role.ID, role.Name, role.Description).Scan(&role.CreatedAt)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create role: %w", err)
+	parentIDs := make([]uuid.UUID, 0, len(req.ParentIDs))
+	for _, idStr := range req.ParentIDs {
+		parentID, err := uuid.Parse(idStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parent role ID: %w", err)
+		}
+		parentIDs = append(parentIDs, parentID)
 	}
+	role.ParentIDs = parentIDs
 
+	if err := s.store.CreateRole(role); err != nil {
+		return nil, err
+	}
 	return role, nil
 }
 
 func (s *RBACService) GetAllRoles() ([]models.Role, error) {
-	query := `SELECT id, name, description, created_at FROM roles ORDER BY name`
-	rows, err := s.db.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+	return s.store.ListRoles()
+}
 
-	var roles []models.Role
-	for rows.Next() {
-		var role models.Role
-		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt); err != nil {
+// GetUserRoles returns the roles the user holds within tenantID, along with
+// every role they inherit through the hierarchy. Pass models.GlobalTenantID
+// for global assignments only.
+func (s *RBACService) GetUserRoles(userID, tenantID uuid.UUID) ([]models.Role, error) {
+	if s.cache != nil {
+		entry, err := s.cachedPermissions(userID, tenantID)
+		if err != nil {
 			return nil, err
 		}
-		roles = append(roles, role)
+		return append([]models.Role(nil), entry.roles...), nil
 	}
+	return s.store.EffectiveRoles(userID, tenantID)
+}
 
-	return roles, nil
+// GetUserAssignments lists the user's direct assignments in every tenant,
+// including scheduled and expired ones that have not been swept yet.
+func (s *RBACService) GetUserAssignments(userID uuid.UUID) ([]models.RoleAssignment, error) {
+	return s.store.ListAssignments(userID)
 }
 
-func (s *RBACService) GetUserRoles(userID uuid.UUID) ([]models.Role, error) {
-	query := `
-        SELECT r.id, r.name, r.description, r.created_at
-        FROM roles r
-        JOIN user_roles ur ON r.id = ur.role_id
-        WHERE ur.user_id = $1
-    `
-	rows, err := s.db.Query(query, userID)
-	if err != nil {
-		return nil, err
+// AssignRole gives the user a role within a.TenantID, or everywhere when it
+// is models.GlobalTenantID, for the optional validity window. Assigning an
+// existing (user, role, tenant) again replaces its window, and makes an
+// assignment managed by an identity provider a manual one.
+func (s *RBACService) AssignRole(a models.RoleAssignment) error {
+	if a.ValidFrom != nil && a.ValidUntil != nil && !a.ValidUntil.After(*a.ValidFrom) {
+		return ErrInvalidValidity
+	}
+	if a.ValidUntil != nil && !a.ValidUntil.After(time.Now()) {
+		return ErrInvalidValidity
 	}
-	defer rows.Close()
 
-	var roles []models.Role
-	for rows.Next() {
-		var role models.Role
-		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt); err != nil {
-			return nil, err
-		}
-		roles = append(roles, role)
+	if err := s.store.AssignRole(a); err != nil {
+		return err
 	}
 
-	return roles, nil
+	s.events.Publish(Event{Type: EventRoleAssigned, UserID: a.UserID, RoleID: a.RoleID, TenantID: a.TenantID})
+	return nil
 }
 
-func (s *RBACService) AssignRole(userID, roleID uuid.UUID) error {
-	query := `
-        INSERT INTO user_roles (user_id, role_id)
-        VALUES ($1, $2)
-        ON CONFLICT (user_id, role_id) DO NOTHING
-    `
-	_, err := s.db.Exec(query, userID, roleID)
+// RemoveRole takes a role away from the user and ends every session of the
+// user, so tokens issued under the old role set cannot outlive the change.
+// Assignments managed by an identity provider cannot be removed this way,
+// since the next sync of the user's groups would restore them; change the
+// group mappings instead.
+//
+// Roles removed by SyncGroupRoles or archived by SweepExpiredAssignments do
+// not end sessions: requests are authorized against the current assignments
+// either way, and authorization snapshots in tokens are outdated by the
+// policy version or by their Until time.
+func (s *RBACService) RemoveRole(userID, roleID, tenantID uuid.UUID) error {
+	assignments, err := s.store.ListAssignments(userID)
 	if err != nil {
-		return fmt.Errorf("failed to assign role: %w", err)
+		return err
+	}
+	for _, a := range assignments {
+		if a.RoleID == roleID && a.TenantID == tenantID && a.ManagedBy != "" {
+			return fmt.Errorf("%w %q", ErrAssignmentManaged, a.ManagedBy)
+		}
+	}
+
+	if err := s.store.RemoveRole(userID, roleID, tenantID); err != nil {
+		return err
+	}
+	revokeErr := s.store.RevokeAllSessions(userID)
+
+	s.events.Publish(Event{Type: EventRoleRemoved, UserID: userID, RoleID: roleID, TenantID: tenantID})
+	if revokeErr != nil {
+		return fmt.Errorf("role removed but failed to revoke sessions: %w", revokeErr)
 	}
 	return nil
 }
 
-func (s *RBACService) RemoveRole(userID, roleID uuid.UUID) error {
-	query := `DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2`
-	_, err := s.db.Exec(query, userID, roleID)
+// SweepExpiredAssignments moves assignments whose validity window has ended
+// into the archive and publishes EventAssignmentExpired for each.
+func (s *RBACService) SweepExpiredAssignments() (int, error) {
+	expired, err := s.store.ArchiveExpiredAssignments()
 	if err != nil {
-		return fmt.Errorf("failed to remove role: %w", err)
+		return 0, err
+	}
+
+	for _, a := range expired {
+		s.events.Publish(Event{Type: EventAssignmentExpired, UserID: a.UserID, RoleID: a.RoleID, TenantID: a.TenantID})
 	}
+	return len(expired), nil
+}
+
+// Role Hierarchy
+func (s *RBACService) GetParentRoles(roleID uuid.UUID) ([]models.Role, error) {
+	return s.store.GetParentRoles(roleID)
+}
+
+// AddParentRole makes roleID inherit every permission of parentID. It refuses
+// links that would make a role its own ancestor.
+func (s *RBACService) AddParentRole(roleID, parentID uuid.UUID) error {
+	if roleID == parentID {
+		return ErrSelfParentRole
+	}
+
+	if err := s.store.AddParentRole(roleID, parentID); err != nil {
+		return err
+	}
+
+	s.events.Publish(Event{Type: EventParentRoleAdded, RoleID: roleID})
+	return nil
+}
+
+func (s *RBACService) RemoveParentRole(roleID, parentID uuid.UUID) error {
+	if err := s.store.RemoveParentRole(roleID, parentID); err != nil {
+		return err
+	}
+
+	s.events.Publish(Event{Type: EventParentRoleRemoved, RoleID: roleID})
+	return nil
+}
+
+// User Attributes
+func (s *RBACService) GetUserAttributes(userID uuid.UUID) (map[string]any, error) {
+	return s.store.GetUserAttributes(userID)
+}
+
+// SetUserAttributes replaces the attributes conditions see as user.<name>.
+func (s *RBACService) SetUserAttributes(userID uuid.UUID, attributes map[string]any) error {
+	if err := s.store.SetUserAttributes(userID, attributes); err != nil {
+		return err
+	}
+
+	s.events.Publish(Event{Type: EventUserAttributesSet, UserID: userID})
 	return nil
 }
 
 // Permission Management
 func (s *RBACService) CreatePermission(req models.CreatePermissionRequest) (*models.Permission, error) {
+	if err := ValidatePermissionPattern(req.Resource, req.Action); err != nil {
+		return nil, err
+	}
+
 	permission := &models.Permission{
 		ID:          uuid.New(),
 		Name:        req.Name,
@@ -116,108 +220,154 @@ func (s *RBACService) CreatePermission(req models.CreatePermissionRequest) (*mod
 		Description: req.Description,
 	}
 
-	query := `
-        INSERT INTO permissions (id, name, resource, action, description)
-        VALUES ($1, $2, $3, $4, $5)
-        RETURNING created_at
-    `
-	err := s.db.QueryRow(query, permission.ID, permission.Name, permission.Resource,
-		permission.Action, permission.Description).Scan(&permission.CreatedAt)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create permission: %w", err)
+	if err := s.store.CreatePermission(permission); err != nil {
+		return nil, err
 	}
-
 	return permission, nil
 }
 
 func (s *RBACService) GetAllPermissions() ([]models.Permission, error) {
-	query := `SELECT id, name, resource, action, description, created_at FROM permissions ORDER BY resource, action`
-	rows, err := s.db.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+	return s.store.ListPermissions()
+}
 
-	var permissions []models.Permission
-	for rows.Next() {
-		var perm models.Permission
-		if err := rows.Scan(&perm.ID, &perm.Name, &perm.Resource, &perm.Action,
-			&perm.Description, &perm.CreatedAt); err != nil {
-			return nil, err
+// GetRolePermissions returns the permissions granted to the role directly or
+// through any of its ancestors.
+func (s *RBACService) GetRolePermissions(roleID uuid.UUID) ([]models.Permission, error) {
+	return s.store.GetRolePermissions(roleID)
+}
+
+// GrantPermission attaches a permission to a role with the given effect and
+// optional condition, which is compiled up front so invalid expressions are
+// rejected at grant time. Granting an existing pair again replaces both.
+func (s *RBACService) GrantPermission(roleID, permissionID uuid.UUID, effect models.Effect, condition string) error {
+	if effect == "" {
+		effect = models.EffectAllow
+	}
+	if condition != "" {
+		if _, err := s.conditions.compile(condition); err != nil {
+			return err
 		}
-		permissions = append(permissions, perm)
 	}
 
-	return permissions, nil
+	if err := s.store.GrantPermission(roleID, permissionID, effect, condition); err != nil {
+		return err
+	}
+
+	s.events.Publish(Event{Type: EventPermissionGranted, RoleID: roleID, PermissionID: permissionID})
+	return nil
 }
 
-func (s *RBACService) GetRolePermissions(roleID uuid.UUID) ([]models.Permission, error) {
-	query := `
-        SELECT p.id, p.name, p.resource, p.action, p.description, p.created_at
-        FROM permissions p
-        JOIN role_permissions rp ON p.id = rp.permission_id
-        WHERE rp.role_id = $1
-        ORDER BY p.resource, p.action
-    `
-	rows, err := s.db.Query(query, roleID)
+func (s *RBACService) RevokePermission(roleID, permissionID uuid.UUID) error {
+	if err := s.store.RevokePermission(roleID, permissionID); err != nil {
+		return err
+	}
+
+	s.events.Publish(Event{Type: EventPermissionRevoked, RoleID: roleID, PermissionID: permissionID})
+	return nil
+}
+
+// Authorization Check
+
+// Decision is the outcome of evaluating a user's grants against a request.
+type Decision int
+
+const (
+	// DecisionNoMatch means no grant covers the request; access is denied by default.
+	DecisionNoMatch Decision = iota
+	DecisionAllow
+	// DecisionDeny means an explicit deny grant covers the request.
+	DecisionDeny
+)
+
+// AccessRequest describes a single authorization question.
+type AccessRequest struct {
+	UserID uuid.UUID
+	// TenantID selects which tenant-scoped assignments apply in addition to
+	// global ones; models.GlobalTenantID considers global assignments only.
+	TenantID uuid.UUID
+	Resource string
+	// ResourceID names a specific instance of Resource. It is only consulted
+	// by instance-level checks such as ACLService.Evaluate and exposed to
+	// conditions as resource.id.
+	ResourceID string
+	Action     string
+
+	// The fields below are only read by conditional grants.
+	ResourceAttributes map[string]any
+	ClientIP           string
+	// Time defaults to the current time when zero.
+	Time time.Time
+}
+
+// Evaluate applies deny-overrides across every role the user holds in the
+// requested tenant, directly or through inheritance: a single matching deny
+// wins over any number of matching allows. Exact matches and wildcard grants
+// are pre-filtered by the store; the pattern semantics live in MatchPermission.
+//
+// A grant with a condition only applies when the condition holds. A condition
+// that fails to evaluate never lets an allow through but still applies a deny.
+func (s *RBACService) Evaluate(req AccessRequest) (Decision, error) {
+	grants, err := s.matchingGrants(req)
 	if err != nil {
-		return nil, err
+		return DecisionNoMatch, err
 	}
-	defer rows.Close()
 
-	var permissions []models.Permission
-	for rows.Next() {
-		var perm models.Permission
-		if err := rows.Scan(&perm.ID, &perm.Name, &perm.Resource, &perm.Action,
-			&perm.Description, &perm.CreatedAt); err != nil {
-			return nil, err
+	var env map[string]any
+	decision := DecisionNoMatch
+	for _, g := range grants {
+		if g.Condition != "" {
+			if env == nil {
+				if env, err = s.conditionEnv(req); err != nil {
+					return DecisionNoMatch, err
+				}
+			}
+			holds, err := s.conditions.eval(g.Condition, env)
+			if err != nil && g.Effect != models.EffectDeny {
+				continue
+			}
+			if err == nil && !holds {
+				continue
+			}
 		}
-		permissions = append(permissions, perm)
+		if g.Effect == models.EffectDeny {
+			return DecisionDeny, nil
+		}
+		decision = DecisionAllow
 	}
 
-	return permissions, nil
+	return decision, nil
 }
 
-func (s *RBACService) GrantPermission(roleID, permissionID uuid.UUID) error {
-	query := `
-        INSERT INTO role_permissions (role_id, permission_id)
-        VALUES ($1, $2)
-        ON CONFLICT (role_id, permission_id) DO NOTHING
-    `
-	_, err := s.db.Exec(query, roleID, permissionID)
-	if err != nil {
-		return fmt.Errorf("failed to grant permission: %w", err)
+// matchingGrants returns the user's grants covering the requested resource
+// and action, from the permission cache when enabled.
+func (s *RBACService) matchingGrants(req AccessRequest) ([]storage.Grant, error) {
+	var all []storage.Grant
+	if s.cache != nil {
+		entry, err := s.cachedPermissions(req.UserID, req.TenantID)
+		if err != nil {
+			return nil, err
+		}
+		all = entry.grants
+	} else {
+		var err error
+		if all, err = s.store.EffectiveGrants(req.UserID, req.TenantID, req.Resource, req.Action); err != nil {
+			return nil, err
+		}
 	}
-	return nil
-}
 
-func (s *RBACService) RevokePermission(roleID, permissionID uuid.UUID) error {
-	query := `DELETE FROM role_permissions WHERE role_id = $1 AND permission_id = $2`
-	_, err := s.db.Exec(query, roleID, permissionID)
-	if err != nil {
-		return fmt.Errorf("failed to revoke permission: %w", err)
+	var grants []storage.Grant
+	for _, g := range all {
+		if MatchPermission(g.Resource, g.Action, req.Resource, req.Action) {
+			grants = append(grants, g)
+		}
 	}
-	return nil
+	return grants, nil
 }
 
-// Authorization Check
-func (s *RBACService) HasPermission(userID uuid.UUID, resource, action string) (bool, error) {
-	query := `
-        SELECT COUNT(*) > 0
-        FROM users u
-        JOIN user_roles ur ON u.id = ur.user_id
-        JOIN role_permissions rp ON ur.role_id = rp.role_id
-        JOIN permissions p ON rp.permission_id = p.id
-        WHERE u.id = $1 AND p.resource = $2 AND p.action = $3
-    `
-	var hasPermission bool
-	err := s.db.QueryRow(query, userID, resource, action).Scan(&hasPermission)
+func (s *RBACService) HasPermission(req AccessRequest) (bool, error) {
+	decision, err := s.Evaluate(req)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return false, nil
-		}
 		return false, err
 	}
-
-	return hasPermission, nil
+	return decision == DecisionAllow, nil
 }
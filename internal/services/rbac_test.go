@@ -0,0 +1,198 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+	"github.com/Anand078/rbac/internal/storage/memory"
+	"github.com/Anand078/rbac/internal/storage/sqlite"
+)
+
+// fixture builds authorization state on a store, in memory unless the test
+// runs on every backend through forEachStore.
+type fixture struct {
+	t     *testing.T
+	store storage.Store
+	rbac  *RBACService
+}
+
+func newFixture(t *testing.T) *fixture {
+	return newFixtureOn(t, memory.New())
+}
+
+func newFixtureOn(t *testing.T, store storage.Store) *fixture {
+	return &fixture{t: t, store: store, rbac: NewRBACService(store)}
+}
+
+// forEachStore runs test on the in-memory and SQLite backends.
+func forEachStore(t *testing.T, test func(t *testing.T, store storage.Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, memory.New())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		store, err := sqlite.Open(filepath.Join(t.TempDir(), "rbac.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer store.Close()
+		test(t, store)
+	})
+}
+
+func (f *fixture) user(email string) uuid.UUID {
+	f.t.Helper()
+	user := &models.User{ID: uuid.New(), Email: email, Name: email, PasswordHash: unusablePasswordHash}
+	if err := f.store.CreateUser(user, uuid.Nil); err != nil {
+		f.t.Fatalf("CreateUser(%s): %v", email, err)
+	}
+	return user.ID
+}
+
+func (f *fixture) role(name string, parents ...uuid.UUID) uuid.UUID {
+	f.t.Helper()
+	req := models.CreateRoleRequest{Name: name}
+	for _, parent := range parents {
+		req.ParentIDs = append(req.ParentIDs, parent.String())
+	}
+	role, err := f.rbac.CreateRole(req)
+	if err != nil {
+		f.t.Fatalf("CreateRole(%s): %v", name, err)
+	}
+	return role.ID
+}
+
+// permission returns the ID of the permission on (resource, action), creating
+// it on first use.
+func (f *fixture) permission(resource, action string) uuid.UUID {
+	f.t.Helper()
+	permissions, err := f.rbac.GetAllPermissions()
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	for _, p := range permissions {
+		if p.Resource == resource && p.Action == action {
+			return p.ID
+		}
+	}
+	permission, err := f.rbac.CreatePermission(models.CreatePermissionRequest{
+		Name: resource + "_" + action, Resource: resource, Action: action,
+	})
+	if err != nil {
+		f.t.Fatalf("CreatePermission(%s, %s): %v", resource, action, err)
+	}
+	return permission.ID
+}
+
+func (f *fixture) grant(roleID uuid.UUID, resource, action string, effect models.Effect, condition string) {
+	f.t.Helper()
+	if err := f.rbac.GrantPermission(roleID, f.permission(resource, action), effect, condition); err != nil {
+		f.t.Fatalf("GrantPermission(%s, %s): %v", resource, action, err)
+	}
+}
+
+func (f *fixture) assign(userID, roleID, tenantID uuid.UUID) {
+	f.t.Helper()
+	if err := f.rbac.AssignRole(models.RoleAssignment{UserID: userID, RoleID: roleID, TenantID: tenantID}); err != nil {
+		f.t.Fatalf("AssignRole: %v", err)
+	}
+}
+
+func (f *fixture) decide(userID, tenantID uuid.UUID, resource, action string) Decision {
+	f.t.Helper()
+	decision, err := f.rbac.Evaluate(AccessRequest{UserID: userID, TenantID: tenantID, Resource: resource, Action: action})
+	if err != nil {
+		f.t.Fatalf("Evaluate(%s, %s): %v", resource, action, err)
+	}
+	return decision
+}
+
+func TestEvaluateDenyOverrides(t *testing.T) {
+	for _, cached := range []bool{false, true} {
+		f := newFixture(t)
+		if cached {
+			f.rbac.EnablePermissionCache(time.Minute, 100)
+		}
+		tenantA, tenantB := uuid.New(), uuid.New()
+
+		student := f.role("student")
+		f.grant(student, "course", "read", models.EffectAllow, "")
+		f.grant(student, "grades/*", "read", models.EffectAllow, "")
+		teacher := f.role("teacher", student)
+		f.grant(teacher, "course", "update", models.EffectAllow, "")
+		probation := f.role("probation")
+		f.grant(probation, "grades/2024", "read", models.EffectDeny, "")
+		suspended := f.role("suspended")
+		f.grant(suspended, "*", "*", models.EffectDeny, "")
+		restricted := f.role("restricted", teacher)
+		f.grant(restricted, "course", "*", models.EffectDeny, "")
+
+		alice := f.user("alice@example.com")
+		f.assign(alice, teacher, models.GlobalTenantID)
+		bob := f.user("bob@example.com")
+		f.assign(bob, student, models.GlobalTenantID)
+		f.assign(bob, probation, models.GlobalTenantID)
+		carol := f.user("carol@example.com")
+		f.assign(carol, teacher, models.GlobalTenantID)
+		f.assign(carol, suspended, tenantA)
+		dave := f.user("dave@example.com")
+		f.assign(dave, restricted, models.GlobalTenantID)
+
+		tests := []struct {
+			name             string
+			userID, tenantID uuid.UUID
+			resource, action string
+			want             Decision
+		}{
+			{"direct allow", alice, models.GlobalTenantID, "course", "update", DecisionAllow},
+			{"inherited allow", alice, models.GlobalTenantID, "course", "read", DecisionAllow},
+			{"no grant", alice, models.GlobalTenantID, "course", "delete", DecisionNoMatch},
+			{"global roles apply in tenants", alice, tenantA, "course", "read", DecisionAllow},
+			{"deny beats allow of another role", bob, models.GlobalTenantID, "grades/2024", "read", DecisionDeny},
+			{"deny only covers its pattern", bob, models.GlobalTenantID, "grades/2025", "read", DecisionAllow},
+			{"wildcard deny in tenant", carol, tenantA, "course", "read", DecisionDeny},
+			{"tenant deny stays in its tenant", carol, tenantB, "course", "read", DecisionAllow},
+			{"tenant deny is not global", carol, models.GlobalTenantID, "course", "update", DecisionAllow},
+			{"deny of child beats inherited allow", dave, models.GlobalTenantID, "course", "read", DecisionDeny},
+			{"inherited allow outside child deny", dave, models.GlobalTenantID, "grades/2024", "read", DecisionAllow},
+		}
+		for _, tt := range tests {
+			if got := f.decide(tt.userID, tt.tenantID, tt.resource, tt.action); got != tt.want {
+				t.Errorf("cached=%v %s: Evaluate(%s, %s) = %v, want %v", cached, tt.name, tt.resource, tt.action, got, tt.want)
+			}
+		}
+
+		// The outcome does not depend on the order grants are found in: an
+		// allow granted after the deny still loses.
+		f.grant(probation, "grades/*", "read", models.EffectAllow, "")
+		if got := f.decide(bob, models.GlobalTenantID, "grades/2024", "read"); got != DecisionDeny {
+			t.Errorf("cached=%v: deny lost to a later allow: %v", cached, got)
+		}
+		// Revoking the deny lets the allow through again.
+		if err := f.rbac.RevokePermission(suspended, f.permission("*", "*")); err != nil {
+			t.Fatal(err)
+		}
+		if got := f.decide(carol, tenantA, "course", "read"); got != DecisionAllow {
+			t.Errorf("cached=%v: revoked deny still applies: %v", cached, got)
+		}
+	}
+}
+
+func TestHasPermissionRequiresAllow(t *testing.T) {
+	f := newFixture(t)
+	role := f.role("auditor")
+	f.grant(role, "reports", "read", models.EffectDeny, "")
+	user := f.user("auditor@example.com")
+	f.assign(user, role, models.GlobalTenantID)
+
+	for _, resource := range []string{"reports", "course"} {
+		ok, err := f.rbac.HasPermission(AccessRequest{UserID: user, Resource: resource, Action: "read"})
+		if err != nil || ok {
+			t.Errorf("HasPermission(%s) = %v, %v; want false", resource, ok, err)
+		}
+	}
+}
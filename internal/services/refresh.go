@@ -0,0 +1,122 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token
+	// is presented again. The whole token family is revoked in response, since
+	// either the legitimate client or an attacker holds a stolen copy.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected; session revoked")
+)
+
+func generateOpaqueToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken returns the value stored at rest for an opaque token. Tokens carry
+// 256 bits of entropy, so a fast unsalted hash is sufficient.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *AuthService) newRefreshToken() (string, storage.RefreshToken, error) {
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return "", storage.RefreshToken{}, err
+	}
+	return token, storage.RefreshToken{
+		ID:        uuid.New(),
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}, nil
+}
+
+func (s *AuthService) createRefreshToken(userID, tenantID, familyID uuid.UUID) (string, error) {
+	token, record, err := s.newRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	record.UserID, record.TenantID, record.FamilyID = userID, tenantID, familyID
+
+	if err := s.store.CreateRefreshToken(record); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token in the same family. Each refresh token can be used once; presenting a
+// used or revoked one revokes every token in its family.
+func (s *AuthService) Refresh(req models.RefreshTokenRequest) (*models.LoginResponse, error) {
+	refreshToken, next, err := s.newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	used, err := s.store.RotateRefreshToken(hashToken(req.RefreshToken), next)
+	switch {
+	case errors.Is(err, storage.ErrTokenReused):
+		log.Printf("Refresh token reuse detected for user %s; revoked family %s", used.UserID, used.FamilyID)
+		return nil, ErrRefreshTokenReused
+	case errors.Is(err, storage.ErrTokenNotFound):
+		return nil, ErrInvalidRefreshToken
+	case err != nil:
+		return nil, err
+	}
+
+	user, err := s.store.GetUserByID(used.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	user.Roles, err = s.store.EffectiveRoles(user.ID, used.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A role requiring MFA granted after login ends the session until the
+	// user enrolls.
+	if err := s.checkMFAEnrollment(user.ID, user.Roles); err != nil {
+		if errors.Is(err, ErrMFAEnrollmentRequired) {
+			if err := s.store.RevokeRefreshFamily(user.ID, next.TokenHash); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+
+	token, err := s.generateToken(user, used.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.accessTTL.Seconds()),
+		User:         *user,
+	}, nil
+}
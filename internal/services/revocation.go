@@ -0,0 +1,62 @@
+package services
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevokeToken blocks a single access token, identified by its jti claim,
+// until it would have expired anyway.
+func (s *AuthService) RevokeToken(jti, userID uuid.UUID, expiresAt time.Time) error {
+	_, err := s.store.RevokeAccessToken(jti, userID, expiresAt)
+	return err
+}
+
+// RevokeRefreshToken revokes the rotation family of refreshToken, ending the
+// session it belongs to. Unknown tokens are ignored.
+func (s *AuthService) RevokeRefreshToken(userID uuid.UUID, refreshToken string) error {
+	return s.store.RevokeRefreshFamily(userID, hashToken(refreshToken))
+}
+
+// RevokeAllSessions invalidates every access token issued to the user so far
+// and revokes all of their refresh tokens.
+func (s *AuthService) RevokeAllSessions(userID uuid.UUID) error {
+	return s.store.RevokeAllSessions(userID)
+}
+
+// IsTokenRevoked reports whether an access token was revoked individually or
+// issued before the user's sessions were last revoked. issuedAt is the iat
+// claim of the token.
+func (s *AuthService) IsTokenRevoked(jti, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	return s.store.IsTokenRevoked(jti, userID, tokenIssuedAt(jti, issuedAt))
+}
+
+// newTokenID returns the jti of a new token and the time to issue it at. The
+// jti is a version 7 UUID, whose timestamp records that time to the
+// millisecond, where the iat claim has whole seconds only.
+func newTokenID() (uuid.UUID, time.Time) {
+	jti := uuid.Must(uuid.NewV7())
+	return jti, time.Unix(jti.Time().UnixTime())
+}
+
+// tokenIssuedAt returns when a token was issued: the time in its jti if it
+// came from newTokenID, so that tokens issued in the same second as a
+// RevokeAllSessions, before or after it, are told apart, and issuedAt, its
+// iat claim, otherwise. The jti only refines issuedAt within its second.
+func tokenIssuedAt(jti uuid.UUID, issuedAt time.Time) time.Time {
+	if jti.Version() != 7 {
+		return issuedAt
+	}
+	generated := time.Unix(jti.Time().UnixTime())
+	if generated.Before(issuedAt) || !generated.Before(issuedAt.Add(time.Second)) {
+		return issuedAt
+	}
+	return generated
+}
+
+// PurgeExpiredTokens deletes revocation entries and refresh tokens that have
+// expired and can no longer be presented.
+func (s *AuthService) PurgeExpiredTokens() (int, error) {
+	return s.store.PurgeExpiredTokens()
+}
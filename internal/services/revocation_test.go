@@ -0,0 +1,271 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/signing"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+const testPassword = "correct horse"
+
+func newTestAuth(store storage.Store) *AuthService {
+	return NewAuthService(store, signing.NewHMACKeyManager("test-secret"), time.Minute, time.Hour)
+}
+
+// accessToken holds the claims of an issued access token that revocation
+// looks at.
+type accessToken struct {
+	jti       uuid.UUID
+	userID    uuid.UUID
+	issuedAt  time.Time
+	expiresAt time.Time
+}
+
+func parseAccessToken(t *testing.T, auth *AuthService, raw string) accessToken {
+	t.Helper()
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(raw, claims, auth.keys.Keyfunc); err != nil {
+		t.Fatalf("parse access token: %v", err)
+	}
+	jti, _ := claims["jti"].(string)
+	userID, _ := claims["user_id"].(string)
+	issuedAt, _ := claims.GetIssuedAt()
+	expiresAt, _ := claims.GetExpirationTime()
+	return accessToken{
+		jti:       uuid.MustParse(jti),
+		userID:    uuid.MustParse(userID),
+		issuedAt:  issuedAt.Time,
+		expiresAt: expiresAt.Time,
+	}
+}
+
+func register(t *testing.T, auth *AuthService, email string) uuid.UUID {
+	t.Helper()
+	user, err := auth.Register(models.CreateUserRequest{Email: email, Name: email, Password: testPassword})
+	if err != nil {
+		t.Fatalf("Register(%s): %v", email, err)
+	}
+	return user.ID
+}
+
+func login(t *testing.T, auth *AuthService, email string) *models.LoginResponse {
+	t.Helper()
+	response, challenge, err := auth.Login(models.LoginRequest{Email: email, Password: testPassword})
+	if err != nil || challenge != nil {
+		t.Fatalf("Login(%s) = %v, %v", email, challenge, err)
+	}
+	return response
+}
+
+func isRevoked(t *testing.T, auth *AuthService, token accessToken) bool {
+	t.Helper()
+	revoked, err := auth.IsTokenRevoked(token.jti, token.userID, token.issuedAt)
+	if err != nil {
+		t.Fatalf("IsTokenRevoked: %v", err)
+	}
+	return revoked
+}
+
+func TestLogoutRevokesToken(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store storage.Store) {
+		auth := newTestAuth(store)
+		register(t, auth, "ada@example.com")
+		first := login(t, auth, "ada@example.com")
+		second := login(t, auth, "ada@example.com")
+		token := parseAccessToken(t, auth, first.Token)
+		other := parseAccessToken(t, auth, second.Token)
+
+		if err := auth.RevokeToken(token.jti, token.userID, token.expiresAt); err != nil {
+			t.Fatal(err)
+		}
+		if !isRevoked(t, auth, token) {
+			t.Error("revoked token is still valid")
+		}
+		if isRevoked(t, auth, other) {
+			t.Error("revoking one token revoked another session")
+		}
+		// Logging out twice is harmless.
+		if err := auth.RevokeToken(token.jti, token.userID, token.expiresAt); err != nil {
+			t.Errorf("second RevokeToken: %v", err)
+		}
+
+		// Logging out with the refresh token ends that session only.
+		if err := auth.RevokeRefreshToken(token.userID, first.RefreshToken); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := auth.Refresh(models.RefreshTokenRequest{RefreshToken: first.RefreshToken}); !errors.Is(err, ErrRefreshTokenReused) {
+			t.Errorf("Refresh after logout = %v, want ErrRefreshTokenReused", err)
+		}
+		if _, err := auth.Refresh(models.RefreshTokenRequest{RefreshToken: second.RefreshToken}); err != nil {
+			t.Errorf("Refresh of the other session: %v", err)
+		}
+		// Another user cannot end the session with a stolen refresh token.
+		third := login(t, auth, "ada@example.com")
+		if err := auth.RevokeRefreshToken(uuid.New(), third.RefreshToken); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := auth.Refresh(models.RefreshTokenRequest{RefreshToken: third.RefreshToken}); err != nil {
+			t.Errorf("Refresh after foreign revocation: %v", err)
+		}
+	})
+}
+
+func TestLogoutAllRevokesEverySession(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store storage.Store) {
+		auth := newTestAuth(store)
+		register(t, auth, "ada@example.com")
+		register(t, auth, "bob@example.com")
+		sessions := []*models.LoginResponse{login(t, auth, "ada@example.com"), login(t, auth, "ada@example.com")}
+		bob := parseAccessToken(t, auth, login(t, auth, "bob@example.com").Token)
+		userID := parseAccessToken(t, auth, sessions[0].Token).userID
+
+		if err := auth.RevokeAllSessions(userID); err != nil {
+			t.Fatal(err)
+		}
+		for i, session := range sessions {
+			if !isRevoked(t, auth, parseAccessToken(t, auth, session.Token)) {
+				t.Errorf("session %d: access token is still valid", i)
+			}
+			if _, err := auth.Refresh(models.RefreshTokenRequest{RefreshToken: session.RefreshToken}); !errors.Is(err, ErrRefreshTokenReused) {
+				t.Errorf("session %d: Refresh = %v, want ErrRefreshTokenReused", i, err)
+			}
+		}
+		if isRevoked(t, auth, bob) {
+			t.Error("another user's session was revoked")
+		}
+
+		// Signing in again right away works.
+		fresh := login(t, auth, "ada@example.com")
+		if isRevoked(t, auth, parseAccessToken(t, auth, fresh.Token)) {
+			t.Error("token issued after logout-all is revoked")
+		}
+		if _, err := auth.Refresh(models.RefreshTokenRequest{RefreshToken: fresh.RefreshToken}); err != nil {
+			t.Errorf("Refresh of a new session: %v", err)
+		}
+	})
+}
+
+// TestTokensValidAfterBoundary checks session-wide revocation within the
+// second it happens in. Tokens of this service carry the millisecond they
+// were issued at in their jti, so of two tokens issued in that second the one
+// before the revocation is revoked and the one after is not. Other tokens
+// have whole-second iat claims only, and count as issued at the start of
+// their second.
+func TestTokensValidAfterBoundary(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store storage.Store) {
+		auth := newTestAuth(store)
+		userID := register(t, auth, "ada@example.com")
+
+		var before, after accessToken
+		for {
+			before = parseAccessToken(t, auth, login(t, auth, "ada@example.com").Token)
+			if err := auth.RevokeAllSessions(userID); err != nil {
+				t.Fatal(err)
+			}
+			after = parseAccessToken(t, auth, login(t, auth, "ada@example.com").Token)
+			if before.issuedAt.Equal(after.issuedAt) {
+				break
+			}
+		}
+		if !isRevoked(t, auth, before) {
+			t.Error("token issued just before the revocation, in its second, is valid")
+		}
+		if isRevoked(t, auth, after) {
+			t.Error("token issued just after the revocation, in its second, is revoked")
+		}
+
+		// A jti placing a token after the revocation outside its iat second
+		// is ignored.
+		second := before.issuedAt
+		late := uuid.Must(uuid.NewV7())
+		ms := second.Add(2 * time.Second).UnixMilli()
+		for i := range 6 {
+			late[i] = byte(ms >> (40 - 8*i))
+		}
+		tests := []struct {
+			name    string
+			jti     uuid.UUID
+			issued  time.Time
+			revoked bool
+		}{
+			{"whole-second iat an hour before", uuid.New(), second.Add(-time.Hour), true},
+			{"whole-second iat in the second before", uuid.New(), second.Add(-time.Second), true},
+			{"whole-second iat in the same second", uuid.New(), second, true},
+			{"whole-second iat in the next second", uuid.New(), second.Add(time.Second), false},
+			{"jti outside the iat second", late, second, true},
+		}
+		for _, tt := range tests {
+			token := accessToken{jti: tt.jti, userID: userID, issuedAt: tt.issued}
+			if got := isRevoked(t, auth, token); got != tt.revoked {
+				t.Errorf("%s: revoked = %v, want %v", tt.name, got, tt.revoked)
+			}
+		}
+		// Other users are unaffected at any time.
+		token := accessToken{jti: uuid.New(), userID: uuid.New(), issuedAt: second.Add(-time.Hour)}
+		if isRevoked(t, auth, token) {
+			t.Error("unknown user's token is revoked")
+		}
+	})
+}
+
+func TestRoleRemovalRevocation(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store storage.Store) {
+		f := newFixtureOn(t, store)
+		auth := newTestAuth(store)
+		userID := register(t, auth, "ada@example.com")
+		editor := f.role("editor")
+		reviewer := f.role("reviewer")
+		intern := f.role("intern")
+		f.assign(userID, editor, models.GlobalTenantID)
+		if _, err := f.rbac.CreateGroupMapping(models.CreateGroupRoleMappingRequest{
+			Provider: "idp", Group: "reviewers", RoleID: reviewer.String(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.rbac.SyncGroupRoles(userID, "idp", []string{"reviewers"}); err != nil {
+			t.Fatal(err)
+		}
+
+		session := login(t, auth, "ada@example.com")
+		token := parseAccessToken(t, auth, session.Token)
+
+		// The identity provider dropping a group removes its role without
+		// ending sessions.
+		if err := f.rbac.SyncGroupRoles(userID, "idp", nil); err != nil {
+			t.Fatal(err)
+		}
+		if isRevoked(t, auth, token) {
+			t.Error("group sync removal revoked the session")
+		}
+
+		// Neither does an assignment expiring.
+		until := time.Now().Add(-time.Minute)
+		if err := store.AssignRole(models.RoleAssignment{UserID: userID, RoleID: intern, ValidUntil: &until}); err != nil {
+			t.Fatal(err)
+		}
+		if n, err := f.rbac.SweepExpiredAssignments(); err != nil || n != 1 {
+			t.Fatalf("SweepExpiredAssignments = %d, %v", n, err)
+		}
+		if isRevoked(t, auth, token) {
+			t.Error("assignment expiry revoked the session")
+		}
+
+		// An admin removing a role ends every session.
+		if err := f.rbac.RemoveRole(userID, editor, models.GlobalTenantID); err != nil {
+			t.Fatal(err)
+		}
+		if !isRevoked(t, auth, token) {
+			t.Error("session survived the removal of a role")
+		}
+		if _, err := auth.Refresh(models.RefreshTokenRequest{RefreshToken: session.RefreshToken}); !errors.Is(err, ErrRefreshTokenReused) {
+			t.Errorf("Refresh after role removal = %v, want ErrRefreshTokenReused", err)
+		}
+	})
+}
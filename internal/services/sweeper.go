@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Sweeper periodically runs a cleanup job, such as archiving expired role
+// assignments or purging expired tokens.
+type Sweeper struct {
+	name     string
+	interval time.Duration
+	sweep    func() (int, error)
+}
+
+// NewSweeper creates a sweeper whose sweep function reports how many rows it
+// cleaned up; name describes those rows in log messages.
+func NewSweeper(name string, interval time.Duration, sweep func() (int, error)) *Sweeper {
+	return &Sweeper{
+		name:     name,
+		interval: interval,
+		sweep:    sweep,
+	}
+}
+
+// Run sweeps once immediately and then every interval until ctx is done.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		count, err := s.sweep()
+		if err != nil {
+			log.Printf("Sweeping %s failed: %v", s.name, err)
+		} else if count > 0 {
+			log.Printf("Swept %d %s(s)", count, s.name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,479 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+	"github.com/Anand078/rbac/internal/webauthn"
+)
+
+var (
+	ErrPasskeyNotFound         = storage.ErrPasskeyNotFound
+	ErrPasskeysDisabled        = errors.New("passkeys are not configured")
+	ErrPasswordlessDisabled    = errors.New("passwordless login is disabled; use passkeys as a second factor")
+	ErrPasskeyExists           = errors.New("passkey is already registered")
+	ErrPasskeyServiceAccount   = errors.New("service accounts cannot use passkeys")
+	ErrInvalidPasskeySession   = errors.New("invalid or expired passkey session")
+	ErrInvalidPasskey          = errors.New("passkey verification failed")
+	ErrPasskeyUserNotVerified  = errors.New("passwordless login requires a passkey that verifies the user")
+	ErrPasskeyCounterRegressed = errors.New("passkey signature counter did not increase; the authenticator may be cloned")
+)
+
+const (
+	// passkeyTokenType is the typ claim of passkey sessions, which the
+	// middleware refuses as access tokens.
+	passkeyTokenType = "passkey"
+	passkeyTimeout   = 5 * time.Minute
+
+	passkeyRegistration = "register"
+	passkeyLogin        = "login"
+	passkeySecondFactor = "mfa"
+
+	defaultPasskeyName = "Passkey"
+	methodTOTP         = "totp"
+	methodPasskey      = "passkey"
+)
+
+// PasskeySettings configures WebAuthn.
+type PasskeySettings struct {
+	RelyingParty webauthn.RelyingParty
+	// Passwordless lets passkeys that verify the user (by biometrics or a
+	// PIN) sign in without a password. Passkeys always serve as a second
+	// factor after a password.
+	Passwordless bool
+}
+
+// EnablePasskeys lets users register WebAuthn credentials.
+func (s *AuthService) EnablePasskeys(settings PasskeySettings) {
+	s.passkeys = &settings
+}
+
+// passkeySession is a verified ceremony session. userID is Nil for
+// passwordless logins, whose user is known from the credential only.
+type passkeySession struct {
+	tokenID   uuid.UUID
+	ceremony  string
+	userID    uuid.UUID
+	tenantID  uuid.UUID
+	challenge []byte
+	issuedAt  time.Time
+	expiresAt time.Time
+}
+
+// newPasskeySession returns a signed session holding a new challenge.
+func (s *AuthService) newPasskeySession(ceremony string, userID, tenantID uuid.UUID) (string, []byte, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return "", nil, err
+	}
+	jti, now := newTokenID()
+	claims := jwt.MapClaims{
+		"typ":       passkeyTokenType,
+		"jti":       jti.String(),
+		"ceremony":  ceremony,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"iat":       now.Unix(),
+		"exp":       now.Add(passkeyTimeout).Unix(),
+	}
+	if userID != uuid.Nil {
+		claims["sub"] = userID.String()
+	}
+	if tenantID != models.GlobalTenantID {
+		claims["tenant_id"] = tenantID.String()
+	}
+	token, err := s.keys.Sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, challenge, nil
+}
+
+func (s *AuthService) parsePasskeySession(token, ceremony string) (*passkeySession, error) {
+	parsed, err := jwt.Parse(token, s.keys.Keyfunc, jwt.WithExpirationRequired())
+	if err != nil || !parsed.Valid {
+		return nil, ErrInvalidPasskeySession
+	}
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != passkeyTokenType || claims["ceremony"] != ceremony {
+		return nil, ErrInvalidPasskeySession
+	}
+
+	session := passkeySession{ceremony: ceremony}
+	rawTokenID, _ := claims["jti"].(string)
+	rawChallenge, _ := claims["challenge"].(string)
+	rawTenantID, _ := claims["tenant_id"].(string)
+	if session.tokenID, err = uuid.Parse(rawTokenID); err != nil {
+		return nil, ErrInvalidPasskeySession
+	}
+	if session.challenge, err = base64.RawURLEncoding.DecodeString(rawChallenge); err != nil {
+		return nil, ErrInvalidPasskeySession
+	}
+	if session.tenantID, err = models.ParseTenantID(rawTenantID); err != nil {
+		return nil, ErrInvalidPasskeySession
+	}
+	if rawUserID, ok := claims["sub"].(string); ok {
+		if session.userID, err = uuid.Parse(rawUserID); err != nil {
+			return nil, ErrInvalidPasskeySession
+		}
+	}
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return nil, ErrInvalidPasskeySession
+	}
+	expiresAt, _ := claims.GetExpirationTime()
+	session.issuedAt, session.expiresAt = issuedAt.Time, expiresAt.Time
+	return &session, nil
+}
+
+// endPasskeySession uses up the session of a successful ceremony of userID.
+// Sessions are revoked like access tokens, and so are those of users whose
+// sessions were all revoked since. Of concurrent requests with one session,
+// only the one that revokes it succeeds.
+func (s *AuthService) endPasskeySession(session *passkeySession, userID uuid.UUID) error {
+	revoked, err := s.IsTokenRevoked(session.tokenID, userID, session.issuedAt)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrInvalidPasskeySession
+	}
+	consumed, err := s.store.RevokeAccessToken(session.tokenID, userID, session.expiresAt)
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidPasskeySession
+	}
+	return nil
+}
+
+// Registration
+
+// BeginPasskeyRegistration starts registering a passkey for the user.
+func (s *AuthService) BeginPasskeyRegistration(userID uuid.UUID) (*models.PasskeyRegistrationOptions, error) {
+	if s.passkeys == nil {
+		return nil, ErrPasskeysDisabled
+	}
+	user, err := s.store.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(user.Email, "@"+models.ServiceAccountDomain) {
+		return nil, ErrPasskeyServiceAccount
+	}
+	creds, err := s.store.GetWebAuthnCredentials(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	session, challenge, err := s.newPasskeySession(passkeyRegistration, userID, models.GlobalTenantID)
+	if err != nil {
+		return nil, err
+	}
+	rp := s.passkeys.RelyingParty
+	options := models.PublicKeyCredentialCreationOptions{
+		Challenge: base64.RawURLEncoding.EncodeToString(challenge),
+		RP:        models.PublicKeyCredentialRPEntity{ID: rp.ID, Name: rp.Name},
+		User: models.PublicKeyCredentialUserEntity{
+			ID:          base64.RawURLEncoding.EncodeToString(userID[:]),
+			Name:        user.Email,
+			DisplayName: user.Name,
+		},
+		Timeout: passkeyTimeout.Milliseconds(),
+		// Discoverable credentials allow passwordless login without
+		// entering an email first.
+		AuthenticatorSelection: models.AuthenticatorSelectionCriteria{
+			ResidentKey:      "preferred",
+			UserVerification: "preferred",
+		},
+		Attestation:        "none",
+		ExcludeCredentials: passkeyDescriptors(creds),
+	}
+	for _, alg := range webauthn.Algorithms {
+		options.PubKeyCredParams = append(options.PubKeyCredParams,
+			models.PublicKeyCredentialParameters{Type: "public-key", Alg: alg})
+	}
+	return &models.PasskeyRegistrationOptions{Session: session, PublicKey: options}, nil
+}
+
+// FinishPasskeyRegistration verifies the new credential and stores it.
+func (s *AuthService) FinishPasskeyRegistration(userID uuid.UUID, req models.PasskeyRegistrationRequest) (*models.WebAuthnCredential, error) {
+	if s.passkeys == nil {
+		return nil, ErrPasskeysDisabled
+	}
+	session, err := s.parsePasskeySession(req.Session, passkeyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if session.userID != userID {
+		return nil, ErrInvalidPasskeySession
+	}
+
+	clientDataJSON, err1 := decodeBase64URL(req.Credential.Response.ClientDataJSON)
+	attestationObject, err2 := decodeBase64URL(req.Credential.Response.AttestationObject)
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("%w: malformed credential", ErrInvalidPasskey)
+	}
+	authData, err := s.passkeys.RelyingParty.VerifyRegistration(session.challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPasskey, err)
+	}
+	credentialID := base64.RawURLEncoding.EncodeToString(authData.CredentialID)
+	if strings.TrimRight(req.Credential.ID, "=") != credentialID {
+		return nil, fmt.Errorf("%w: credential ID mismatch", ErrInvalidPasskey)
+	}
+	if err := s.endPasskeySession(session, userID); err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = defaultPasskeyName
+	}
+	cred := &models.WebAuthnCredential{
+		ID:             credentialID,
+		UserID:         userID,
+		Name:           name,
+		PublicKey:      authData.PublicKey,
+		SignCount:      authData.SignCount,
+		Transports:     req.Credential.Response.Transports,
+		BackupEligible: authData.BackupEligible(),
+	}
+	if err := s.store.CreateWebAuthnCredential(cred); err != nil {
+		if errors.Is(err, storage.ErrDuplicate) {
+			return nil, ErrPasskeyExists
+		}
+		return nil, err
+	}
+	return cred, nil
+}
+
+func (s *AuthService) GetPasskeys(userID uuid.UUID) ([]models.WebAuthnCredential, error) {
+	return s.store.GetWebAuthnCredentials(userID)
+}
+
+func (s *AuthService) DeletePasskey(userID uuid.UUID, credentialID string) error {
+	return s.store.DeleteWebAuthnCredential(userID, credentialID)
+}
+
+// Passwordless Login
+
+// BeginPasskeyLogin starts a passwordless login with any discoverable
+// passkey of the relying party, so no email is needed.
+func (s *AuthService) BeginPasskeyLogin(req models.PasskeyLoginRequest) (*models.PasskeyLoginOptions, error) {
+	if s.passkeys == nil {
+		return nil, ErrPasskeysDisabled
+	}
+	if !s.passkeys.Passwordless {
+		return nil, ErrPasswordlessDisabled
+	}
+	tenantID, err := models.ParseTenantID(req.TenantID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tenant ID: %w", err)
+	}
+
+	session, challenge, err := s.newPasskeySession(passkeyLogin, uuid.Nil, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.PasskeyLoginOptions{
+		Session:   session,
+		PublicKey: s.requestOptions(challenge, nil, "required"),
+	}, nil
+}
+
+// FinishPasskeyLogin verifies the assertion and signs its user in. A passkey
+// that verified the user is a second factor in itself, so no MFA challenge
+// follows.
+func (s *AuthService) FinishPasskeyLogin(req models.PasskeyAssertionRequest) (*models.LoginResponse, error) {
+	if s.passkeys == nil {
+		return nil, ErrPasskeysDisabled
+	}
+	if !s.passkeys.Passwordless {
+		return nil, ErrPasswordlessDisabled
+	}
+	session, err := s.parsePasskeySession(req.Session, passkeyLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	cred, err := s.lookupPasskey(req.Credential)
+	if err != nil {
+		return nil, err
+	}
+	// The user handle names the account a discoverable credential belongs
+	// to.
+	userHandle, err := decodeBase64URL(req.Credential.Response.UserHandle)
+	if err != nil || !bytes.Equal(userHandle, cred.UserID[:]) {
+		return nil, fmt.Errorf("%w: user handle mismatch", ErrInvalidPasskey)
+	}
+
+	authData, err := s.verifyPasskeyAssertion(session, cred, req.Credential)
+	if err != nil {
+		return nil, err
+	}
+	if !authData.UserVerified() {
+		return nil, ErrPasskeyUserNotVerified
+	}
+	if err := s.usePasskey(session, cred, authData); err != nil {
+		return nil, err
+	}
+
+	user, err := s.store.GetUserByID(cred.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, ErrInvalidPasskey
+		}
+		return nil, err
+	}
+	return s.issueTokens(user, session.tenantID, uuid.New())
+}
+
+// Second Factor
+
+// BeginPasskeyMFA starts completing a password login with one of the user's
+// passkeys.
+func (s *AuthService) BeginPasskeyMFA(mfaToken string) (*models.PasskeyLoginOptions, error) {
+	if s.passkeys == nil {
+		return nil, ErrPasskeysDisabled
+	}
+	challenge, err := s.parseMFAChallenge(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.enroll {
+		return nil, ErrMFAEnrollmentRequired
+	}
+	creds, err := s.store.GetWebAuthnCredentials(challenge.userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(creds) == 0 {
+		return nil, ErrPasskeyNotFound
+	}
+
+	session, sessionChallenge, err := s.newPasskeySession(passkeySecondFactor, challenge.userID, challenge.tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.PasskeyLoginOptions{
+		Session:   session,
+		PublicKey: s.requestOptions(sessionChallenge, creds, "discouraged"),
+	}, nil
+}
+
+// FinishPasskeyMFA verifies the assertion and completes the password login.
+func (s *AuthService) FinishPasskeyMFA(req models.MFAPasskeyRequest) (*models.LoginResponse, error) {
+	if s.passkeys == nil {
+		return nil, ErrPasskeysDisabled
+	}
+	challenge, err := s.parseMFAChallenge(req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.enroll {
+		return nil, ErrMFAEnrollmentRequired
+	}
+	session, err := s.parsePasskeySession(req.Session, passkeySecondFactor)
+	if err != nil {
+		return nil, err
+	}
+	if session.userID != challenge.userID {
+		return nil, ErrInvalidPasskeySession
+	}
+
+	cred, err := s.lookupPasskey(req.Credential)
+	if err != nil {
+		return nil, err
+	}
+	if cred.UserID != challenge.userID {
+		return nil, ErrInvalidPasskey
+	}
+	authData, err := s.verifyPasskeyAssertion(session, cred, req.Credential)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.usePasskey(session, cred, authData); err != nil {
+		return nil, err
+	}
+	return s.completeMFALogin(challenge)
+}
+
+func (s *AuthService) requestOptions(challenge []byte, creds []models.WebAuthnCredential, userVerification string) models.PublicKeyCredentialRequestOptions {
+	return models.PublicKeyCredentialRequestOptions{
+		Challenge:        base64.RawURLEncoding.EncodeToString(challenge),
+		Timeout:          passkeyTimeout.Milliseconds(),
+		RPID:             s.passkeys.RelyingParty.ID,
+		AllowCredentials: passkeyDescriptors(creds),
+		UserVerification: userVerification,
+	}
+}
+
+func passkeyDescriptors(creds []models.WebAuthnCredential) []models.PublicKeyCredentialDescriptor {
+	descriptors := []models.PublicKeyCredentialDescriptor{}
+	for _, cred := range creds {
+		descriptors = append(descriptors, models.PublicKeyCredentialDescriptor{
+			Type:       "public-key",
+			ID:         cred.ID,
+			Transports: cred.Transports,
+		})
+	}
+	return descriptors
+}
+
+func (s *AuthService) lookupPasskey(credential models.AssertionCredential) (*models.WebAuthnCredential, error) {
+	cred, err := s.store.GetWebAuthnCredential(strings.TrimRight(credential.ID, "="))
+	if err != nil {
+		if errors.Is(err, storage.ErrPasskeyNotFound) {
+			return nil, fmt.Errorf("%w: unknown credential", ErrInvalidPasskey)
+		}
+		return nil, err
+	}
+	return cred, nil
+}
+
+func (s *AuthService) verifyPasskeyAssertion(session *passkeySession, cred *models.WebAuthnCredential, credential models.AssertionCredential) (*webauthn.AuthenticatorData, error) {
+	clientDataJSON, err1 := decodeBase64URL(credential.Response.ClientDataJSON)
+	authenticatorData, err2 := decodeBase64URL(credential.Response.AuthenticatorData)
+	signature, err3 := decodeBase64URL(credential.Response.Signature)
+	if err := errors.Join(err1, err2, err3); err != nil {
+		return nil, fmt.Errorf("%w: malformed credential", ErrInvalidPasskey)
+	}
+	authData, err := s.passkeys.RelyingParty.VerifyAssertion(session.challenge, cred.PublicKey,
+		clientDataJSON, authenticatorData, signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPasskey, err)
+	}
+	return authData, nil
+}
+
+// usePasskey ends the session and records the assertion, refusing a
+// signature counter that went backwards.
+func (s *AuthService) usePasskey(session *passkeySession, cred *models.WebAuthnCredential, authData *webauthn.AuthenticatorData) error {
+	if err := s.endPasskeySession(session, cred.UserID); err != nil {
+		return err
+	}
+	ok, err := s.store.UseWebAuthnCredential(cred.ID, authData.SignCount, time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrPasskeyCounterRegressed
+	}
+	return nil
+}
+
+// decodeBase64URL accepts base64url with or without padding, as clients
+// differ.
+func decodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
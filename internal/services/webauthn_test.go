@@ -0,0 +1,342 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+	"github.com/Anand078/rbac/internal/storage/memory"
+	"github.com/Anand078/rbac/internal/webauthn"
+	"github.com/Anand078/rbac/internal/webauthn/webauthntest"
+)
+
+var testRelyingParty = webauthn.RelyingParty{ID: "example.com", Name: "Example", Origins: []string{"https://app.example.com"}}
+
+func newPasskeyAuth(store storage.Store) *AuthService {
+	auth := newTestAuth(store)
+	auth.EnablePasskeys(PasskeySettings{RelyingParty: testRelyingParty, Passwordless: true})
+	return auth
+}
+
+func decodeChallenge(t *testing.T, challenge string) []byte {
+	t.Helper()
+	decoded, err := base64.RawURLEncoding.DecodeString(challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+// registration answers the registration options of a session with the
+// credential of a.
+func registration(a *webauthntest.Authenticator, session string, challenge []byte) models.PasskeyRegistrationRequest {
+	clientDataJSON, attestationObject := a.Create(challenge)
+	return models.PasskeyRegistrationRequest{
+		Session: session,
+		Credential: models.RegistrationCredential{
+			ID:   base64.RawURLEncoding.EncodeToString(a.CredentialID),
+			Type: "public-key",
+			Response: models.AuthenticatorAttestationResponse{
+				ClientDataJSON:    base64.RawURLEncoding.EncodeToString(clientDataJSON),
+				AttestationObject: base64.RawURLEncoding.EncodeToString(attestationObject),
+			},
+		},
+	}
+}
+
+// assertion answers a login challenge with the credential of a, which
+// belongs to userID.
+func assertion(a *webauthntest.Authenticator, userID uuid.UUID, challenge []byte) models.AssertionCredential {
+	clientDataJSON, authenticatorData, signature := a.Get(challenge)
+	return models.AssertionCredential{
+		ID:   base64.RawURLEncoding.EncodeToString(a.CredentialID),
+		Type: "public-key",
+		Response: models.AuthenticatorAssertionResponse{
+			ClientDataJSON:    base64.RawURLEncoding.EncodeToString(clientDataJSON),
+			AuthenticatorData: base64.RawURLEncoding.EncodeToString(authenticatorData),
+			Signature:         base64.RawURLEncoding.EncodeToString(signature),
+			UserHandle:        base64.RawURLEncoding.EncodeToString(userID[:]),
+		},
+	}
+}
+
+func registerPasskey(t *testing.T, auth *AuthService, userID uuid.UUID, a *webauthntest.Authenticator) *models.WebAuthnCredential {
+	t.Helper()
+	options, err := auth.BeginPasskeyRegistration(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := auth.FinishPasskeyRegistration(userID, registration(a, options.Session, decodeChallenge(t, options.PublicKey.Challenge)))
+	if err != nil {
+		t.Fatalf("FinishPasskeyRegistration = %v", err)
+	}
+	return cred
+}
+
+// signInWithPasskey signs in with the passkey of a alone.
+func signInWithPasskey(t *testing.T, auth *AuthService, userID uuid.UUID, a *webauthntest.Authenticator) (*models.LoginResponse, error) {
+	t.Helper()
+	options, err := auth.BeginPasskeyLogin(models.PasskeyLoginRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth.FinishPasskeyLogin(models.PasskeyAssertionRequest{
+		Session:    options.Session,
+		Credential: assertion(a, userID, decodeChallenge(t, options.PublicKey.Challenge)),
+	})
+}
+
+func TestPasskeyCeremonies(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store storage.Store) {
+		auth := newPasskeyAuth(store)
+		for _, alg := range webauthn.Algorithms {
+			email := uuid.NewString() + "@example.com"
+			userID := register(t, auth, email)
+			a := webauthntest.New(t, alg, testRelyingParty)
+
+			cred := registerPasskey(t, auth, userID, a)
+			stored, err := store.GetWebAuthnCredential(cred.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.UserID != userID || !bytes.Equal(stored.PublicKey, a.PublicKey()) || stored.SignCount != 0 {
+				t.Errorf("alg %d: stored credential = %+v", alg, stored)
+			}
+
+			response, err := signInWithPasskey(t, auth, userID, a)
+			if err != nil {
+				t.Fatalf("alg %d: FinishPasskeyLogin = %v", alg, err)
+			}
+			if parseAccessToken(t, auth, response.Token).userID != userID {
+				t.Errorf("alg %d: passkey login signed in another user", alg)
+			}
+
+			// As a second factor the passkey need not verify the user.
+			a.UserVerified = false
+			token := mfaToken(t, auth, email)
+			options, err := auth.BeginPasskeyMFA(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response, err = auth.FinishPasskeyMFA(models.MFAPasskeyRequest{
+				MFAToken:   token,
+				Session:    options.Session,
+				Credential: assertion(a, userID, decodeChallenge(t, options.PublicKey.Challenge)),
+			})
+			if err != nil {
+				t.Fatalf("alg %d: FinishPasskeyMFA = %v", alg, err)
+			}
+			if parseAccessToken(t, auth, response.Token).userID != userID {
+				t.Errorf("alg %d: passkey MFA signed in another user", alg)
+			}
+			if stored, err := store.GetWebAuthnCredential(cred.ID); err != nil || stored.SignCount != 2 {
+				t.Errorf("alg %d: stored credential = %+v, %v; want sign count 2", alg, stored, err)
+			}
+		}
+	})
+}
+
+func TestPasskeyRegistrationRejects(t *testing.T) {
+	auth := newPasskeyAuth(memory.New())
+	userID := register(t, auth, "ada@example.com")
+
+	tests := []struct {
+		name   string
+		tamper func(a *webauthntest.Authenticator, req *models.PasskeyRegistrationRequest, challenge []byte)
+		want   error
+	}{
+		{"wrong challenge", func(a *webauthntest.Authenticator, req *models.PasskeyRegistrationRequest, challenge []byte) {
+			*req = registration(a, req.Session, append([]byte{challenge[0] ^ 1}, challenge[1:]...))
+		}, ErrInvalidPasskey},
+		{"wrong origin", func(a *webauthntest.Authenticator, req *models.PasskeyRegistrationRequest, challenge []byte) {
+			a.Origin = "https://evil.test"
+			*req = registration(a, req.Session, challenge)
+		}, ErrInvalidPasskey},
+		{"wrong RP ID hash", func(a *webauthntest.Authenticator, req *models.PasskeyRegistrationRequest, challenge []byte) {
+			a.RPID = "evil.test"
+			*req = registration(a, req.Session, challenge)
+		}, ErrInvalidPasskey},
+		{"credential ID mismatch", func(a *webauthntest.Authenticator, req *models.PasskeyRegistrationRequest, challenge []byte) {
+			req.Credential.ID = base64.RawURLEncoding.EncodeToString([]byte("another credential"))
+		}, ErrInvalidPasskey},
+		{"truncated attestation object", func(a *webauthntest.Authenticator, req *models.PasskeyRegistrationRequest, challenge []byte) {
+			req.Credential.Response.AttestationObject = req.Credential.Response.AttestationObject[:40]
+		}, ErrInvalidPasskey},
+		{"deeply nested attestation object", func(a *webauthntest.Authenticator, req *models.PasskeyRegistrationRequest, challenge []byte) {
+			nested := append(bytes.Repeat([]byte{0xa1, 0x00}, 100000), 0x00)
+			req.Credential.Response.AttestationObject = base64.RawURLEncoding.EncodeToString(nested)
+		}, ErrInvalidPasskey},
+		{"session of a login", func(a *webauthntest.Authenticator, req *models.PasskeyRegistrationRequest, challenge []byte) {
+			options, err := auth.BeginPasskeyLogin(models.PasskeyLoginRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			*req = registration(a, options.Session, decodeChallenge(t, options.PublicKey.Challenge))
+		}, ErrInvalidPasskeySession},
+	}
+	for _, tt := range tests {
+		a := webauthntest.New(t, webauthn.AlgES256, testRelyingParty)
+		options, err := auth.BeginPasskeyRegistration(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		challenge := decodeChallenge(t, options.PublicKey.Challenge)
+		req := registration(a, options.Session, challenge)
+		tt.tamper(a, &req, challenge)
+		if cred, err := auth.FinishPasskeyRegistration(userID, req); !errors.Is(err, tt.want) {
+			t.Errorf("%s: FinishPasskeyRegistration = %+v, %v; want %v", tt.name, cred, err, tt.want)
+		}
+	}
+
+	// A session registers one credential, for the user it was started for.
+	options, err := auth.BeginPasskeyRegistration(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge := decodeChallenge(t, options.PublicKey.Challenge)
+	other := register(t, auth, "grace@example.com")
+	if _, err := auth.FinishPasskeyRegistration(other, registration(webauthntest.New(t, webauthn.AlgES256, testRelyingParty), options.Session, challenge)); !errors.Is(err, ErrInvalidPasskeySession) {
+		t.Errorf("session of another user: FinishPasskeyRegistration = %v, want ErrInvalidPasskeySession", err)
+	}
+	if _, err := auth.FinishPasskeyRegistration(userID, registration(webauthntest.New(t, webauthn.AlgES256, testRelyingParty), options.Session, challenge)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.FinishPasskeyRegistration(userID, registration(webauthntest.New(t, webauthn.AlgES256, testRelyingParty), options.Session, challenge)); !errors.Is(err, ErrInvalidPasskeySession) {
+		t.Errorf("replayed session: FinishPasskeyRegistration = %v, want ErrInvalidPasskeySession", err)
+	}
+}
+
+func TestPasskeyLoginRejects(t *testing.T) {
+	f := newFixture(t)
+	auth := newPasskeyAuth(f.store)
+	userID := register(t, auth, "ada@example.com")
+	authenticator := webauthntest.New(t, webauthn.AlgES256, testRelyingParty)
+	cred := registerPasskey(t, auth, userID, authenticator)
+	if _, err := signInWithPasskey(t, auth, userID, authenticator); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		answer func(a *webauthntest.Authenticator, challenge []byte) models.AssertionCredential
+		want   error
+	}{
+		{"wrong challenge", func(a *webauthntest.Authenticator, challenge []byte) models.AssertionCredential {
+			return assertion(a, userID, append([]byte{challenge[0] ^ 1}, challenge[1:]...))
+		}, ErrInvalidPasskey},
+		{"wrong origin", func(a *webauthntest.Authenticator, challenge []byte) models.AssertionCredential {
+			a.Origin = "https://evil.test"
+			return assertion(a, userID, challenge)
+		}, ErrInvalidPasskey},
+		{"wrong RP ID hash", func(a *webauthntest.Authenticator, challenge []byte) models.AssertionCredential {
+			a.RPID = "evil.test"
+			return assertion(a, userID, challenge)
+		}, ErrInvalidPasskey},
+		{"user not verified", func(a *webauthntest.Authenticator, challenge []byte) models.AssertionCredential {
+			a.UserVerified = false
+			return assertion(a, userID, challenge)
+		}, ErrPasskeyUserNotVerified},
+		{"signature counter regressed", func(a *webauthntest.Authenticator, challenge []byte) models.AssertionCredential {
+			a.SignCount = 0
+			return assertion(a, userID, challenge)
+		}, ErrPasskeyCounterRegressed},
+		{"user handle of another user", func(a *webauthntest.Authenticator, challenge []byte) models.AssertionCredential {
+			return assertion(a, uuid.New(), challenge)
+		}, ErrInvalidPasskey},
+		{"unknown credential", func(a *webauthntest.Authenticator, challenge []byte) models.AssertionCredential {
+			return assertion(webauthntest.New(t, webauthn.AlgES256, testRelyingParty), userID, challenge)
+		}, ErrInvalidPasskey},
+		{"truncated authenticator data", func(a *webauthntest.Authenticator, challenge []byte) models.AssertionCredential {
+			credential := assertion(a, userID, challenge)
+			credential.Response.AuthenticatorData = credential.Response.AuthenticatorData[:40]
+			return credential
+		}, ErrInvalidPasskey},
+	}
+	for _, tt := range tests {
+		a := *authenticator
+		options, err := auth.BeginPasskeyLogin(models.PasskeyLoginRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := auth.FinishPasskeyLogin(models.PasskeyAssertionRequest{
+			Session:    options.Session,
+			Credential: tt.answer(&a, decodeChallenge(t, options.PublicKey.Challenge)),
+		})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: FinishPasskeyLogin = %+v, %v; want %v", tt.name, response, err, tt.want)
+		}
+	}
+	if stored, err := f.store.GetWebAuthnCredential(cred.ID); err != nil || stored.SignCount != 1 {
+		t.Errorf("refused assertions were recorded: %+v, %v", stored, err)
+	}
+
+	// A session signs in once, even with a fresh assertion.
+	options, err := auth.BeginPasskeyLogin(models.PasskeyLoginRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge := decodeChallenge(t, options.PublicKey.Challenge)
+	for i, want := range []error{nil, ErrInvalidPasskeySession} {
+		request := models.PasskeyAssertionRequest{Session: options.Session, Credential: assertion(authenticator, userID, challenge)}
+		if _, err := auth.FinishPasskeyLogin(request); !errors.Is(err, want) {
+			t.Errorf("use %d of a session: FinishPasskeyLogin = %v, want %v", i+1, err, want)
+		}
+	}
+
+	// Passwordless login can be turned off, leaving passkeys as a second
+	// factor.
+	auth.EnablePasskeys(PasskeySettings{RelyingParty: testRelyingParty})
+	if _, err := auth.BeginPasskeyLogin(models.PasskeyLoginRequest{}); !errors.Is(err, ErrPasswordlessDisabled) {
+		t.Errorf("passwordless disabled: BeginPasskeyLogin = %v, want ErrPasswordlessDisabled", err)
+	}
+}
+
+// raceStore runs afterRevocationCheck, once, when IsTokenRevoked next
+// returns, to interleave another request between the check and the revoke.
+type raceStore struct {
+	storage.Store
+	afterRevocationCheck func()
+}
+
+func (s *raceStore) IsTokenRevoked(jti, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	revoked, err := s.Store.IsTokenRevoked(jti, userID, issuedAt)
+	if hook := s.afterRevocationCheck; hook != nil {
+		s.afterRevocationCheck = nil
+		hook()
+	}
+	return revoked, err
+}
+
+func TestPasskeySessionRace(t *testing.T) {
+	store := &raceStore{Store: memory.New()}
+	auth := newPasskeyAuth(store)
+	userID := register(t, auth, "ada@example.com")
+	laptop := webauthntest.New(t, webauthn.AlgES256, testRelyingParty)
+	phone := webauthntest.New(t, webauthn.AlgEdDSA, testRelyingParty)
+	registerPasskey(t, auth, userID, laptop)
+	registerPasskey(t, auth, userID, phone)
+
+	// Two passkeys answer one session; the second request completes while
+	// the first is between checking and revoking the session.
+	options, err := auth.BeginPasskeyLogin(models.PasskeyLoginRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge := decodeChallenge(t, options.PublicKey.Challenge)
+	store.afterRevocationCheck = func() {
+		request := models.PasskeyAssertionRequest{Session: options.Session, Credential: assertion(phone, userID, challenge)}
+		if _, err := auth.FinishPasskeyLogin(request); err != nil {
+			t.Errorf("racing FinishPasskeyLogin = %v", err)
+		}
+	}
+	request := models.PasskeyAssertionRequest{Session: options.Session, Credential: assertion(laptop, userID, challenge)}
+	if _, err := auth.FinishPasskeyLogin(request); !errors.Is(err, ErrInvalidPasskeySession) {
+		t.Errorf("FinishPasskeyLogin losing the race = %v, want ErrInvalidPasskeySession", err)
+	}
+}
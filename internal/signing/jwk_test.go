@@ -0,0 +1,151 @@
+package signing
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestJWKS(t *testing.T) {
+	want := map[string]struct{ kty, crv string }{
+		AlgRS256: {"RSA", ""},
+		AlgES256: {"EC", "P-256"},
+		AlgEdDSA: {"OKP", "Ed25519"},
+	}
+	for _, alg := range asymmetric {
+		m := newManager(t, alg, time.Hour)
+		old := kidOf(t, sign(t, m))
+		if err := m.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		token := sign(t, m)
+		current := kidOf(t, token)
+
+		keys := m.JWKS().Keys
+		var kids []string
+		for _, jwk := range keys {
+			kids = append(kids, jwk.Kid)
+			if jwk.Kty != want[alg].kty || jwk.Crv != want[alg].crv || jwk.Alg != alg || jwk.Use != "sig" {
+				t.Errorf("%s: JWKS published %+v", alg, jwk)
+			}
+			if jwk.Thumbprint() != jwk.Kid {
+				t.Errorf("%s: kid %s is not the thumbprint %s", alg, jwk.Kid, jwk.Thumbprint())
+			}
+
+			// Only the public members of the key are published.
+			data, err := json.Marshal(jwk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var members map[string]any
+			if err := json.Unmarshal(data, &members); err != nil {
+				t.Fatal(err)
+			}
+			for _, private := range []string{"d", "p", "q", "dp", "dq", "qi", "oth", "k"} {
+				if _, ok := members[private]; ok {
+					t.Errorf("%s: JWKS published private member %q", alg, private)
+				}
+			}
+		}
+		sort.Strings(kids)
+		wantKids := []string{old, current}
+		sort.Strings(wantKids)
+		if !reflect.DeepEqual(kids, wantKids) {
+			t.Errorf("%s: JWKS kids = %v, want %v", alg, kids, wantKids)
+		}
+
+		// A verifier holding only the published key accepts the token.
+		for _, jwk := range keys {
+			if jwk.Kid != current {
+				continue
+			}
+			publicKey, err := jwk.PublicKey()
+			if err != nil {
+				t.Fatalf("%s: PublicKey: %v", alg, err)
+			}
+			if _, err := jwt.Parse(token, func(*jwt.Token) (any, error) { return publicKey, nil }, jwt.WithValidMethods([]string{alg})); err != nil {
+				t.Errorf("%s: token verified with the published key: %v", alg, err)
+			}
+		}
+
+		// Keys past their grace period are no longer published.
+		expire(m, old)
+		keys = m.JWKS().Keys
+		if len(keys) != 1 || keys[0].Kid != current {
+			t.Errorf("%s: JWKS after the grace period = %+v, want only %s", alg, keys, current)
+		}
+	}
+}
+
+func TestThumbprint(t *testing.T) {
+	// The example of RFC 7638, section 3.1.
+	jwk := JWK{
+		Kty: "RSA",
+		N:   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:   "AQAB",
+		Alg: AlgRS256,
+		Kid: "2011-04-29",
+	}
+	if got, want := jwk.Thumbprint(), "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; got != want {
+		t.Errorf("Thumbprint = %s, want %s", got, want)
+	}
+}
+
+func TestParsePEM(t *testing.T) {
+	for _, alg := range asymmetric {
+		key, err := GenerateKey(alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := ParsePEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+		if err != nil {
+			t.Fatalf("%s: ParsePEM: %v", alg, err)
+		}
+		// Every instance loading the same file derives the same kid.
+		if parsed.ID != key.ID || parsed.Algorithm != alg {
+			t.Errorf("%s: ParsePEM = %s %s, want %s %s", alg, parsed.ID, parsed.Algorithm, key.ID, alg)
+		}
+	}
+
+	weakRSA, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384DER, err := x509.MarshalECPrivateKey(p384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"no PEM block", []byte("not a key")},
+		{"public key", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte{0}})},
+		{"1024-bit RSA", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(weakRSA)})},
+		{"P-384", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: p384DER})},
+	}
+	for _, tt := range tests {
+		if _, err := ParsePEM(tt.data); !errors.Is(err, ErrUnsupportedKey) {
+			t.Errorf("%s: ParsePEM = %v, want ErrUnsupportedKey", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Package signing manages the keys used to sign and verify the JWTs issued by
+// AuthService. It supports a shared HMAC secret (HS256) or asymmetric keys
+// (RS256, ES256, EdDSA) identified by a kid header, with rotation and a grace
+// window during which retired keys still verify tokens.
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+var ErrUnsupportedKey = errors.New("unsupported signing key")
+
+// Key is an asymmetric signing key.
+type Key struct {
+	ID         string
+	Algorithm  string
+	PrivateKey crypto.Signer
+	CreatedAt  time.Time
+	// RetiredAt is set once the key stops signing new tokens. It keeps
+	// verifying tokens until RetiredAt plus the manager's grace period.
+	RetiredAt *time.Time
+}
+
+func (k *Key) PublicKey() crypto.PublicKey {
+	return k.PrivateKey.Public()
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// NewKey wraps a private key, inferring its algorithm from its type and
+// deriving its kid from the RFC 7638 thumbprint of the public key.
+func NewKey(privateKey crypto.Signer) (*Key, error) {
+	var alg string
+	switch k := privateKey.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("%w: RSA keys must be at least 2048 bits", ErrUnsupportedKey)
+		}
+		alg = AlgRS256
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%w: ECDSA keys must use P-256", ErrUnsupportedKey)
+		}
+		alg = AlgES256
+	case ed25519.PrivateKey:
+		alg = AlgEdDSA
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, privateKey)
+	}
+
+	jwk, err := publicJWK(privateKey.Public(), alg, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{
+		ID:         jwk.Thumbprint(),
+		Algorithm:  alg,
+		PrivateKey: privateKey,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// GenerateKey creates a fresh key for alg.
+func GenerateKey(alg string) (*Key, error) {
+	var (
+		privateKey crypto.Signer
+		err        error
+	)
+	switch alg {
+	case AlgRS256:
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgES256:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("%w: cannot generate %s keys", ErrUnsupportedKey, alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %w", alg, err)
+	}
+	return NewKey(privateKey)
+}
+
+// ParsePEM parses a PKCS#8, PKCS#1 (RSA) or SEC 1 (EC) private key.
+func ParsePEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM block found", ErrUnsupportedKey)
+	}
+
+	var (
+		parsed any
+		err    error
+	)
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: PEM block type %q", ErrUnsupportedKey, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, parsed)
+	}
+	return NewKey(signer)
+}
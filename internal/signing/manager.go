@@ -0,0 +1,231 @@
+package signing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnknownKey = errors.New("unknown or expired signing key")
+
+// KeyManager signs tokens with the active key and verifies tokens signed by
+// any key it still trusts.
+type KeyManager struct {
+	mu        sync.RWMutex
+	algorithm string
+	secret    []byte
+	active    *Key
+	keys      map[string]*Key
+	grace     time.Duration
+}
+
+// NewHMACKeyManager signs and verifies with a shared HS256 secret. It
+// publishes no public keys.
+func NewHMACKeyManager(secret string) *KeyManager {
+	return &KeyManager{
+		algorithm: AlgHS256,
+		secret:    []byte(secret),
+		keys:      map[string]*Key{},
+	}
+}
+
+// NewKeyManager uses asymmetric keys of the given algorithm. The last key in
+// keys signs new tokens; the others only verify. When keys is empty a fresh
+// key is generated. Retired keys keep verifying for grace after retirement.
+func NewKeyManager(algorithm string, keys []*Key, grace time.Duration) (*KeyManager, error) {
+	m := &KeyManager{
+		algorithm: algorithm,
+		keys:      map[string]*Key{},
+		grace:     grace,
+	}
+
+	if len(keys) == 0 {
+		key, err := GenerateKey(algorithm)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("No signing keys configured; generated ephemeral %s key %s", algorithm, key.ID)
+		keys = []*Key{key}
+	}
+	if err := m.SetKeys(keys); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *KeyManager) Algorithm() string {
+	return m.algorithm
+}
+
+// SetKeys replaces the trusted key set, for example after re-reading key
+// files. The last key becomes active. Keys that disappear from the set are
+// retired rather than dropped so tokens they signed stay valid for the grace
+// period.
+func (m *KeyManager) SetKeys(keys []*Key) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("%w: empty key set", ErrUnsupportedKey)
+	}
+	for _, key := range keys {
+		if key.Algorithm != m.algorithm {
+			return fmt.Errorf("%w: key %s is %s, expected %s", ErrUnsupportedKey, key.ID, key.Algorithm, m.algorithm)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	present := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		present[key.ID] = true
+		if existing, ok := m.keys[key.ID]; ok {
+			existing.RetiredAt = nil
+			continue
+		}
+		m.keys[key.ID] = key
+	}
+	for id, key := range m.keys {
+		if !present[id] && key.RetiredAt == nil {
+			key.RetiredAt = &now
+		}
+	}
+
+	newActive := m.keys[keys[len(keys)-1].ID]
+	if m.active != nil && m.active.ID != newActive.ID {
+		log.Printf("Signing key rotated from %s to %s", m.active.ID, newActive.ID)
+	}
+	m.active = newActive
+	m.pruneLocked(now)
+	return nil
+}
+
+// Rotate generates a new active key and retires the current one. Generated
+// keys live in memory only, so this suits single-instance deployments;
+// replicas should share key files and rotate through SetKeys instead.
+func (m *KeyManager) Rotate() error {
+	if m.algorithm == AlgHS256 {
+		return fmt.Errorf("%w: HS256 secrets cannot be rotated automatically", ErrUnsupportedKey)
+	}
+
+	key, err := GenerateKey(m.algorithm)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if m.active != nil {
+		m.active.RetiredAt = &now
+		log.Printf("Signing key rotated from %s to %s", m.active.ID, key.ID)
+	}
+	m.keys[key.ID] = key
+	m.active = key
+	m.pruneLocked(now)
+	return nil
+}
+
+// RunRotation rotates keys every interval until ctx is done. With a reload
+// function the key set is re-read (e.g. from PEM files) instead of
+// generating a new key.
+func (m *KeyManager) RunRotation(ctx context.Context, interval time.Duration, reload func() ([]*Key, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var err error
+		if reload != nil {
+			var keys []*Key
+			if keys, err = reload(); err == nil {
+				err = m.SetKeys(keys)
+			}
+		} else {
+			err = m.Rotate()
+		}
+		if err != nil {
+			log.Printf("Signing key rotation failed: %v", err)
+		}
+
+		m.mu.Lock()
+		m.pruneLocked(time.Now())
+		m.mu.Unlock()
+	}
+}
+
+func (m *KeyManager) pruneLocked(now time.Time) {
+	for id, key := range m.keys {
+		if key.RetiredAt != nil && now.After(key.RetiredAt.Add(m.grace)) {
+			delete(m.keys, id)
+		}
+	}
+}
+
+// Sign signs claims with the active key and sets the kid header.
+func (m *KeyManager) Sign(claims jwt.Claims) (string, error) {
+	if m.algorithm == AlgHS256 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+	}
+
+	m.mu.RLock()
+	key := m.active
+	m.mu.RUnlock()
+
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
+}
+
+// Keyfunc resolves the verification key for a token. It only accepts the
+// configured algorithm, which rules out algorithm-confusion attacks, and for
+// asymmetric keys requires a kid that is active or within its grace period.
+func (m *KeyManager) Keyfunc(token *jwt.Token) (any, error) {
+	if token.Method.Alg() != m.algorithm {
+		return nil, jwt.ErrSignatureInvalid
+	}
+	if m.algorithm == AlgHS256 {
+		return m.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.keys[kid]
+	if !ok || (key.RetiredAt != nil && time.Now().After(key.RetiredAt.Add(m.grace))) {
+		return nil, ErrUnknownKey
+	}
+	return key.PublicKey(), nil
+}
+
+// JWKS returns the public keys that currently verify tokens.
+func (m *KeyManager) JWKS() JWKS {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	now := time.Now()
+	for _, key := range m.keys {
+		if key.RetiredAt != nil && now.After(key.RetiredAt.Add(m.grace)) {
+			continue
+		}
+		jwk, err := publicJWK(key.PublicKey(), key.Algorithm, key.ID)
+		if err != nil {
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
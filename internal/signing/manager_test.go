@@ -0,0 +1,232 @@
+package signing
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var asymmetric = []string{AlgRS256, AlgES256, AlgEdDSA}
+
+func newManager(t *testing.T, alg string, grace time.Duration) *KeyManager {
+	t.Helper()
+	m, err := NewKeyManager(alg, nil, grace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func sign(t *testing.T, m *KeyManager) string {
+	t.Helper()
+	token, err := m.Sign(jwt.MapClaims{"sub": "ada"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func verify(m *KeyManager, token string) error {
+	_, err := jwt.Parse(token, m.Keyfunc)
+	return err
+}
+
+// expire backdates the retirement of key so its grace period is over.
+func expire(m *KeyManager, kid string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	retired := time.Now().Add(-m.grace - time.Second)
+	m.keys[kid].RetiredAt = &retired
+}
+
+func kidOf(t *testing.T, token string) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestRotateGracePeriod(t *testing.T) {
+	for _, alg := range asymmetric {
+		m := newManager(t, alg, time.Hour)
+		old := sign(t, m)
+		oldKid := kidOf(t, old)
+
+		if err := m.Rotate(); err != nil {
+			t.Fatalf("%s: Rotate: %v", alg, err)
+		}
+		current := sign(t, m)
+		if kidOf(t, current) == oldKid {
+			t.Fatalf("%s: Rotate kept signing with %s", alg, oldKid)
+		}
+		if err := verify(m, old); err != nil {
+			t.Errorf("%s: token of the retired key within the grace period: %v", alg, err)
+		}
+		if err := verify(m, current); err != nil {
+			t.Errorf("%s: token of the active key: %v", alg, err)
+		}
+
+		expire(m, oldKid)
+		if err := verify(m, old); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("%s: token of the retired key after the grace period = %v, want ErrUnknownKey", alg, err)
+		}
+		if err := verify(m, current); err != nil {
+			t.Errorf("%s: token of the active key after the grace period: %v", alg, err)
+		}
+
+		// The next rotation prunes the expired key from the set.
+		if err := m.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := m.keys[oldKid]; ok {
+			t.Errorf("%s: Rotate kept the expired key %s", alg, oldKid)
+		}
+	}
+}
+
+func TestSetKeysGracePeriod(t *testing.T) {
+	first, err := GenerateKey(AlgES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := GenerateKey(AlgES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewKeyManager(AlgES256, []*Key{first}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := sign(t, m)
+
+	// Adding a key makes it active while the first keeps verifying.
+	if err := m.SetKeys([]*Key{first, second}); err != nil {
+		t.Fatal(err)
+	}
+	current := sign(t, m)
+	if kidOf(t, current) != second.ID {
+		t.Fatalf("SetKeys: signing with %s, want %s", kidOf(t, current), second.ID)
+	}
+	if err := verify(m, old); err != nil {
+		t.Errorf("token of a key still in the set: %v", err)
+	}
+
+	// Dropping the first key retires it for the grace period.
+	if err := m.SetKeys([]*Key{second}); err != nil {
+		t.Fatal(err)
+	}
+	if err := verify(m, old); err != nil {
+		t.Errorf("token of a dropped key within the grace period: %v", err)
+	}
+	expire(m, first.ID)
+	if err := verify(m, old); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("token of a dropped key after the grace period = %v, want ErrUnknownKey", err)
+	}
+	if err := verify(m, current); err != nil {
+		t.Errorf("token of the active key: %v", err)
+	}
+
+	// A key of another algorithm is refused and leaves the set unchanged.
+	other, err := GenerateKey(AlgEdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetKeys([]*Key{second, other}); !errors.Is(err, ErrUnsupportedKey) {
+		t.Errorf("SetKeys with an EdDSA key = %v, want ErrUnsupportedKey", err)
+	}
+	if err := m.SetKeys(nil); !errors.Is(err, ErrUnsupportedKey) {
+		t.Errorf("SetKeys with no keys = %v, want ErrUnsupportedKey", err)
+	}
+	if kidOf(t, sign(t, m)) != second.ID {
+		t.Error("a refused SetKeys changed the active key")
+	}
+}
+
+func TestKeyfuncRejects(t *testing.T) {
+	claims := jwt.MapClaims{"sub": "ada"}
+	for _, alg := range asymmetric {
+		m := newManager(t, alg, time.Hour)
+		stranger := newManager(t, alg, time.Hour)
+		m.mu.RLock()
+		active := m.active
+		m.mu.RUnlock()
+
+		publicDER, err := x509.MarshalPKIXPublicKey(active.PublicKey())
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+		unsigned := func(method jwt.SigningMethod, kid string) *jwt.Token {
+			token := jwt.NewWithClaims(method, claims)
+			if kid != "" {
+				token.Header["kid"] = kid
+			}
+			return token
+		}
+		signed := func(token *jwt.Token, key any) string {
+			raw, err := token.SignedString(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return raw
+		}
+
+		tests := []struct {
+			name  string
+			token string
+		}{
+			{"unknown kid", sign(t, stranger)},
+			{"missing kid", signed(unsigned(active.method(), ""), active.PrivateKey)},
+			{"kid of another key", signed(unsigned(active.method(), active.ID), stranger.active.PrivateKey)},
+			{"HS256 keyed with the public key", signed(unsigned(jwt.SigningMethodHS256, active.ID), publicPEM)},
+			{"HS256 keyed with the DER public key", signed(unsigned(jwt.SigningMethodHS256, active.ID), publicDER)},
+			{"none", signed(unsigned(jwt.SigningMethodNone, active.ID), jwt.UnsafeAllowNoneSignatureType)},
+		}
+		for _, other := range asymmetric {
+			if other == alg {
+				continue
+			}
+			key := newManager(t, other, time.Hour).active
+			tests = append(tests, struct {
+				name  string
+				token string
+			}{other + " under the active kid", signed(unsigned(key.method(), active.ID), key.PrivateKey)})
+		}
+
+		for _, tt := range tests {
+			if err := verify(m, tt.token); err == nil {
+				t.Errorf("%s: %s: token verified", alg, tt.name)
+			}
+		}
+	}
+}
+
+func TestHMACKeyManager(t *testing.T) {
+	m := NewHMACKeyManager("secret")
+	token := sign(t, m)
+	if err := verify(m, token); err != nil {
+		t.Errorf("HS256 token: %v", err)
+	}
+	if err := verify(NewHMACKeyManager("other secret"), token); err == nil {
+		t.Error("HS256 token verified with another secret")
+	}
+
+	asymmetric := newManager(t, AlgRS256, time.Hour)
+	if err := verify(m, sign(t, asymmetric)); err == nil {
+		t.Error("RS256 token verified by an HS256 manager")
+	}
+	if err := m.Rotate(); !errors.Is(err, ErrUnsupportedKey) {
+		t.Errorf("Rotate = %v, want ErrUnsupportedKey", err)
+	}
+	if keys := m.JWKS().Keys; len(keys) != 0 {
+		t.Errorf("JWKS = %+v, want no keys", keys)
+	}
+}
@@ -0,0 +1,41 @@
+package storage
+
+// DefaultRole and DefaultPermission describe the data the default data
+// migration inserts into a fresh database, for backends without migrations.
+// Keep them in sync with internal/migrations.
+type DefaultRole struct {
+	Name        string
+	Description string
+	// Parent is the role this one inherits from, if any. Parents are listed
+	// before their children.
+	Parent string
+}
+
+type DefaultPermission struct {
+	Role        string
+	Name        string
+	Resource    string
+	Action      string
+	Description string
+}
+
+var DefaultRoles = []DefaultRole{
+	{Name: "student", Description: "Student role with basic access to courses and grades"},
+	{Name: "teacher", Description: "Teacher role with course management and grading access", Parent: "student"},
+	{Name: "admin", Description: "Administrator role with full system access", Parent: "teacher"},
+}
+
+var DefaultPermissions = []DefaultPermission{
+	{Role: "teacher", Name: "create_course", Resource: "course", Action: "create", Description: "Create new courses"},
+	{Role: "student", Name: "view_course", Resource: "course", Action: "read", Description: "View course details and content"},
+	{Role: "teacher", Name: "update_course", Resource: "course", Action: "update", Description: "Update course information and content"},
+	{Role: "admin", Name: "delete_course", Resource: "course", Action: "delete", Description: "Delete courses permanently"},
+	{Role: "student", Name: "view_grades", Resource: "grades", Action: "read", Description: "View student grades and transcripts"},
+	{Role: "teacher", Name: "update_grades", Resource: "grades", Action: "update", Description: "Update and manage student grades"},
+	{Role: "teacher", Name: "view_students", Resource: "students", Action: "read", Description: "View student profiles and information"},
+	{Role: "admin", Name: "manage_students", Resource: "students", Action: "manage", Description: "Full student management capabilities"},
+	{Role: "admin", Name: "manage_users", Resource: "users", Action: "manage", Description: "Create, update, and delete user accounts"},
+	{Role: "admin", Name: "assign_roles", Resource: "users", Action: "assign_roles", Description: "Assign and remove user roles"},
+	{Role: "teacher", Name: "view_analytics", Resource: "analytics", Action: "read", Description: "View system analytics and reports"},
+	{Role: "admin", Name: "manage_settings", Resource: "settings", Action: "manage", Description: "Manage system settings and configuration"},
+}
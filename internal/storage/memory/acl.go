@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func (s *Store) UpsertACLEntry(entry *models.ACLEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := aclKey{
+		resource:    entry.Resource,
+		resourceID:  entry.ResourceID,
+		subjectType: entry.SubjectType,
+		subjectID:   entry.SubjectID,
+		action:      entry.Action,
+	}
+	if existing, ok := s.acl[key]; ok {
+		entry.ID, entry.CreatedAt = existing.ID, existing.CreatedAt
+	} else {
+		entry.CreatedAt = time.Now()
+	}
+	s.acl[key] = *entry
+	return nil
+}
+
+func (s *Store) ListACLEntries(resource, resourceID string) ([]models.ACLEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []models.ACLEntry
+	for key, entry := range s.acl {
+		if key.resource == resource && key.resourceID == resourceID {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].SubjectType != entries[j].SubjectType {
+			return entries[i].SubjectType < entries[j].SubjectType
+		}
+		return entries[i].Action < entries[j].Action
+	})
+	return entries, nil
+}
+
+func (s *Store) DeleteACLEntry(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, entry := range s.acl {
+		if entry.ID == id {
+			delete(s.acl, key)
+			return nil
+		}
+	}
+	return storage.ErrACLEntryNotFound
+}
+
+func (s *Store) SubjectACLEntries(userID, tenantID uuid.UUID, resource, resourceID, action string) ([]models.ACLEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tree := s.roleTreeLocked(userID, tenantID)
+	var entries []models.ACLEntry
+	for key, entry := range s.acl {
+		if key.resource != resource || key.resourceID != resourceID {
+			continue
+		}
+		if key.action != action && key.action != "*" {
+			continue
+		}
+		switch key.subjectType {
+		case models.SubjectUser:
+			if key.subjectID != userID {
+				continue
+			}
+		case models.SubjectRole:
+			if _, ok := tree[key.subjectID]; !ok {
+				continue
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
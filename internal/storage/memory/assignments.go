@@ -0,0 +1,187 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func (s *Store) AssignRole(a models.RoleAssignment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[a.UserID]; !ok {
+		return fmt.Errorf("failed to assign role: %w", storage.ErrUserNotFound)
+	}
+	if _, ok := s.roles[a.RoleID]; !ok {
+		return fmt.Errorf("failed to assign role: %w", storage.ErrRoleNotFound)
+	}
+
+	key := assignmentKey{userID: a.UserID, roleID: a.RoleID, tenantID: a.TenantID}
+	if existing, ok := s.assignments[key]; ok {
+		a.AssignedAt = existing.AssignedAt
+	} else {
+		a.AssignedAt = time.Now()
+	}
+	a.ManagedBy = ""
+	s.assignments[key] = a
+	return nil
+}
+
+func (s *Store) RemoveRole(userID, roleID, tenantID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.assignments, assignmentKey{userID: userID, roleID: roleID, tenantID: tenantID})
+	return nil
+}
+
+func (s *Store) ListAssignments(userID uuid.UUID) ([]models.RoleAssignment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var assignments []models.RoleAssignment
+	for key, a := range s.assignments {
+		if key.userID == userID {
+			assignments = append(assignments, a)
+		}
+	}
+	sort.Slice(assignments, func(i, j int) bool {
+		return assignments[i].AssignedAt.Before(assignments[j].AssignedAt)
+	})
+	return assignments, nil
+}
+
+// appliesIn reports whether an assignment of the user is scoped to tenantID
+// or global.
+func appliesIn(key assignmentKey, userID, tenantID uuid.UUID) bool {
+	return key.userID == userID && (key.tenantID == tenantID || key.tenantID == models.GlobalTenantID)
+}
+
+// roleTreeLocked returns the roles the user currently holds in tenantID,
+// directly or inherited.
+func (s *Store) roleTreeLocked(userID, tenantID uuid.UUID) map[uuid.UUID]struct{} {
+	now := time.Now()
+	var direct []uuid.UUID
+	for key, a := range s.assignments {
+		if appliesIn(key, userID, tenantID) && a.IsActive(now) {
+			direct = append(direct, key.roleID)
+		}
+	}
+	return s.ancestorsLocked(direct...)
+}
+
+func (s *Store) EffectiveRoles(userID, tenantID uuid.UUID) ([]models.Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var roles []models.Role
+	for roleID := range s.roleTreeLocked(userID, tenantID) {
+		roles = append(roles, s.roles[roleID])
+	}
+	return sortRoles(roles), nil
+}
+
+// EffectiveGrants ignores resource and action; scanning memory needs no
+// prefilter.
+func (s *Store) EffectiveGrants(userID, tenantID uuid.UUID, resource, action string) ([]storage.Grant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[storage.Grant]struct{})
+	var grants []storage.Grant
+	for roleID := range s.roleTreeLocked(userID, tenantID) {
+		for permissionID, record := range s.grants[roleID] {
+			permission := s.permissions[permissionID]
+			g := storage.Grant{
+				Resource:  permission.Resource,
+				Action:    permission.Action,
+				Effect:    record.effect,
+				Condition: record.condition,
+			}
+			if _, dup := seen[g]; dup {
+				continue
+			}
+			seen[g] = struct{}{}
+			grants = append(grants, g)
+		}
+	}
+	return grants, nil
+}
+
+func (s *Store) NextAssignmentBoundary(userID, tenantID uuid.UUID) (*time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	var next *time.Time
+	consider := func(t *time.Time) {
+		if t != nil && t.After(now) && (next == nil || t.Before(*next)) {
+			boundary := *t
+			next = &boundary
+		}
+	}
+	for key, a := range s.assignments {
+		if appliesIn(key, userID, tenantID) {
+			consider(a.ValidFrom)
+			consider(a.ValidUntil)
+		}
+	}
+	return next, nil
+}
+
+func (s *Store) ArchiveExpiredAssignments() ([]models.RoleAssignment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var expired []models.RoleAssignment
+	for key, a := range s.assignments {
+		if a.ValidUntil != nil && !a.ValidUntil.After(now) {
+			delete(s.assignments, key)
+			s.archive = append(s.archive, a)
+			expired = append(expired, a)
+		}
+	}
+	return expired, nil
+}
+
+func (s *Store) SyncManagedAssignments(userID uuid.UUID, provider string, desired []models.RoleAssignment) ([]models.RoleAssignment, []models.RoleAssignment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wanted := make(map[assignmentKey]bool, len(desired))
+	for _, a := range desired {
+		wanted[assignmentKey{userID: userID, roleID: a.RoleID, tenantID: a.TenantID}] = true
+	}
+
+	var added, removed []models.RoleAssignment
+	for key, a := range s.assignments {
+		if key.userID == userID && a.ManagedBy == provider && !wanted[key] {
+			delete(s.assignments, key)
+			removed = append(removed, a)
+		}
+	}
+
+	now := time.Now()
+	for _, d := range desired {
+		key := assignmentKey{userID: userID, roleID: d.RoleID, tenantID: d.TenantID}
+		if _, ok := s.assignments[key]; ok {
+			continue
+		}
+		if _, ok := s.roles[d.RoleID]; !ok {
+			continue
+		}
+		a := models.RoleAssignment{
+			UserID: userID, RoleID: d.RoleID, TenantID: d.TenantID, AssignedAt: now, ManagedBy: provider,
+		}
+		s.assignments[key] = a
+		added = append(added, a)
+	}
+	return added, removed, nil
+}
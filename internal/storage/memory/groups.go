@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func (s *Store) CreateGroupMapping(mapping *models.GroupRoleMapping) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.roles[mapping.RoleID]; !ok {
+		return fmt.Errorf("failed to create group mapping: %w", storage.ErrRoleNotFound)
+	}
+	for _, m := range s.groupMappings {
+		if m.Provider == mapping.Provider && m.Group == mapping.Group &&
+			m.RoleID == mapping.RoleID && m.TenantID == mapping.TenantID {
+			return fmt.Errorf("group mapping: %w", storage.ErrDuplicate)
+		}
+	}
+
+	mapping.CreatedAt = time.Now()
+	s.groupMappings[mapping.ID] = *mapping
+	return nil
+}
+
+func (s *Store) ListGroupMappings() ([]models.GroupRoleMapping, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	mappings := make([]models.GroupRoleMapping, 0, len(s.groupMappings))
+	for _, m := range s.groupMappings {
+		mappings = append(mappings, m)
+	}
+	sort.Slice(mappings, func(i, j int) bool {
+		a, b := mappings[i], mappings[j]
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+	return mappings, nil
+}
+
+func (s *Store) DeleteGroupMapping(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.groupMappings[id]; !ok {
+		return storage.ErrGroupMappingNotFound
+	}
+	delete(s.groupMappings, id)
+	return nil
+}
@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func (s *Store) GetUserByIdentity(provider, subject string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	identity, ok := s.identities[identityKey{provider: provider, subject: subject}]
+	if !ok {
+		return nil, storage.ErrUserNotFound
+	}
+	user := s.users[identity.UserID].user
+	user.PasswordHash = ""
+	return &user, nil
+}
+
+func (s *Store) ListIdentities(userID uuid.UUID) ([]models.Identity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var identities []models.Identity
+	for _, identity := range s.identities {
+		if identity.UserID == userID {
+			identities = append(identities, identity)
+		}
+	}
+	return identities, nil
+}
+
+func (s *Store) CreateUserWithIdentity(user *models.User, roleID uuid.UUID, identity *models.Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkNewUserLocked(user, roleID); err != nil {
+		return err
+	}
+	key := identityKey{provider: identity.Provider, subject: identity.Subject}
+	if _, exists := s.identities[key]; exists {
+		return fmt.Errorf("identity %s/%s: %w", identity.Provider, identity.Subject, storage.ErrDuplicate)
+	}
+
+	s.insertUserLocked(user, roleID)
+	identity.UserID, identity.CreatedAt = user.ID, user.CreatedAt
+	s.identities[key] = *identity
+	return nil
+}
+
+func (s *Store) LinkIdentity(identity *models.Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[identity.UserID]; !ok {
+		return storage.ErrUserNotFound
+	}
+	key := identityKey{provider: identity.Provider, subject: identity.Subject}
+	if _, exists := s.identities[key]; exists {
+		return fmt.Errorf("identity %s/%s: %w", identity.Provider, identity.Subject, storage.ErrDuplicate)
+	}
+
+	identity.CreatedAt = time.Now()
+	s.identities[key] = *identity
+	return nil
+}
@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func (s *Store) GetTOTPFactor(userID uuid.UUID) (*models.TOTPFactor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	factor, ok := s.totpFactors[userID]
+	if !ok {
+		return nil, storage.ErrTOTPFactorNotFound
+	}
+	return &factor, nil
+}
+
+func (s *Store) CreateTOTPFactor(factor *models.TOTPFactor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[factor.UserID]; !ok {
+		return fmt.Errorf("failed to create TOTP factor: %w", storage.ErrUserNotFound)
+	}
+	if existing, ok := s.totpFactors[factor.UserID]; ok && existing.ConfirmedAt != nil {
+		return fmt.Errorf("TOTP factor of user %s: %w", factor.UserID, storage.ErrDuplicate)
+	}
+	factor.CreatedAt = time.Now()
+	s.totpFactors[factor.UserID] = models.TOTPFactor{
+		UserID:    factor.UserID,
+		Secret:    factor.Secret,
+		CreatedAt: factor.CreatedAt,
+	}
+	return nil
+}
+
+func (s *Store) ConfirmTOTPFactor(userID uuid.UUID, step int64, codeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	factor, ok := s.totpFactors[userID]
+	if !ok || factor.ConfirmedAt != nil {
+		return storage.ErrTOTPFactorNotFound
+	}
+	now := time.Now()
+	factor.ConfirmedAt, factor.LastUsedStep, factor.FailedAttempts = &now, step, 0
+	s.totpFactors[userID] = factor
+	s.replaceRecoveryCodesLocked(userID, codeHashes)
+	return nil
+}
+
+func (s *Store) UseTOTPStep(userID uuid.UUID, step int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	factor, ok := s.totpFactors[userID]
+	if !ok || factor.LastUsedStep >= step {
+		return false, nil
+	}
+	factor.LastUsedStep, factor.FailedAttempts, factor.LockedUntil = step, 0, nil
+	s.totpFactors[userID] = factor
+	return true, nil
+}
+
+func (s *Store) RecordMFAFailure(userID uuid.UUID, maxAttempts int, lockedUntil time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	factor, ok := s.totpFactors[userID]
+	if !ok {
+		return nil
+	}
+	factor.FailedAttempts++
+	if factor.FailedAttempts >= maxAttempts {
+		factor.FailedAttempts, factor.LockedUntil = 0, &lockedUntil
+	}
+	s.totpFactors[userID] = factor
+	return nil
+}
+
+func (s *Store) DeleteTOTPFactor(userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.totpFactors[userID]; !ok {
+		return storage.ErrTOTPFactorNotFound
+	}
+	delete(s.totpFactors, userID)
+	delete(s.recoveryCodes, userID)
+	return nil
+}
+
+// Recovery Codes
+func (s *Store) replaceRecoveryCodesLocked(userID uuid.UUID, codeHashes []string) {
+	codes := make(map[string]struct{}, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes[hash] = struct{}{}
+	}
+	s.recoveryCodes[userID] = codes
+}
+
+func (s *Store) ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.replaceRecoveryCodesLocked(userID, codeHashes)
+	return nil
+}
+
+func (s *Store) UseRecoveryCode(userID uuid.UUID, codeHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.recoveryCodes[userID][codeHash]; !ok {
+		return storage.ErrTokenNotFound
+	}
+	delete(s.recoveryCodes[userID], codeHash)
+	if factor, ok := s.totpFactors[userID]; ok {
+		factor.FailedAttempts, factor.LockedUntil = 0, nil
+		s.totpFactors[userID] = factor
+	}
+	return nil
+}
+
+func (s *Store) CountRecoveryCodes(userID uuid.UUID) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.recoveryCodes[userID]), nil
+}
@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func (s *Store) CreateOAuthClient(client *models.OAuthClient) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.serviceAccounts[client.ServiceAccountID]; !ok {
+		return fmt.Errorf("failed to create OAuth client: %w", storage.ErrServiceAccountNotFound)
+	}
+
+	client.CreatedAt = time.Now()
+	stored := *client
+	stored.Scopes = append([]string{}, client.Scopes...)
+	s.oauthClients[client.ID] = stored
+	return nil
+}
+
+func (s *Store) ListOAuthClients(serviceAccountID uuid.UUID) ([]models.OAuthClient, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var clients []models.OAuthClient
+	for _, client := range s.oauthClients {
+		if client.ServiceAccountID == serviceAccountID {
+			client.SecretHash = ""
+			clients = append(clients, client)
+		}
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].CreatedAt.Before(clients[j].CreatedAt) })
+	return clients, nil
+}
+
+func (s *Store) GetOAuthClient(id uuid.UUID) (*models.OAuthClient, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	client, ok := s.oauthClients[id]
+	if !ok {
+		return nil, storage.ErrOAuthClientNotFound
+	}
+	return &client, nil
+}
+
+func (s *Store) RecordOAuthClientToken(clientID, jti uuid.UUID, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.oauthClients[clientID]; !ok {
+		return storage.ErrOAuthClientNotFound
+	}
+	s.clientTokens[jti] = oauthClientToken{clientID: clientID, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *Store) DeleteOAuthClient(serviceAccountID, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, ok := s.oauthClients[id]
+	if !ok || client.ServiceAccountID != serviceAccountID {
+		return storage.ErrOAuthClientNotFound
+	}
+	delete(s.oauthClients, id)
+
+	now := time.Now()
+	for jti, token := range s.clientTokens {
+		if token.clientID != id {
+			continue
+		}
+		if _, revoked := s.revokedTokens[jti]; !revoked && token.expiresAt.After(now) {
+			s.revokedTokens[jti] = token.expiresAt
+		}
+		delete(s.clientTokens, jti)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func sortRoles(roles []models.Role) []models.Role {
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles
+}
+
+func sortPermissions(permissions []models.Permission) []models.Permission {
+	sort.Slice(permissions, func(i, j int) bool {
+		if permissions[i].Resource != permissions[j].Resource {
+			return permissions[i].Resource < permissions[j].Resource
+		}
+		return permissions[i].Action < permissions[j].Action
+	})
+	return permissions
+}
+
+// ancestorsLocked returns the given roles together with every role they
+// inherit from.
+func (s *Store) ancestorsLocked(roleIDs ...uuid.UUID) map[uuid.UUID]struct{} {
+	tree := make(map[uuid.UUID]struct{})
+	queue := append([]uuid.UUID(nil), roleIDs...)
+	for len(queue) > 0 {
+		roleID := queue[0]
+		queue = queue[1:]
+		if _, seen := tree[roleID]; seen {
+			continue
+		}
+		tree[roleID] = struct{}{}
+		for parentID := range s.parents[roleID] {
+			queue = append(queue, parentID)
+		}
+	}
+	return tree
+}
+
+func (s *Store) CreateRole(role *models.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.roles {
+		if existing.Name == role.Name {
+			return fmt.Errorf("role %s: %w", role.Name, storage.ErrDuplicate)
+		}
+	}
+	for _, parentID := range role.ParentIDs {
+		if _, ok := s.roles[parentID]; !ok {
+			return fmt.Errorf("failed to add parent role: %w", storage.ErrRoleNotFound)
+		}
+	}
+
+	role.CreatedAt = time.Now()
+	s.roles[role.ID] = models.Role{
+		ID: role.ID, Name: role.Name, Description: role.Description, CreatedAt: role.CreatedAt,
+	}
+	for _, parentID := range role.ParentIDs {
+		if s.parents[role.ID] == nil {
+			s.parents[role.ID] = make(map[uuid.UUID]struct{})
+		}
+		s.parents[role.ID][parentID] = struct{}{}
+	}
+	return nil
+}
+
+func (s *Store) ListRoles() ([]models.Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	roles := make([]models.Role, 0, len(s.roles))
+	for _, role := range s.roles {
+		roles = append(roles, role)
+	}
+	return sortRoles(roles), nil
+}
+
+// Role Hierarchy
+func (s *Store) GetParentRoles(roleID uuid.UUID) ([]models.Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var roles []models.Role
+	for parentID := range s.parents[roleID] {
+		roles = append(roles, s.roles[parentID])
+	}
+	return sortRoles(roles), nil
+}
+
+func (s *Store) AddParentRole(roleID, parentID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, roleExists := s.roles[roleID]
+	_, parentExists := s.roles[parentID]
+	if !roleExists || !parentExists {
+		return storage.ErrRoleNotFound
+	}
+	if _, inherits := s.ancestorsLocked(parentID)[roleID]; inherits {
+		return storage.ErrRoleCycle
+	}
+
+	if s.parents[roleID] == nil {
+		s.parents[roleID] = make(map[uuid.UUID]struct{})
+	}
+	s.parents[roleID][parentID] = struct{}{}
+	return nil
+}
+
+func (s *Store) RemoveParentRole(roleID, parentID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.parents[roleID], parentID)
+	return nil
+}
+
+// Permission Management
+func (s *Store) CreatePermission(permission *models.Permission) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.permissions {
+		if existing.Name == permission.Name {
+			return fmt.Errorf("permission %s: %w", permission.Name, storage.ErrDuplicate)
+		}
+	}
+
+	permission.CreatedAt = time.Now()
+	stored := *permission
+	stored.Effect, stored.Condition = "", ""
+	s.permissions[permission.ID] = stored
+	return nil
+}
+
+func (s *Store) ListPermissions() ([]models.Permission, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	permissions := make([]models.Permission, 0, len(s.permissions))
+	for _, permission := range s.permissions {
+		permissions = append(permissions, permission)
+	}
+	return sortPermissions(permissions), nil
+}
+
+func (s *Store) GetRolePermissions(roleID uuid.UUID) ([]models.Permission, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Like SELECT DISTINCT, the same grant inherited twice is listed once.
+	seen := make(map[models.Permission]struct{})
+	var permissions []models.Permission
+	for treeRoleID := range s.ancestorsLocked(roleID) {
+		for permissionID, grant := range s.grants[treeRoleID] {
+			permission := s.permissions[permissionID]
+			permission.Effect, permission.Condition = grant.effect, grant.condition
+			if _, dup := seen[permission]; dup {
+				continue
+			}
+			seen[permission] = struct{}{}
+			permissions = append(permissions, permission)
+		}
+	}
+	return sortPermissions(permissions), nil
+}
+
+func (s *Store) GrantPermission(roleID, permissionID uuid.UUID, effect models.Effect, condition string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.roles[roleID]; !ok {
+		return fmt.Errorf("failed to grant permission: %w", storage.ErrRoleNotFound)
+	}
+	if _, ok := s.permissions[permissionID]; !ok {
+		return fmt.Errorf("failed to grant permission: unknown permission %s", permissionID)
+	}
+
+	if s.grants[roleID] == nil {
+		s.grants[roleID] = make(map[uuid.UUID]grantRecord)
+	}
+	s.grants[roleID][permissionID] = grantRecord{effect: effect, condition: condition}
+	return nil
+}
+
+func (s *Store) RevokePermission(roleID, permissionID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.grants[roleID], permissionID)
+	return nil
+}
@@ -0,0 +1,42 @@
+package memory
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+// SeedDefaults loads storage.DefaultRoles and storage.DefaultPermissions
+// (admin > teacher > student), so a fresh in-memory store is as usable as a
+// freshly created database.
+func (s *Store) SeedDefaults() error {
+	roleIDs := make(map[string]uuid.UUID, len(storage.DefaultRoles))
+	for _, r := range storage.DefaultRoles {
+		role := &models.Role{ID: uuid.New(), Name: r.Name, Description: r.Description}
+		if r.Parent != "" {
+			role.ParentIDs = []uuid.UUID{roleIDs[r.Parent]}
+		}
+		if err := s.CreateRole(role); err != nil {
+			return err
+		}
+		roleIDs[r.Name] = role.ID
+	}
+
+	for _, p := range storage.DefaultPermissions {
+		permission := &models.Permission{
+			ID:          uuid.New(),
+			Name:        p.Name,
+			Resource:    p.Resource,
+			Action:      p.Action,
+			Description: p.Description,
+		}
+		if err := s.CreatePermission(permission); err != nil {
+			return err
+		}
+		if err := s.GrantPermission(roleIDs[p.Role], permission.ID, models.EffectAllow, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
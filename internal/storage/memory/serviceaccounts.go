@@ -0,0 +1,182 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func (s *Store) CreateServiceAccount(user *models.User, roleID uuid.UUID, account *models.ServiceAccount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkNewUserLocked(user, roleID); err != nil {
+		return err
+	}
+	s.insertUserLocked(user, roleID)
+	account.ID, account.Name, account.Email, account.CreatedAt = user.ID, user.Name, user.Email, user.CreatedAt
+	s.serviceAccounts[user.ID] = *account
+	return nil
+}
+
+func (s *Store) ListServiceAccounts() ([]models.ServiceAccount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	accounts := make([]models.ServiceAccount, 0, len(s.serviceAccounts))
+	for _, a := range s.serviceAccounts {
+		accounts = append(accounts, a)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Name < accounts[j].Name })
+	return accounts, nil
+}
+
+func (s *Store) GetServiceAccount(id uuid.UUID) (*models.ServiceAccount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	account, ok := s.serviceAccounts[id]
+	if !ok {
+		return nil, storage.ErrServiceAccountNotFound
+	}
+	return &account, nil
+}
+
+func (s *Store) DeleteServiceAccount(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.serviceAccounts[id]; !ok {
+		return storage.ErrServiceAccountNotFound
+	}
+	delete(s.serviceAccounts, id)
+	for keyID, key := range s.apiKeys {
+		if key.ServiceAccountID == id {
+			delete(s.apiKeys, keyID)
+		}
+	}
+	for clientID, client := range s.oauthClients {
+		if client.ServiceAccountID == id {
+			delete(s.oauthClients, clientID)
+		}
+	}
+	for jti, token := range s.clientTokens {
+		if _, ok := s.oauthClients[token.clientID]; !ok {
+			delete(s.clientTokens, jti)
+		}
+	}
+	s.deleteUserLocked(id)
+	return nil
+}
+
+// deleteUserLocked removes a user and the rows that reference it, like the
+// ON DELETE CASCADE constraints of the SQL schema.
+func (s *Store) deleteUserLocked(id uuid.UUID) {
+	if record, ok := s.users[id]; ok {
+		delete(s.usersByEmail, record.user.Email)
+		delete(s.users, id)
+	}
+	for key, identity := range s.identities {
+		if identity.UserID == id {
+			delete(s.identities, key)
+		}
+	}
+	for key := range s.assignments {
+		if key.userID == id {
+			delete(s.assignments, key)
+		}
+	}
+	for hash, token := range s.refreshTokens {
+		if token.UserID == id {
+			delete(s.refreshTokens, hash)
+		}
+	}
+	for hash, token := range s.emailTokens {
+		if token.UserID == id {
+			delete(s.emailTokens, hash)
+		}
+	}
+	delete(s.totpFactors, id)
+	delete(s.recoveryCodes, id)
+	for credID, cred := range s.passkeys {
+		if cred.UserID == id {
+			delete(s.passkeys, credID)
+		}
+	}
+}
+
+// API Keys
+func (s *Store) CreateAPIKey(key *models.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.serviceAccounts[key.ServiceAccountID]; !ok {
+		return fmt.Errorf("failed to create API key: %w", storage.ErrServiceAccountNotFound)
+	}
+	for _, k := range s.apiKeys {
+		if k.Prefix == key.Prefix {
+			return fmt.Errorf("API key %s: %w", key.Prefix, storage.ErrDuplicate)
+		}
+	}
+
+	key.CreatedAt = time.Now()
+	stored := *key
+	stored.Scopes = append([]string{}, key.Scopes...)
+	s.apiKeys[key.ID] = stored
+	return nil
+}
+
+func (s *Store) ListAPIKeys(serviceAccountID uuid.UUID) ([]models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []models.APIKey
+	for _, key := range s.apiKeys {
+		if key.ServiceAccountID == serviceAccountID {
+			key.KeyHash = ""
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (s *Store) GetAPIKeyByPrefix(prefix string) (*models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.apiKeys {
+		if key.Prefix == prefix {
+			return &key, nil
+		}
+	}
+	return nil, storage.ErrAPIKeyNotFound
+}
+
+func (s *Store) TouchAPIKey(id uuid.UUID, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.apiKeys[id]; ok {
+		key.LastUsedAt = &usedAt
+		s.apiKeys[id] = key
+	}
+	return nil
+}
+
+func (s *Store) DeleteAPIKey(serviceAccountID, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.apiKeys[id]
+	if !ok || key.ServiceAccountID != serviceAccountID {
+		return storage.ErrAPIKeyNotFound
+	}
+	delete(s.apiKeys, id)
+	return nil
+}
@@ -0,0 +1,124 @@
+// Package memory implements storage.Store in process memory. It behaves like
+// the Postgres backend, including the uniqueness and hierarchy rules, but
+// keeps nothing across restarts. It is meant for tests and local development.
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+type userRecord struct {
+	user             models.User
+	attributes       map[string]any
+	tokensValidAfter *time.Time
+}
+
+type identityKey struct {
+	provider, subject string
+}
+
+type grantRecord struct {
+	effect    models.Effect
+	condition string
+}
+
+type assignmentKey struct {
+	userID, roleID, tenantID uuid.UUID
+}
+
+type oauthClientToken struct {
+	clientID  uuid.UUID
+	expiresAt time.Time
+}
+
+type aclKey struct {
+	resource, resourceID string
+	subjectType          models.SubjectType
+	subjectID            uuid.UUID
+	action               string
+}
+
+type Store struct {
+	mu sync.RWMutex
+
+	users        map[uuid.UUID]*userRecord
+	usersByEmail map[string]uuid.UUID
+	identities   map[identityKey]models.Identity
+
+	serviceAccounts map[uuid.UUID]models.ServiceAccount
+	apiKeys         map[uuid.UUID]models.APIKey
+	oauthClients    map[uuid.UUID]models.OAuthClient
+	clientTokens    map[uuid.UUID]oauthClientToken
+
+	roles       map[uuid.UUID]models.Role
+	parents     map[uuid.UUID]map[uuid.UUID]struct{}
+	permissions map[uuid.UUID]models.Permission
+	grants      map[uuid.UUID]map[uuid.UUID]grantRecord
+
+	assignments   map[assignmentKey]models.RoleAssignment
+	archive       []models.RoleAssignment
+	groupMappings map[uuid.UUID]models.GroupRoleMapping
+
+	acl map[aclKey]models.ACLEntry
+
+	refreshTokens map[string]*storage.RefreshToken
+	revokedTokens map[uuid.UUID]time.Time
+	emailTokens   map[string]storage.EmailToken
+
+	totpFactors   map[uuid.UUID]models.TOTPFactor
+	recoveryCodes map[uuid.UUID]map[string]struct{}
+	passkeys      map[string]models.WebAuthnCredential
+
+	policyVersion int64
+}
+
+var _ storage.Store = (*Store)(nil)
+
+func New() *Store {
+	return &Store{
+		users:           make(map[uuid.UUID]*userRecord),
+		usersByEmail:    make(map[string]uuid.UUID),
+		identities:      make(map[identityKey]models.Identity),
+		serviceAccounts: make(map[uuid.UUID]models.ServiceAccount),
+		apiKeys:         make(map[uuid.UUID]models.APIKey),
+		oauthClients:    make(map[uuid.UUID]models.OAuthClient),
+		clientTokens:    make(map[uuid.UUID]oauthClientToken),
+		roles:           make(map[uuid.UUID]models.Role),
+		parents:         make(map[uuid.UUID]map[uuid.UUID]struct{}),
+		permissions:     make(map[uuid.UUID]models.Permission),
+		grants:          make(map[uuid.UUID]map[uuid.UUID]grantRecord),
+		assignments:     make(map[assignmentKey]models.RoleAssignment),
+		groupMappings:   make(map[uuid.UUID]models.GroupRoleMapping),
+		acl:             make(map[aclKey]models.ACLEntry),
+		refreshTokens:   make(map[string]*storage.RefreshToken),
+		revokedTokens:   make(map[uuid.UUID]time.Time),
+		emailTokens:     make(map[string]storage.EmailToken),
+		totpFactors:     make(map[uuid.UUID]models.TOTPFactor),
+		recoveryCodes:   make(map[uuid.UUID]map[string]struct{}),
+		passkeys:        make(map[string]models.WebAuthnCredential),
+	}
+}
+
+func (s *Store) Close() error {
+	return nil
+}
+
+// Policy Version
+func (s *Store) PolicyVersion() (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.policyVersion, nil
+}
+
+func (s *Store) BumpPolicyVersion() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policyVersion++
+	return s.policyVersion, nil
+}
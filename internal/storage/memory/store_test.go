@@ -0,0 +1,15 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/Anand078/rbac/internal/storage"
+	"github.com/Anand078/rbac/internal/storage/memory"
+	"github.com/Anand078/rbac/internal/storage/storagetest"
+)
+
+func TestStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Store {
+		return memory.New()
+	})
+}
@@ -0,0 +1,170 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func (s *Store) CreateRefreshToken(token storage.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshTokens[token.TokenHash] = &token
+	return nil
+}
+
+func (s *Store) revokeFamilyLocked(familyID uuid.UUID, now time.Time) {
+	for _, token := range s.refreshTokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			revokedAt := now
+			token.RevokedAt = &revokedAt
+		}
+	}
+}
+
+func (s *Store) RotateRefreshToken(tokenHash string, next storage.RefreshToken) (*storage.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.refreshTokens[tokenHash]
+	if !ok {
+		return nil, storage.ErrTokenNotFound
+	}
+
+	now := time.Now()
+	if current.UsedAt != nil || current.RevokedAt != nil {
+		s.revokeFamilyLocked(current.FamilyID, now)
+		rotated := *current
+		return &rotated, storage.ErrTokenReused
+	}
+	if !now.Before(current.ExpiresAt) {
+		return nil, storage.ErrTokenNotFound
+	}
+
+	usedAt := now
+	current.UsedAt = &usedAt
+	next.UserID, next.TenantID, next.FamilyID = current.UserID, current.TenantID, current.FamilyID
+	s.refreshTokens[next.TokenHash] = &next
+
+	rotated := *current
+	return &rotated, nil
+}
+
+func (s *Store) RevokeRefreshFamily(userID uuid.UUID, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.refreshTokens[tokenHash]
+	if !ok || token.UserID != userID {
+		return nil
+	}
+	s.revokeFamilyLocked(token.FamilyID, time.Now())
+	return nil
+}
+
+func (s *Store) RevokeAccessToken(jti, userID uuid.UUID, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.revokedTokens[jti]; exists {
+		return false, nil
+	}
+	s.revokedTokens[jti] = expiresAt
+	return true, nil
+}
+
+func (s *Store) RevokeAllSessions(userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if record, ok := s.users[userID]; ok {
+		record.tokensValidAfter = &now
+	}
+	for _, token := range s.refreshTokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			revokedAt := now
+			token.RevokedAt = &revokedAt
+		}
+	}
+	return nil
+}
+
+func (s *Store) IsTokenRevoked(jti, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, revoked := s.revokedTokens[jti]; revoked {
+		return true, nil
+	}
+	record, ok := s.users[userID]
+	if !ok || record.tokensValidAfter == nil {
+		return false, nil
+	}
+	return record.tokensValidAfter.After(issuedAt), nil
+}
+
+func (s *Store) PurgeExpiredTokens() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	purged := 0
+	for jti, expiresAt := range s.revokedTokens {
+		if !expiresAt.After(now) {
+			delete(s.revokedTokens, jti)
+			purged++
+		}
+	}
+	for hash, token := range s.refreshTokens {
+		if !token.ExpiresAt.After(now) {
+			delete(s.refreshTokens, hash)
+			purged++
+		}
+	}
+	for hash, token := range s.emailTokens {
+		if !token.ExpiresAt.After(now) {
+			delete(s.emailTokens, hash)
+			purged++
+		}
+	}
+	for jti, token := range s.clientTokens {
+		if !token.expiresAt.After(now) {
+			delete(s.clientTokens, jti)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// Email Tokens
+func (s *Store) CreateEmailToken(token storage.EmailToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, existing := range s.emailTokens {
+		if existing.UserID == token.UserID && existing.Purpose == token.Purpose {
+			delete(s.emailTokens, hash)
+		}
+	}
+	s.emailTokens[token.TokenHash] = token
+	return nil
+}
+
+func (s *Store) ConsumeEmailToken(tokenHash, purpose string) (*storage.EmailToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.emailTokens[tokenHash]
+	if !ok || token.Purpose != purpose {
+		return nil, storage.ErrTokenNotFound
+	}
+	delete(s.emailTokens, tokenHash)
+	if !time.Now().Before(token.ExpiresAt) {
+		return nil, storage.ErrTokenNotFound
+	}
+	return &token, nil
+}
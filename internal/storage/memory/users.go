@@ -0,0 +1,146 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+// copyAttributes deep-copies attributes through JSON, which also normalizes
+// values the way a JSONB round trip would.
+func copyAttributes(attributes map[string]any) (map[string]any, error) {
+	raw, err := json.Marshal(attributes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode user attributes: %w", err)
+	}
+	copied := map[string]any{}
+	if err := json.Unmarshal(raw, &copied); err != nil {
+		return nil, fmt.Errorf("failed to decode user attributes: %w", err)
+	}
+	return copied, nil
+}
+
+func (s *Store) CreateUser(user *models.User, roleID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkNewUserLocked(user, roleID); err != nil {
+		return err
+	}
+	s.insertUserLocked(user, roleID)
+	return nil
+}
+
+func (s *Store) checkNewUserLocked(user *models.User, roleID uuid.UUID) error {
+	if _, exists := s.usersByEmail[user.Email]; exists {
+		return fmt.Errorf("user %s: %w", user.Email, storage.ErrDuplicate)
+	}
+	if roleID != uuid.Nil {
+		if _, ok := s.roles[roleID]; !ok {
+			return fmt.Errorf("failed to assign role: %w", storage.ErrRoleNotFound)
+		}
+	}
+	return nil
+}
+
+func (s *Store) insertUserLocked(user *models.User, roleID uuid.UUID) {
+	now := time.Now()
+	user.CreatedAt, user.UpdatedAt = now, now
+	record := &userRecord{user: *user, attributes: map[string]any{}}
+	record.user.Roles = nil
+	s.users[user.ID] = record
+	s.usersByEmail[user.Email] = user.ID
+
+	if roleID != uuid.Nil {
+		key := assignmentKey{userID: user.ID, roleID: roleID, tenantID: models.GlobalTenantID}
+		s.assignments[key] = models.RoleAssignment{
+			UserID: user.ID, RoleID: roleID, TenantID: models.GlobalTenantID, AssignedAt: now,
+		}
+	}
+}
+
+func (s *Store) GetUserByEmail(email string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.usersByEmail[email]
+	if !ok {
+		return nil, storage.ErrUserNotFound
+	}
+	user := s.users[id].user
+	return &user, nil
+}
+
+func (s *Store) GetUserByID(id uuid.UUID) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.users[id]
+	if !ok {
+		return nil, storage.ErrUserNotFound
+	}
+	user := record.user
+	user.PasswordHash = ""
+	return &user, nil
+}
+
+func (s *Store) GetUserAttributes(userID uuid.UUID) (map[string]any, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.users[userID]
+	if !ok {
+		return nil, storage.ErrUserNotFound
+	}
+	return copyAttributes(record.attributes)
+}
+
+func (s *Store) SetUserAttributes(userID uuid.UUID, attributes map[string]any) error {
+	copied, err := copyAttributes(attributes)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.users[userID]
+	if !ok {
+		return storage.ErrUserNotFound
+	}
+	record.attributes = copied
+	record.user.UpdatedAt = time.Now()
+	return nil
+}
+
+func (s *Store) MarkEmailVerified(userID uuid.UUID, verifiedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.users[userID]
+	if !ok {
+		return storage.ErrUserNotFound
+	}
+	if record.user.EmailVerifiedAt == nil {
+		record.user.EmailVerifiedAt = &verifiedAt
+	}
+	return nil
+}
+
+func (s *Store) UpdatePassword(userID uuid.UUID, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.users[userID]
+	if !ok {
+		return storage.ErrUserNotFound
+	}
+	record.user.PasswordHash = passwordHash
+	record.user.UpdatedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func (s *Store) CreateWebAuthnCredential(cred *models.WebAuthnCredential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[cred.UserID]; !ok {
+		return fmt.Errorf("failed to create passkey: %w", storage.ErrUserNotFound)
+	}
+	if _, ok := s.passkeys[cred.ID]; ok {
+		return fmt.Errorf("passkey %s: %w", cred.ID, storage.ErrDuplicate)
+	}
+	cred.CreatedAt = time.Now()
+	stored := *cred
+	stored.PublicKey = slices.Clone(cred.PublicKey)
+	stored.Transports = slices.Clone(cred.Transports)
+	s.passkeys[cred.ID] = stored
+	return nil
+}
+
+func (s *Store) GetWebAuthnCredential(id string) (*models.WebAuthnCredential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cred, ok := s.passkeys[id]
+	if !ok {
+		return nil, storage.ErrPasskeyNotFound
+	}
+	return &cred, nil
+}
+
+func (s *Store) GetWebAuthnCredentials(userID uuid.UUID) ([]models.WebAuthnCredential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var creds []models.WebAuthnCredential
+	for _, cred := range s.passkeys {
+		if cred.UserID == userID {
+			creds = append(creds, cred)
+		}
+	}
+	sort.Slice(creds, func(i, j int) bool { return creds[i].CreatedAt.Before(creds[j].CreatedAt) })
+	return creds, nil
+}
+
+func (s *Store) UseWebAuthnCredential(id string, signCount uint32, usedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cred, ok := s.passkeys[id]
+	if !ok || !(cred.SignCount < signCount || cred.SignCount == 0 && signCount == 0) {
+		return false, nil
+	}
+	cred.SignCount, cred.LastUsedAt = signCount, &usedAt
+	s.passkeys[id] = cred
+	return true, nil
+}
+
+func (s *Store) DeleteWebAuthnCredential(userID uuid.UUID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cred, ok := s.passkeys[id]; !ok || cred.UserID != userID {
+		return storage.ErrPasskeyNotFound
+	}
+	delete(s.passkeys, id)
+	return nil
+}
+
+func (s *Store) DeleteWebAuthnCredentials(userID uuid.UUID) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for id, cred := range s.passkeys {
+		if cred.UserID == userID {
+			delete(s.passkeys, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func (s *Store) UpsertACLEntry(entry *models.ACLEntry) error {
+	query := `
+        INSERT INTO acl_entries (id, resource, resource_id, subject_type, subject_id, action, effect)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        ON CONFLICT (resource, resource_id, subject_type, subject_id, action)
+        DO UPDATE SET effect = EXCLUDED.effect
+        RETURNING id, created_at
+    `
+	err := s.db.QueryRow(query, entry.ID, entry.Resource, entry.ResourceID, entry.SubjectType,
+		entry.SubjectID, entry.Action, entry.Effect).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create ACL entry: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) ListACLEntries(resource, resourceID string) ([]models.ACLEntry, error) {
+	query := `
+        SELECT id, resource, resource_id, subject_type, subject_id, action, effect, created_at
+        FROM acl_entries
+        WHERE resource = $1 AND resource_id = $2
+        ORDER BY subject_type, action
+    `
+	rows, err := s.db.Query(query, resource, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.ACLEntry
+	for rows.Next() {
+		var entry models.ACLEntry
+		if err := rows.Scan(&entry.ID, &entry.Resource, &entry.ResourceID, &entry.SubjectType,
+			&entry.SubjectID, &entry.Action, &entry.Effect, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (s *Store) DeleteACLEntry(id uuid.UUID) error {
+	result, err := s.db.Exec(`DELETE FROM acl_entries WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete ACL entry: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrACLEntryNotFound
+	}
+	return nil
+}
+
+func (s *Store) SubjectACLEntries(userID, tenantID uuid.UUID, resource, resourceID, action string) ([]models.ACLEntry, error) {
+	query := userRoleTreeCTE + `
+        SELECT a.id, a.resource, a.resource_id, a.subject_type, a.subject_id, a.action, a.effect, a.created_at
+        FROM acl_entries a
+        WHERE a.resource = $3 AND a.resource_id = $4
+          AND (a.action = $5 OR a.action = '*')
+          AND (
+              (a.subject_type = 'user' AND a.subject_id = $1)
+              OR (a.subject_type = 'role' AND a.subject_id IN (SELECT role_id FROM role_tree))
+          )
+    `
+	rows, err := s.db.Query(query, userID, tenantID, resource, resourceID, action)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.ACLEntry
+	for rows.Next() {
+		var entry models.ACLEntry
+		if err := rows.Scan(&entry.ID, &entry.Resource, &entry.ResourceID, &entry.SubjectType,
+			&entry.SubjectID, &entry.Action, &entry.Effect, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
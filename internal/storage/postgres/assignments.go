@@ -0,0 +1,230 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func (s *Store) AssignRole(a models.RoleAssignment) error {
+	query := `
+        INSERT INTO user_roles (user_id, role_id, tenant_id, valid_from, valid_until)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (user_id, role_id, tenant_id)
+        DO UPDATE SET valid_from = EXCLUDED.valid_from, valid_until = EXCLUDED.valid_until, managed_by = NULL
+    `
+	if _, err := s.db.Exec(query, a.UserID, a.RoleID, a.TenantID, a.ValidFrom, a.ValidUntil); err != nil {
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) RemoveRole(userID, roleID, tenantID uuid.UUID) error {
+	query := `DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2 AND tenant_id = $3`
+	if _, err := s.db.Exec(query, userID, roleID, tenantID); err != nil {
+		return fmt.Errorf("failed to remove role: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) ListAssignments(userID uuid.UUID) ([]models.RoleAssignment, error) {
+	query := `
+        SELECT user_id, role_id, tenant_id, valid_from, valid_until, assigned_at, COALESCE(managed_by, '')
+        FROM user_roles
+        WHERE user_id = $1
+        ORDER BY assigned_at
+    `
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assignments []models.RoleAssignment
+	for rows.Next() {
+		var a models.RoleAssignment
+		if err := rows.Scan(&a.UserID, &a.RoleID, &a.TenantID, &a.ValidFrom, &a.ValidUntil, &a.AssignedAt, &a.ManagedBy); err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, rows.Err()
+}
+
+func (s *Store) EffectiveRoles(userID, tenantID uuid.UUID) ([]models.Role, error) {
+	query := userRoleTreeCTE + `
+        SELECT r.id, r.name, r.description, r.created_at
+        FROM roles r
+        JOIN role_tree rt ON r.id = rt.role_id
+        ORDER BY r.name
+    `
+	rows, err := s.db.Query(query, userID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return scanRoles(rows)
+}
+
+func (s *Store) EffectiveGrants(userID, tenantID uuid.UUID, resource, action string) ([]storage.Grant, error) {
+	query := userRoleTreeCTE + `
+        SELECT DISTINCT p.resource, p.action, rp.effect, COALESCE(rp.condition, '')
+        FROM role_tree rt
+        JOIN role_permissions rp ON rt.role_id = rp.role_id
+        JOIN permissions p ON rp.permission_id = p.id
+    `
+	args := []any{userID, tenantID}
+	if resource != "" && action != "" {
+		// Exact matches and wildcard patterns; MatchPermission decides the rest.
+		query += `
+        WHERE (p.action = $4 OR p.action = '*')
+          AND (p.resource = $3 OR p.resource LIKE '%*%')
+    `
+		args = append(args, resource, action)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var grants []storage.Grant
+	for rows.Next() {
+		var g storage.Grant
+		if err := rows.Scan(&g.Resource, &g.Action, &g.Effect, &g.Condition); err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+func (s *Store) NextAssignmentBoundary(userID, tenantID uuid.UUID) (*time.Time, error) {
+	var boundary sql.NullTime
+	err := s.db.QueryRow(`
+        SELECT MIN(t) FROM (
+            SELECT valid_from AS t FROM user_roles
+            WHERE user_id = $1 AND tenant_id IN ($2, '00000000-0000-0000-0000-000000000000') AND valid_from > NOW()
+            UNION ALL
+            SELECT valid_until FROM user_roles
+            WHERE user_id = $1 AND tenant_id IN ($2, '00000000-0000-0000-0000-000000000000') AND valid_until > NOW()
+        ) boundaries
+    `, userID, tenantID).Scan(&boundary)
+	if err != nil {
+		return nil, err
+	}
+	if !boundary.Valid {
+		return nil, nil
+	}
+	return &boundary.Time, nil
+}
+
+func (s *Store) ArchiveExpiredAssignments() ([]models.RoleAssignment, error) {
+	query := `
+        WITH expired AS (
+            DELETE FROM user_roles
+            WHERE valid_until IS NOT NULL AND valid_until <= NOW()
+            RETURNING user_id, role_id, tenant_id, assigned_at, valid_from, valid_until
+        )
+        INSERT INTO user_roles_archive (user_id, role_id, tenant_id, assigned_at, valid_from, valid_until)
+        SELECT user_id, role_id, tenant_id, assigned_at, valid_from, valid_until FROM expired
+        RETURNING user_id, role_id, tenant_id, valid_from, valid_until, assigned_at
+    `
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sweep expired assignments: %w", err)
+	}
+	defer rows.Close()
+
+	var expired []models.RoleAssignment
+	for rows.Next() {
+		var a models.RoleAssignment
+		if err := rows.Scan(&a.UserID, &a.RoleID, &a.TenantID, &a.ValidFrom, &a.ValidUntil, &a.AssignedAt); err != nil {
+			return nil, err
+		}
+		expired = append(expired, a)
+	}
+	return expired, rows.Err()
+}
+
+func (s *Store) SyncManagedAssignments(userID uuid.UUID, provider string, desired []models.RoleAssignment) ([]models.RoleAssignment, []models.RoleAssignment, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	type key struct{ roleID, tenantID uuid.UUID }
+	wanted := make(map[key]bool, len(desired))
+	for _, a := range desired {
+		wanted[key{a.RoleID, a.TenantID}] = true
+	}
+
+	rows, err := tx.Query(`
+        SELECT role_id, tenant_id FROM user_roles
+        WHERE user_id = $1 AND managed_by = $2
+        FOR UPDATE
+    `, userID, provider)
+	if err != nil {
+		return nil, nil, err
+	}
+	var stale []key
+	for rows.Next() {
+		var k key
+		if err := rows.Scan(&k.roleID, &k.tenantID); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		if !wanted[k] {
+			stale = append(stale, k)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var added, removed []models.RoleAssignment
+	for _, k := range stale {
+		var a models.RoleAssignment
+		err := tx.QueryRow(`
+            DELETE FROM user_roles
+            WHERE user_id = $1 AND role_id = $2 AND tenant_id = $3 AND managed_by = $4
+            RETURNING user_id, role_id, tenant_id, assigned_at
+        `, userID, k.roleID, k.tenantID, provider).Scan(&a.UserID, &a.RoleID, &a.TenantID, &a.AssignedAt)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to remove managed role: %w", err)
+		}
+		a.ManagedBy = provider
+		removed = append(removed, a)
+	}
+
+	for _, d := range desired {
+		var a models.RoleAssignment
+		err := tx.QueryRow(`
+            INSERT INTO user_roles (user_id, role_id, tenant_id, managed_by)
+            VALUES ($1, $2, $3, $4)
+            ON CONFLICT (user_id, role_id, tenant_id) DO NOTHING
+            RETURNING user_id, role_id, tenant_id, assigned_at
+        `, userID, d.RoleID, d.TenantID, provider).Scan(&a.UserID, &a.RoleID, &a.TenantID, &a.AssignedAt)
+		if err == sql.ErrNoRows {
+			// Already held, managed or by hand.
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to assign managed role: %w", err)
+		}
+		a.ManagedBy = provider
+		added = append(added, a)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return added, removed, nil
+}
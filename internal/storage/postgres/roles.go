@@ -0,0 +1,226 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func scanRoles(rows *sql.Rows) ([]models.Role, error) {
+	defer rows.Close()
+
+	var roles []models.Role
+	for rows.Next() {
+		var role models.Role
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+func (s *Store) CreateRole(role *models.Role) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        INSERT INTO roles (id, name, description)
+        VALUES ($1, $2, $3)
+        RETURNING created_at
+    `
+	err = tx.QueryRow(query, role.ID, role.Name, role.Description).Scan(&role.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("role %s: %w", role.Name, storage.ErrDuplicate)
+		}
+		return fmt.Errorf("failed to create role: %w", err)
+	}
+
+	// A freshly created role has no children, so its parents cannot form a cycle.
+	for _, parentID := range role.ParentIDs {
+		_, err := tx.Exec(
+			"INSERT INTO role_parents (role_id, parent_role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+			role.ID, parentID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to add parent role: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) ListRoles() ([]models.Role, error) {
+	rows, err := s.db.Query(`SELECT id, name, description, created_at FROM roles ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	return scanRoles(rows)
+}
+
+// Role Hierarchy
+func (s *Store) GetParentRoles(roleID uuid.UUID) ([]models.Role, error) {
+	query := `
+        SELECT r.id, r.name, r.description, r.created_at
+        FROM roles r
+        JOIN role_parents rp ON r.id = rp.parent_role_id
+        WHERE rp.role_id = $1
+        ORDER BY r.name
+    `
+	rows, err := s.db.Query(query, roleID)
+	if err != nil {
+		return nil, err
+	}
+	return scanRoles(rows)
+}
+
+func (s *Store) AddParentRole(roleID, parentID uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Serialize hierarchy edits so two concurrent inserts cannot close a cycle
+	// that neither of them sees on its own.
+	if _, err := tx.Exec("LOCK TABLE role_parents IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return fmt.Errorf("failed to lock role hierarchy: %w", err)
+	}
+
+	var count int
+	err = tx.QueryRow("SELECT COUNT(*) FROM roles WHERE id IN ($1, $2)", roleID, parentID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	// A role made its own parent is found once; the cycle check rejects it.
+	want := 2
+	if roleID == parentID {
+		want = 1
+	}
+	if count != want {
+		return storage.ErrRoleNotFound
+	}
+
+	var createsCycle bool
+	err = tx.QueryRow(roleTreeCTE+`SELECT EXISTS (SELECT 1 FROM role_tree WHERE role_id = $2)`,
+		parentID, roleID).Scan(&createsCycle)
+	if err != nil {
+		return fmt.Errorf("failed to check role hierarchy: %w", err)
+	}
+	if createsCycle {
+		return storage.ErrRoleCycle
+	}
+
+	query := `
+        INSERT INTO role_parents (role_id, parent_role_id)
+        VALUES ($1, $2)
+        ON CONFLICT (role_id, parent_role_id) DO NOTHING
+    `
+	if _, err := tx.Exec(query, roleID, parentID); err != nil {
+		return fmt.Errorf("failed to add parent role: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) RemoveParentRole(roleID, parentID uuid.UUID) error {
+	query := `DELETE FROM role_parents WHERE role_id = $1 AND parent_role_id = $2`
+	if _, err := s.db.Exec(query, roleID, parentID); err != nil {
+		return fmt.Errorf("failed to remove parent role: %w", err)
+	}
+	return nil
+}
+
+// Permission Management
+func (s *Store) CreatePermission(permission *models.Permission) error {
+	query := `
+        INSERT INTO permissions (id, name, resource, action, description)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING created_at
+    `
+	err := s.db.QueryRow(query, permission.ID, permission.Name, permission.Resource,
+		permission.Action, permission.Description).Scan(&permission.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("permission %s: %w", permission.Name, storage.ErrDuplicate)
+		}
+		return fmt.Errorf("failed to create permission: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) ListPermissions() ([]models.Permission, error) {
+	query := `SELECT id, name, resource, action, description, created_at FROM permissions ORDER BY resource, action`
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []models.Permission
+	for rows.Next() {
+		var perm models.Permission
+		if err := rows.Scan(&perm.ID, &perm.Name, &perm.Resource, &perm.Action,
+			&perm.Description, &perm.CreatedAt); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, perm)
+	}
+	return permissions, rows.Err()
+}
+
+func (s *Store) GetRolePermissions(roleID uuid.UUID) ([]models.Permission, error) {
+	query := roleTreeCTE + `
+        SELECT DISTINCT p.id, p.name, p.resource, p.action, p.description, rp.effect,
+               COALESCE(rp.condition, ''), p.created_at
+        FROM permissions p
+        JOIN role_permissions rp ON p.id = rp.permission_id
+        JOIN role_tree rt ON rp.role_id = rt.role_id
+        ORDER BY p.resource, p.action
+    `
+	rows, err := s.db.Query(query, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []models.Permission
+	for rows.Next() {
+		var perm models.Permission
+		if err := rows.Scan(&perm.ID, &perm.Name, &perm.Resource, &perm.Action,
+			&perm.Description, &perm.Effect, &perm.Condition, &perm.CreatedAt); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, perm)
+	}
+	return permissions, rows.Err()
+}
+
+func (s *Store) GrantPermission(roleID, permissionID uuid.UUID, effect models.Effect, condition string) error {
+	query := `
+        INSERT INTO role_permissions (role_id, permission_id, effect, condition)
+        VALUES ($1, $2, $3, NULLIF($4, ''))
+        ON CONFLICT (role_id, permission_id)
+        DO UPDATE SET effect = EXCLUDED.effect, condition = EXCLUDED.condition
+    `
+	if _, err := s.db.Exec(query, roleID, permissionID, effect, condition); err != nil {
+		return fmt.Errorf("failed to grant permission: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) RevokePermission(roleID, permissionID uuid.UUID) error {
+	query := `DELETE FROM role_permissions WHERE role_id = $1 AND permission_id = $2`
+	if _, err := s.db.Exec(query, roleID, permissionID); err != nil {
+		return fmt.Errorf("failed to revoke permission: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// Package postgres implements storage.Store on the schema described in
+// docs/database_schema_design.md.
+package postgres
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+
+	"github.com/Anand078/rbac/internal/database"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+// userRoleTreeCTE resolves every role held by user $1 within tenant $2,
+// directly or through inherited parent roles. Global assignments apply in every
+// tenant, and assignments outside their validity window are ignored. UNION
+// (not UNION ALL) keeps it finite on cycles.
+const userRoleTreeCTE = `
+        WITH RECURSIVE role_tree AS (
+            SELECT ur.role_id FROM user_roles ur
+            WHERE ur.user_id = $1
+              AND (ur.tenant_id = $2 OR ur.tenant_id = '00000000-0000-0000-0000-000000000000')
+              AND (ur.valid_from IS NULL OR ur.valid_from <= NOW())
+              AND (ur.valid_until IS NULL OR ur.valid_until > NOW())
+            UNION
+            SELECT rp.parent_role_id
+            FROM role_parents rp
+            JOIN role_tree rt ON rp.role_id = rt.role_id
+        )
+    `
+
+// roleTreeCTE resolves role $1 together with all of its ancestors.
+const roleTreeCTE = `
+        WITH RECURSIVE role_tree AS (
+            SELECT $1::uuid AS role_id
+            UNION
+            SELECT rp.parent_role_id
+            FROM role_parents rp
+            JOIN role_tree rt ON rp.role_id = rt.role_id
+        )
+    `
+
+type Store struct {
+	db *database.DB
+}
+
+var _ storage.Store = (*Store)(nil)
+
+func New(db *database.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Policy Version
+func (s *Store) PolicyVersion() (int64, error) {
+	var version int64
+	err := s.db.QueryRow(`SELECT version FROM policy_version`).Scan(&version)
+	return version, err
+}
+
+func (s *Store) BumpPolicyVersion() (int64, error) {
+	var version int64
+	err := s.db.QueryRow(`UPDATE policy_version SET version = version + 1 RETURNING version`).Scan(&version)
+	return version, err
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
@@ -0,0 +1,42 @@
+package postgres_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/Anand078/rbac/internal/database"
+	"github.com/Anand078/rbac/internal/migrations"
+	"github.com/Anand078/rbac/internal/storage"
+	"github.com/Anand078/rbac/internal/storage/postgres"
+	"github.com/Anand078/rbac/internal/storage/storagetest"
+)
+
+// TestStore runs against the database in DATABASE_URL, migrating it first.
+// The suite only adds rows of its own, so any development database will do.
+func TestStore(t *testing.T) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+	db, err := database.NewConnection(databaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := migrations.New(db.DB, migrations.DialectPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	storagetest.Run(t, func(t *testing.T) storage.Store {
+		db, err := database.NewConnection(databaseURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return postgres.New(db)
+	})
+}
@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func (s *Store) CreateWebAuthnCredential(cred *models.WebAuthnCredential) error {
+	transports, err := json.Marshal(cred.Transports)
+	if err != nil {
+		return fmt.Errorf("failed to encode passkey transports: %w", err)
+	}
+
+	query := `
+        INSERT INTO webauthn_credentials (id, user_id, name, public_key, sign_count, transports, backup_eligible)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING created_at
+    `
+	err = s.db.QueryRow(query, cred.ID, cred.UserID, cred.Name, cred.PublicKey, int64(cred.SignCount),
+		string(transports), cred.BackupEligible).Scan(&cred.CreatedAt)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err):
+			return fmt.Errorf("passkey %s: %w", cred.ID, storage.ErrDuplicate)
+		case isForeignKeyViolation(err):
+			return fmt.Errorf("failed to create passkey: %w", storage.ErrUserNotFound)
+		}
+		return fmt.Errorf("failed to create passkey: %w", err)
+	}
+	return nil
+}
+
+const webauthnCredentialColumns = `id, user_id, name, public_key, sign_count, transports, backup_eligible, last_used_at, created_at`
+
+func scanWebAuthnCredential(row rowScanner) (*models.WebAuthnCredential, error) {
+	var (
+		cred       models.WebAuthnCredential
+		signCount  int64
+		transports []byte
+	)
+	err := row.Scan(&cred.ID, &cred.UserID, &cred.Name, &cred.PublicKey, &signCount, &transports,
+		&cred.BackupEligible, &cred.LastUsedAt, &cred.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	cred.SignCount = uint32(signCount)
+	if err := json.Unmarshal(transports, &cred.Transports); err != nil {
+		return nil, fmt.Errorf("failed to decode passkey transports: %w", err)
+	}
+	return &cred, nil
+}
+
+func (s *Store) GetWebAuthnCredential(id string) (*models.WebAuthnCredential, error) {
+	query := `SELECT ` + webauthnCredentialColumns + ` FROM webauthn_credentials WHERE id = $1`
+	cred, err := scanWebAuthnCredential(s.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrPasskeyNotFound
+		}
+		return nil, err
+	}
+	return cred, nil
+}
+
+func (s *Store) GetWebAuthnCredentials(userID uuid.UUID) ([]models.WebAuthnCredential, error) {
+	query := `SELECT ` + webauthnCredentialColumns + ` FROM webauthn_credentials WHERE user_id = $1 ORDER BY created_at`
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var creds []models.WebAuthnCredential
+	for rows.Next() {
+		cred, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		creds = append(creds, *cred)
+	}
+	return creds, rows.Err()
+}
+
+func (s *Store) UseWebAuthnCredential(id string, signCount uint32, usedAt time.Time) (bool, error) {
+	query := `
+        UPDATE webauthn_credentials
+        SET sign_count = $2, last_used_at = $3
+        WHERE id = $1 AND (sign_count < $2 OR (sign_count = 0 AND $2 = 0))
+    `
+	result, err := s.db.Exec(query, id, int64(signCount), usedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to record passkey use: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (s *Store) DeleteWebAuthnCredential(userID uuid.UUID, id string) error {
+	result, err := s.db.Exec(`DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete passkey: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrPasskeyNotFound
+	}
+	return nil
+}
+
+func (s *Store) DeleteWebAuthnCredentials(userID uuid.UUID) (int, error) {
+	result, err := s.db.Exec(`DELETE FROM webauthn_credentials WHERE user_id = $1`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete passkeys: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,243 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func scanAssignments(rows *sql.Rows) ([]models.RoleAssignment, error) {
+	defer rows.Close()
+
+	var assignments []models.RoleAssignment
+	for rows.Next() {
+		var a models.RoleAssignment
+		if err := rows.Scan(&a.UserID, &a.RoleID, &a.TenantID, &a.ValidFrom, &a.ValidUntil, &a.AssignedAt, &a.ManagedBy); err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, rows.Err()
+}
+
+func (s *Store) AssignRole(a models.RoleAssignment) error {
+	query := `
+        INSERT INTO user_roles (user_id, role_id, tenant_id, valid_from, valid_until, assigned_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (user_id, role_id, tenant_id)
+        DO UPDATE SET valid_from = excluded.valid_from, valid_until = excluded.valid_until, managed_by = NULL
+    `
+	_, err := s.db.Exec(query, a.UserID, a.RoleID, a.TenantID,
+		nullTimestamp(a.ValidFrom), nullTimestamp(a.ValidUntil), timestamp(time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) RemoveRole(userID, roleID, tenantID uuid.UUID) error {
+	query := `DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2 AND tenant_id = $3`
+	if _, err := s.db.Exec(query, userID, roleID, tenantID); err != nil {
+		return fmt.Errorf("failed to remove role: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) ListAssignments(userID uuid.UUID) ([]models.RoleAssignment, error) {
+	query := `
+        SELECT user_id, role_id, tenant_id, valid_from, valid_until, assigned_at, COALESCE(managed_by, '')
+        FROM user_roles
+        WHERE user_id = $1
+        ORDER BY assigned_at
+    `
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanAssignments(rows)
+}
+
+func (s *Store) EffectiveRoles(userID, tenantID uuid.UUID) ([]models.Role, error) {
+	query := userRoleTreeCTE + `
+        SELECT r.id, r.name, r.description, r.created_at
+        FROM roles r
+        JOIN role_tree rt ON r.id = rt.role_id
+        ORDER BY r.name
+    `
+	rows, err := s.db.Query(query, userID, tenantID, timestamp(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	return scanRoles(rows)
+}
+
+func (s *Store) EffectiveGrants(userID, tenantID uuid.UUID, resource, action string) ([]storage.Grant, error) {
+	query := userRoleTreeCTE + `
+        SELECT DISTINCT p.resource, p.action, rp.effect, COALESCE(rp.condition, '')
+        FROM role_tree rt
+        JOIN role_permissions rp ON rt.role_id = rp.role_id
+        JOIN permissions p ON rp.permission_id = p.id
+    `
+	args := []any{userID, tenantID, timestamp(time.Now())}
+	if resource != "" && action != "" {
+		// Exact matches and wildcard patterns; MatchPermission decides the rest.
+		query += `
+        WHERE (p.action = $5 OR p.action = '*')
+          AND (p.resource = $4 OR p.resource LIKE '%*%')
+    `
+		args = append(args, resource, action)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var grants []storage.Grant
+	for rows.Next() {
+		var g storage.Grant
+		if err := rows.Scan(&g.Resource, &g.Action, &g.Effect, &g.Condition); err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+func (s *Store) NextAssignmentBoundary(userID, tenantID uuid.UUID) (*time.Time, error) {
+	// MIN over text loses the column type, so the result is parsed here.
+	var boundary sql.NullString
+	err := s.db.QueryRow(`
+        SELECT MIN(t) FROM (
+            SELECT valid_from AS t FROM user_roles
+            WHERE user_id = $1 AND tenant_id IN ($2, '00000000-0000-0000-0000-000000000000') AND valid_from > $3
+            UNION ALL
+            SELECT valid_until FROM user_roles
+            WHERE user_id = $1 AND tenant_id IN ($2, '00000000-0000-0000-0000-000000000000') AND valid_until > $3
+        ) boundaries
+    `, userID, tenantID, timestamp(time.Now())).Scan(&boundary)
+	if err != nil {
+		return nil, err
+	}
+	if !boundary.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(timeLayout, boundary.String)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse assignment boundary: %w", err)
+	}
+	return &t, nil
+}
+
+func (s *Store) ArchiveExpiredAssignments() ([]models.RoleAssignment, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := timestamp(time.Now())
+	rows, err := tx.Query(`
+        SELECT user_id, role_id, tenant_id, valid_from, valid_until, assigned_at, COALESCE(managed_by, '')
+        FROM user_roles
+        WHERE valid_until IS NOT NULL AND valid_until <= $1
+    `, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sweep expired assignments: %w", err)
+	}
+	expired, err := scanAssignments(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(expired) == 0 {
+		return nil, nil
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO user_roles_archive (user_id, role_id, tenant_id, assigned_at, valid_from, valid_until, archived_at)
+        SELECT user_id, role_id, tenant_id, assigned_at, valid_from, valid_until, $1 FROM user_roles
+        WHERE valid_until IS NOT NULL AND valid_until <= $1
+    `, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive expired assignments: %w", err)
+	}
+	_, err = tx.Exec(`DELETE FROM user_roles WHERE valid_until IS NOT NULL AND valid_until <= $1`, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sweep expired assignments: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
+
+func (s *Store) SyncManagedAssignments(userID uuid.UUID, provider string, desired []models.RoleAssignment) ([]models.RoleAssignment, []models.RoleAssignment, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	type key struct{ roleID, tenantID uuid.UUID }
+	wanted := make(map[key]bool, len(desired))
+	for _, a := range desired {
+		wanted[key{a.RoleID, a.TenantID}] = true
+	}
+
+	rows, err := tx.Query(`
+        SELECT user_id, role_id, tenant_id, valid_from, valid_until, assigned_at, managed_by
+        FROM user_roles
+        WHERE user_id = $1 AND managed_by = $2
+    `, userID, provider)
+	if err != nil {
+		return nil, nil, err
+	}
+	managed, err := scanAssignments(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var added, removed []models.RoleAssignment
+	for _, a := range managed {
+		if wanted[key{a.RoleID, a.TenantID}] {
+			continue
+		}
+		_, err := tx.Exec(`DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2 AND tenant_id = $3`,
+			a.UserID, a.RoleID, a.TenantID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to remove managed role: %w", err)
+		}
+		removed = append(removed, a)
+	}
+
+	now := time.Now().UTC()
+	for _, d := range desired {
+		result, err := tx.Exec(`
+            INSERT INTO user_roles (user_id, role_id, tenant_id, assigned_at, managed_by)
+            VALUES ($1, $2, $3, $4, $5)
+            ON CONFLICT (user_id, role_id, tenant_id) DO NOTHING
+        `, userID, d.RoleID, d.TenantID, timestamp(now), provider)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to assign managed role: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			// Already held, managed or by hand.
+			continue
+		}
+		added = append(added, models.RoleAssignment{
+			UserID: userID, RoleID: d.RoleID, TenantID: d.TenantID, AssignedAt: now, ManagedBy: provider,
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return added, removed, nil
+}
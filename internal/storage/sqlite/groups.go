@@ -0,0 +1,65 @@
+package sqlite
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func (s *Store) CreateGroupMapping(mapping *models.GroupRoleMapping) error {
+	now := time.Now().UTC()
+	query := `
+        INSERT INTO group_role_mappings (id, provider, group_name, role_id, tenant_id, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `
+	_, err := s.db.Exec(query, mapping.ID, mapping.Provider, mapping.Group, mapping.RoleID, mapping.TenantID, timestamp(now))
+	if err != nil {
+		switch {
+		case isUniqueViolation(err):
+			return fmt.Errorf("group mapping: %w", storage.ErrDuplicate)
+		case isForeignKeyViolation(err):
+			return fmt.Errorf("failed to create group mapping: %w", storage.ErrRoleNotFound)
+		}
+		return fmt.Errorf("failed to create group mapping: %w", err)
+	}
+	mapping.CreatedAt = now
+	return nil
+}
+
+func (s *Store) ListGroupMappings() ([]models.GroupRoleMapping, error) {
+	query := `
+        SELECT id, provider, group_name, role_id, tenant_id, created_at
+        FROM group_role_mappings
+        ORDER BY provider, group_name, created_at
+    `
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mappings []models.GroupRoleMapping
+	for rows.Next() {
+		var m models.GroupRoleMapping
+		if err := rows.Scan(&m.ID, &m.Provider, &m.Group, &m.RoleID, &m.TenantID, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, rows.Err()
+}
+
+func (s *Store) DeleteGroupMapping(id uuid.UUID) error {
+	result, err := s.db.Exec(`DELETE FROM group_role_mappings WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete group mapping: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrGroupMappingNotFound
+	}
+	return nil
+}
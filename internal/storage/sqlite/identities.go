@@ -0,0 +1,91 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func insertIdentity(db execer, identity *models.Identity) error {
+	now := time.Now().UTC()
+	query := `
+        INSERT INTO identities (provider, subject, user_id, email, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+    `
+	_, err := db.Exec(query, identity.Provider, identity.Subject, identity.UserID, identity.Email, timestamp(now))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("identity %s/%s: %w", identity.Provider, identity.Subject, storage.ErrDuplicate)
+		}
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+	identity.CreatedAt = now
+	return nil
+}
+
+func (s *Store) GetUserByIdentity(provider, subject string) (*models.User, error) {
+	var user models.User
+	query := `
+        SELECT u.id, u.email, u.name, u.email_verified_at, u.created_at, u.updated_at
+        FROM users u
+        JOIN identities i ON i.user_id = u.id
+        WHERE i.provider = $1 AND i.subject = $2
+    `
+	err := s.db.QueryRow(query, provider, subject).
+		Scan(&user.ID, &user.Email, &user.Name, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *Store) ListIdentities(userID uuid.UUID) ([]models.Identity, error) {
+	query := `
+        SELECT user_id, provider, subject, email, created_at
+        FROM identities WHERE user_id = $1 ORDER BY created_at
+    `
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identities []models.Identity
+	for rows.Next() {
+		var identity models.Identity
+		if err := rows.Scan(&identity.UserID, &identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt); err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+func (s *Store) CreateUserWithIdentity(user *models.User, roleID uuid.UUID, identity *models.Identity) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertUser(tx, user, roleID); err != nil {
+		return err
+	}
+	identity.UserID = user.ID
+	if err := insertIdentity(tx, identity); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) LinkIdentity(identity *models.Identity) error {
+	return insertIdentity(s.db, identity)
+}
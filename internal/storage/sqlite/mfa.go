@@ -0,0 +1,190 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func (s *Store) GetTOTPFactor(userID uuid.UUID) (*models.TOTPFactor, error) {
+	var factor models.TOTPFactor
+	query := `
+        SELECT user_id, secret, confirmed_at, last_used_step, failed_attempts, locked_until, created_at
+        FROM totp_factors
+        WHERE user_id = $1
+    `
+	err := s.db.QueryRow(query, userID).Scan(&factor.UserID, &factor.Secret, &factor.ConfirmedAt,
+		&factor.LastUsedStep, &factor.FailedAttempts, &factor.LockedUntil, &factor.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrTOTPFactorNotFound
+		}
+		return nil, err
+	}
+	return &factor, nil
+}
+
+func (s *Store) CreateTOTPFactor(factor *models.TOTPFactor) error {
+	now := time.Now().UTC()
+	query := `
+        INSERT INTO totp_factors (user_id, secret, created_at)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (user_id) DO UPDATE
+            SET secret = excluded.secret, last_used_step = 0, failed_attempts = 0,
+                locked_until = NULL, created_at = excluded.created_at
+            WHERE totp_factors.confirmed_at IS NULL
+    `
+	result, err := s.db.Exec(query, factor.UserID, factor.Secret, timestamp(now))
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("failed to create TOTP factor: %w", storage.ErrUserNotFound)
+		}
+		return fmt.Errorf("failed to create TOTP factor: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("TOTP factor of user %s: %w", factor.UserID, storage.ErrDuplicate)
+	}
+	factor.CreatedAt = now
+	return nil
+}
+
+func (s *Store) ConfirmTOTPFactor(userID uuid.UUID, step int64, codeHashes []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        UPDATE totp_factors
+        SET confirmed_at = $3, last_used_step = $2, failed_attempts = 0
+        WHERE user_id = $1 AND confirmed_at IS NULL
+    `
+	result, err := tx.Exec(query, userID, step, timestamp(time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to confirm TOTP factor: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrTOTPFactorNotFound
+	}
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) UseTOTPStep(userID uuid.UUID, step int64) (bool, error) {
+	query := `
+        UPDATE totp_factors
+        SET last_used_step = $2, failed_attempts = 0, locked_until = NULL
+        WHERE user_id = $1 AND last_used_step < $2
+    `
+	result, err := s.db.Exec(query, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to record TOTP use: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (s *Store) RecordMFAFailure(userID uuid.UUID, maxAttempts int, lockedUntil time.Time) error {
+	query := `
+        UPDATE totp_factors
+        SET failed_attempts = CASE WHEN failed_attempts + 1 >= $2 THEN 0 ELSE failed_attempts + 1 END,
+            locked_until = CASE WHEN failed_attempts + 1 >= $2 THEN $3 ELSE locked_until END
+        WHERE user_id = $1
+    `
+	if _, err := s.db.Exec(query, userID, maxAttempts, timestamp(lockedUntil)); err != nil {
+		return fmt.Errorf("failed to record MFA failure: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteTOTPFactor(userID uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	result, err := tx.Exec(`DELETE FROM totp_factors WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete TOTP factor: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrTOTPFactorNotFound
+	}
+	return tx.Commit()
+}
+
+// Recovery Codes
+func replaceRecoveryCodes(tx *sql.Tx, userID uuid.UUID, codeHashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+	now := timestamp(time.Now())
+	for _, hash := range codeHashes {
+		_, err := tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, $3)`,
+			userID, hash, now)
+		if err != nil {
+			return fmt.Errorf("failed to store recovery code: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) UseRecoveryCode(userID uuid.UUID, codeHash string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1 AND code_hash = $2`, userID, codeHash)
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrTokenNotFound
+	}
+	if _, err := tx.Exec(
+		`UPDATE totp_factors SET failed_attempts = 0, locked_until = NULL WHERE user_id = $1`, userID,
+	); err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *Store) CountRecoveryCodes(userID uuid.UUID) (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1`, userID).Scan(&count)
+	return count, err
+}
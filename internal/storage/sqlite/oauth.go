@@ -0,0 +1,123 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func (s *Store) CreateOAuthClient(client *models.OAuthClient) error {
+	scopes, err := json.Marshal(client.Scopes)
+	if err != nil {
+		return fmt.Errorf("failed to encode OAuth client scopes: %w", err)
+	}
+
+	now := time.Now().UTC()
+	query := `
+        INSERT INTO oauth_clients (id, service_account_id, name, secret_hash, scopes, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `
+	_, err = s.db.Exec(query, client.ID, client.ServiceAccountID, client.Name, client.SecretHash, string(scopes), timestamp(now))
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("failed to create OAuth client: %w", storage.ErrServiceAccountNotFound)
+		}
+		return fmt.Errorf("failed to create OAuth client: %w", err)
+	}
+	client.CreatedAt = now
+	return nil
+}
+
+const oauthClientColumns = `id, service_account_id, name, secret_hash, scopes, created_at`
+
+func scanOAuthClient(row rowScanner) (*models.OAuthClient, error) {
+	var (
+		client models.OAuthClient
+		scopes []byte
+	)
+	err := row.Scan(&client.ID, &client.ServiceAccountID, &client.Name, &client.SecretHash, &scopes, &client.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(scopes, &client.Scopes); err != nil {
+		return nil, fmt.Errorf("failed to decode OAuth client scopes: %w", err)
+	}
+	return &client, nil
+}
+
+func (s *Store) ListOAuthClients(serviceAccountID uuid.UUID) ([]models.OAuthClient, error) {
+	query := `SELECT ` + oauthClientColumns + ` FROM oauth_clients WHERE service_account_id = $1 ORDER BY created_at`
+	rows, err := s.db.Query(query, serviceAccountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clients []models.OAuthClient
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		client.SecretHash = ""
+		clients = append(clients, *client)
+	}
+	return clients, rows.Err()
+}
+
+func (s *Store) GetOAuthClient(id uuid.UUID) (*models.OAuthClient, error) {
+	client, err := scanOAuthClient(s.db.QueryRow(`SELECT `+oauthClientColumns+` FROM oauth_clients WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrOAuthClientNotFound
+		}
+		return nil, err
+	}
+	return client, nil
+}
+
+func (s *Store) RecordOAuthClientToken(clientID, jti uuid.UUID, expiresAt time.Time) error {
+	query := `INSERT INTO oauth_client_tokens (jti, client_id, expires_at) VALUES ($1, $2, $3)`
+	if _, err := s.db.Exec(query, jti, clientID, timestamp(expiresAt)); err != nil {
+		if isForeignKeyViolation(err) {
+			return storage.ErrOAuthClientNotFound
+		}
+		return fmt.Errorf("failed to record OAuth client token: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteOAuthClient(serviceAccountID, id uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := timestamp(time.Now())
+	revoke := `
+        INSERT INTO revoked_tokens (jti, user_id, expires_at, revoked_at)
+        SELECT t.jti, c.service_account_id, t.expires_at, $3
+        FROM oauth_client_tokens t
+        JOIN oauth_clients c ON c.id = t.client_id
+        WHERE t.client_id = $1 AND c.service_account_id = $2 AND t.expires_at > $3
+        ON CONFLICT (jti) DO NOTHING
+    `
+	if _, err := tx.Exec(revoke, id, serviceAccountID, now); err != nil {
+		return fmt.Errorf("failed to revoke OAuth client tokens: %w", err)
+	}
+	result, err := tx.Exec(`DELETE FROM oauth_clients WHERE id = $1 AND service_account_id = $2`, id, serviceAccountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete OAuth client: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrOAuthClientNotFound
+	}
+	return tx.Commit()
+}
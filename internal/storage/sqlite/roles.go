@@ -0,0 +1,232 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func scanRoles(rows *sql.Rows) ([]models.Role, error) {
+	defer rows.Close()
+
+	var roles []models.Role
+	for rows.Next() {
+		var (
+			role        models.Role
+			description sql.NullString
+		)
+		if err := rows.Scan(&role.ID, &role.Name, &description, &role.CreatedAt); err != nil {
+			return nil, err
+		}
+		role.Description = description.String
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+func (s *Store) CreateRole(role *models.Role) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	query := `
+        INSERT INTO roles (id, name, description, created_at)
+        VALUES ($1, $2, $3, $4)
+    `
+	_, err = tx.Exec(query, role.ID, role.Name, role.Description, timestamp(now))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("role %s: %w", role.Name, storage.ErrDuplicate)
+		}
+		return fmt.Errorf("failed to create role: %w", err)
+	}
+
+	// A freshly created role has no children, so its parents cannot form a cycle.
+	for _, parentID := range role.ParentIDs {
+		_, err := tx.Exec(
+			"INSERT INTO role_parents (role_id, parent_role_id, created_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+			role.ID, parentID, timestamp(now),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to add parent role: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	role.CreatedAt = now
+	return nil
+}
+
+func (s *Store) ListRoles() ([]models.Role, error) {
+	rows, err := s.db.Query(`SELECT id, name, description, created_at FROM roles ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	return scanRoles(rows)
+}
+
+// Role Hierarchy
+func (s *Store) GetParentRoles(roleID uuid.UUID) ([]models.Role, error) {
+	query := `
+        SELECT r.id, r.name, r.description, r.created_at
+        FROM roles r
+        JOIN role_parents rp ON r.id = rp.parent_role_id
+        WHERE rp.role_id = $1
+        ORDER BY r.name
+    `
+	rows, err := s.db.Query(query, roleID)
+	if err != nil {
+		return nil, err
+	}
+	return scanRoles(rows)
+}
+
+func (s *Store) AddParentRole(roleID, parentID uuid.UUID) error {
+	// The transaction holds the database write lock from the start, which
+	// serializes hierarchy edits the way LOCK TABLE does on Postgres.
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.QueryRow("SELECT COUNT(*) FROM roles WHERE id IN ($1, $2)", roleID, parentID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	// A role made its own parent is found once; the cycle check rejects it.
+	want := 2
+	if roleID == parentID {
+		want = 1
+	}
+	if count != want {
+		return storage.ErrRoleNotFound
+	}
+
+	var createsCycle bool
+	err = tx.QueryRow(roleTreeCTE+`SELECT EXISTS (SELECT 1 FROM role_tree WHERE role_id = $2)`,
+		parentID, roleID).Scan(&createsCycle)
+	if err != nil {
+		return fmt.Errorf("failed to check role hierarchy: %w", err)
+	}
+	if createsCycle {
+		return storage.ErrRoleCycle
+	}
+
+	query := `
+        INSERT INTO role_parents (role_id, parent_role_id, created_at)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (role_id, parent_role_id) DO NOTHING
+    `
+	if _, err := tx.Exec(query, roleID, parentID, timestamp(time.Now())); err != nil {
+		return fmt.Errorf("failed to add parent role: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) RemoveParentRole(roleID, parentID uuid.UUID) error {
+	query := `DELETE FROM role_parents WHERE role_id = $1 AND parent_role_id = $2`
+	if _, err := s.db.Exec(query, roleID, parentID); err != nil {
+		return fmt.Errorf("failed to remove parent role: %w", err)
+	}
+	return nil
+}
+
+// Permission Management
+func (s *Store) CreatePermission(permission *models.Permission) error {
+	now := time.Now().UTC()
+	query := `
+        INSERT INTO permissions (id, name, resource, action, description, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `
+	_, err := s.db.Exec(query, permission.ID, permission.Name, permission.Resource,
+		permission.Action, permission.Description, timestamp(now))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("permission %s: %w", permission.Name, storage.ErrDuplicate)
+		}
+		return fmt.Errorf("failed to create permission: %w", err)
+	}
+	permission.CreatedAt = now
+	return nil
+}
+
+func (s *Store) ListPermissions() ([]models.Permission, error) {
+	query := `SELECT id, name, resource, action, COALESCE(description, ''), created_at FROM permissions ORDER BY resource, action`
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []models.Permission
+	for rows.Next() {
+		var perm models.Permission
+		if err := rows.Scan(&perm.ID, &perm.Name, &perm.Resource, &perm.Action,
+			&perm.Description, &perm.CreatedAt); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, perm)
+	}
+	return permissions, rows.Err()
+}
+
+func (s *Store) GetRolePermissions(roleID uuid.UUID) ([]models.Permission, error) {
+	query := roleTreeCTE + `
+        SELECT DISTINCT p.id, p.name, p.resource, p.action, COALESCE(p.description, ''), rp.effect,
+               COALESCE(rp.condition, ''), p.created_at
+        FROM permissions p
+        JOIN role_permissions rp ON p.id = rp.permission_id
+        JOIN role_tree rt ON rp.role_id = rt.role_id
+        ORDER BY p.resource, p.action
+    `
+	rows, err := s.db.Query(query, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []models.Permission
+	for rows.Next() {
+		var perm models.Permission
+		if err := rows.Scan(&perm.ID, &perm.Name, &perm.Resource, &perm.Action,
+			&perm.Description, &perm.Effect, &perm.Condition, &perm.CreatedAt); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, perm)
+	}
+	return permissions, rows.Err()
+}
+
+func (s *Store) GrantPermission(roleID, permissionID uuid.UUID, effect models.Effect, condition string) error {
+	query := `
+        INSERT INTO role_permissions (role_id, permission_id, effect, condition, granted_at)
+        VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+        ON CONFLICT (role_id, permission_id)
+        DO UPDATE SET effect = excluded.effect, condition = excluded.condition
+    `
+	if _, err := s.db.Exec(query, roleID, permissionID, effect, condition, timestamp(time.Now())); err != nil {
+		return fmt.Errorf("failed to grant permission: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) RevokePermission(roleID, permissionID uuid.UUID) error {
+	query := `DELETE FROM role_permissions WHERE role_id = $1 AND permission_id = $2`
+	if _, err := s.db.Exec(query, roleID, permissionID); err != nil {
+		return fmt.Errorf("failed to revoke permission: %w", err)
+	}
+	return nil
+}
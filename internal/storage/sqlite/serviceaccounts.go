@@ -0,0 +1,192 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func (s *Store) CreateServiceAccount(user *models.User, roleID uuid.UUID, account *models.ServiceAccount) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertUser(tx, user, roleID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		`INSERT INTO service_accounts (user_id, description, created_at) VALUES ($1, $2, $3)`,
+		user.ID, account.Description, timestamp(user.CreatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create service account: %w", err)
+	}
+	account.ID, account.Name, account.Email, account.CreatedAt = user.ID, user.Name, user.Email, user.CreatedAt
+	return tx.Commit()
+}
+
+const serviceAccountColumns = `u.id, u.name, u.email, sa.description, sa.created_at`
+
+func (s *Store) ListServiceAccounts() ([]models.ServiceAccount, error) {
+	query := `
+        SELECT ` + serviceAccountColumns + `
+        FROM service_accounts sa
+        JOIN users u ON u.id = sa.user_id
+        ORDER BY u.name
+    `
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []models.ServiceAccount
+	for rows.Next() {
+		var a models.ServiceAccount
+		if err := rows.Scan(&a.ID, &a.Name, &a.Email, &a.Description, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+func (s *Store) GetServiceAccount(id uuid.UUID) (*models.ServiceAccount, error) {
+	var a models.ServiceAccount
+	query := `
+        SELECT ` + serviceAccountColumns + `
+        FROM service_accounts sa
+        JOIN users u ON u.id = sa.user_id
+        WHERE sa.user_id = $1
+    `
+	err := s.db.QueryRow(query, id).Scan(&a.ID, &a.Name, &a.Email, &a.Description, &a.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrServiceAccountNotFound
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (s *Store) DeleteServiceAccount(id uuid.UUID) error {
+	query := `
+        DELETE FROM users
+        WHERE id = $1 AND EXISTS (SELECT 1 FROM service_accounts WHERE user_id = $1)
+    `
+	result, err := s.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete service account: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrServiceAccountNotFound
+	}
+	return nil
+}
+
+// API Keys
+func (s *Store) CreateAPIKey(key *models.APIKey) error {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return fmt.Errorf("failed to encode API key scopes: %w", err)
+	}
+
+	now := time.Now().UTC()
+	query := `
+        INSERT INTO api_keys (id, service_account_id, name, prefix, key_hash, scopes, expires_at, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    `
+	_, err = s.db.Exec(query, key.ID, key.ServiceAccountID, key.Name, key.Prefix, key.KeyHash,
+		string(scopes), nullTimestamp(key.ExpiresAt), timestamp(now))
+	if err != nil {
+		switch {
+		case isUniqueViolation(err):
+			return fmt.Errorf("API key %s: %w", key.Prefix, storage.ErrDuplicate)
+		case isForeignKeyViolation(err):
+			return fmt.Errorf("failed to create API key: %w", storage.ErrServiceAccountNotFound)
+		}
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+	key.CreatedAt = now
+	return nil
+}
+
+const apiKeyColumns = `id, service_account_id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_at`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var (
+		key    models.APIKey
+		scopes []byte
+	)
+	err := row.Scan(&key.ID, &key.ServiceAccountID, &key.Name, &key.Prefix, &key.KeyHash,
+		&scopes, &key.ExpiresAt, &key.LastUsedAt, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(scopes, &key.Scopes); err != nil {
+		return nil, fmt.Errorf("failed to decode API key scopes: %w", err)
+	}
+	return &key, nil
+}
+
+func (s *Store) ListAPIKeys(serviceAccountID uuid.UUID) ([]models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE service_account_id = $1 ORDER BY created_at`
+	rows, err := s.db.Query(query, serviceAccountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		key.KeyHash = ""
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *Store) GetAPIKeyByPrefix(prefix string) (*models.APIKey, error) {
+	key, err := scanAPIKey(s.db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE prefix = $1`, prefix))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+func (s *Store) TouchAPIKey(id uuid.UUID, usedAt time.Time) error {
+	if _, err := s.db.Exec(`UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, timestamp(usedAt)); err != nil {
+		return fmt.Errorf("failed to record API key use: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteAPIKey(serviceAccountID, id uuid.UUID) error {
+	result, err := s.db.Exec(`DELETE FROM api_keys WHERE id = $1 AND service_account_id = $2`, id, serviceAccountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete API key: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrAPIKeyNotFound
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+// Package sqlite implements storage.Store on an SQLite database for embedded
+// and single-node deployments. Queries mirror the postgres package, including
+// its ON CONFLICT handling, so both backends behave the same.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	sqlitedriver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/Anand078/rbac/internal/migrations"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+// timeLayout is how timestamps are stored: always UTC and fixed width, so
+// comparing them as text orders them correctly.
+const timeLayout = "2006-01-02 15:04:05.000000000"
+
+// userRoleTreeCTE resolves every role held by user $1 within tenant $2 at time
+// $3, directly or through inherited parent roles. See the postgres package.
+const userRoleTreeCTE = `
+        WITH RECURSIVE role_tree AS (
+            SELECT ur.role_id FROM user_roles ur
+            WHERE ur.user_id = $1
+              AND (ur.tenant_id = $2 OR ur.tenant_id = '00000000-0000-0000-0000-000000000000')
+              AND (ur.valid_from IS NULL OR ur.valid_from <= $3)
+              AND (ur.valid_until IS NULL OR ur.valid_until > $3)
+            UNION
+            SELECT rp.parent_role_id
+            FROM role_parents rp
+            JOIN role_tree rt ON rp.role_id = rt.role_id
+        )
+    `
+
+// roleTreeCTE resolves role $1 together with all of its ancestors.
+const roleTreeCTE = `
+        WITH RECURSIVE role_tree AS (
+            SELECT $1 AS role_id
+            UNION
+            SELECT rp.parent_role_id
+            FROM role_parents rp
+            JOIN role_tree rt ON rp.role_id = rt.role_id
+        )
+    `
+
+type Store struct {
+	db *sql.DB
+}
+
+var _ storage.Store = (*Store)(nil)
+
+// OpenDB opens (creating if needed) the database file at path, or a private
+// in-memory database for ":memory:", without touching its schema.
+func OpenDB(path string) (*sql.DB, error) {
+	query := url.Values{}
+	query.Add("_pragma", "foreign_keys(1)")
+	query.Add("_pragma", "busy_timeout(5000)")
+	query.Add("_pragma", "journal_mode(WAL)")
+	// Take the write lock when a transaction begins, so read-then-write
+	// transactions such as the cycle check cannot interleave.
+	query.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// SQLite allows a single writer; one connection also keeps ":memory:"
+	// databases from being per connection.
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// Open opens the database like OpenDB and brings its schema up to date.
+func Open(path string) (*Store, error) {
+	db, err := OpenDB(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// migrate applies pending schema migrations. Writers are serialized by the
+// database itself, so no extra lock is needed.
+func (s *Store) migrate() error {
+	migrator, err := migrations.New(s.db, migrations.DialectSQLite)
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up(context.Background())
+	for _, m := range applied {
+		log.Printf("Applied migration %d_%s", m.Version, m.Name)
+	}
+	return err
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Policy Version
+func (s *Store) PolicyVersion() (int64, error) {
+	var version int64
+	err := s.db.QueryRow(`SELECT version FROM policy_version`).Scan(&version)
+	return version, err
+}
+
+func (s *Store) BumpPolicyVersion() (int64, error) {
+	var version int64
+	err := s.db.QueryRow(`UPDATE policy_version SET version = version + 1 RETURNING version`).Scan(&version)
+	return version, err
+}
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+func nullTimestamp(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return timestamp(*t)
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlitedriver.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code()
+	return code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
+
+func isForeignKeyViolation(err error) bool {
+	var sqliteErr *sqlitedriver.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+}
@@ -0,0 +1,20 @@
+package sqlite_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Anand078/rbac/internal/storage"
+	"github.com/Anand078/rbac/internal/storage/sqlite"
+	"github.com/Anand078/rbac/internal/storage/storagetest"
+)
+
+func TestStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Store {
+		store, err := sqlite.Open(filepath.Join(t.TempDir(), "rbac.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
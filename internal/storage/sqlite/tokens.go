@@ -0,0 +1,233 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func insertRefreshToken(db execer, token storage.RefreshToken) error {
+	query := `
+        INSERT INTO refresh_tokens (id, user_id, tenant_id, family_id, token_hash, expires_at, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `
+	_, err := db.Exec(query, token.ID, token.UserID, token.TenantID, token.FamilyID, token.TokenHash,
+		timestamp(token.ExpiresAt), timestamp(time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) CreateRefreshToken(token storage.RefreshToken) error {
+	return insertRefreshToken(s.db, token)
+}
+
+func (s *Store) RotateRefreshToken(tokenHash string, next storage.RefreshToken) (*storage.RefreshToken, error) {
+	// The transaction takes the write lock up front, which stands in for
+	// SELECT ... FOR UPDATE.
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		current           storage.RefreshToken
+		usedAt, revokedAt sql.NullTime
+	)
+	query := `
+        SELECT id, user_id, tenant_id, family_id, token_hash, expires_at, used_at, revoked_at
+        FROM refresh_tokens
+        WHERE token_hash = $1
+    `
+	err = tx.QueryRow(query, tokenHash).Scan(
+		&current.ID, &current.UserID, &current.TenantID, &current.FamilyID,
+		&current.TokenHash, &current.ExpiresAt, &usedAt, &revokedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrTokenNotFound
+		}
+		return nil, err
+	}
+
+	now := timestamp(time.Now())
+	if usedAt.Valid || revokedAt.Valid {
+		if _, err := tx.Exec(
+			"UPDATE refresh_tokens SET revoked_at = $2 WHERE family_id = $1 AND revoked_at IS NULL",
+			current.FamilyID, now,
+		); err != nil {
+			return nil, fmt.Errorf("failed to revoke token family: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return &current, storage.ErrTokenReused
+	}
+
+	if !time.Now().Before(current.ExpiresAt) {
+		return nil, storage.ErrTokenNotFound
+	}
+
+	if _, err := tx.Exec("UPDATE refresh_tokens SET used_at = $2 WHERE id = $1", current.ID, now); err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	next.UserID, next.TenantID, next.FamilyID = current.UserID, current.TenantID, current.FamilyID
+	if err := insertRefreshToken(tx, next); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &current, nil
+}
+
+func (s *Store) RevokeRefreshFamily(userID uuid.UUID, tokenHash string) error {
+	query := `
+        UPDATE refresh_tokens SET revoked_at = $3
+        WHERE revoked_at IS NULL AND user_id = $1 AND family_id = (
+            SELECT family_id FROM refresh_tokens WHERE token_hash = $2
+        )
+    `
+	if _, err := s.db.Exec(query, userID, tokenHash, timestamp(time.Now())); err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) RevokeAccessToken(jti, userID uuid.UUID, expiresAt time.Time) (bool, error) {
+	query := `
+        INSERT INTO revoked_tokens (jti, user_id, expires_at, revoked_at)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (jti) DO NOTHING
+    `
+	result, err := s.db.Exec(query, jti, userID, timestamp(expiresAt), timestamp(time.Now()))
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke token: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (s *Store) RevokeAllSessions(userID uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := timestamp(time.Now())
+	if _, err := tx.Exec(`UPDATE users SET tokens_valid_after = $2 WHERE id = $1`, userID, now); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	if _, err := tx.Exec(
+		`UPDATE refresh_tokens SET revoked_at = $2 WHERE user_id = $1 AND revoked_at IS NULL`, userID, now,
+	); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) IsTokenRevoked(jti, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	query := `
+        SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+            OR EXISTS (
+                SELECT 1 FROM users
+                WHERE id = $2 AND tokens_valid_after IS NOT NULL
+                  AND tokens_valid_after > $3
+            )
+    `
+	var revoked bool
+	err := s.db.QueryRow(query, jti, userID, timestamp(issuedAt)).Scan(&revoked)
+	if err != nil {
+		return false, err
+	}
+	return revoked, nil
+}
+
+func (s *Store) PurgeExpiredTokens() (int, error) {
+	now := timestamp(time.Now())
+	revoked, err := s.db.Exec(`DELETE FROM revoked_tokens WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge revoked tokens: %w", err)
+	}
+	refresh, err := s.db.Exec(`DELETE FROM refresh_tokens WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge refresh tokens: %w", err)
+	}
+	email, err := s.db.Exec(`DELETE FROM email_tokens WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge email tokens: %w", err)
+	}
+	client, err := s.db.Exec(`DELETE FROM oauth_client_tokens WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge OAuth client tokens: %w", err)
+	}
+
+	n1, _ := revoked.RowsAffected()
+	n2, _ := refresh.RowsAffected()
+	n3, _ := email.RowsAffected()
+	n4, _ := client.RowsAffected()
+	return int(n1 + n2 + n3 + n4), nil
+}
+
+// Email Tokens
+func (s *Store) CreateEmailToken(token storage.EmailToken) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`DELETE FROM email_tokens WHERE user_id = $1 AND purpose = $2`, token.UserID, token.Purpose,
+	); err != nil {
+		return fmt.Errorf("failed to replace email tokens: %w", err)
+	}
+	query := `
+        INSERT INTO email_tokens (token_hash, user_id, purpose, expires_at, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+    `
+	_, err = tx.Exec(query, token.TokenHash, token.UserID, token.Purpose,
+		timestamp(token.ExpiresAt), timestamp(time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to store email token: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *Store) ConsumeEmailToken(tokenHash, purpose string) (*storage.EmailToken, error) {
+	token := storage.EmailToken{TokenHash: tokenHash, Purpose: purpose}
+	query := `
+        DELETE FROM email_tokens
+        WHERE token_hash = $1 AND purpose = $2
+        RETURNING user_id, expires_at
+    `
+	err := s.db.QueryRow(query, tokenHash, purpose).Scan(&token.UserID, &token.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to consume email token: %w", err)
+	}
+	if !time.Now().Before(token.ExpiresAt) {
+		return nil, storage.ErrTokenNotFound
+	}
+	return &token, nil
+}
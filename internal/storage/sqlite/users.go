@@ -0,0 +1,158 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+func (s *Store) CreateUser(user *models.User, roleID uuid.UUID) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertUser(tx, user, roleID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertUser stores user and assigns it roleID globally unless roleID is
+// uuid.Nil.
+func insertUser(tx *sql.Tx, user *models.User, roleID uuid.UUID) error {
+	now := time.Now().UTC()
+	query := `
+        INSERT INTO users (id, email, name, password_hash, email_verified_at, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $6)
+    `
+	_, err := tx.Exec(query, user.ID, user.Email, user.Name, user.PasswordHash,
+		nullTimestamp(user.EmailVerifiedAt), timestamp(now))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("user %s: %w", user.Email, storage.ErrDuplicate)
+		}
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	if roleID != uuid.Nil {
+		_, err = tx.Exec(
+			"INSERT INTO user_roles (user_id, role_id, tenant_id, assigned_at) VALUES ($1, $2, $3, $4)",
+			user.ID, roleID, models.GlobalTenantID, timestamp(now),
+		)
+		if err != nil {
+			if isForeignKeyViolation(err) {
+				return fmt.Errorf("failed to assign role: %w", storage.ErrRoleNotFound)
+			}
+			return fmt.Errorf("failed to assign role: %w", err)
+		}
+	}
+
+	user.CreatedAt, user.UpdatedAt = now, now
+	return nil
+}
+
+func (s *Store) GetUserByEmail(email string) (*models.User, error) {
+	var user models.User
+	query := `
+        SELECT id, email, name, password_hash, email_verified_at, created_at, updated_at
+        FROM users
+        WHERE email = $1
+    `
+	err := s.db.QueryRow(query, email).Scan(
+		&user.ID, &user.Email, &user.Name, &user.PasswordHash,
+		&user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *Store) GetUserByID(id uuid.UUID) (*models.User, error) {
+	var user models.User
+	err := s.db.QueryRow(
+		"SELECT id, email, name, email_verified_at, created_at, updated_at FROM users WHERE id = $1", id,
+	).Scan(&user.ID, &user.Email, &user.Name, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *Store) GetUserAttributes(userID uuid.UUID) (map[string]any, error) {
+	var raw []byte
+	err := s.db.QueryRow(`SELECT attributes FROM users WHERE id = $1`, userID).Scan(&raw)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	attributes := map[string]any{}
+	if err := json.Unmarshal(raw, &attributes); err != nil {
+		return nil, fmt.Errorf("failed to decode user attributes: %w", err)
+	}
+	return attributes, nil
+}
+
+func (s *Store) SetUserAttributes(userID uuid.UUID, attributes map[string]any) error {
+	raw, err := json.Marshal(attributes)
+	if err != nil {
+		return fmt.Errorf("failed to encode user attributes: %w", err)
+	}
+
+	result, err := s.db.Exec(
+		`UPDATE users SET attributes = $2, updated_at = $3 WHERE id = $1`,
+		userID, string(raw), timestamp(time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update user attributes: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *Store) MarkEmailVerified(userID uuid.UUID, verifiedAt time.Time) error {
+	result, err := s.db.Exec(
+		`UPDATE users SET email_verified_at = COALESCE(email_verified_at, $2) WHERE id = $1`,
+		userID, timestamp(verifiedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark email verified: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *Store) UpdatePassword(userID uuid.UUID, passwordHash string) error {
+	result, err := s.db.Exec(
+		`UPDATE users SET password_hash = $2, updated_at = $3 WHERE id = $1`,
+		userID, passwordHash, timestamp(time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrUserNotFound
+	}
+	return nil
+}
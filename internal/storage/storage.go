@@ -0,0 +1,312 @@
+// Package storage defines the repositories the services persist their state
+// through. The postgres subpackage implements them on top of the SQL schema in
+// docs/database_schema_design.md; the memory subpackage keeps everything in
+// process for tests and local development.
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+)
+
+var (
+	ErrUserNotFound           = errors.New("user not found")
+	ErrRoleNotFound           = errors.New("role not found")
+	ErrRoleCycle              = errors.New("role hierarchy would contain a cycle")
+	ErrACLEntryNotFound       = errors.New("ACL entry not found")
+	ErrGroupMappingNotFound   = errors.New("group mapping not found")
+	ErrServiceAccountNotFound = errors.New("service account not found")
+	ErrAPIKeyNotFound         = errors.New("API key not found")
+	ErrOAuthClientNotFound    = errors.New("OAuth client not found")
+	ErrTOTPFactorNotFound     = errors.New("TOTP factor not found")
+	ErrPasskeyNotFound        = errors.New("passkey not found")
+	ErrDuplicate              = errors.New("already exists")
+	ErrTokenNotFound          = errors.New("token not found or expired")
+	ErrTokenReused            = errors.New("token already used or revoked")
+)
+
+// Grant is a permission attached to one of a user's effective roles.
+type Grant struct {
+	Resource  string
+	Action    string
+	Effect    models.Effect
+	Condition string
+}
+
+// RefreshToken is a stored refresh token. Only the hash of the opaque token
+// is kept.
+type RefreshToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	TenantID  uuid.UUID
+	FamilyID  uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+// Purposes of email tokens.
+const (
+	EmailTokenVerifyEmail   = "verify_email"
+	EmailTokenResetPassword = "reset_password"
+)
+
+// EmailToken is a single-use token sent to a user by email. Only the hash of
+// the token is kept.
+type EmailToken struct {
+	TokenHash string
+	UserID    uuid.UUID
+	Purpose   string
+	ExpiresAt time.Time
+}
+
+type UserRepository interface {
+	// CreateUser stores user, setting its timestamps, and assigns it roleID
+	// globally unless roleID is uuid.Nil. It returns ErrDuplicate when the
+	// email is taken.
+	CreateUser(user *models.User, roleID uuid.UUID) error
+	// GetUserByEmail and GetUserByID return ErrUserNotFound for unknown users.
+	// Only GetUserByEmail loads the password hash.
+	GetUserByEmail(email string) (*models.User, error)
+	GetUserByID(id uuid.UUID) (*models.User, error)
+	GetUserAttributes(userID uuid.UUID) (map[string]any, error)
+	SetUserAttributes(userID uuid.UUID, attributes map[string]any) error
+	// MarkEmailVerified records verifiedAt unless the email is already
+	// verified.
+	MarkEmailVerified(userID uuid.UUID, verifiedAt time.Time) error
+	// UpdatePassword returns ErrUserNotFound for unknown users.
+	UpdatePassword(userID uuid.UUID, passwordHash string) error
+}
+
+type IdentityRepository interface {
+	// GetUserByIdentity returns the user linked to subject at provider, or
+	// ErrUserNotFound.
+	GetUserByIdentity(provider, subject string) (*models.User, error)
+	// CreateUserWithIdentity creates user like CreateUser and links identity
+	// to it in the same transaction. It returns ErrDuplicate when the email
+	// or the identity is taken.
+	CreateUserWithIdentity(user *models.User, roleID uuid.UUID, identity *models.Identity) error
+	// LinkIdentity links identity to an existing user, returning
+	// ErrDuplicate if it is already linked.
+	LinkIdentity(identity *models.Identity) error
+	// ListIdentities returns the identities linked to the user.
+	ListIdentities(userID uuid.UUID) ([]models.Identity, error)
+}
+
+type ServiceAccountRepository interface {
+	// CreateServiceAccount creates user like CreateUser and records it as the
+	// service account described by account in the same transaction. It
+	// returns ErrDuplicate when the email is taken.
+	CreateServiceAccount(user *models.User, roleID uuid.UUID, account *models.ServiceAccount) error
+	ListServiceAccounts() ([]models.ServiceAccount, error)
+	// GetServiceAccount returns ErrServiceAccountNotFound for users that are
+	// not service accounts.
+	GetServiceAccount(id uuid.UUID) (*models.ServiceAccount, error)
+	// DeleteServiceAccount deletes the account's user together with its API
+	// keys and role assignments.
+	DeleteServiceAccount(id uuid.UUID) error
+	// CreateAPIKey sets CreatedAt. It returns ErrServiceAccountNotFound for
+	// an unknown account and ErrDuplicate if the prefix is taken.
+	CreateAPIKey(key *models.APIKey) error
+	ListAPIKeys(serviceAccountID uuid.UUID) ([]models.APIKey, error)
+	// GetAPIKeyByPrefix loads the key hash too. It returns ErrAPIKeyNotFound
+	// for unknown prefixes.
+	GetAPIKeyByPrefix(prefix string) (*models.APIKey, error)
+	TouchAPIKey(id uuid.UUID, usedAt time.Time) error
+	DeleteAPIKey(serviceAccountID, id uuid.UUID) error
+}
+
+type OAuthClientRepository interface {
+	// CreateOAuthClient sets CreatedAt. It returns ErrServiceAccountNotFound
+	// for an unknown account.
+	CreateOAuthClient(client *models.OAuthClient) error
+	ListOAuthClients(serviceAccountID uuid.UUID) ([]models.OAuthClient, error)
+	// GetOAuthClient loads the secret hash too. It returns
+	// ErrOAuthClientNotFound for unknown clients.
+	GetOAuthClient(id uuid.UUID) (*models.OAuthClient, error)
+	// RecordOAuthClientToken remembers an access token issued to the client
+	// until it expires. It returns ErrOAuthClientNotFound for unknown clients.
+	RecordOAuthClientToken(clientID, jti uuid.UUID, expiresAt time.Time) error
+	// DeleteOAuthClient deletes the client and revokes the unexpired access
+	// tokens recorded for it, leaving other tokens of the account valid.
+	DeleteOAuthClient(serviceAccountID, id uuid.UUID) error
+}
+
+type MFARepository interface {
+	// GetTOTPFactor returns ErrTOTPFactorNotFound for users without one.
+	GetTOTPFactor(userID uuid.UUID) (*models.TOTPFactor, error)
+	// CreateTOTPFactor stores an unconfirmed factor, replacing an
+	// unconfirmed one, and sets CreatedAt. It returns ErrDuplicate if the
+	// user has a confirmed factor.
+	CreateTOTPFactor(factor *models.TOTPFactor) error
+	// ConfirmTOTPFactor confirms the user's unconfirmed factor, recording
+	// step as used, and replaces the user's recovery codes with codeHashes
+	// in the same transaction. It returns ErrTOTPFactorNotFound if there is
+	// no unconfirmed factor.
+	ConfirmTOTPFactor(userID uuid.UUID, step int64, codeHashes []string) error
+	// UseTOTPStep records step as used and clears failed attempts. It
+	// reports false, recording nothing, unless step is after the last used
+	// step.
+	UseTOTPStep(userID uuid.UUID, step int64) (bool, error)
+	// RecordMFAFailure counts a failed attempt. The maxAttempts-th failure
+	// in a row locks the factor until lockedUntil and starts a new count.
+	RecordMFAFailure(userID uuid.UUID, maxAttempts int, lockedUntil time.Time) error
+	// DeleteTOTPFactor deletes the factor with the user's recovery codes. It
+	// returns ErrTOTPFactorNotFound for users without one.
+	DeleteTOTPFactor(userID uuid.UUID) error
+	ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error
+	// UseRecoveryCode deletes the user's code with codeHash and clears
+	// failed attempts, like UseTOTPStep. It returns ErrTokenNotFound if
+	// there is no such code.
+	UseRecoveryCode(userID uuid.UUID, codeHash string) error
+	CountRecoveryCodes(userID uuid.UUID) (int, error)
+}
+
+type WebAuthnRepository interface {
+	// CreateWebAuthnCredential sets CreatedAt. It returns ErrDuplicate if
+	// the credential ID is registered already.
+	CreateWebAuthnCredential(cred *models.WebAuthnCredential) error
+	// GetWebAuthnCredential returns ErrPasskeyNotFound for unknown IDs.
+	GetWebAuthnCredential(id string) (*models.WebAuthnCredential, error)
+	GetWebAuthnCredentials(userID uuid.UUID) ([]models.WebAuthnCredential, error)
+	// UseWebAuthnCredential records an assertion with signCount. It reports
+	// false, recording nothing, unless signCount is above the stored count
+	// or both are zero, as authenticators without a counter report.
+	UseWebAuthnCredential(id string, signCount uint32, usedAt time.Time) (bool, error)
+	// DeleteWebAuthnCredential returns ErrPasskeyNotFound unless the user
+	// has the credential.
+	DeleteWebAuthnCredential(userID uuid.UUID, id string) error
+	// DeleteWebAuthnCredentials deletes every credential of the user and
+	// returns how many there were.
+	DeleteWebAuthnCredentials(userID uuid.UUID) (int, error)
+}
+
+type RoleRepository interface {
+	// CreateRole stores role together with its ParentIDs and sets CreatedAt.
+	CreateRole(role *models.Role) error
+	ListRoles() ([]models.Role, error)
+	GetParentRoles(roleID uuid.UUID) ([]models.Role, error)
+	// AddParentRole returns ErrRoleNotFound if either role is missing and
+	// ErrRoleCycle if parentID already inherits from roleID. Concurrent calls
+	// must not be able to close a cycle together.
+	AddParentRole(roleID, parentID uuid.UUID) error
+	RemoveParentRole(roleID, parentID uuid.UUID) error
+}
+
+type PermissionRepository interface {
+	CreatePermission(permission *models.Permission) error
+	ListPermissions() ([]models.Permission, error)
+	// GetRolePermissions includes the permissions of the role's ancestors.
+	GetRolePermissions(roleID uuid.UUID) ([]models.Permission, error)
+	// GrantPermission replaces the effect and condition of an existing grant.
+	GrantPermission(roleID, permissionID uuid.UUID, effect models.Effect, condition string) error
+	RevokePermission(roleID, permissionID uuid.UUID) error
+}
+
+type AssignmentRepository interface {
+	// AssignRole replaces the validity window of an existing assignment and
+	// makes it a manual one.
+	AssignRole(a models.RoleAssignment) error
+	RemoveRole(userID, roleID, tenantID uuid.UUID) error
+	ListAssignments(userID uuid.UUID) ([]models.RoleAssignment, error)
+	// EffectiveRoles returns the roles the user currently holds in tenantID,
+	// including global assignments and inherited roles, ordered by name.
+	EffectiveRoles(userID, tenantID uuid.UUID) ([]models.Role, error)
+	// EffectiveGrants returns the grants attached to the user's effective
+	// roles. With a non-empty resource and action it may leave out grants
+	// that cannot match them; callers still apply the pattern rules.
+	EffectiveGrants(userID, tenantID uuid.UUID, resource, action string) ([]Grant, error)
+	// NextAssignmentBoundary returns when the next of the user's assignments
+	// in tenantID (or global ones) starts or ends, or nil if none will.
+	NextAssignmentBoundary(userID, tenantID uuid.UUID) (*time.Time, error)
+	// ArchiveExpiredAssignments moves assignments whose validity has ended
+	// to the archive and returns them.
+	ArchiveExpiredAssignments() ([]models.RoleAssignment, error)
+	// SyncManagedAssignments makes the user's assignments managed by provider
+	// match desired, in one transaction: managed assignments missing from
+	// desired are deleted, and desired (role, tenant) pairs the user holds in
+	// no way are assigned, managed by provider. Manual assignments are never
+	// touched. It returns the assignments added and removed.
+	SyncManagedAssignments(userID uuid.UUID, provider string, desired []models.RoleAssignment) (added, removed []models.RoleAssignment, err error)
+}
+
+type GroupMappingRepository interface {
+	// CreateGroupMapping sets CreatedAt. It returns ErrRoleNotFound for an
+	// unknown role and ErrDuplicate if the same mapping exists.
+	CreateGroupMapping(mapping *models.GroupRoleMapping) error
+	ListGroupMappings() ([]models.GroupRoleMapping, error)
+	DeleteGroupMapping(id uuid.UUID) error
+}
+
+type ACLRepository interface {
+	// UpsertACLEntry replaces the effect of an existing entry for the same
+	// resource instance, subject and action, keeping its ID.
+	UpsertACLEntry(entry *models.ACLEntry) error
+	ListACLEntries(resource, resourceID string) ([]models.ACLEntry, error)
+	DeleteACLEntry(id uuid.UUID) error
+	// SubjectACLEntries returns the entries on a resource instance for action
+	// (or "*") that name the user or one of their effective roles in tenantID.
+	SubjectACLEntries(userID, tenantID uuid.UUID, resource, resourceID, action string) ([]models.ACLEntry, error)
+}
+
+type TokenRepository interface {
+	CreateRefreshToken(token RefreshToken) error
+	// RotateRefreshToken atomically marks the token with tokenHash as used
+	// and stores next in the same user, tenant and family. It returns
+	// ErrTokenNotFound for unknown or expired tokens, and ErrTokenReused after
+	// revoking the whole family when the token was already used or revoked.
+	RotateRefreshToken(tokenHash string, next RefreshToken) (*RefreshToken, error)
+	// RevokeRefreshFamily revokes the family of the user's token with
+	// tokenHash. Unknown tokens are ignored.
+	RevokeRefreshFamily(userID uuid.UUID, tokenHash string) error
+	// RevokeAccessToken reports whether this call revoked the token, false
+	// if it was revoked already, so single-use tokens can be consumed
+	// atomically.
+	RevokeAccessToken(jti, userID uuid.UUID, expiresAt time.Time) (bool, error)
+	// RevokeAllSessions revokes the user's refresh tokens and every access
+	// token issued up to now.
+	RevokeAllSessions(userID uuid.UUID) error
+	// IsTokenRevoked reports whether the token was revoked individually or
+	// issued before the user's sessions were last revoked. issuedAt is
+	// compared as precisely as it is given: a whole-second iat claim counts
+	// as issued at the start of its second.
+	IsTokenRevoked(jti, userID uuid.UUID, issuedAt time.Time) (bool, error)
+	// CreateEmailToken stores token and deletes the user's other tokens for
+	// the same purpose, so only the latest one sent works.
+	CreateEmailToken(token EmailToken) error
+	// ConsumeEmailToken deletes the token with tokenHash and purpose and
+	// returns it. It returns ErrTokenNotFound for unknown, expired and
+	// already consumed tokens.
+	ConsumeEmailToken(tokenHash, purpose string) (*EmailToken, error)
+	// PurgeExpiredTokens deletes expired refresh tokens, revocation entries,
+	// email tokens and records of tokens issued to OAuth clients.
+	PurgeExpiredTokens() (int, error)
+}
+
+type PolicyRepository interface {
+	PolicyVersion() (int64, error)
+	BumpPolicyVersion() (int64, error)
+}
+
+// Store is a complete storage backend.
+type Store interface {
+	UserRepository
+	IdentityRepository
+	ServiceAccountRepository
+	OAuthClientRepository
+	MFARepository
+	WebAuthnRepository
+	RoleRepository
+	PermissionRepository
+	AssignmentRepository
+	GroupMappingRepository
+	ACLRepository
+	TokenRepository
+	PolicyRepository
+	Close() error
+}
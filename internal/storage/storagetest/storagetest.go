@@ -0,0 +1,785 @@
+// Package storagetest is a conformance suite for storage.Store
+// implementations. Each backend runs it from its own tests, so the behaviour
+// the services rely on is checked the same way everywhere.
+package storagetest
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Anand078/rbac/internal/models"
+	"github.com/Anand078/rbac/internal/storage"
+)
+
+// Run runs the suite. open returns an empty or freshly migrated store; it may
+// be called once per subtest and may return stores sharing one database, as
+// every subtest names its rows uniquely.
+func Run(t *testing.T, open func(t *testing.T) storage.Store) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s *suite)
+	}{
+		{"RoleHierarchy", testRoleHierarchy},
+		{"RoleCycles", testRoleCycles},
+		{"TenantScoping", testTenantScoping},
+		{"EffectiveGrantsPrefilter", testEffectiveGrantsPrefilter},
+		{"AssignmentValidity", testAssignmentValidity},
+		{"ArchiveExpiredAssignments", testArchiveExpiredAssignments},
+		{"ACLEntries", testACLEntries},
+		{"RefreshTokenFamilies", testRefreshTokenFamilies},
+		{"RevokeAccessTokenOnce", testRevokeAccessTokenOnce},
+		{"RevokeAllSessions", testRevokeAllSessions},
+		{"OAuthClientTokens", testOAuthClientTokens},
+		{"RecoveryCodes", testRecoveryCodes},
+		{"PolicyVersion", testPolicyVersion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := open(t)
+			t.Cleanup(func() { store.Close() })
+			tt.test(t, &suite{t: t, store: store, suffix: uuid.New().String()[:8]})
+		})
+	}
+}
+
+type suite struct {
+	t      *testing.T
+	store  storage.Store
+	suffix string
+}
+
+func (s *suite) user() uuid.UUID {
+	s.t.Helper()
+	user := &models.User{ID: uuid.New(), Name: "user", PasswordHash: "!"}
+	user.Email = user.ID.String() + "@example.com"
+	if err := s.store.CreateUser(user, uuid.Nil); err != nil {
+		s.t.Fatalf("CreateUser: %v", err)
+	}
+	return user.ID
+}
+
+func (s *suite) role(name string, parents ...uuid.UUID) uuid.UUID {
+	s.t.Helper()
+	role := &models.Role{ID: uuid.New(), Name: name + "-" + s.suffix, ParentIDs: parents}
+	if err := s.store.CreateRole(role); err != nil {
+		s.t.Fatalf("CreateRole(%s): %v", name, err)
+	}
+	return role.ID
+}
+
+func (s *suite) grant(roleID uuid.UUID, resource, action string, effect models.Effect) {
+	s.t.Helper()
+	permission := &models.Permission{
+		ID:       uuid.New(),
+		Name:     resource + "_" + action + "-" + uuid.New().String()[:8],
+		Resource: resource,
+		Action:   action,
+	}
+	if err := s.store.CreatePermission(permission); err != nil {
+		s.t.Fatalf("CreatePermission(%s, %s): %v", resource, action, err)
+	}
+	if err := s.store.GrantPermission(roleID, permission.ID, effect, ""); err != nil {
+		s.t.Fatalf("GrantPermission(%s, %s): %v", resource, action, err)
+	}
+}
+
+func (s *suite) assign(a models.RoleAssignment) {
+	s.t.Helper()
+	if err := s.store.AssignRole(a); err != nil {
+		s.t.Fatalf("AssignRole: %v", err)
+	}
+}
+
+// roles returns the unsuffixed names of the user's effective roles in
+// tenantID, in the order the store returned them.
+func (s *suite) roles(userID, tenantID uuid.UUID) []string {
+	s.t.Helper()
+	roles, err := s.store.EffectiveRoles(userID, tenantID)
+	if err != nil {
+		s.t.Fatalf("EffectiveRoles: %v", err)
+	}
+	names := []string{}
+	for _, role := range roles {
+		names = append(names, role.Name[:len(role.Name)-len(s.suffix)-1])
+	}
+	return names
+}
+
+// grants returns the user's effective grants in tenantID as sorted
+// "effect resource action" strings.
+func (s *suite) grants(userID, tenantID uuid.UUID, resource, action string) []string {
+	s.t.Helper()
+	grants, err := s.store.EffectiveGrants(userID, tenantID, resource, action)
+	if err != nil {
+		s.t.Fatalf("EffectiveGrants: %v", err)
+	}
+	encoded := []string{}
+	for _, g := range grants {
+		encoded = append(encoded, string(g.Effect)+" "+g.Resource+" "+g.Action)
+	}
+	sort.Strings(encoded)
+	return encoded
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testRoleHierarchy(t *testing.T, s *suite) {
+	// A diamond: editor and reviewer both inherit from viewer, and lead
+	// inherits from both.
+	viewer := s.role("viewer")
+	s.grant(viewer, "course", "read", models.EffectAllow)
+	editor := s.role("editor", viewer)
+	s.grant(editor, "course", "update", models.EffectAllow)
+	reviewer := s.role("reviewer", viewer)
+	s.grant(reviewer, "grades", "read", models.EffectAllow)
+	lead := s.role("lead", editor)
+	if err := s.store.AddParentRole(lead, reviewer); err != nil {
+		t.Fatal(err)
+	}
+
+	parents, err := s.store.GetParentRoles(lead)
+	if err != nil || len(parents) != 2 {
+		t.Errorf("GetParentRoles(lead) = %d roles, %v; want 2", len(parents), err)
+	}
+	permissions, err := s.store.GetRolePermissions(lead)
+	if err != nil || len(permissions) != 3 {
+		t.Errorf("GetRolePermissions(lead) = %d permissions, %v; want the 3 inherited once each", len(permissions), err)
+	}
+
+	user := s.user()
+	s.assign(models.RoleAssignment{UserID: user, RoleID: lead})
+	if got, want := s.roles(user, models.GlobalTenantID), []string{"editor", "lead", "reviewer", "viewer"}; !equal(got, want) {
+		t.Errorf("EffectiveRoles = %v, want %v", got, want)
+	}
+	want := []string{"allow course read", "allow course update", "allow grades read"}
+	if got := s.grants(user, models.GlobalTenantID, "", ""); !equal(got, want) {
+		t.Errorf("EffectiveGrants = %v, want %v", got, want)
+	}
+
+	// Removing a parent link drops what came through it only.
+	if err := s.store.RemoveParentRole(lead, reviewer); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.roles(user, models.GlobalTenantID), []string{"editor", "lead", "viewer"}; !equal(got, want) {
+		t.Errorf("after RemoveParentRole: EffectiveRoles = %v, want %v", got, want)
+	}
+}
+
+func testRoleCycles(t *testing.T, s *suite) {
+	a := s.role("a")
+	b := s.role("b", a)
+	c := s.role("c", b)
+
+	for _, link := range []struct {
+		name           string
+		role, parentID uuid.UUID
+	}{
+		{"self", a, a},
+		{"direct", a, b},
+		{"indirect", a, c},
+	} {
+		if err := s.store.AddParentRole(link.role, link.parentID); !errors.Is(err, storage.ErrRoleCycle) {
+			t.Errorf("%s cycle: AddParentRole = %v, want ErrRoleCycle", link.name, err)
+		}
+	}
+	if err := s.store.AddParentRole(a, uuid.New()); !errors.Is(err, storage.ErrRoleNotFound) {
+		t.Errorf("unknown parent: AddParentRole = %v, want ErrRoleNotFound", err)
+	}
+	if err := s.store.AddParentRole(uuid.New(), a); !errors.Is(err, storage.ErrRoleNotFound) {
+		t.Errorf("unknown role: AddParentRole = %v, want ErrRoleNotFound", err)
+	}
+	// A shortcut to an ancestor is not a cycle.
+	if err := s.store.AddParentRole(c, a); err != nil {
+		t.Errorf("AddParentRole(c, a) = %v, want nil", err)
+	}
+
+	user := s.user()
+	s.assign(models.RoleAssignment{UserID: user, RoleID: c})
+	if got, want := s.roles(user, models.GlobalTenantID), []string{"a", "b", "c"}; !equal(got, want) {
+		t.Errorf("EffectiveRoles = %v, want %v", got, want)
+	}
+}
+
+func testTenantScoping(t *testing.T, s *suite) {
+	tenantA, tenantB := uuid.New(), uuid.New()
+	member := s.role("member")
+	s.grant(member, "course", "read", models.EffectAllow)
+	owner := s.role("owner", member)
+	s.grant(owner, "course", "delete", models.EffectAllow)
+	banned := s.role("banned")
+	s.grant(banned, "*", "*", models.EffectDeny)
+
+	user := s.user()
+	s.assign(models.RoleAssignment{UserID: user, RoleID: member})
+	s.assign(models.RoleAssignment{UserID: user, RoleID: owner, TenantID: tenantA})
+	s.assign(models.RoleAssignment{UserID: user, RoleID: banned, TenantID: tenantB})
+
+	tests := []struct {
+		tenantID uuid.UUID
+		roles    []string
+		grants   []string
+	}{
+		{models.GlobalTenantID, []string{"member"}, []string{"allow course read"}},
+		{tenantA, []string{"member", "owner"}, []string{"allow course delete", "allow course read"}},
+		{tenantB, []string{"banned", "member"}, []string{"allow course read", "deny * *"}},
+		{uuid.New(), []string{"member"}, []string{"allow course read"}},
+	}
+	for i, tt := range tests {
+		if got := s.roles(user, tt.tenantID); !equal(got, tt.roles) {
+			t.Errorf("tenant %d: EffectiveRoles = %v, want %v", i, got, tt.roles)
+		}
+		if got := s.grants(user, tt.tenantID, "", ""); !equal(got, tt.grants) {
+			t.Errorf("tenant %d: EffectiveGrants = %v, want %v", i, got, tt.grants)
+		}
+	}
+
+	// Removing a tenant assignment leaves the same role elsewhere alone.
+	s.assign(models.RoleAssignment{UserID: user, RoleID: owner, TenantID: tenantB})
+	if err := s.store.RemoveRole(user, owner, tenantA); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.roles(user, tenantA), []string{"member"}; !equal(got, want) {
+		t.Errorf("after RemoveRole: EffectiveRoles in tenant A = %v, want %v", got, want)
+	}
+	if got, want := s.roles(user, tenantB), []string{"banned", "member", "owner"}; !equal(got, want) {
+		t.Errorf("after RemoveRole: EffectiveRoles in tenant B = %v, want %v", got, want)
+	}
+	assignments, err := s.store.ListAssignments(user)
+	if err != nil || len(assignments) != 3 {
+		t.Errorf("ListAssignments = %d assignments, %v; want 3", len(assignments), err)
+	}
+}
+
+// testEffectiveGrantsPrefilter checks that filtering by resource and action
+// never drops a grant whose pattern matches, whatever the shape of the
+// wildcard. Backends may return more than what matches.
+func testEffectiveGrantsPrefilter(t *testing.T, s *suite) {
+	role := s.role("prefilter")
+	patterns := []struct{ resource, action string }{
+		{"course", "read"},
+		{"course", "*"},
+		{"*", "*"},
+		{"*", "read"},
+		{"course:*", "read"},
+		{"course:*:grades", "read"},
+		{"grades/*", "read"},
+		{"course:42", "read"},
+		{"course", "update"},
+		{"roster", "read"},
+	}
+	for _, p := range patterns {
+		s.grant(role, p.resource, p.action, models.EffectAllow)
+	}
+	user := s.user()
+	s.assign(models.RoleAssignment{UserID: user, RoleID: role})
+
+	tests := []struct {
+		resource, action string
+		mustInclude      []string
+	}{
+		{"course", "read", []string{"allow * *", "allow * read", "allow course *", "allow course read"}},
+		{"course:42", "read", []string{"allow * *", "allow * read", "allow course:* read", "allow course:42 read"}},
+		{"course:42:grades", "read", []string{"allow * *", "allow * read", "allow course:* read", "allow course:*:grades read"}},
+		{"grades/2024", "read", []string{"allow * *", "allow * read", "allow grades/* read"}},
+		{"course", "delete", []string{"allow * *", "allow course *"}},
+	}
+	for _, tt := range tests {
+		got := s.grants(user, models.GlobalTenantID, tt.resource, tt.action)
+		present := make(map[string]bool, len(got))
+		for _, g := range got {
+			present[g] = true
+		}
+		for _, want := range tt.mustInclude {
+			if !present[want] {
+				t.Errorf("EffectiveGrants(%s, %s) = %v, missing %q", tt.resource, tt.action, got, want)
+			}
+		}
+	}
+}
+
+func testAssignmentValidity(t *testing.T, s *suite) {
+	now := time.Now()
+	past, soon, later := now.Add(-time.Hour), now.Add(time.Hour), now.Add(2*time.Hour)
+	current := s.role("current")
+	expired := s.role("expired")
+	pending := s.role("pending")
+	ending := s.role("ending")
+	tenantID := uuid.New()
+
+	user := s.user()
+	s.assign(models.RoleAssignment{UserID: user, RoleID: current, ValidFrom: &past})
+	s.assign(models.RoleAssignment{UserID: user, RoleID: expired, ValidUntil: &past})
+	s.assign(models.RoleAssignment{UserID: user, RoleID: pending, ValidFrom: &later})
+	s.assign(models.RoleAssignment{UserID: user, RoleID: ending, TenantID: tenantID, ValidUntil: &soon})
+
+	if got, want := s.roles(user, models.GlobalTenantID), []string{"current"}; !equal(got, want) {
+		t.Errorf("EffectiveRoles = %v, want %v", got, want)
+	}
+	if got, want := s.roles(user, tenantID), []string{"current", "ending"}; !equal(got, want) {
+		t.Errorf("EffectiveRoles in tenant = %v, want %v", got, want)
+	}
+
+	boundary, err := s.store.NextAssignmentBoundary(user, models.GlobalTenantID)
+	if err != nil || boundary == nil || !boundary.Equal(later) {
+		t.Errorf("NextAssignmentBoundary = %v, %v; want %v", boundary, err, later)
+	}
+	boundary, err = s.store.NextAssignmentBoundary(user, tenantID)
+	if err != nil || boundary == nil || !boundary.Equal(soon) {
+		t.Errorf("NextAssignmentBoundary in tenant = %v, %v; want %v", boundary, err, soon)
+	}
+	if boundary, err := s.store.NextAssignmentBoundary(s.user(), models.GlobalTenantID); err != nil || boundary != nil {
+		t.Errorf("NextAssignmentBoundary without assignments = %v, %v; want nil", boundary, err)
+	}
+
+	// Assigning again replaces the window.
+	s.assign(models.RoleAssignment{UserID: user, RoleID: pending})
+	if got, want := s.roles(user, models.GlobalTenantID), []string{"current", "pending"}; !equal(got, want) {
+		t.Errorf("after reassigning: EffectiveRoles = %v, want %v", got, want)
+	}
+}
+
+func testArchiveExpiredAssignments(t *testing.T, s *suite) {
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	expired := s.role("expired")
+	live := s.role("live")
+	pending := s.role("pending")
+	tenantID := uuid.New()
+
+	user := s.user()
+	s.assign(models.RoleAssignment{UserID: user, RoleID: expired, ValidUntil: &past})
+	s.assign(models.RoleAssignment{UserID: user, RoleID: expired, TenantID: tenantID, ValidUntil: &past})
+	s.assign(models.RoleAssignment{UserID: user, RoleID: live, ValidUntil: &future})
+	s.assign(models.RoleAssignment{UserID: user, RoleID: pending, ValidFrom: &future})
+
+	archived, err := s.store.ArchiveExpiredAssignments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mine []models.RoleAssignment
+	for _, a := range archived {
+		if a.UserID == user {
+			mine = append(mine, a)
+		}
+	}
+	if len(mine) != 2 {
+		t.Fatalf("ArchiveExpiredAssignments archived %d of the user's assignments, want 2", len(mine))
+	}
+	for _, a := range mine {
+		if a.RoleID != expired || a.ValidUntil == nil || !a.ValidUntil.Equal(past) {
+			t.Errorf("archived %+v, want the expired role with its end time", a)
+		}
+	}
+
+	assignments, err := s.store.ListAssignments(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assignments) != 2 {
+		t.Errorf("ListAssignments after archiving = %d assignments, want 2", len(assignments))
+	}
+	for _, a := range assignments {
+		if a.RoleID == expired {
+			t.Error("expired assignment is still listed")
+		}
+	}
+
+	archived, err = s.store.ArchiveExpiredAssignments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range archived {
+		if a.UserID == user {
+			t.Errorf("assignment archived twice: %+v", a)
+		}
+	}
+}
+
+func testACLEntries(t *testing.T, s *suite) {
+	tenantID := uuid.New()
+	viewer := s.role("viewer")
+	editor := s.role("editor", viewer)
+	other := s.role("other")
+	user := s.user()
+	s.assign(models.RoleAssignment{UserID: user, RoleID: editor, TenantID: tenantID})
+
+	course, otherCourse := "42-"+s.suffix, "43-"+s.suffix
+	upsert := func(resourceID string, subjectType models.SubjectType, subjectID uuid.UUID, action string, effect models.Effect) *models.ACLEntry {
+		t.Helper()
+		entry := &models.ACLEntry{
+			ID: uuid.New(), Resource: "course", ResourceID: resourceID,
+			SubjectType: subjectType, SubjectID: subjectID, Action: action, Effect: effect,
+		}
+		if err := s.store.UpsertACLEntry(entry); err != nil {
+			t.Fatalf("UpsertACLEntry: %v", err)
+		}
+		return entry
+	}
+	ids := func(entries []models.ACLEntry) []string {
+		encoded := []string{}
+		for _, entry := range entries {
+			encoded = append(encoded, entry.ID.String())
+		}
+		sort.Strings(encoded)
+		return encoded
+	}
+	idsOf := func(entries ...*models.ACLEntry) []string {
+		values := []models.ACLEntry{}
+		for _, entry := range entries {
+			values = append(values, *entry)
+		}
+		return ids(values)
+	}
+
+	byUser := upsert(course, models.SubjectUser, user, "update", models.EffectAllow)
+	byRole := upsert(course, models.SubjectRole, viewer, "*", models.EffectDeny)
+	byOtherRole := upsert(course, models.SubjectRole, other, "read", models.EffectAllow)
+	upsert(otherCourse, models.SubjectUser, user, "update", models.EffectAllow)
+
+	entries, err := s.store.ListACLEntries("course", course)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(entries), idsOf(byUser, byRole, byOtherRole); !equal(got, want) {
+		t.Errorf("ListACLEntries = %v, want %v", got, want)
+	}
+	for _, entry := range entries {
+		if entry.Resource != "course" || entry.ResourceID != course || entry.CreatedAt.IsZero() {
+			t.Errorf("ListACLEntries returned %+v", entry)
+		}
+	}
+
+	// Upserting the same instance, subject and action keeps the entry and
+	// replaces its effect.
+	replaced := upsert(course, models.SubjectUser, user, "update", models.EffectDeny)
+	if replaced.ID != byUser.ID {
+		t.Errorf("UpsertACLEntry of an existing entry set ID %v, want %v", replaced.ID, byUser.ID)
+	}
+	entries, err = s.store.ListACLEntries("course", course)
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("ListACLEntries = %d entries, %v; want 3", len(entries), err)
+	}
+	for _, entry := range entries {
+		if entry.ID == byUser.ID && entry.Effect != models.EffectDeny {
+			t.Errorf("upserted entry has effect %q, want deny", entry.Effect)
+		}
+	}
+
+	// Entries name the user directly or a role the user holds, inherited
+	// ones included, in the tenant; action "*" covers every action.
+	tests := []struct {
+		name     string
+		userID   uuid.UUID
+		tenantID uuid.UUID
+		action   string
+		want     []string
+	}{
+		{"tenant of the assignment", user, tenantID, "update", idsOf(byUser, byRole)},
+		{"another action", user, tenantID, "read", idsOf(byRole)},
+		{"global tenant", user, models.GlobalTenantID, "update", idsOf(byUser)},
+		{"another user", s.user(), tenantID, "update", []string{}},
+	}
+	for _, tt := range tests {
+		entries, err := s.store.SubjectACLEntries(tt.userID, tt.tenantID, "course", course, tt.action)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(entries); !equal(got, tt.want) {
+			t.Errorf("%s: SubjectACLEntries = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if err := s.store.DeleteACLEntry(byUser.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.DeleteACLEntry(byUser.ID); !errors.Is(err, storage.ErrACLEntryNotFound) {
+		t.Errorf("second DeleteACLEntry = %v, want ErrACLEntryNotFound", err)
+	}
+	entries, err = s.store.ListACLEntries("course", course)
+	if got, want := ids(entries), idsOf(byRole, byOtherRole); err != nil || !equal(got, want) {
+		t.Errorf("after DeleteACLEntry: ListACLEntries = %v, %v; want %v", got, err, want)
+	}
+}
+
+func testRefreshTokenFamilies(t *testing.T, s *suite) {
+	user := s.user()
+	tenantID := uuid.New()
+	hash := func() string { return uuid.New().String() }
+	expires := time.Now().Add(time.Hour)
+
+	first := storage.RefreshToken{ID: uuid.New(), UserID: user, TenantID: tenantID, FamilyID: uuid.New(), TokenHash: hash(), ExpiresAt: expires}
+	if err := s.store.CreateRefreshToken(first); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotation keeps the user, tenant and family.
+	second := storage.RefreshToken{ID: uuid.New(), TokenHash: hash(), ExpiresAt: expires}
+	rotated, err := s.store.RotateRefreshToken(first.TokenHash, second)
+	if err != nil {
+		t.Fatalf("RotateRefreshToken: %v", err)
+	}
+	if rotated.UserID != user || rotated.TenantID != tenantID || rotated.FamilyID != first.FamilyID {
+		t.Errorf("RotateRefreshToken returned %+v, want the first token", rotated)
+	}
+	third := storage.RefreshToken{ID: uuid.New(), TokenHash: hash(), ExpiresAt: expires}
+	rotated, err = s.store.RotateRefreshToken(second.TokenHash, third)
+	if err != nil {
+		t.Fatalf("RotateRefreshToken of the rotated token: %v", err)
+	}
+	if rotated.UserID != user || rotated.TenantID != tenantID || rotated.FamilyID != first.FamilyID {
+		t.Errorf("rotated token left its family: %+v", rotated)
+	}
+
+	// Reusing a rotated token revokes the whole family, including the
+	// latest token.
+	if _, err := s.store.RotateRefreshToken(first.TokenHash, storage.RefreshToken{ID: uuid.New(), TokenHash: hash(), ExpiresAt: expires}); !errors.Is(err, storage.ErrTokenReused) {
+		t.Errorf("reuse: RotateRefreshToken = %v, want ErrTokenReused", err)
+	}
+	if _, err := s.store.RotateRefreshToken(third.TokenHash, storage.RefreshToken{ID: uuid.New(), TokenHash: hash(), ExpiresAt: expires}); !errors.Is(err, storage.ErrTokenReused) {
+		t.Errorf("after reuse: RotateRefreshToken = %v, want ErrTokenReused", err)
+	}
+
+	// Unknown and expired tokens are not found.
+	if _, err := s.store.RotateRefreshToken(hash(), storage.RefreshToken{ID: uuid.New(), TokenHash: hash(), ExpiresAt: expires}); !errors.Is(err, storage.ErrTokenNotFound) {
+		t.Errorf("unknown: RotateRefreshToken = %v, want ErrTokenNotFound", err)
+	}
+	stale := storage.RefreshToken{ID: uuid.New(), UserID: user, FamilyID: uuid.New(), TokenHash: hash(), ExpiresAt: time.Now().Add(-time.Second)}
+	if err := s.store.CreateRefreshToken(stale); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.store.RotateRefreshToken(stale.TokenHash, storage.RefreshToken{ID: uuid.New(), TokenHash: hash(), ExpiresAt: expires}); !errors.Is(err, storage.ErrTokenNotFound) {
+		t.Errorf("expired: RotateRefreshToken = %v, want ErrTokenNotFound", err)
+	}
+
+	// Revoking a family needs the owner and leaves other families alone.
+	a := storage.RefreshToken{ID: uuid.New(), UserID: user, FamilyID: uuid.New(), TokenHash: hash(), ExpiresAt: expires}
+	b := storage.RefreshToken{ID: uuid.New(), UserID: user, FamilyID: uuid.New(), TokenHash: hash(), ExpiresAt: expires}
+	for _, token := range []storage.RefreshToken{a, b} {
+		if err := s.store.CreateRefreshToken(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.store.RevokeRefreshFamily(uuid.New(), a.TokenHash); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.RevokeRefreshFamily(user, hash()); err != nil {
+		t.Errorf("RevokeRefreshFamily of an unknown token = %v, want nil", err)
+	}
+	a2 := storage.RefreshToken{ID: uuid.New(), TokenHash: hash(), ExpiresAt: expires}
+	if _, err := s.store.RotateRefreshToken(a.TokenHash, a2); err != nil {
+		t.Fatalf("family revoked by another user: RotateRefreshToken = %v", err)
+	}
+	if err := s.store.RevokeRefreshFamily(user, a.TokenHash); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.store.RotateRefreshToken(a2.TokenHash, storage.RefreshToken{ID: uuid.New(), TokenHash: hash(), ExpiresAt: expires}); !errors.Is(err, storage.ErrTokenReused) {
+		t.Errorf("revoked family: RotateRefreshToken = %v, want ErrTokenReused", err)
+	}
+
+	// Ending every session revokes the remaining families.
+	if err := s.store.RevokeAllSessions(user); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.store.RotateRefreshToken(b.TokenHash, storage.RefreshToken{ID: uuid.New(), TokenHash: hash(), ExpiresAt: expires}); !errors.Is(err, storage.ErrTokenReused) {
+		t.Errorf("after RevokeAllSessions: RotateRefreshToken = %v, want ErrTokenReused", err)
+	}
+}
+
+func testRevokeAccessTokenOnce(t *testing.T, s *suite) {
+	user := s.user()
+	jti := uuid.New()
+	issuedAt := time.Now().Add(-time.Minute)
+
+	// Concurrent revocations of one token: exactly one of them revokes it.
+	const attempts = 8
+	results := make(chan bool, attempts)
+	var wg sync.WaitGroup
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			revoked, err := s.store.RevokeAccessToken(jti, user, time.Now().Add(time.Hour))
+			if err != nil {
+				t.Errorf("RevokeAccessToken: %v", err)
+			}
+			results <- revoked
+		}()
+	}
+	wg.Wait()
+	close(results)
+	revocations := 0
+	for revoked := range results {
+		if revoked {
+			revocations++
+		}
+	}
+	if revocations != 1 {
+		t.Errorf("%d concurrent RevokeAccessToken calls reported revoking the token, want 1", revocations)
+	}
+
+	if revoked, err := s.store.IsTokenRevoked(jti, user, issuedAt); err != nil || !revoked {
+		t.Errorf("IsTokenRevoked = %v, %v; want true", revoked, err)
+	}
+	if revoked, err := s.store.IsTokenRevoked(uuid.New(), user, issuedAt); err != nil || revoked {
+		t.Errorf("IsTokenRevoked of another token = %v, %v; want false", revoked, err)
+	}
+}
+
+func testRevokeAllSessions(t *testing.T, s *suite) {
+	user := s.user()
+	before := time.Now()
+	if err := s.store.RevokeAllSessions(user); err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now()
+
+	// Issue times are compared below the second.
+	tests := []struct {
+		name     string
+		issuedAt time.Time
+		want     bool
+	}{
+		{"issued just before", before, true},
+		{"issued just after", after, false},
+		{"issued at the start of the second", before.Truncate(time.Second), true},
+		{"issued a minute later", before.Add(time.Minute), false},
+	}
+	for _, tt := range tests {
+		if revoked, err := s.store.IsTokenRevoked(uuid.New(), user, tt.issuedAt); err != nil || revoked != tt.want {
+			t.Errorf("%s: IsTokenRevoked = %v, %v; want %v", tt.name, revoked, err, tt.want)
+		}
+	}
+	if revoked, err := s.store.IsTokenRevoked(uuid.New(), s.user(), before); err != nil || revoked {
+		t.Errorf("another user: IsTokenRevoked = %v, %v; want false", revoked, err)
+	}
+}
+
+func testOAuthClientTokens(t *testing.T, s *suite) {
+	user := &models.User{ID: uuid.New(), Name: "batch-" + s.suffix, PasswordHash: "!"}
+	user.Email = user.ID.String() + "@" + models.ServiceAccountDomain
+	if err := s.store.CreateServiceAccount(user, uuid.Nil, &models.ServiceAccount{}); err != nil {
+		t.Fatal(err)
+	}
+	client := func() uuid.UUID {
+		t.Helper()
+		client := &models.OAuthClient{ID: uuid.New(), ServiceAccountID: user.ID, Name: "client", SecretHash: "hash", Scopes: []string{"course:read"}}
+		if err := s.store.CreateOAuthClient(client); err != nil {
+			t.Fatal(err)
+		}
+		return client.ID
+	}
+	deleted, kept := client(), client()
+	issuedAt := time.Now().Add(-time.Minute)
+	expires := time.Now().Add(time.Hour)
+
+	record := func(clientID uuid.UUID) uuid.UUID {
+		t.Helper()
+		jti := uuid.New()
+		if err := s.store.RecordOAuthClientToken(clientID, jti, expires); err != nil {
+			t.Fatalf("RecordOAuthClientToken: %v", err)
+		}
+		return jti
+	}
+	deletedTokens := []uuid.UUID{record(deleted), record(deleted)}
+	keptToken := record(kept)
+	if err := s.store.RecordOAuthClientToken(uuid.New(), uuid.New(), expires); !errors.Is(err, storage.ErrOAuthClientNotFound) {
+		t.Errorf("unknown client: RecordOAuthClientToken = %v, want ErrOAuthClientNotFound", err)
+	}
+
+	if err := s.store.DeleteOAuthClient(uuid.New(), deleted); !errors.Is(err, storage.ErrOAuthClientNotFound) {
+		t.Errorf("client of another account: DeleteOAuthClient = %v, want ErrOAuthClientNotFound", err)
+	}
+	if revoked, err := s.store.IsTokenRevoked(deletedTokens[0], user.ID, issuedAt); err != nil || revoked {
+		t.Errorf("refused DeleteOAuthClient revoked a token: %v, %v", revoked, err)
+	}
+
+	// Deleting a client revokes its tokens and no others of the account.
+	if err := s.store.DeleteOAuthClient(user.ID, deleted); err != nil {
+		t.Fatal(err)
+	}
+	for _, jti := range deletedTokens {
+		if revoked, err := s.store.IsTokenRevoked(jti, user.ID, issuedAt); err != nil || !revoked {
+			t.Errorf("token of the deleted client: IsTokenRevoked = %v, %v; want true", revoked, err)
+		}
+	}
+	if revoked, err := s.store.IsTokenRevoked(keptToken, user.ID, issuedAt); err != nil || revoked {
+		t.Errorf("token of the other client: IsTokenRevoked = %v, %v; want false", revoked, err)
+	}
+	if revoked, err := s.store.IsTokenRevoked(uuid.New(), user.ID, issuedAt); err != nil || revoked {
+		t.Errorf("token of the account without a client: IsTokenRevoked = %v, %v; want false", revoked, err)
+	}
+	if err := s.store.DeleteOAuthClient(user.ID, deleted); !errors.Is(err, storage.ErrOAuthClientNotFound) {
+		t.Errorf("second DeleteOAuthClient = %v, want ErrOAuthClientNotFound", err)
+	}
+	if err := s.store.RecordOAuthClientToken(deleted, uuid.New(), expires); !errors.Is(err, storage.ErrOAuthClientNotFound) {
+		t.Errorf("deleted client: RecordOAuthClientToken = %v, want ErrOAuthClientNotFound", err)
+	}
+}
+
+func testRecoveryCodes(t *testing.T, s *suite) {
+	user := s.user()
+	if err := s.store.CreateTOTPFactor(&models.TOTPFactor{UserID: user, Secret: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.ConfirmTOTPFactor(user, 1, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := s.store.RecordMFAFailure(user, 5, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if factor, err := s.store.GetTOTPFactor(user); err != nil || factor.FailedAttempts != 2 {
+		t.Fatalf("GetTOTPFactor = %+v, %v; want 2 failed attempts", factor, err)
+	}
+
+	// A code is used once, and using it clears failed attempts.
+	if err := s.store.UseRecoveryCode(user, "a"); err != nil {
+		t.Fatalf("UseRecoveryCode: %v", err)
+	}
+	if err := s.store.UseRecoveryCode(user, "a"); !errors.Is(err, storage.ErrTokenNotFound) {
+		t.Errorf("second UseRecoveryCode = %v, want ErrTokenNotFound", err)
+	}
+	if err := s.store.UseRecoveryCode(s.user(), "b"); !errors.Is(err, storage.ErrTokenNotFound) {
+		t.Errorf("UseRecoveryCode of another user's code = %v, want ErrTokenNotFound", err)
+	}
+	if factor, err := s.store.GetTOTPFactor(user); err != nil || factor.FailedAttempts != 0 || factor.LockedUntil != nil {
+		t.Errorf("after a recovery code: GetTOTPFactor = %+v, %v; want no failed attempts", factor, err)
+	}
+	if count, err := s.store.CountRecoveryCodes(user); err != nil || count != 1 {
+		t.Errorf("CountRecoveryCodes = %d, %v; want 1", count, err)
+	}
+}
+
+func testPolicyVersion(t *testing.T, s *suite) {
+	start, err := s.store.PolicyVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(1); i <= 3; i++ {
+		version, err := s.store.BumpPolicyVersion()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != start+i {
+			t.Errorf("BumpPolicyVersion = %d, want %d", version, start+i)
+		}
+	}
+	if version, err := s.store.PolicyVersion(); err != nil || version != start+3 {
+		t.Errorf("PolicyVersion = %d, %v; want %d", version, err, start+3)
+	}
+}
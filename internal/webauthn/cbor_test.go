@@ -0,0 +1,74 @@
+package webauthn
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// nested returns depth arrays, each holding the next, around a zero.
+func nested(depth int) []byte {
+	return append(bytes.Repeat([]byte{0x81}, depth), 0x00)
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want any
+	}{
+		{"small integer", []byte{0x17}, int64(23)},
+		{"one byte integer", []byte{0x18, 0xff}, int64(255)},
+		{"eight byte integer", []byte{0x1b, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, int64(1<<63 - 1)},
+		{"negative integer", []byte{0x38, 0x63}, int64(-100)},
+		{"byte string", []byte{0x42, 0x01, 0x02}, []byte{1, 2}},
+		{"text string", []byte{0x63, 'f', 'm', 't'}, "fmt"},
+		{"array", []byte{0x82, 0x01, 0x20}, []any{int64(1), int64(-1)}},
+		{"map", []byte{0xa2, 0x01, 0x02, 0x61, 'a', 0xf6}, map[any]any{int64(1): int64(2), "a": nil}},
+		{"tag", []byte{0xd8, 0x18, 0x41, 0x00}, []byte{0}},
+		{"booleans", []byte{0x82, 0xf4, 0xf5}, []any{false, true}},
+	}
+	for _, tt := range tests {
+		got, rest, err := decodeCBOR(append(tt.data, 0xff))
+		if err != nil || !reflect.DeepEqual(got, tt.want) || !bytes.Equal(rest, []byte{0xff}) {
+			t.Errorf("%s: decodeCBOR = %#v, %x, %v; want %#v", tt.name, got, rest, err, tt.want)
+		}
+	}
+
+	if _, _, err := decodeCBOR(nested(maxCBORDepth)); err != nil {
+		t.Errorf("nesting at the limit: decodeCBOR = %v", err)
+	}
+}
+
+func TestDecodeCBORRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"truncated argument", []byte{0x19, 0x01}},
+		{"truncated byte string", []byte{0x43, 0x01, 0x02}},
+		{"truncated array", []byte{0x83, 0x01, 0x02}},
+		{"truncated map", []byte{0xa1, 0x01}},
+		{"huge byte string", []byte{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"huge array", []byte{0x9b, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}},
+		{"huge map", []byte{0xbb, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}},
+		{"integer out of range", []byte{0x1b, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{"negative integer out of range", []byte{0x3b, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{"indefinite length", []byte{0x9f, 0x01, 0xff}},
+		{"reserved additional information", []byte{0x1c}},
+		{"float", []byte{0xf9, 0x3c, 0x00}},
+		{"byte string map key", []byte{0xa1, 0x41, 0x00, 0x01}},
+		{"duplicate map key", []byte{0xa2, 0x01, 0x02, 0x01, 0x03}},
+		{"nested too deeply", nested(maxCBORDepth + 1)},
+		{"nested very deeply", nested(1 << 20)},
+		{"tags nested too deeply", append(bytes.Repeat([]byte{0xc0}, maxCBORDepth+1), 0x00)},
+		{"maps nested too deeply", append(bytes.Repeat([]byte{0xa1, 0x00}, maxCBORDepth+1), 0x00)},
+	}
+	for _, tt := range tests {
+		if _, _, err := decodeCBOR(tt.data); !errors.Is(err, errCBOR) {
+			t.Errorf("%s: decodeCBOR = %v, want errCBOR", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Package webauthn verifies the responses of WebAuthn registration and
+// authentication ceremonies (passkeys and security keys) for a relying party.
+// Attestation statements are not verified: credentials are trusted as
+// registered by a signed-in user, as with "none" attestation conveyance.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidResponse is wrapped by every verification error.
+var ErrInvalidResponse = errors.New("invalid WebAuthn response")
+
+// Authenticator data flags.
+const (
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagBackupEligible = 0x08
+	flagBackedUp       = 0x10
+	flagAttestedData   = 0x40
+	flagExtensionData  = 0x80
+)
+
+const (
+	challengeSize = 32
+	// maxCredentialIDSize is the limit WebAuthn sets on credential IDs.
+	maxCredentialIDSize = 1023
+)
+
+// RelyingParty is the service credentials are scoped to.
+type RelyingParty struct {
+	// ID is the domain credentials are bound to, such as example.com.
+	ID   string
+	Name string
+	// Origins are the origins allowed to run ceremonies, such as
+	// https://app.example.com.
+	Origins []string
+}
+
+// NewChallenge returns a random ceremony challenge.
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// AuthenticatorData is the data an authenticator signs.
+type AuthenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+	// CredentialID and PublicKey (a COSE_Key) are only present in
+	// registrations.
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+func (d *AuthenticatorData) UserPresent() bool    { return d.Flags&flagUserPresent != 0 }
+func (d *AuthenticatorData) UserVerified() bool   { return d.Flags&flagUserVerified != 0 }
+func (d *AuthenticatorData) BackupEligible() bool { return d.Flags&flagBackupEligible != 0 }
+func (d *AuthenticatorData) BackedUp() bool       { return d.Flags&flagBackedUp != 0 }
+
+// ParseAuthenticatorData parses authenticator data with its attested
+// credential data, if any.
+func ParseAuthenticatorData(raw []byte) (*AuthenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrInvalidResponse)
+	}
+	data := &AuthenticatorData{
+		RPIDHash:  raw[:32],
+		Flags:     raw[32],
+		SignCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	rest := raw[37:]
+
+	if data.Flags&flagAttestedData != 0 {
+		// AAGUID, then the length of the credential ID.
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("%w: attested credential data too short", ErrInvalidResponse)
+		}
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength == 0 || idLength > maxCredentialIDSize || len(rest) < idLength {
+			return nil, fmt.Errorf("%w: invalid credential ID", ErrInvalidResponse)
+		}
+		data.CredentialID, rest = rest[:idLength], rest[idLength:]
+
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: credential public key: %v", ErrInvalidResponse, err)
+		}
+		data.PublicKey, rest = rest[:len(rest)-len(after)], after
+	}
+	if data.Flags&flagExtensionData != 0 {
+		extensions, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: extensions: %v", ErrInvalidResponse, err)
+		}
+		if _, ok := extensions.(map[any]any); !ok {
+			return nil, fmt.Errorf("%w: extensions are not a map", ErrInvalidResponse)
+		}
+		rest = after
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: trailing authenticator data", ErrInvalidResponse)
+	}
+	return data, nil
+}
+
+// VerifyRegistration checks the response of navigator.credentials.create
+// against the challenge of the ceremony and returns the authenticator data
+// holding the new credential. The caller decides whether user verification
+// was required.
+func (rp *RelyingParty) VerifyRegistration(challenge, clientDataJSON, attestationObject []byte) (*AuthenticatorData, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	value, rest, err := decodeCBOR(attestationObject)
+	if err != nil || len(rest) != 0 {
+		return nil, fmt.Errorf("%w: malformed attestation object", ErrInvalidResponse)
+	}
+	attestation, _ := value.(map[any]any)
+	format, _ := attestation["fmt"].(string)
+	rawAuthData, _ := attestation["authData"].([]byte)
+	if format == "" || rawAuthData == nil {
+		return nil, fmt.Errorf("%w: malformed attestation object", ErrInvalidResponse)
+	}
+
+	authData, err := rp.verifyAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if authData.CredentialID == nil {
+		return nil, fmt.Errorf("%w: no attested credential data", ErrInvalidResponse)
+	}
+	if _, err := ParsePublicKey(authData.PublicKey); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+	return authData, nil
+}
+
+// VerifyAssertion checks the response of navigator.credentials.get against
+// the challenge of the ceremony and the stored public key of the credential,
+// and returns the signed authenticator data. The caller checks the
+// signature counter and whether user verification was required.
+func (rp *RelyingParty) VerifyAssertion(challenge, publicKey, clientDataJSON, authenticatorData, signature []byte) (*AuthenticatorData, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return nil, err
+	}
+	authData, err := rp.verifyAuthenticatorData(authenticatorData)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(slices.Clip(authenticatorData), clientDataHash[:]...)
+	if err := key.Verify(signed, signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+	return authData, nil
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+func (rp *RelyingParty) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return fmt.Errorf("%w: malformed client data", ErrInvalidResponse)
+	}
+	if data.Type != ceremony {
+		return fmt.Errorf("%w: client data type %q, expected %q", ErrInvalidResponse, data.Type, ceremony)
+	}
+	received, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(data.Challenge, "="))
+	if err != nil || subtle.ConstantTimeCompare(received, challenge) != 1 {
+		return fmt.Errorf("%w: challenge mismatch", ErrInvalidResponse)
+	}
+	if !slices.Contains(rp.Origins, data.Origin) {
+		return fmt.Errorf("%w: origin %q not allowed", ErrInvalidResponse, data.Origin)
+	}
+	if data.CrossOrigin {
+		return fmt.Errorf("%w: cross-origin ceremony", ErrInvalidResponse)
+	}
+	return nil
+}
+
+func (rp *RelyingParty) verifyAuthenticatorData(raw []byte) (*AuthenticatorData, error) {
+	authData, err := ParseAuthenticatorData(raw)
+	if err != nil {
+		return nil, err
+	}
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(authData.RPIDHash, rpIDHash[:]) {
+		return nil, fmt.Errorf("%w: credential is scoped to another relying party", ErrInvalidResponse)
+	}
+	if !authData.UserPresent() {
+		return nil, fmt.Errorf("%w: user not present", ErrInvalidResponse)
+	}
+	return authData, nil
+}
@@ -0,0 +1,204 @@
+package webauthn_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/Anand078/rbac/internal/webauthn"
+	"github.com/Anand078/rbac/internal/webauthn/webauthntest"
+)
+
+var rp = webauthn.RelyingParty{
+	ID:      "example.com",
+	Name:    "Example",
+	Origins: []string{"https://app.example.com", "https://admin.example.com"},
+}
+
+func challenge(t *testing.T) []byte {
+	t.Helper()
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return challenge
+}
+
+func TestCeremonies(t *testing.T) {
+	for _, alg := range webauthn.Algorithms {
+		a := webauthntest.New(t, alg, rp)
+
+		registration := challenge(t)
+		clientDataJSON, attestationObject := a.Create(registration)
+		authData, err := rp.VerifyRegistration(registration, clientDataJSON, attestationObject)
+		if err != nil {
+			t.Fatalf("alg %d: VerifyRegistration = %v", alg, err)
+		}
+		if !bytes.Equal(authData.CredentialID, a.CredentialID) || !bytes.Equal(authData.PublicKey, a.PublicKey()) ||
+			authData.SignCount != 0 || !authData.UserVerified() {
+			t.Errorf("alg %d: VerifyRegistration = %+v", alg, authData)
+		}
+		key, err := webauthn.ParsePublicKey(authData.PublicKey)
+		if err != nil || key.Algorithm != alg {
+			t.Errorf("alg %d: ParsePublicKey = %+v, %v", alg, key, err)
+		}
+
+		// Either origin may run the ceremony. Whether the user was
+		// verified is reported for the caller to decide.
+		a.Origin = rp.Origins[1]
+		a.UserVerified = false
+		assertion := challenge(t)
+		clientDataJSON, authenticatorData, signature := a.Get(assertion)
+		authData, err = rp.VerifyAssertion(assertion, a.PublicKey(), clientDataJSON, authenticatorData, signature)
+		if err != nil {
+			t.Fatalf("alg %d: VerifyAssertion = %v", alg, err)
+		}
+		if authData.SignCount != 1 || authData.UserVerified() || authData.CredentialID != nil {
+			t.Errorf("alg %d: VerifyAssertion = %+v", alg, authData)
+		}
+	}
+}
+
+func TestVerifyRegistrationRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(a *webauthntest.Authenticator, challenge []byte) (clientDataJSON, attestationObject []byte)
+	}{
+		{"wrong challenge", func(a *webauthntest.Authenticator, challenge []byte) ([]byte, []byte) {
+			return a.Create(append([]byte{challenge[0] ^ 1}, challenge[1:]...))
+		}},
+		{"wrong origin", func(a *webauthntest.Authenticator, challenge []byte) ([]byte, []byte) {
+			a.Origin = "https://app.example.com.evil.test"
+			return a.Create(challenge)
+		}},
+		{"wrong RP ID hash", func(a *webauthntest.Authenticator, challenge []byte) ([]byte, []byte) {
+			a.RPID = "evil.test"
+			return a.Create(challenge)
+		}},
+		{"client data of an assertion", func(a *webauthntest.Authenticator, challenge []byte) ([]byte, []byte) {
+			_, attestationObject := a.Create(challenge)
+			return a.ClientData("webauthn.get", challenge), attestationObject
+		}},
+		{"no attested credential data", func(a *webauthntest.Authenticator, challenge []byte) ([]byte, []byte) {
+			clientDataJSON, _ := a.Create(challenge)
+			return clientDataJSON, webauthntest.EncodeCBOR(webauthntest.Map{
+				{"fmt", "none"}, {"attStmt", webauthntest.Map{}}, {"authData", a.AuthenticatorData(false)},
+			})
+		}},
+		{"no format", func(a *webauthntest.Authenticator, challenge []byte) ([]byte, []byte) {
+			clientDataJSON, _ := a.Create(challenge)
+			return clientDataJSON, webauthntest.EncodeCBOR(webauthntest.Map{{"authData", a.AuthenticatorData(true)}})
+		}},
+		{"trailing data", func(a *webauthntest.Authenticator, challenge []byte) ([]byte, []byte) {
+			clientDataJSON, attestationObject := a.Create(challenge)
+			return clientDataJSON, append(attestationObject, 0x00)
+		}},
+		{"deeply nested", func(a *webauthntest.Authenticator, challenge []byte) ([]byte, []byte) {
+			clientDataJSON, _ := a.Create(challenge)
+			return clientDataJSON, append(bytes.Repeat([]byte{0x81}, 100000), 0x00)
+		}},
+	}
+	for _, tt := range tests {
+		a := webauthntest.New(t, webauthn.AlgES256, rp)
+		c := challenge(t)
+		clientDataJSON, attestationObject := tt.tamper(a, c)
+		if _, err := rp.VerifyRegistration(c, clientDataJSON, attestationObject); !errors.Is(err, webauthn.ErrInvalidResponse) {
+			t.Errorf("%s: VerifyRegistration = %v, want ErrInvalidResponse", tt.name, err)
+		}
+	}
+
+	// Every truncation of a valid response is refused.
+	a := webauthntest.New(t, webauthn.AlgES256, rp)
+	c := challenge(t)
+	clientDataJSON, attestationObject := a.Create(c)
+	for n := range len(attestationObject) {
+		if _, err := rp.VerifyRegistration(c, clientDataJSON, attestationObject[:n]); !errors.Is(err, webauthn.ErrInvalidResponse) {
+			t.Fatalf("attestation object truncated to %d bytes: VerifyRegistration = %v, want ErrInvalidResponse", n, err)
+		}
+	}
+	authData := a.AuthenticatorData(true)
+	for n := range len(authData) {
+		if _, err := webauthn.ParseAuthenticatorData(authData[:n]); !errors.Is(err, webauthn.ErrInvalidResponse) {
+			t.Fatalf("authenticator data truncated to %d bytes: ParseAuthenticatorData = %v, want ErrInvalidResponse", n, err)
+		}
+	}
+}
+
+func TestVerifyAssertionRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(a *webauthntest.Authenticator, challenge []byte) (clientDataJSON, authenticatorData, signature []byte)
+	}{
+		{"wrong challenge", func(a *webauthntest.Authenticator, challenge []byte) ([]byte, []byte, []byte) {
+			return a.Get(append([]byte{challenge[0] ^ 1}, challenge[1:]...))
+		}},
+		{"wrong origin", func(a *webauthntest.Authenticator, challenge []byte) ([]byte, []byte, []byte) {
+			a.Origin = "https://evil.test"
+			return a.Get(challenge)
+		}},
+		{"wrong RP ID hash", func(a *webauthntest.Authenticator, challenge []byte) ([]byte, []byte, []byte) {
+			a.RPID = "evil.test"
+			return a.Get(challenge)
+		}},
+		{"client data of a registration", func(a *webauthntest.Authenticator, challenge []byte) ([]byte, []byte, []byte) {
+			clientDataJSON := a.ClientData("webauthn.create", challenge)
+			authenticatorData := a.AuthenticatorData(false)
+			return clientDataJSON, authenticatorData, a.Sign(authenticatorData, clientDataJSON)
+		}},
+		{"tampered counter", func(a *webauthntest.Authenticator, challenge []byte) ([]byte, []byte, []byte) {
+			clientDataJSON, authenticatorData, signature := a.Get(challenge)
+			authenticatorData[36]++
+			return clientDataJSON, authenticatorData, signature
+		}},
+		{"tampered signature", func(a *webauthntest.Authenticator, challenge []byte) ([]byte, []byte, []byte) {
+			clientDataJSON, authenticatorData, signature := a.Get(challenge)
+			signature[len(signature)/2] ^= 1
+			return clientDataJSON, authenticatorData, signature
+		}},
+		{"signed by another credential", func(a *webauthntest.Authenticator, challenge []byte) ([]byte, []byte, []byte) {
+			return webauthntest.New(t, webauthn.AlgES256, rp).Get(challenge)
+		}},
+		{"deeply nested extensions", func(a *webauthntest.Authenticator, challenge []byte) ([]byte, []byte, []byte) {
+			clientDataJSON := a.ClientData("webauthn.get", challenge)
+			authenticatorData := a.AuthenticatorData(false)
+			authenticatorData[32] |= 0x80
+			authenticatorData = append(authenticatorData, bytes.Repeat([]byte{0xa1, 0x00}, 100000)...)
+			authenticatorData = append(authenticatorData, 0x00)
+			return clientDataJSON, authenticatorData, a.Sign(authenticatorData, clientDataJSON)
+		}},
+	}
+	for _, tt := range tests {
+		a := webauthntest.New(t, webauthn.AlgES256, rp)
+		c := challenge(t)
+		clientDataJSON, authenticatorData, signature := tt.tamper(a, c)
+		if _, err := rp.VerifyAssertion(c, a.PublicKey(), clientDataJSON, authenticatorData, signature); !errors.Is(err, webauthn.ErrInvalidResponse) {
+			t.Errorf("%s: VerifyAssertion = %v, want ErrInvalidResponse", tt.name, err)
+		}
+	}
+}
+
+func TestParsePublicKeyRejects(t *testing.T) {
+	ed25519Key := webauthntest.New(t, webauthn.AlgEdDSA, rp).PublicKey()
+	tests := []struct {
+		name string
+		key  []byte
+	}{
+		{"not a map", webauthntest.EncodeCBOR("key")},
+		{"unknown algorithm", webauthntest.EncodeCBOR(webauthntest.Map{{1, 2}, {3, -35}})},
+		{"algorithm of another key type", webauthntest.EncodeCBOR(webauthntest.Map{{1, 1}, {3, int64(webauthn.AlgES256)}})},
+		{"point not on the curve", webauthntest.EncodeCBOR(webauthntest.Map{
+			{1, 2}, {3, int64(webauthn.AlgES256)}, {-1, 1}, {-2, make([]byte, 32)}, {-3, make([]byte, 32)},
+		})},
+		{"short RSA modulus", webauthntest.EncodeCBOR(webauthntest.Map{
+			{1, 3}, {3, int64(webauthn.AlgRS256)}, {-1, bytes.Repeat([]byte{0xff}, 128)}, {-2, []byte{1, 0, 1}},
+		})},
+		{"trailing data", append(ed25519Key, 0x00)},
+		{"truncated", ed25519Key[:len(ed25519Key)-1]},
+		{"deeply nested", append(bytes.Repeat([]byte{0x81}, 100000), 0x00)},
+	}
+	for _, tt := range tests {
+		if key, err := webauthn.ParsePublicKey(tt.key); err == nil {
+			t.Errorf("%s: ParsePublicKey = %+v, want an error", tt.name, key)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Package webauthntest is a software authenticator for testing relying
+// parties. It holds one credential of any algorithm the webauthn package
+// accepts, and its fields let tests send what a broken or malicious client
+// would.
+package webauthntest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/Anand078/rbac/internal/webauthn"
+)
+
+// Authenticator is a credential and the browser reporting its ceremonies.
+type Authenticator struct {
+	Algorithm    int64
+	CredentialID []byte
+	// RPID is the relying party the authenticator data is scoped to and
+	// Origin the origin the client data reports.
+	RPID   string
+	Origin string
+	// UserVerified sets the UV flag, as after a PIN or biometrics.
+	UserVerified bool
+	// SignCount is incremented before each assertion; lower it to act as
+	// a cloned authenticator.
+	SignCount uint32
+
+	signer crypto.Signer
+}
+
+// New returns an authenticator with a new credential of algorithm for rp,
+// reporting the first of its origins.
+func New(t testing.TB, algorithm int64, rp webauthn.RelyingParty) *Authenticator {
+	t.Helper()
+	a := &Authenticator{
+		Algorithm:    algorithm,
+		CredentialID: make([]byte, 16),
+		RPID:         rp.ID,
+		UserVerified: true,
+	}
+	if len(rp.Origins) > 0 {
+		a.Origin = rp.Origins[0]
+	}
+	if _, err := rand.Read(a.CredentialID); err != nil {
+		t.Fatal(err)
+	}
+
+	var err error
+	switch algorithm {
+	case webauthn.AlgES256:
+		a.signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case webauthn.AlgEdDSA:
+		_, a.signer, err = ed25519.GenerateKey(rand.Reader)
+	case webauthn.AlgRS256:
+		a.signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		err = fmt.Errorf("unsupported algorithm %d", algorithm)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// PublicKey returns the COSE_Key of the credential.
+func (a *Authenticator) PublicKey() []byte {
+	switch key := a.signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		x, y := make([]byte, 32), make([]byte, 32)
+		key.X.FillBytes(x)
+		key.Y.FillBytes(y)
+		return EncodeCBOR(Map{{1, 2}, {3, a.Algorithm}, {-1, 1}, {-2, x}, {-3, y}})
+	case ed25519.PublicKey:
+		return EncodeCBOR(Map{{1, 1}, {3, a.Algorithm}, {-1, 6}, {-2, []byte(key)}})
+	case *rsa.PublicKey:
+		e := binary.BigEndian.AppendUint32(nil, uint32(key.E))
+		return EncodeCBOR(Map{{1, 3}, {3, a.Algorithm}, {-1, key.N.Bytes()}, {-2, e}})
+	}
+	panic("unreachable")
+}
+
+// ClientData returns the client data JSON of a ceremony, webauthn.create or
+// webauthn.get.
+func (a *Authenticator) ClientData(ceremony string, challenge []byte) []byte {
+	data, err := json.Marshal(map[string]any{
+		"type":        ceremony,
+		"challenge":   base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// AuthenticatorData returns the authenticator data for the current state,
+// with the attested credential data of a registration if attested is set.
+func (a *Authenticator) AuthenticatorData(attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	flags := byte(0x01) // user present
+	if a.UserVerified {
+		flags |= 0x04
+	}
+	if attested {
+		flags |= 0x40
+	}
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.SignCount)
+	if attested {
+		data = append(data, make([]byte, 16)...) // AAGUID
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.CredentialID)))
+		data = append(data, a.CredentialID...)
+		data = append(data, a.PublicKey()...)
+	}
+	return data
+}
+
+// Create answers navigator.credentials.create with "none" attestation.
+func (a *Authenticator) Create(challenge []byte) (clientDataJSON, attestationObject []byte) {
+	clientDataJSON = a.ClientData("webauthn.create", challenge)
+	attestationObject = EncodeCBOR(Map{
+		{"fmt", "none"},
+		{"attStmt", Map{}},
+		{"authData", a.AuthenticatorData(true)},
+	})
+	return clientDataJSON, attestationObject
+}
+
+// Get answers navigator.credentials.get, incrementing the signature counter.
+func (a *Authenticator) Get(challenge []byte) (clientDataJSON, authenticatorData, signature []byte) {
+	a.SignCount++
+	clientDataJSON = a.ClientData("webauthn.get", challenge)
+	authenticatorData = a.AuthenticatorData(false)
+	return clientDataJSON, authenticatorData, a.Sign(authenticatorData, clientDataJSON)
+}
+
+// Sign signs authenticator data and the hash of client data as assertions
+// do.
+func (a *Authenticator) Sign(authenticatorData, clientDataJSON []byte) []byte {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authenticatorData...), clientDataHash[:]...)
+
+	var signature []byte
+	var err error
+	if a.Algorithm == webauthn.AlgEdDSA {
+		signature, err = a.signer.Sign(rand.Reader, signed, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(signed)
+		signature, err = a.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return signature
+}
+
+// Map is a CBOR map, encoded in the order of its entries.
+type Map [][2]any
+
+// EncodeCBOR encodes integers, byte and text strings, arrays ([]any) and
+// maps.
+func EncodeCBOR(value any) []byte {
+	switch v := value.(type) {
+	case int:
+		return EncodeCBOR(int64(v))
+	case int64:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case []any:
+		data := cborHead(4, uint64(len(v)))
+		for _, item := range v {
+			data = append(data, EncodeCBOR(item)...)
+		}
+		return data
+	case Map:
+		data := cborHead(5, uint64(len(v)))
+		for _, entry := range v {
+			data = append(data, EncodeCBOR(entry[0])...)
+			data = append(data, EncodeCBOR(entry[1])...)
+		}
+		return data
+	}
+	panic(fmt.Sprintf("webauthntest: cannot encode %T", value))
+}
+
+func cborHead(major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return []byte{major<<5 | byte(arg)}
+	case arg <= 0xff:
+		return []byte{major<<5 | 24, byte(arg)}
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(arg))
+	case arg <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(arg))
+	default:
+		return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, arg)
+	}
+}